@@ -1,6 +1,7 @@
 package adapter
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,11 +15,12 @@ type userBatchAdapterImpl struct {
 	userBatchEndpoint string
 }
 
+// CreateUserBatchAdapter reads USER_BATCH_TRIGGER_ENDPOINT. Without endpoint the batch is never triggered,
+// users are then provisioned from their tokens only.
 func CreateUserBatchAdapter() *userBatchAdapterImpl {
 	endpoint := os.Getenv("USER_BATCH_TRIGGER_ENDPOINT")
 	if endpoint == "" {
-		log.Fatal("USER_BATCH_TRIGGER_ENDPOINT must be specified")
-		return nil
+		log.Println("USER_BATCH_TRIGGER_ENDPOINT not specified, user batch will not be triggered")
 	}
 	return &userBatchAdapterImpl{
 		userBatchEndpoint: endpoint,
@@ -26,6 +28,9 @@ func CreateUserBatchAdapter() *userBatchAdapterImpl {
 }
 
 func (a *userBatchAdapterImpl) TriggerUserBatchRun() model.TokyError {
+	if a.userBatchEndpoint == "" {
+		return model.CreateBusinessError("User Batch is not configured", errors.New("missing trigger endpoint"))
+	}
 
 	client := &http.Client{}
 
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"sort"
 	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type Call struct {
@@ -42,6 +44,9 @@ func (mac *MockAccountingHandler) UpdateBooking(w http.ResponseWriter, r *http.R
 func (mac *MockAccountingHandler) DeleteBooking(w http.ResponseWriter, r *http.Request) {
 	registerCall("deleteBooking", mac, r)
 }
+func (mac *MockAccountingHandler) ApproveBooking(w http.ResponseWriter, r *http.Request) {
+	registerCall("approveBooking", mac, r)
+}
 func (mac *MockAccountingHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	registerCall("createAccount", mac, r)
 }
@@ -58,6 +63,22 @@ func (mac *MockAccountingHandler) ReadClosingStatements(w http.ResponseWriter, r
 	registerCall("readClosingStatements", mac, r)
 }
 
+func (mac *MockAccountingHandler) ReadIncomeStatementsByCostCenter(w http.ResponseWriter, r *http.Request) {
+	registerCall("readIncomeStatementsByCostCenter", mac, r)
+}
+func (mac *MockAccountingHandler) ReadAccruals(w http.ResponseWriter, r *http.Request) {
+	registerCall("readAccruals", mac, r)
+}
+func (mac *MockAccountingHandler) CreateInterBookTransfer(w http.ResponseWriter, r *http.Request) {
+	registerCall("createInterBookTransfer", mac, r)
+}
+func (mac *MockAccountingHandler) ReadClearingAccounts(w http.ResponseWriter, r *http.Request) {
+	registerCall("readClearingAccounts", mac, r)
+}
+func (mac *MockAccountingHandler) SaveClearingAccount(w http.ResponseWriter, r *http.Request) {
+	registerCall("saveClearingAccount", mac, r)
+}
+
 func (mah *MockAccountingHandler) popFirstCall() (Call, bool) {
 	if len(mah.calls) > 0 {
 		sort.Slice(mah.calls, func(i, j int) bool {
@@ -96,8 +117,17 @@ func (mbh *MockBookHandler) CreateBookRealm(w http.ResponseWriter, r *http.Reque
 func (mbh *MockBookHandler) UpdateBookRealm(w http.ResponseWriter, r *http.Request) {
 	registerCall("updateBookRealm", mbh, r)
 }
-func (mbh *MockBookHandler) DeleteBookRealm(w http.ResponseWriter, r *http.Request) {
-	registerCall("deleteBookRealm", mbh, r)
+func (mbh *MockBookHandler) ArchiveBookRealm(w http.ResponseWriter, r *http.Request) {
+	registerCall("archiveBookRealm", mbh, r)
+}
+func (mbh *MockBookHandler) RestoreBookRealm(w http.ResponseWriter, r *http.Request) {
+	registerCall("restoreBookRealm", mbh, r)
+}
+func (mbh *MockBookHandler) PurgeBookRealm(w http.ResponseWriter, r *http.Request) {
+	registerCall("purgeBookRealm", mbh, r)
+}
+func (mbh *MockBookHandler) CloneBookRealm(w http.ResponseWriter, r *http.Request) {
+	registerCall("cloneBookRealm", mbh, r)
 }
 func (mbh *MockBookHandler) ReadAccountingUsers(w http.ResponseWriter, r *http.Request) {
 	registerCall("readAccountingUsers", mbh, r)
@@ -132,6 +162,402 @@ func (mbh *MockBookHandler) appendCall(call Call) {
 	mbh.calls = append(mbh.calls, call)
 }
 
+// Mock SubledgerHandler
+type MockSubledgerHandler struct {
+	calls []Call
+}
+
+func (msh *MockSubledgerHandler) ReadBusinessPartners(w http.ResponseWriter, r *http.Request) {
+	registerCall("readBusinessPartners", msh, r)
+}
+func (msh *MockSubledgerHandler) CreateBusinessPartner(w http.ResponseWriter, r *http.Request) {
+	registerCall("createBusinessPartner", msh, r)
+}
+func (msh *MockSubledgerHandler) UpdateBusinessPartner(w http.ResponseWriter, r *http.Request) {
+	registerCall("updateBusinessPartner", msh, r)
+}
+func (msh *MockSubledgerHandler) ReadOpenItems(w http.ResponseWriter, r *http.Request) {
+	registerCall("readOpenItems", msh, r)
+}
+func (msh *MockSubledgerHandler) CreateOpenItem(w http.ResponseWriter, r *http.Request) {
+	registerCall("createOpenItem", msh, r)
+}
+func (msh *MockSubledgerHandler) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	registerCall("createPayment", msh, r)
+}
+func (msh *MockSubledgerHandler) ReadAgingReport(w http.ResponseWriter, r *http.Request) {
+	registerCall("readAgingReport", msh, r)
+}
+
+func (msh *MockSubledgerHandler) popFirstCall() (Call, bool) {
+	if len(msh.calls) > 0 {
+		sort.Slice(msh.calls, func(i, j int) bool {
+			return msh.calls[i].time.Before(msh.calls[j].time)
+		})
+		firstCall := msh.calls[0]
+		msh.calls = msh.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (msh *MockSubledgerHandler) readCalls() []Call {
+	return msh.calls
+}
+
+func (msh *MockSubledgerHandler) resetCalls() {
+	msh.calls = []Call{}
+}
+
+func (msh *MockSubledgerHandler) appendCall(call Call) {
+	msh.calls = append(msh.calls, call)
+}
+
+// Mock InvoiceHandler
+type MockInvoiceHandler struct {
+	calls []Call
+}
+
+func (mih *MockInvoiceHandler) ReadInvoiceSettings(w http.ResponseWriter, r *http.Request) {
+	registerCall("readInvoiceSettings", mih, r)
+}
+func (mih *MockInvoiceHandler) UpdateInvoiceSettings(w http.ResponseWriter, r *http.Request) {
+	registerCall("updateInvoiceSettings", mih, r)
+}
+func (mih *MockInvoiceHandler) ReadInvoices(w http.ResponseWriter, r *http.Request) {
+	registerCall("readInvoices", mih, r)
+}
+func (mih *MockInvoiceHandler) ReadInvoice(w http.ResponseWriter, r *http.Request) {
+	registerCall("readInvoice", mih, r)
+}
+func (mih *MockInvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
+	registerCall("createInvoice", mih, r)
+}
+func (mih *MockInvoiceHandler) ReadInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	registerCall("readInvoicePDF", mih, r)
+}
+func (mih *MockInvoiceHandler) MatchPaymentByReference(w http.ResponseWriter, r *http.Request) {
+	registerCall("matchPaymentByReference", mih, r)
+}
+func (mih *MockInvoiceHandler) ImportPayments(w http.ResponseWriter, r *http.Request) {
+	registerCall("importPayments", mih, r)
+}
+
+func (mih *MockInvoiceHandler) popFirstCall() (Call, bool) {
+	if len(mih.calls) > 0 {
+		sort.Slice(mih.calls, func(i, j int) bool {
+			return mih.calls[i].time.Before(mih.calls[j].time)
+		})
+		firstCall := mih.calls[0]
+		mih.calls = mih.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (mih *MockInvoiceHandler) readCalls() []Call {
+	return mih.calls
+}
+
+func (mih *MockInvoiceHandler) resetCalls() {
+	mih.calls = []Call{}
+}
+
+func (mih *MockInvoiceHandler) appendCall(call Call) {
+	mih.calls = append(mih.calls, call)
+}
+
+// Mock AssetHandler
+type MockAssetHandler struct {
+	calls []Call
+}
+
+func (mah *MockAssetHandler) ReadFixedAssets(w http.ResponseWriter, r *http.Request) {
+	registerCall("readFixedAssets", mah, r)
+}
+func (mah *MockAssetHandler) CreateFixedAsset(w http.ResponseWriter, r *http.Request) {
+	registerCall("createFixedAsset", mah, r)
+}
+func (mah *MockAssetHandler) RunDepreciation(w http.ResponseWriter, r *http.Request) {
+	registerCall("runDepreciation", mah, r)
+}
+func (mah *MockAssetHandler) ReadAssetSchedule(w http.ResponseWriter, r *http.Request) {
+	registerCall("readAssetSchedule", mah, r)
+}
+
+func (mah *MockAssetHandler) popFirstCall() (Call, bool) {
+	if len(mah.calls) > 0 {
+		sort.Slice(mah.calls, func(i, j int) bool {
+			return mah.calls[i].time.Before(mah.calls[j].time)
+		})
+		firstCall := mah.calls[0]
+		mah.calls = mah.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (mah *MockAssetHandler) readCalls() []Call {
+	return mah.calls
+}
+
+func (mah *MockAssetHandler) resetCalls() {
+	mah.calls = []Call{}
+}
+
+func (mah *MockAssetHandler) appendCall(call Call) {
+	mah.calls = append(mah.calls, call)
+}
+
+// Mock SharingHandler
+type MockSharingHandler struct {
+	calls []Call
+}
+
+func (msh *MockSharingHandler) ReadInvitations(w http.ResponseWriter, r *http.Request) {
+	registerCall("readInvitations", msh, r)
+}
+func (msh *MockSharingHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	registerCall("createInvitation", msh, r)
+}
+func (msh *MockSharingHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	registerCall("revokeInvitation", msh, r)
+}
+func (msh *MockSharingHandler) ReadInvitationsOfUser(w http.ResponseWriter, r *http.Request) {
+	registerCall("readInvitationsOfUser", msh, r)
+}
+func (msh *MockSharingHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	registerCall("acceptInvitation", msh, r)
+}
+func (msh *MockSharingHandler) LeaveBook(w http.ResponseWriter, r *http.Request) {
+	registerCall("leaveBook", msh, r)
+}
+func (msh *MockSharingHandler) RequestOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	registerCall("requestOwnershipTransfer", msh, r)
+}
+func (msh *MockSharingHandler) CancelOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	registerCall("cancelOwnershipTransfer", msh, r)
+}
+func (msh *MockSharingHandler) AcceptOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	registerCall("acceptOwnershipTransfer", msh, r)
+}
+
+func (msh *MockSharingHandler) popFirstCall() (Call, bool) {
+	if len(msh.calls) > 0 {
+		sort.Slice(msh.calls, func(i, j int) bool {
+			return msh.calls[i].time.Before(msh.calls[j].time)
+		})
+		firstCall := msh.calls[0]
+		msh.calls = msh.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (msh *MockSharingHandler) readCalls() []Call {
+	return msh.calls
+}
+
+func (msh *MockSharingHandler) resetCalls() {
+	msh.calls = []Call{}
+}
+
+func (msh *MockSharingHandler) appendCall(call Call) {
+	msh.calls = append(msh.calls, call)
+}
+
+// Mock ApiTokenHandler
+type MockApiTokenHandler struct {
+	calls []Call
+}
+
+func (math *MockApiTokenHandler) ReadApiTokens(w http.ResponseWriter, r *http.Request) {
+	registerCall("readApiTokens", math, r)
+}
+func (math *MockApiTokenHandler) CreateApiToken(w http.ResponseWriter, r *http.Request) {
+	registerCall("createApiToken", math, r)
+}
+func (math *MockApiTokenHandler) RevokeApiToken(w http.ResponseWriter, r *http.Request) {
+	registerCall("revokeApiToken", math, r)
+}
+
+func (math *MockApiTokenHandler) popFirstCall() (Call, bool) {
+	if len(math.calls) > 0 {
+		sort.Slice(math.calls, func(i, j int) bool {
+			return math.calls[i].time.Before(math.calls[j].time)
+		})
+		firstCall := math.calls[0]
+		math.calls = math.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (math *MockApiTokenHandler) readCalls() []Call {
+	return math.calls
+}
+
+func (math *MockApiTokenHandler) resetCalls() {
+	math.calls = []Call{}
+}
+
+func (math *MockApiTokenHandler) appendCall(call Call) {
+	math.calls = append(math.calls, call)
+}
+
+// Mock BookArchiveHandler
+type MockBookArchiveHandler struct {
+	calls []Call
+}
+
+func (mbah *MockBookArchiveHandler) ExportBookRealm(w http.ResponseWriter, r *http.Request) {
+	registerCall("exportBookRealm", mbah, r)
+}
+func (mbah *MockBookArchiveHandler) ImportBookRealm(w http.ResponseWriter, r *http.Request) {
+	registerCall("importBookRealm", mbah, r)
+}
+
+func (mbah *MockBookArchiveHandler) popFirstCall() (Call, bool) {
+	if len(mbah.calls) > 0 {
+		sort.Slice(mbah.calls, func(i, j int) bool {
+			return mbah.calls[i].time.Before(mbah.calls[j].time)
+		})
+		firstCall := mbah.calls[0]
+		mbah.calls = mbah.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (mbah *MockBookArchiveHandler) readCalls() []Call {
+	return mbah.calls
+}
+
+func (mbah *MockBookArchiveHandler) resetCalls() {
+	mbah.calls = []Call{}
+}
+
+func (mbah *MockBookArchiveHandler) appendCall(call Call) {
+	mbah.calls = append(mbah.calls, call)
+}
+
+// Mock ConsolidationHandler
+type MockConsolidationHandler struct {
+	calls []Call
+}
+
+func (mch *MockConsolidationHandler) ReadConsolidatedStatements(w http.ResponseWriter, r *http.Request) {
+	registerCall("readConsolidatedStatements", mch, r)
+}
+
+func (mch *MockConsolidationHandler) popFirstCall() (Call, bool) {
+	if len(mch.calls) > 0 {
+		sort.Slice(mch.calls, func(i, j int) bool {
+			return mch.calls[i].time.Before(mch.calls[j].time)
+		})
+		firstCall := mch.calls[0]
+		mch.calls = mch.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (mch *MockConsolidationHandler) readCalls() []Call {
+	return mch.calls
+}
+
+func (mch *MockConsolidationHandler) resetCalls() {
+	mch.calls = []Call{}
+}
+
+func (mch *MockConsolidationHandler) appendCall(call Call) {
+	mch.calls = append(mch.calls, call)
+}
+
+// Mock WebhookHandler
+type MockWebhookHandler struct {
+	calls []Call
+}
+
+func (mwh *MockWebhookHandler) ReadWebhooks(w http.ResponseWriter, r *http.Request) {
+	registerCall("readWebhooks", mwh, r)
+}
+
+func (mwh *MockWebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	registerCall("createWebhook", mwh, r)
+}
+
+func (mwh *MockWebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	registerCall("deleteWebhook", mwh, r)
+}
+
+func (mwh *MockWebhookHandler) ReadWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	registerCall("readWebhookDeliveries", mwh, r)
+}
+
+func (mwh *MockWebhookHandler) popFirstCall() (Call, bool) {
+	if len(mwh.calls) > 0 {
+		sort.Slice(mwh.calls, func(i, j int) bool {
+			return mwh.calls[i].time.Before(mwh.calls[j].time)
+		})
+		firstCall := mwh.calls[0]
+		mwh.calls = mwh.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (mwh *MockWebhookHandler) readCalls() []Call {
+	return mwh.calls
+}
+
+func (mwh *MockWebhookHandler) resetCalls() {
+	mwh.calls = []Call{}
+}
+
+func (mwh *MockWebhookHandler) appendCall(call Call) {
+	mwh.calls = append(mwh.calls, call)
+}
+
+// Mock SessionHandler
+type MockSessionHandler struct {
+	calls []Call
+}
+
+func (msh *MockSessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+	registerCall("login", msh, r)
+}
+func (msh *MockSessionHandler) LoginCallback(w http.ResponseWriter, r *http.Request) {
+	registerCall("loginCallback", msh, r)
+}
+func (msh *MockSessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	registerCall("logout", msh, r)
+}
+
+func (msh *MockSessionHandler) popFirstCall() (Call, bool) {
+	if len(msh.calls) > 0 {
+		sort.Slice(msh.calls, func(i, j int) bool {
+			return msh.calls[i].time.Before(msh.calls[j].time)
+		})
+		firstCall := msh.calls[0]
+		msh.calls = msh.calls[1:]
+		return firstCall, true
+	}
+	return Call{}, false
+}
+
+func (msh *MockSessionHandler) readCalls() []Call {
+	return msh.calls
+}
+
+func (msh *MockSessionHandler) resetCalls() {
+	msh.calls = []Call{}
+}
+
+func (msh *MockSessionHandler) appendCall(call Call) {
+	msh.calls = append(msh.calls, call)
+}
+
 // Mock MonitoringHandler
 type MockMonitoringHandler struct {
 	calls []Call
@@ -189,18 +615,38 @@ func (mah *MockAuthenticationHandler) AuthenticationMiddleware(next http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
-func (mah *MockAuthenticationHandler) HasWritePermissions(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		mah.appendCall(
-			Call{name: "hasWritePermissions", params: map[string]string{}, time: time.Now()},
-		)
-		next.ServeHTTP(w, r)
-	})
+func (mah *MockAuthenticationHandler) RequirePermission(permission types.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mah.appendCall(
+				Call{name: "requirePermission:" + string(permission), params: map[string]string{}, time: time.Now()},
+			)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
+func (mah *MockAuthenticationHandler) RequireArchivedBookPermission(permission types.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mah.appendCall(
+				Call{name: "requireArchivedBookPermission:" + string(permission), params: map[string]string{}, time: time.Now()},
+			)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
-func (mah *MockAuthenticationHandler) IsOwner(next http.Handler) http.Handler {
+
+// unreadableBookID is a book the mocked user has no read permission for
+const unreadableBookID = "404"
+
+func (mah *MockAuthenticationHandler) HasReadPermissions(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		mah.appendCall(Call{name: "isOwner", params: map[string]string{}, time: time.Now()})
+		mah.appendCall(Call{name: "hasReadPermissions", params: map[string]string{}, time: time.Now()})
+		if r.PathValue("bookID") == unreadableBookID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -241,6 +687,9 @@ func readParamsFromRequest(r *http.Request) map[string]string {
 	bookId := r.PathValue("bookID")
 	accountId := r.PathValue("accountID")
 	bookingId := r.PathValue("bookingID")
+	partnerId := r.PathValue("partnerID")
+	openItemId := r.PathValue("openItemID")
+	invoiceId := r.PathValue("invoiceID")
 
 	if bookId != "" {
 		params["bookID"] = bookId
@@ -251,5 +700,14 @@ func readParamsFromRequest(r *http.Request) map[string]string {
 	if bookingId != "" {
 		params["bookingID"] = bookingId
 	}
+	if partnerId != "" {
+		params["partnerID"] = partnerId
+	}
+	if openItemId != "" {
+		params["openItemID"] = openItemId
+	}
+	if invoiceId != "" {
+		params["invoiceID"] = invoiceId
+	}
 	return params
 }
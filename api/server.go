@@ -4,6 +4,8 @@ import (
 	"log"
 	"net/http"
 	"strings"
+
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type middleware func(http.Handler) http.Handler
@@ -15,23 +17,100 @@ type AccountingHandler interface {
 	CreateBooking(w http.ResponseWriter, r *http.Request)
 	UpdateBooking(http.ResponseWriter, *http.Request)
 	DeleteBooking(http.ResponseWriter, *http.Request)
+	ApproveBooking(http.ResponseWriter, *http.Request)
 	CreateAccount(w http.ResponseWriter, r *http.Request)
 	UpdateAccount(w http.ResponseWriter, r *http.Request)
 	DeleteAccount(http.ResponseWriter, *http.Request)
 	SaveAccountOption(w http.ResponseWriter, r *http.Request)
 	ReadClosingStatements(w http.ResponseWriter, r *http.Request)
+	ReadIncomeStatementsByCostCenter(w http.ResponseWriter, r *http.Request)
+	ReadAccruals(w http.ResponseWriter, r *http.Request)
+	CreateInterBookTransfer(w http.ResponseWriter, r *http.Request)
+	ReadClearingAccounts(w http.ResponseWriter, r *http.Request)
+	SaveClearingAccount(w http.ResponseWriter, r *http.Request)
 }
 
 type BookHandler interface {
 	ReadBookRealms(w http.ResponseWriter, r *http.Request)
 	CreateBookRealm(w http.ResponseWriter, r *http.Request)
 	UpdateBookRealm(w http.ResponseWriter, r *http.Request)
-	DeleteBookRealm(w http.ResponseWriter, r *http.Request)
+	ArchiveBookRealm(w http.ResponseWriter, r *http.Request)
+	RestoreBookRealm(w http.ResponseWriter, r *http.Request)
+	PurgeBookRealm(w http.ResponseWriter, r *http.Request)
+	CloneBookRealm(w http.ResponseWriter, r *http.Request)
 	ReadAccountingUsers(w http.ResponseWriter, r *http.Request)
 	CreateUser(w http.ResponseWriter, r *http.Request)
 	ReadBookRealmById(w http.ResponseWriter, r *http.Request)
 }
 
+type BookArchiveHandler interface {
+	ExportBookRealm(w http.ResponseWriter, r *http.Request)
+	ImportBookRealm(w http.ResponseWriter, r *http.Request)
+}
+
+type ConsolidationHandler interface {
+	ReadConsolidatedStatements(w http.ResponseWriter, r *http.Request)
+}
+
+type WebhookHandler interface {
+	ReadWebhooks(w http.ResponseWriter, r *http.Request)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	ReadWebhookDeliveries(w http.ResponseWriter, r *http.Request)
+}
+
+type SubledgerHandler interface {
+	ReadBusinessPartners(w http.ResponseWriter, r *http.Request)
+	CreateBusinessPartner(w http.ResponseWriter, r *http.Request)
+	UpdateBusinessPartner(w http.ResponseWriter, r *http.Request)
+	ReadOpenItems(w http.ResponseWriter, r *http.Request)
+	CreateOpenItem(w http.ResponseWriter, r *http.Request)
+	CreatePayment(w http.ResponseWriter, r *http.Request)
+	ReadAgingReport(w http.ResponseWriter, r *http.Request)
+}
+
+type InvoiceHandler interface {
+	ReadInvoiceSettings(w http.ResponseWriter, r *http.Request)
+	UpdateInvoiceSettings(w http.ResponseWriter, r *http.Request)
+	ReadInvoices(w http.ResponseWriter, r *http.Request)
+	ReadInvoice(w http.ResponseWriter, r *http.Request)
+	CreateInvoice(w http.ResponseWriter, r *http.Request)
+	ReadInvoicePDF(w http.ResponseWriter, r *http.Request)
+	MatchPaymentByReference(w http.ResponseWriter, r *http.Request)
+	ImportPayments(w http.ResponseWriter, r *http.Request)
+}
+
+type AssetHandler interface {
+	ReadFixedAssets(w http.ResponseWriter, r *http.Request)
+	CreateFixedAsset(w http.ResponseWriter, r *http.Request)
+	RunDepreciation(w http.ResponseWriter, r *http.Request)
+	ReadAssetSchedule(w http.ResponseWriter, r *http.Request)
+}
+
+type SharingHandler interface {
+	ReadInvitations(w http.ResponseWriter, r *http.Request)
+	CreateInvitation(w http.ResponseWriter, r *http.Request)
+	RevokeInvitation(w http.ResponseWriter, r *http.Request)
+	ReadInvitationsOfUser(w http.ResponseWriter, r *http.Request)
+	AcceptInvitation(w http.ResponseWriter, r *http.Request)
+	LeaveBook(w http.ResponseWriter, r *http.Request)
+	RequestOwnershipTransfer(w http.ResponseWriter, r *http.Request)
+	CancelOwnershipTransfer(w http.ResponseWriter, r *http.Request)
+	AcceptOwnershipTransfer(w http.ResponseWriter, r *http.Request)
+}
+
+type ApiTokenHandler interface {
+	ReadApiTokens(w http.ResponseWriter, r *http.Request)
+	CreateApiToken(w http.ResponseWriter, r *http.Request)
+	RevokeApiToken(w http.ResponseWriter, r *http.Request)
+}
+
+type SessionHandler interface {
+	Login(w http.ResponseWriter, r *http.Request)
+	LoginCallback(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+}
+
 type MonitoringHandler interface {
 	MetricsHandler() http.Handler
 	MeasureRequest(http.Handler) http.Handler
@@ -39,26 +118,45 @@ type MonitoringHandler interface {
 
 type AuthenticationHandler interface {
 	AuthenticationMiddleware(http.Handler) http.Handler
-	HasWritePermissions(next http.Handler) http.Handler
-	IsOwner(next http.Handler) http.Handler
+	RequirePermission(permission types.Permission) func(http.Handler) http.Handler
+	RequireArchivedBookPermission(permission types.Permission) func(http.Handler) http.Handler
+	HasReadPermissions(next http.Handler) http.Handler
 	JwksUrl(w http.ResponseWriter, r *http.Request)
 }
 
 type Server struct {
 	bookHandler           BookHandler
+	bookArchiveHandler    BookArchiveHandler
+	consolidationHandler  ConsolidationHandler
+	webhookHandler        WebhookHandler
 	monitoringHandler     MonitoringHandler
 	accountingHandler     AccountingHandler
 	authenticationHandler AuthenticationHandler
+	subledgerHandler      SubledgerHandler
+	invoiceHandler        InvoiceHandler
+	assetHandler          AssetHandler
+	sharingHandler        SharingHandler
+	apiTokenHandler       ApiTokenHandler
+	sessionHandler        SessionHandler
 	router                *http.ServeMux
 }
 
-func CreateServer(bookHandler BookHandler, monitoringHandler MonitoringHandler, accountingHandler AccountingHandler, authenticationHandler AuthenticationHandler) *Server {
+func CreateServer(bookHandler BookHandler, monitoringHandler MonitoringHandler, accountingHandler AccountingHandler, authenticationHandler AuthenticationHandler, subledgerHandler SubledgerHandler, invoiceHandler InvoiceHandler, assetHandler AssetHandler, sharingHandler SharingHandler, apiTokenHandler ApiTokenHandler, sessionHandler SessionHandler, bookArchiveHandler BookArchiveHandler, consolidationHandler ConsolidationHandler, webhookHandler WebhookHandler) *Server {
 
 	return &Server{
 		bookHandler:           bookHandler,
 		monitoringHandler:     monitoringHandler,
 		accountingHandler:     accountingHandler,
 		authenticationHandler: authenticationHandler,
+		subledgerHandler:      subledgerHandler,
+		invoiceHandler:        invoiceHandler,
+		assetHandler:          assetHandler,
+		sharingHandler:        sharingHandler,
+		apiTokenHandler:       apiTokenHandler,
+		sessionHandler:        sessionHandler,
+		bookArchiveHandler:    bookArchiveHandler,
+		consolidationHandler:  consolidationHandler,
+		webhookHandler:        webhookHandler,
 	}
 }
 
@@ -66,25 +164,74 @@ func (s *Server) RegisterHandlers() {
 
 	r := http.NewServeMux()
 
-	// TODO bei den Updates und deltes muss noch geprüft werden, ob die Entsprechende Entity auch im richtigen BookRealm ist
 	r.Handle("GET /metrics", s.monitoringHandler.MetricsHandler())
 	r.HandleFunc("GET /login-info", s.authenticationHandler.JwksUrl)
+	r.HandleFunc("GET /auth/login", s.sessionHandler.Login)
+	r.HandleFunc("GET /auth/callback", s.sessionHandler.LoginCallback)
+	r.HandleFunc("POST /auth/logout", s.sessionHandler.Logout)
 	api := Subrouter(r, "/api")
 	api.Handle("GET /book", s.authMonitoring(s.bookHandler.ReadBookRealms))
 	api.Handle("POST /book", s.authMonitoring(s.bookHandler.CreateBookRealm))
-	api.Handle("PUT /book/{bookID}", s.authMonitoring(http.HandlerFunc(s.bookHandler.UpdateBookRealm), s.authenticationHandler.IsOwner))
-	api.Handle("DELETE /book/{bookID}", s.authMonitoring(http.HandlerFunc(s.bookHandler.DeleteBookRealm), s.authenticationHandler.IsOwner))
-	api.Handle("GET /book/{bookID}", s.authMonitoring(s.bookHandler.ReadBookRealmById))
-	api.Handle("GET /book/{bookID}/account", s.authMonitoring(s.accountingHandler.ReadAccounts))
-	api.Handle("POST /book/{bookID}/account", s.authMonitoring(http.HandlerFunc(s.accountingHandler.CreateAccount), s.authenticationHandler.HasWritePermissions))
-	api.Handle("PUT /book/{bookID}/account/{accountID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.UpdateAccount), s.authenticationHandler.HasWritePermissions))
-	api.Handle("DELETE /book/{bookID}/account/{accountID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.DeleteAccount), s.authenticationHandler.HasWritePermissions))
-	api.Handle("GET /book/{bookID}/accountOption", s.authMonitoring(s.accountingHandler.ReadAccountOptions))
-	api.Handle("GET /book/{bookID}/closingStatements", s.authMonitoring(s.accountingHandler.ReadClosingStatements))
-	api.Handle("GET /book/{bookID}/booking", s.authMonitoring(s.accountingHandler.ReadBookings))
-	api.Handle("POST /book/{bookID}/booking", s.authMonitoring(http.HandlerFunc(s.accountingHandler.CreateBooking), s.authenticationHandler.HasWritePermissions))
-	api.Handle("PUT /book/{bookID}/booking/{bookingID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.UpdateBooking), s.authenticationHandler.HasWritePermissions))
-	api.Handle("DELETE /book/{bookID}/booking/{bookingID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.DeleteBooking), s.authenticationHandler.HasWritePermissions))
+	api.Handle("POST /book/import", s.authMonitoring(s.bookArchiveHandler.ImportBookRealm))
+	api.Handle("PUT /book/{bookID}", s.authMonitoring(http.HandlerFunc(s.bookHandler.UpdateBookRealm), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("DELETE /book/{bookID}", s.authMonitoring(http.HandlerFunc(s.bookHandler.ArchiveBookRealm), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("POST /book/{bookID}/restore", s.authMonitoring(http.HandlerFunc(s.bookHandler.RestoreBookRealm), s.authenticationHandler.RequireArchivedBookPermission(types.PermissionAdminister)))
+	api.Handle("DELETE /book/{bookID}/purge", s.authMonitoring(http.HandlerFunc(s.bookHandler.PurgeBookRealm), s.authenticationHandler.RequireArchivedBookPermission(types.PermissionAdminister)))
+	api.Handle("GET /book/{bookID}", s.authMonitoring(http.HandlerFunc(s.bookHandler.ReadBookRealmById), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/clone", s.authMonitoring(http.HandlerFunc(s.bookHandler.CloneBookRealm), s.authenticationHandler.RequireArchivedBookPermission(types.PermissionAdminister)))
+	api.Handle("GET /book/{bookID}/export", s.authMonitoring(http.HandlerFunc(s.bookArchiveHandler.ExportBookRealm), s.authenticationHandler.RequireArchivedBookPermission(types.PermissionAdminister)))
+	api.Handle("GET /book/{bookID}/account", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadAccounts), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/account", s.authMonitoring(http.HandlerFunc(s.accountingHandler.CreateAccount), s.authenticationHandler.RequirePermission(types.PermissionManageAccounts)))
+	api.Handle("PUT /book/{bookID}/account/{accountID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.UpdateAccount), s.authenticationHandler.RequirePermission(types.PermissionManageAccounts)))
+	api.Handle("DELETE /book/{bookID}/account/{accountID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.DeleteAccount), s.authenticationHandler.RequirePermission(types.PermissionManageAccounts)))
+	api.Handle("GET /book/{bookID}/accountOption", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadAccountOptions), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/closingStatements", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadClosingStatements), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/closingStatements/costCenter", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadIncomeStatementsByCostCenter), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/booking", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadBookings), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/accrual", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadAccruals), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/booking", s.authMonitoring(http.HandlerFunc(s.accountingHandler.CreateBooking), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("PUT /book/{bookID}/booking/{bookingID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.UpdateBooking), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("DELETE /book/{bookID}/booking/{bookingID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.DeleteBooking), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("POST /book/{bookID}/booking/{bookingID}/approval", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ApproveBooking), s.authenticationHandler.RequirePermission(types.PermissionApprove)))
+	api.Handle("POST /book/{bookID}/transfer", s.authMonitoring(http.HandlerFunc(s.accountingHandler.CreateInterBookTransfer), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("GET /book/{bookID}/clearingAccount", s.authMonitoring(http.HandlerFunc(s.accountingHandler.ReadClearingAccounts), s.authenticationHandler.HasReadPermissions))
+	api.Handle("PUT /book/{bookID}/clearingAccount/{counterpartBookID}", s.authMonitoring(http.HandlerFunc(s.accountingHandler.SaveClearingAccount), s.authenticationHandler.RequirePermission(types.PermissionManageAccounts)))
+	api.Handle("GET /book/{bookID}/partner", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.ReadBusinessPartners), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/partner", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.CreateBusinessPartner), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("PUT /book/{bookID}/partner/{partnerID}", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.UpdateBusinessPartner), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("GET /book/{bookID}/openItem", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.ReadOpenItems), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/openItem", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.CreateOpenItem), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("POST /book/{bookID}/openItem/{openItemID}/payment", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.CreatePayment), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("GET /book/{bookID}/agingReport", s.authMonitoring(http.HandlerFunc(s.subledgerHandler.ReadAgingReport), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/invoiceSettings", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.ReadInvoiceSettings), s.authenticationHandler.HasReadPermissions))
+	api.Handle("PUT /book/{bookID}/invoiceSettings", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.UpdateInvoiceSettings), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("GET /book/{bookID}/invoice", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.ReadInvoices), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/invoice", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.CreateInvoice), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("GET /book/{bookID}/invoice/{invoiceID}", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.ReadInvoice), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/invoice/{invoiceID}/pdf", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.ReadInvoicePDF), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/invoice/payment", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.MatchPaymentByReference), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("POST /book/{bookID}/invoice/payment/camt", s.authMonitoring(http.HandlerFunc(s.invoiceHandler.ImportPayments), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("GET /book/{bookID}/asset", s.authMonitoring(http.HandlerFunc(s.assetHandler.ReadFixedAssets), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/asset", s.authMonitoring(http.HandlerFunc(s.assetHandler.CreateFixedAsset), s.authenticationHandler.RequirePermission(types.PermissionManageAccounts)))
+	api.Handle("POST /book/{bookID}/asset/depreciation", s.authMonitoring(http.HandlerFunc(s.assetHandler.RunDepreciation), s.authenticationHandler.RequirePermission(types.PermissionBook)))
+	api.Handle("GET /book/{bookID}/asset/schedule", s.authMonitoring(http.HandlerFunc(s.assetHandler.ReadAssetSchedule), s.authenticationHandler.HasReadPermissions))
+	api.Handle("GET /book/{bookID}/invitation", s.authMonitoring(http.HandlerFunc(s.sharingHandler.ReadInvitations), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("POST /book/{bookID}/invitation", s.authMonitoring(http.HandlerFunc(s.sharingHandler.CreateInvitation), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("DELETE /book/{bookID}/invitation/{invitationID}", s.authMonitoring(http.HandlerFunc(s.sharingHandler.RevokeInvitation), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("GET /book/{bookID}/webhook", s.authMonitoring(http.HandlerFunc(s.webhookHandler.ReadWebhooks), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("POST /book/{bookID}/webhook", s.authMonitoring(http.HandlerFunc(s.webhookHandler.CreateWebhook), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("DELETE /book/{bookID}/webhook/{webhookID}", s.authMonitoring(http.HandlerFunc(s.webhookHandler.DeleteWebhook), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("GET /book/{bookID}/webhook/delivery", s.authMonitoring(http.HandlerFunc(s.webhookHandler.ReadWebhookDeliveries), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("DELETE /book/{bookID}/membership", s.authMonitoring(http.HandlerFunc(s.sharingHandler.LeaveBook), s.authenticationHandler.HasReadPermissions))
+	api.Handle("POST /book/{bookID}/ownershipTransfer", s.authMonitoring(http.HandlerFunc(s.sharingHandler.RequestOwnershipTransfer), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("DELETE /book/{bookID}/ownershipTransfer", s.authMonitoring(http.HandlerFunc(s.sharingHandler.CancelOwnershipTransfer), s.authenticationHandler.RequirePermission(types.PermissionAdminister)))
+	api.Handle("POST /book/{bookID}/ownershipTransfer/accept", s.authMonitoring(s.sharingHandler.AcceptOwnershipTransfer))
+	api.Handle("POST /consolidation", s.authMonitoring(s.consolidationHandler.ReadConsolidatedStatements))
+	api.Handle("GET /invitation", s.authMonitoring(s.sharingHandler.ReadInvitationsOfUser))
+	api.Handle("POST /invitation/{token}/accept", s.authMonitoring(s.sharingHandler.AcceptInvitation))
+	api.Handle("GET /token", s.authMonitoring(s.apiTokenHandler.ReadApiTokens))
+	api.Handle("POST /token", s.authMonitoring(s.apiTokenHandler.CreateApiToken))
+	api.Handle("DELETE /token/{tokenID}", s.authMonitoring(s.apiTokenHandler.RevokeApiToken))
 	api.Handle("POST /user", s.authMonitoring(s.bookHandler.CreateUser))
 	api.Handle("GET /user", s.authMonitoring(s.bookHandler.ReadAccountingUsers))
 	s.router = r
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -11,32 +12,95 @@ func createCallNamesHandlerMap(
 	monitoringHandler *MockMonitoringHandler,
 	accountingHandler *MockAccountingHandler,
 	authenticationHandler *MockAuthenticationHandler,
+	subledgerHandler *MockSubledgerHandler,
+	invoiceHandler *MockInvoiceHandler,
+	assetHandler *MockAssetHandler,
+	sessionHandler *MockSessionHandler,
+	apiTokenHandler *MockApiTokenHandler,
+	sharingHandler *MockSharingHandler,
+	bookArchiveHandler *MockBookArchiveHandler,
+	consolidationHandler *MockConsolidationHandler,
+	webhookHandler *MockWebhookHandler,
 ) map[string]Mock {
 	return map[string]Mock{
-		"readAccounts":             accountingHandler,
-		"readAccountOptions":       accountingHandler,
-		"readBookings":             accountingHandler,
-		"createBooking":            accountingHandler,
-		"updateBooking":            accountingHandler,
-		"deleteBooking":            accountingHandler,
-		"createAccount":            accountingHandler,
-		"updateAccount":            accountingHandler,
-		"deleteAccount":            accountingHandler,
-		"saveAccountOption":        accountingHandler,
-		"readClosingStatements":    accountingHandler,
-		"readBookRealms":           bookHandler,
-		"createBookRealm":          bookHandler,
-		"updateBookRealm":          bookHandler,
-		"deleteBookRealm":          bookHandler,
-		"readAccountingUsers":      bookHandler,
-		"createUser":               bookHandler,
-		"readBookRealmById":        bookHandler,
-		"monitoringHandler":        monitoringHandler,
-		"measureRequest":           monitoringHandler,
-		"authenticationMiddleware": authenticationHandler,
-		"hasWritePermissions":      authenticationHandler,
-		"isOwner":                  authenticationHandler,
-		"jwksUrl":                  authenticationHandler,
+		"readWebhooks":                             webhookHandler,
+		"createWebhook":                            webhookHandler,
+		"deleteWebhook":                            webhookHandler,
+		"readWebhookDeliveries":                    webhookHandler,
+		"readConsolidatedStatements":               consolidationHandler,
+		"exportBookRealm":                          bookArchiveHandler,
+		"importBookRealm":                          bookArchiveHandler,
+		"login":                                    sessionHandler,
+		"loginCallback":                            sessionHandler,
+		"logout":                                   sessionHandler,
+		"readApiTokens":                            apiTokenHandler,
+		"createApiToken":                           apiTokenHandler,
+		"revokeApiToken":                           apiTokenHandler,
+		"readInvitations":                          sharingHandler,
+		"createInvitation":                         sharingHandler,
+		"revokeInvitation":                         sharingHandler,
+		"readInvitationsOfUser":                    sharingHandler,
+		"acceptInvitation":                         sharingHandler,
+		"leaveBook":                                sharingHandler,
+		"requestOwnershipTransfer":                 sharingHandler,
+		"cancelOwnershipTransfer":                  sharingHandler,
+		"acceptOwnershipTransfer":                  sharingHandler,
+		"readFixedAssets":                          assetHandler,
+		"createFixedAsset":                         assetHandler,
+		"runDepreciation":                          assetHandler,
+		"readAssetSchedule":                        assetHandler,
+		"readInvoiceSettings":                      invoiceHandler,
+		"updateInvoiceSettings":                    invoiceHandler,
+		"readInvoices":                             invoiceHandler,
+		"readInvoice":                              invoiceHandler,
+		"createInvoice":                            invoiceHandler,
+		"readInvoicePDF":                           invoiceHandler,
+		"matchPaymentByReference":                  invoiceHandler,
+		"importPayments":                           invoiceHandler,
+		"readBusinessPartners":                     subledgerHandler,
+		"createBusinessPartner":                    subledgerHandler,
+		"updateBusinessPartner":                    subledgerHandler,
+		"readOpenItems":                            subledgerHandler,
+		"createOpenItem":                           subledgerHandler,
+		"createPayment":                            subledgerHandler,
+		"readAgingReport":                          subledgerHandler,
+		"readAccounts":                             accountingHandler,
+		"readAccountOptions":                       accountingHandler,
+		"readBookings":                             accountingHandler,
+		"createBooking":                            accountingHandler,
+		"updateBooking":                            accountingHandler,
+		"deleteBooking":                            accountingHandler,
+		"approveBooking":                           accountingHandler,
+		"createAccount":                            accountingHandler,
+		"updateAccount":                            accountingHandler,
+		"deleteAccount":                            accountingHandler,
+		"saveAccountOption":                        accountingHandler,
+		"readClosingStatements":                    accountingHandler,
+		"readIncomeStatementsByCostCenter":         accountingHandler,
+		"readAccruals":                             accountingHandler,
+		"createInterBookTransfer":                  accountingHandler,
+		"readClearingAccounts":                     accountingHandler,
+		"saveClearingAccount":                      accountingHandler,
+		"readBookRealms":                           bookHandler,
+		"createBookRealm":                          bookHandler,
+		"updateBookRealm":                          bookHandler,
+		"archiveBookRealm":                         bookHandler,
+		"restoreBookRealm":                         bookHandler,
+		"purgeBookRealm":                           bookHandler,
+		"cloneBookRealm":                           bookHandler,
+		"readAccountingUsers":                      bookHandler,
+		"createUser":                               bookHandler,
+		"readBookRealmById":                        bookHandler,
+		"monitoringHandler":                        monitoringHandler,
+		"measureRequest":                           monitoringHandler,
+		"authenticationMiddleware":                 authenticationHandler,
+		"hasReadPermissions":                       authenticationHandler,
+		"requirePermission:book":                   authenticationHandler,
+		"requirePermission:manageAccounts":         authenticationHandler,
+		"requirePermission:administer":             authenticationHandler,
+		"requirePermission:approve":                authenticationHandler,
+		"requireArchivedBookPermission:administer": authenticationHandler,
+		"jwksUrl":                                  authenticationHandler,
 	}
 }
 
@@ -45,6 +109,7 @@ func TestServer_Start(t *testing.T) {
 		requestType           string
 		requestUrl            string
 		excpectedCallsInOrder []string
+		expectedStatus        int
 	}
 	tests := []struct {
 		name   string
@@ -66,6 +131,30 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{"jwksUrl"},
 			},
 		},
+		{
+			name: "Test login",
+			fields: fields{
+				requestType:           "GET",
+				requestUrl:            "/auth/login",
+				excpectedCallsInOrder: []string{"login"},
+			},
+		},
+		{
+			name: "Test loginCallback",
+			fields: fields{
+				requestType:           "GET",
+				requestUrl:            "/auth/callback",
+				excpectedCallsInOrder: []string{"loginCallback"},
+			},
+		},
+		{
+			name: "Test logout",
+			fields: fields{
+				requestType:           "POST",
+				requestUrl:            "/auth/logout",
+				excpectedCallsInOrder: []string{"logout"},
+			},
+		},
 		{
 			name: "Test readBookRealms",
 			fields: fields{
@@ -98,21 +187,97 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"isOwner",
+					"requirePermission:administer",
 					"updateBookRealm",
 				},
 			},
 		},
 		{
-			name: "Test deleteBookRealm",
+			name: "Test archiveBookRealm",
 			fields: fields{
 				requestType: "DELETE",
 				requestUrl:  "/api/book/123",
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"isOwner",
-					"deleteBookRealm",
+					"requirePermission:administer",
+					"archiveBookRealm",
+				},
+			},
+		},
+		{
+			name: "Test restoreBookRealm",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/restore",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requireArchivedBookPermission:administer",
+					"restoreBookRealm",
+				},
+			},
+		},
+		{
+			name: "Test purgeBookRealm",
+			fields: fields{
+				requestType: "DELETE",
+				requestUrl:  "/api/book/123/purge",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requireArchivedBookPermission:administer",
+					"purgeBookRealm",
+				},
+			},
+		},
+		{
+			name: "Test cloneBookRealm",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/clone",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requireArchivedBookPermission:administer",
+					"cloneBookRealm",
+				},
+			},
+		},
+		{
+			name: "Test exportBookRealm",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/export",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requireArchivedBookPermission:administer",
+					"exportBookRealm",
+				},
+			},
+		},
+		{
+			name: "Test importBookRealm",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/import",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"importBookRealm",
+				},
+			},
+		},
+		{
+			name: "Test readConsolidatedStatements",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/consolidation",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"readConsolidatedStatements",
 				},
 			},
 		},
@@ -124,6 +289,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
+					"hasReadPermissions",
 					"readBookRealmById",
 				},
 			},
@@ -136,6 +302,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
+					"hasReadPermissions",
 					"readAccounts",
 				},
 			},
@@ -148,7 +315,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"hasWritePermissions",
+					"requirePermission:manageAccounts",
 					"createAccount",
 				},
 			},
@@ -161,7 +328,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"hasWritePermissions",
+					"requirePermission:manageAccounts",
 					"updateAccount",
 				},
 			},
@@ -174,7 +341,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"hasWritePermissions",
+					"requirePermission:manageAccounts",
 					"deleteAccount",
 				},
 			},
@@ -187,6 +354,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
+					"hasReadPermissions",
 					"readAccountOptions",
 				},
 			},
@@ -199,10 +367,24 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
+					"hasReadPermissions",
 					"readClosingStatements",
 				},
 			},
 		},
+		{
+			name: "Test readIncomeStatementsByCostCenter",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/closingStatements/costCenter",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readIncomeStatementsByCostCenter",
+				},
+			},
+		},
 		{
 			name: "Test readBookings",
 			fields: fields{
@@ -211,6 +393,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
+					"hasReadPermissions",
 					"readBookings",
 				},
 			},
@@ -223,7 +406,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"hasWritePermissions",
+					"requirePermission:book",
 					"createBooking",
 				},
 			},
@@ -236,7 +419,7 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"hasWritePermissions",
+					"requirePermission:book",
 					"updateBooking",
 				},
 			},
@@ -249,11 +432,323 @@ func TestServer_Start(t *testing.T) {
 				excpectedCallsInOrder: []string{
 					"measureRequest",
 					"authenticationMiddleware",
-					"hasWritePermissions",
+					"requirePermission:book",
 					"deleteBooking",
 				},
 			},
 		},
+		{
+			name: "Test approveBooking",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/booking/789/approval",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:approve",
+					"approveBooking",
+				},
+			},
+		},
+		{
+			name: "Test readBusinessPartners",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/partner",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readBusinessPartners",
+				},
+			},
+		},
+		{
+			name: "Test createBusinessPartner",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/partner",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"createBusinessPartner",
+				},
+			},
+		},
+		{
+			name: "Test updateBusinessPartner",
+			fields: fields{
+				requestType: "PUT",
+				requestUrl:  "/api/book/123/partner/42",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"updateBusinessPartner",
+				},
+			},
+		},
+		{
+			name: "Test readOpenItems",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/openItem",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readOpenItems",
+				},
+			},
+		},
+		{
+			name: "Test createOpenItem",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/openItem",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"createOpenItem",
+				},
+			},
+		},
+		{
+			name: "Test createPayment",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/openItem/7/payment",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"createPayment",
+				},
+			},
+		},
+		{
+			name: "Test readAgingReport",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/agingReport",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readAgingReport",
+				},
+			},
+		},
+		{
+			name: "Test readInvoiceSettings",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/invoiceSettings",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readInvoiceSettings",
+				},
+			},
+		},
+		{
+			name: "Test updateInvoiceSettings",
+			fields: fields{
+				requestType: "PUT",
+				requestUrl:  "/api/book/123/invoiceSettings",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"updateInvoiceSettings",
+				},
+			},
+		},
+		{
+			name: "Test readInvoices",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/invoice",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readInvoices",
+				},
+			},
+		},
+		{
+			name: "Test createInvoice",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/invoice",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"createInvoice",
+				},
+			},
+		},
+		{
+			name: "Test readInvoice",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/invoice/5",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readInvoice",
+				},
+			},
+		},
+		{
+			name: "Test readInvoicePDF",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/invoice/5/pdf",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readInvoicePDF",
+				},
+			},
+		},
+		{
+			name: "Test matchPaymentByReference",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/invoice/payment",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"matchPaymentByReference",
+				},
+			},
+		},
+		{
+			name: "Test importPayments",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/invoice/payment/camt",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"importPayments",
+				},
+			},
+		},
+		{
+			name: "Test readFixedAssets",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/asset",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readFixedAssets",
+				},
+			},
+		},
+		{
+			name: "Test createFixedAsset",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/asset",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:manageAccounts",
+					"createFixedAsset",
+				},
+			},
+		},
+		{
+			name: "Test runDepreciation",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/asset/depreciation",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"runDepreciation",
+				},
+			},
+		},
+		{
+			name: "Test readAssetSchedule",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/asset/schedule",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readAssetSchedule",
+				},
+			},
+		},
+		{
+			name: "Test createInterBookTransfer",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/transfer",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:book",
+					"createInterBookTransfer",
+				},
+			},
+		},
+		{
+			name: "Test readClearingAccounts",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/clearingAccount",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readClearingAccounts",
+				},
+			},
+		},
+		{
+			name: "Test saveClearingAccount",
+			fields: fields{
+				requestType: "PUT",
+				requestUrl:  "/api/book/123/clearingAccount/456",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:manageAccounts",
+					"saveClearingAccount",
+				},
+			},
+		},
+		{
+			name: "Test readAccruals",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/accrual",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"readAccruals",
+				},
+			},
+		},
 		{
 			name: "Test createUser",
 			fields: fields{
@@ -266,6 +761,234 @@ func TestServer_Start(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Test readBookRealmById without read permission",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/" + unreadableBookID,
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+				},
+				expectedStatus: http.StatusNotFound,
+			},
+		},
+		{
+			name: "Test readAccounts without read permission",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/" + unreadableBookID + "/account",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+				},
+				expectedStatus: http.StatusNotFound,
+			},
+		},
+		{
+			name: "Test readWebhooks",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/webhook",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"readWebhooks",
+				},
+			},
+		},
+		{
+			name: "Test createWebhook",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/webhook",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"createWebhook",
+				},
+			},
+		},
+		{
+			name: "Test deleteWebhook",
+			fields: fields{
+				requestType: "DELETE",
+				requestUrl:  "/api/book/123/webhook/5",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"deleteWebhook",
+				},
+			},
+		},
+		{
+			name: "Test readWebhookDeliveries",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/webhook/delivery",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"readWebhookDeliveries",
+				},
+			},
+		},
+		{
+			name: "Test readInvitations",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/book/123/invitation",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"readInvitations",
+				},
+			},
+		},
+		{
+			name: "Test createInvitation",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/invitation",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"createInvitation",
+				},
+			},
+		},
+		{
+			name: "Test revokeInvitation",
+			fields: fields{
+				requestType: "DELETE",
+				requestUrl:  "/api/book/123/invitation/9",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"revokeInvitation",
+				},
+			},
+		},
+		{
+			name: "Test leaveBook",
+			fields: fields{
+				requestType: "DELETE",
+				requestUrl:  "/api/book/123/membership",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"hasReadPermissions",
+					"leaveBook",
+				},
+			},
+		},
+		{
+			name: "Test requestOwnershipTransfer",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/ownershipTransfer",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"requestOwnershipTransfer",
+				},
+			},
+		},
+		{
+			name: "Test cancelOwnershipTransfer",
+			fields: fields{
+				requestType: "DELETE",
+				requestUrl:  "/api/book/123/ownershipTransfer",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"requirePermission:administer",
+					"cancelOwnershipTransfer",
+				},
+			},
+		},
+		{
+			name: "Test acceptOwnershipTransfer",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/book/123/ownershipTransfer/accept",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"acceptOwnershipTransfer",
+				},
+			},
+		},
+		{
+			name: "Test readInvitationsOfUser",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/invitation",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"readInvitationsOfUser",
+				},
+			},
+		},
+		{
+			name: "Test acceptInvitation",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/invitation/abc/accept",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"acceptInvitation",
+				},
+			},
+		},
+		{
+			name: "Test readApiTokens",
+			fields: fields{
+				requestType: "GET",
+				requestUrl:  "/api/token",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"readApiTokens",
+				},
+			},
+		},
+		{
+			name: "Test createApiToken",
+			fields: fields{
+				requestType: "POST",
+				requestUrl:  "/api/token",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"createApiToken",
+				},
+			},
+		},
+		{
+			name: "Test revokeApiToken",
+			fields: fields{
+				requestType: "DELETE",
+				requestUrl:  "/api/token/1",
+				excpectedCallsInOrder: []string{
+					"measureRequest",
+					"authenticationMiddleware",
+					"revokeApiToken",
+				},
+			},
+		},
 		{
 			name: "Test readUser",
 			fields: fields{
@@ -285,24 +1008,60 @@ func TestServer_Start(t *testing.T) {
 			accountingHandler := MockAccountingHandler{}
 			monitoringHandler := MockMonitoringHandler{}
 			authenticationHandler := MockAuthenticationHandler{}
+			subledgerHandler := MockSubledgerHandler{}
+			invoiceHandler := MockInvoiceHandler{}
+			assetHandler := MockAssetHandler{}
+			sessionHandler := MockSessionHandler{}
+			apiTokenHandler := MockApiTokenHandler{}
+			sharingHandler := MockSharingHandler{}
+			bookArchiveHandler := MockBookArchiveHandler{}
+			consolidationHandler := MockConsolidationHandler{}
+			webhookHandler := MockWebhookHandler{}
 
 			handlerCallMap := createCallNamesHandlerMap(
 				&bookHandler,
 				&monitoringHandler,
 				&accountingHandler,
 				&authenticationHandler,
+				&subledgerHandler,
+				&invoiceHandler,
+				&assetHandler,
+				&sessionHandler,
+				&apiTokenHandler,
+				&sharingHandler,
+				&bookArchiveHandler,
+				&consolidationHandler,
+				&webhookHandler,
 			)
 
 			accountingHandler.resetCalls()
 			bookHandler.resetCalls()
 			monitoringHandler.resetCalls()
 			authenticationHandler.resetCalls()
+			subledgerHandler.resetCalls()
+			invoiceHandler.resetCalls()
+			assetHandler.resetCalls()
+			sessionHandler.resetCalls()
+			apiTokenHandler.resetCalls()
+			sharingHandler.resetCalls()
+			bookArchiveHandler.resetCalls()
+			consolidationHandler.resetCalls()
+			webhookHandler.resetCalls()
 
 			s := &Server{
 				bookHandler:           &bookHandler,
 				monitoringHandler:     &monitoringHandler,
 				accountingHandler:     &accountingHandler,
 				authenticationHandler: &authenticationHandler,
+				subledgerHandler:      &subledgerHandler,
+				invoiceHandler:        &invoiceHandler,
+				assetHandler:          &assetHandler,
+				sessionHandler:        &sessionHandler,
+				apiTokenHandler:       &apiTokenHandler,
+				sharingHandler:        &sharingHandler,
+				bookArchiveHandler:    &bookArchiveHandler,
+				consolidationHandler:  &consolidationHandler,
+				webhookHandler:        &webhookHandler,
 			}
 			s.RegisterHandlers()
 
@@ -314,6 +1073,10 @@ func TestServer_Start(t *testing.T) {
 
 			s.router.ServeHTTP(w, request)
 
+			if tt.fields.expectedStatus != 0 && w.Code != tt.fields.expectedStatus {
+				t.Errorf("%s: expected status %d, but got %d", tt.name, tt.fields.expectedStatus, w.Code)
+			}
+
 			for _, callName := range tt.fields.excpectedCallsInOrder {
 				call, ok := handlerCallMap[callName].popFirstCall()
 				if !ok {
@@ -353,6 +1116,69 @@ func TestServer_Start(t *testing.T) {
 					callsAccountingHandler,
 				)
 			}
+			callsSubledgerHandler := subledgerHandler.readCalls()
+			if len(callsSubledgerHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for subledgerHandler, but got %v",
+					callsSubledgerHandler,
+				)
+			}
+			callsInvoiceHandler := invoiceHandler.readCalls()
+			if len(callsInvoiceHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for invoiceHandler, but got %v",
+					callsInvoiceHandler,
+				)
+			}
+			callsAssetHandler := assetHandler.readCalls()
+			if len(callsAssetHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for assetHandler, but got %v",
+					callsAssetHandler,
+				)
+			}
+			callsSessionHandler := sessionHandler.readCalls()
+			if len(callsSessionHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for sessionHandler, but got %v",
+					callsSessionHandler,
+				)
+			}
+			callsApiTokenHandler := apiTokenHandler.readCalls()
+			if len(callsApiTokenHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for apiTokenHandler, but got %v",
+					callsApiTokenHandler,
+				)
+			}
+			callsSharingHandler := sharingHandler.readCalls()
+			if len(callsSharingHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for sharingHandler, but got %v",
+					callsSharingHandler,
+				)
+			}
+			callsBookArchiveHandler := bookArchiveHandler.readCalls()
+			if len(callsBookArchiveHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for bookArchiveHandler, but got %v",
+					callsBookArchiveHandler,
+				)
+			}
+			callsConsolidationHandler := consolidationHandler.readCalls()
+			if len(callsConsolidationHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for consolidationHandler, but got %v",
+					callsConsolidationHandler,
+				)
+			}
+			callsWebhookHandler := webhookHandler.readCalls()
+			if len(callsWebhookHandler) > 0 {
+				t.Errorf(
+					"expected no more calls for webhookHandler, but got %v",
+					callsWebhookHandler,
+				)
+			}
 			callsAuthenticationHandler := authenticationHandler.readCalls()
 			if len(callsAuthenticationHandler) > 0 {
 				t.Errorf(
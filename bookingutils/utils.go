@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const DateLayout = "2006-01-02"
+
 // StringSliceToInt converts slice of string to slice of uints
 func StringSliceToInt(strings []string) (convertedUints []uint, err error) {
 	convertedUints = make([]uint, 0, len(strings))
@@ -39,3 +43,17 @@ func FormatFloatToAmmount(value float64) string {
 func AlmostZero(val float64) bool {
 	return math.Abs(val) <= 0.05
 }
+
+// ParseDate reads a date either in the format 2006-01-02 or as RFC3339 timestamp
+func ParseDate(date string) (time.Time, error) {
+	trimmedDate := strings.TrimSpace(date)
+	parsedDate, err := time.Parse(DateLayout, trimmedDate)
+	if err == nil {
+		return parsedDate, nil
+	}
+	parsedTimestamp, err := time.Parse(time.RFC3339, trimmedDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(parsedTimestamp.Year(), parsedTimestamp.Month(), parsedTimestamp.Day(), 0, 0, 0, 0, time.UTC), nil
+}
@@ -3,6 +3,7 @@ package bookingutils
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestStringSliceToInt(t *testing.T) {
@@ -160,3 +161,31 @@ func TestAlmostZero(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDate(t *testing.T) {
+	type args struct {
+		date string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    time.Time
+		wantErr bool
+	}{
+		{"parse date", args{date: "2024-03-31"}, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), false},
+		{"parse timestamp", args{date: "2024-03-31T18:15:00+02:00"}, time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), false},
+		{"error invalid date", args{date: "31.03.2024"}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.args.date)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package camt reads the incoming payments with a structured creditor reference out of ISO 20022 bank to customer
+// messages, the camt.053 account statement and the camt.054 debit and credit notification.
+package camt
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	creditIndicator = "CRDT"
+	bookedStatus    = "BOOK"
+)
+
+// Payment is a credited transaction of a booked entry carrying a structured creditor reference (QR or SCOR)
+type Payment struct {
+	Reference   string
+	Ammount     string
+	Currency    string
+	BookingDate string
+}
+
+// the element names are matched in any namespace, so all versions of the messages are read by the same structs
+type document struct {
+	Statements    []report `xml:"BkToCstmrStmt>Stmt"`
+	Notifications []report `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+}
+
+type report struct {
+	Entries []entry `xml:"Ntry"`
+}
+
+type entry struct {
+	Ammount      ammount       `xml:"Amt"`
+	CreditDebit  string        `xml:"CdtDbtInd"`
+	Reversal     bool          `xml:"RvslInd"`
+	Status       status        `xml:"Sts"`
+	BookingDate  dateTime      `xml:"BookgDt"`
+	Transactions []transaction `xml:"NtryDtls>TxDtls"`
+}
+
+type ammount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// status is a plain code up to version 04 and a choice of codes from version 06 on
+type status struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+type dateTime struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type transaction struct {
+	Ammount            *ammount `xml:"Amt"`
+	TransactionAmmount *ammount `xml:"AmtDtls>TxAmt>Amt"`
+	CreditDebit        string   `xml:"CdtDbtInd"`
+	References         []string `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+}
+
+// ReadPayments returns the credited transactions with a creditor reference of all booked entries.
+// Reversed and pending entries as well as transactions without a reference are left out.
+func ReadPayments(r io.Reader) ([]Payment, error) {
+	var message document
+	if err := xml.NewDecoder(r).Decode(&message); err != nil {
+		return nil, err
+	}
+	reports := append(message.Statements, message.Notifications...)
+	if len(reports) == 0 {
+		return nil, errors.New("document is neither a camt.053 statement nor a camt.054 notification")
+	}
+	payments := []Payment{}
+	for _, report := range reports {
+		for _, entry := range report.Entries {
+			if !entry.isBookedCredit() {
+				continue
+			}
+			for _, transaction := range entry.Transactions {
+				payment, ok := entry.readPayment(transaction)
+				if ok {
+					payments = append(payments, payment)
+				}
+			}
+		}
+	}
+	return payments, nil
+}
+
+func (e entry) isBookedCredit() bool {
+	entryStatus := strings.TrimSpace(e.Status.Code)
+	if entryStatus == "" {
+		entryStatus = strings.TrimSpace(e.Status.Value)
+	}
+	return e.CreditDebit == creditIndicator && !e.Reversal && (entryStatus == "" || entryStatus == bookedStatus)
+}
+
+// readPayment takes the amount of the transaction, the amount of the entry only applies to an entry of one transaction
+func (e entry) readPayment(t transaction) (Payment, bool) {
+	if t.CreditDebit != "" && t.CreditDebit != creditIndicator {
+		return Payment{}, false
+	}
+	reference := ""
+	for _, candidate := range t.References {
+		if reference = strings.TrimSpace(candidate); reference != "" {
+			break
+		}
+	}
+	transactionAmmount := t.Ammount
+	if transactionAmmount == nil {
+		transactionAmmount = t.TransactionAmmount
+	}
+	if transactionAmmount == nil && len(e.Transactions) == 1 {
+		transactionAmmount = &e.Ammount
+	}
+	if reference == "" || transactionAmmount == nil {
+		return Payment{}, false
+	}
+	return Payment{
+		Reference:   reference,
+		Ammount:     strings.TrimSpace(transactionAmmount.Value),
+		Currency:    strings.TrimSpace(transactionAmmount.Currency),
+		BookingDate: e.BookingDate.read(),
+	}, true
+}
+
+// read returns the date of the date or of the timestamp
+func (d dateTime) read() string {
+	if date := strings.TrimSpace(d.Date); date != "" {
+		return date
+	}
+	timestamp := strings.TrimSpace(d.DateTime)
+	if len(timestamp) > len("2006-01-02") {
+		return timestamp[:len("2006-01-02")]
+	}
+	return timestamp
+}
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.28.1
+// 	protoc-gen-go v1.34.2
 // 	protoc        v3.11.4
 // source: grpc_users/userservice.proto
 
@@ -231,6 +231,143 @@ func (x *GetUsersResponse) GetUsers() []*User {
 	return nil
 }
 
+// SyncUsersRequest contains the complete list of users of the identity provider.
+// Users of an earlier snapshot missing in this one are deleted, users provisioned from a token in the meantime are kept.
+// The revision must not be lower than the one of the last sync.
+type SyncUsersRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Users    []*User `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
+	Revision int64   `protobuf:"varint,2,opt,name=revision,proto3" json:"revision,omitempty"`
+}
+
+func (x *SyncUsersRequest) Reset() {
+	*x = SyncUsersRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpc_users_userservice_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncUsersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncUsersRequest) ProtoMessage() {}
+
+func (x *SyncUsersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_users_userservice_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncUsersRequest.ProtoReflect.Descriptor instead.
+func (*SyncUsersRequest) Descriptor() ([]byte, []int) {
+	return file_grpc_users_userservice_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SyncUsersRequest) GetUsers() []*User {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *SyncUsersRequest) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+type SyncUsersReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Revision  int64 `protobuf:"varint,1,opt,name=revision,proto3" json:"revision,omitempty"`
+	Added     int32 `protobuf:"varint,2,opt,name=added,proto3" json:"added,omitempty"`
+	Updated   int32 `protobuf:"varint,3,opt,name=updated,proto3" json:"updated,omitempty"`
+	Deleted   int32 `protobuf:"varint,4,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Unchanged int32 `protobuf:"varint,5,opt,name=unchanged,proto3" json:"unchanged,omitempty"`
+}
+
+func (x *SyncUsersReport) Reset() {
+	*x = SyncUsersReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_grpc_users_userservice_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SyncUsersReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncUsersReport) ProtoMessage() {}
+
+func (x *SyncUsersReport) ProtoReflect() protoreflect.Message {
+	mi := &file_grpc_users_userservice_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncUsersReport.ProtoReflect.Descriptor instead.
+func (*SyncUsersReport) Descriptor() ([]byte, []int) {
+	return file_grpc_users_userservice_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SyncUsersReport) GetRevision() int64 {
+	if x != nil {
+		return x.Revision
+	}
+	return 0
+}
+
+func (x *SyncUsersReport) GetAdded() int32 {
+	if x != nil {
+		return x.Added
+	}
+	return 0
+}
+
+func (x *SyncUsersReport) GetUpdated() int32 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *SyncUsersReport) GetDeleted() int32 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+func (x *SyncUsersReport) GetUnchanged() int32 {
+	if x != nil {
+		return x.Unchanged
+	}
+	return 0
+}
+
 var File_grpc_users_userservice_proto protoreflect.FileDescriptor
 
 var file_grpc_users_userservice_proto_rawDesc = []byte{
@@ -250,8 +387,23 @@ var file_grpc_users_userservice_proto_rawDesc = []byte{
 	0x69, 0x64, 0x22, 0x3a, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x55, 0x73, 0x65, 0x72, 0x73, 0x52, 0x65,
 	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x26, 0x0a, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18,
 	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x75, 0x73, 0x65,
-	0x72, 0x73, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x52, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x32, 0xed,
-	0x01, 0x0a, 0x0b, 0x55, 0x73, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x40,
+	0x72, 0x73, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x52, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x22, 0x56,
+	0x0a, 0x10, 0x53, 0x79, 0x6e, 0x63, 0x55, 0x73, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x26, 0x0a, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x10, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x55,
+	0x73, 0x65, 0x72, 0x52, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x72, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x95, 0x01, 0x0a, 0x0f, 0x53, 0x79, 0x6e, 0x63, 0x55,
+	0x73, 0x65, 0x72, 0x73, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x72, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x72, 0x65,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x64, 0x64, 0x65, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x61, 0x64, 0x64, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07,
+	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x75,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x64, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x64,
+	0x12, 0x1c, 0x0a, 0x09, 0x75, 0x6e, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x09, 0x75, 0x6e, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x32, 0xb7,
+	0x02, 0x0a, 0x0b, 0x55, 0x73, 0x65, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x40,
 	0x0a, 0x0b, 0x47, 0x65, 0x74, 0x41, 0x6c, 0x6c, 0x55, 0x73, 0x65, 0x72, 0x73, 0x12, 0x11, 0x2e,
 	0x67, 0x72, 0x70, 0x63, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
 	0x1a, 0x1c, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x47, 0x65,
@@ -265,12 +417,16 @@ var file_grpc_users_userservice_proto_rawDesc = []byte{
 	0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x35, 0x0a, 0x0a, 0x44, 0x65, 0x6c, 0x65, 0x74,
 	0x65, 0x55, 0x73, 0x65, 0x72, 0x12, 0x12, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x75, 0x73, 0x65,
 	0x72, 0x73, 0x2e, 0x55, 0x73, 0x65, 0x72, 0x49, 0x64, 0x1a, 0x11, 0x2e, 0x67, 0x72, 0x70, 0x63,
-	0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x42, 0x3e,
-	0x5a, 0x3c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x6f, 0x6b,
-	0x79, 0x30, 0x33, 0x2f, 0x74, 0x6f, 0x6b, 0x79, 0x2d, 0x66, 0x69, 0x6e, 0x61, 0x6e, 0x63, 0x65,
-	0x2d, 0x61, 0x63, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x69, 0x6e, 0x67, 0x2d, 0x73, 0x65, 0x72, 0x76,
-	0x69, 0x63, 0x65, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x22, 0x00, 0x12, 0x48,
+	0x0a, 0x09, 0x53, 0x79, 0x6e, 0x63, 0x55, 0x73, 0x65, 0x72, 0x73, 0x12, 0x1c, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x55, 0x73, 0x65,
+	0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x67, 0x72, 0x70, 0x63,
+	0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x53, 0x79, 0x6e, 0x63, 0x55, 0x73, 0x65, 0x72, 0x73,
+	0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x22, 0x00, 0x42, 0x3e, 0x5a, 0x3c, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x6f, 0x6b, 0x79, 0x30, 0x33, 0x2f, 0x74, 0x6f,
+	0x6b, 0x79, 0x2d, 0x66, 0x69, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x2d, 0x61, 0x63, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x69, 0x6e, 0x67, 0x2d, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2f, 0x67, 0x72,
+	0x70, 0x63, 0x5f, 0x75, 0x73, 0x65, 0x72, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -285,28 +441,33 @@ func file_grpc_users_userservice_proto_rawDescGZIP() []byte {
 	return file_grpc_users_userservice_proto_rawDescData
 }
 
-var file_grpc_users_userservice_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
-var file_grpc_users_userservice_proto_goTypes = []interface{}{
+var file_grpc_users_userservice_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_grpc_users_userservice_proto_goTypes = []any{
 	(*User)(nil),             // 0: grpc_users.User
 	(*Empty)(nil),            // 1: grpc_users.Empty
 	(*UserId)(nil),           // 2: grpc_users.UserId
 	(*GetUsersResponse)(nil), // 3: grpc_users.GetUsersResponse
+	(*SyncUsersRequest)(nil), // 4: grpc_users.SyncUsersRequest
+	(*SyncUsersReport)(nil),  // 5: grpc_users.SyncUsersReport
 }
 var file_grpc_users_userservice_proto_depIdxs = []int32{
 	0, // 0: grpc_users.GetUsersResponse.users:type_name -> grpc_users.User
-	1, // 1: grpc_users.UserService.GetAllUsers:input_type -> grpc_users.Empty
-	0, // 2: grpc_users.UserService.UpdateUser:input_type -> grpc_users.User
-	0, // 3: grpc_users.UserService.AddUser:input_type -> grpc_users.User
-	2, // 4: grpc_users.UserService.DeleteUser:input_type -> grpc_users.UserId
-	3, // 5: grpc_users.UserService.GetAllUsers:output_type -> grpc_users.GetUsersResponse
-	1, // 6: grpc_users.UserService.UpdateUser:output_type -> grpc_users.Empty
-	1, // 7: grpc_users.UserService.AddUser:output_type -> grpc_users.Empty
-	1, // 8: grpc_users.UserService.DeleteUser:output_type -> grpc_users.Empty
-	5, // [5:9] is the sub-list for method output_type
-	1, // [1:5] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0, // 1: grpc_users.SyncUsersRequest.users:type_name -> grpc_users.User
+	1, // 2: grpc_users.UserService.GetAllUsers:input_type -> grpc_users.Empty
+	0, // 3: grpc_users.UserService.UpdateUser:input_type -> grpc_users.User
+	0, // 4: grpc_users.UserService.AddUser:input_type -> grpc_users.User
+	2, // 5: grpc_users.UserService.DeleteUser:input_type -> grpc_users.UserId
+	4, // 6: grpc_users.UserService.SyncUsers:input_type -> grpc_users.SyncUsersRequest
+	3, // 7: grpc_users.UserService.GetAllUsers:output_type -> grpc_users.GetUsersResponse
+	1, // 8: grpc_users.UserService.UpdateUser:output_type -> grpc_users.Empty
+	1, // 9: grpc_users.UserService.AddUser:output_type -> grpc_users.Empty
+	1, // 10: grpc_users.UserService.DeleteUser:output_type -> grpc_users.Empty
+	5, // 11: grpc_users.UserService.SyncUsers:output_type -> grpc_users.SyncUsersReport
+	7, // [7:12] is the sub-list for method output_type
+	2, // [2:7] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_grpc_users_userservice_proto_init() }
@@ -315,7 +476,7 @@ func file_grpc_users_userservice_proto_init() {
 		return
 	}
 	if !protoimpl.UnsafeEnabled {
-		file_grpc_users_userservice_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+		file_grpc_users_userservice_proto_msgTypes[0].Exporter = func(v any, i int) any {
 			switch v := v.(*User); i {
 			case 0:
 				return &v.state
@@ -327,7 +488,7 @@ func file_grpc_users_userservice_proto_init() {
 				return nil
 			}
 		}
-		file_grpc_users_userservice_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+		file_grpc_users_userservice_proto_msgTypes[1].Exporter = func(v any, i int) any {
 			switch v := v.(*Empty); i {
 			case 0:
 				return &v.state
@@ -339,7 +500,7 @@ func file_grpc_users_userservice_proto_init() {
 				return nil
 			}
 		}
-		file_grpc_users_userservice_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+		file_grpc_users_userservice_proto_msgTypes[2].Exporter = func(v any, i int) any {
 			switch v := v.(*UserId); i {
 			case 0:
 				return &v.state
@@ -351,7 +512,7 @@ func file_grpc_users_userservice_proto_init() {
 				return nil
 			}
 		}
-		file_grpc_users_userservice_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+		file_grpc_users_userservice_proto_msgTypes[3].Exporter = func(v any, i int) any {
 			switch v := v.(*GetUsersResponse); i {
 			case 0:
 				return &v.state
@@ -363,6 +524,30 @@ func file_grpc_users_userservice_proto_init() {
 				return nil
 			}
 		}
+		file_grpc_users_userservice_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*SyncUsersRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_grpc_users_userservice_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*SyncUsersReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -370,7 +555,7 @@ func file_grpc_users_userservice_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_grpc_users_userservice_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -26,6 +26,7 @@ type UserServiceClient interface {
 	UpdateUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*Empty, error)
 	AddUser(ctx context.Context, in *User, opts ...grpc.CallOption) (*Empty, error)
 	DeleteUser(ctx context.Context, in *UserId, opts ...grpc.CallOption) (*Empty, error)
+	SyncUsers(ctx context.Context, in *SyncUsersRequest, opts ...grpc.CallOption) (*SyncUsersReport, error)
 }
 
 type userServiceClient struct {
@@ -72,6 +73,15 @@ func (c *userServiceClient) DeleteUser(ctx context.Context, in *UserId, opts ...
 	return out, nil
 }
 
+func (c *userServiceClient) SyncUsers(ctx context.Context, in *SyncUsersRequest, opts ...grpc.CallOption) (*SyncUsersReport, error) {
+	out := new(SyncUsersReport)
+	err := c.cc.Invoke(ctx, "/grpc_users.UserService/SyncUsers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserServiceServer is the server API for UserService service.
 // All implementations must embed UnimplementedUserServiceServer
 // for forward compatibility
@@ -80,6 +90,7 @@ type UserServiceServer interface {
 	UpdateUser(context.Context, *User) (*Empty, error)
 	AddUser(context.Context, *User) (*Empty, error)
 	DeleteUser(context.Context, *UserId) (*Empty, error)
+	SyncUsers(context.Context, *SyncUsersRequest) (*SyncUsersReport, error)
 	mustEmbedUnimplementedUserServiceServer()
 }
 
@@ -99,6 +110,9 @@ func (UnimplementedUserServiceServer) AddUser(context.Context, *User) (*Empty, e
 func (UnimplementedUserServiceServer) DeleteUser(context.Context, *UserId) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUser not implemented")
 }
+func (UnimplementedUserServiceServer) SyncUsers(context.Context, *SyncUsersRequest) (*SyncUsersReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncUsers not implemented")
+}
 func (UnimplementedUserServiceServer) mustEmbedUnimplementedUserServiceServer() {}
 
 // UnsafeUserServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -184,6 +198,24 @@ func _UserService_DeleteUser_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserService_SyncUsers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncUsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserServiceServer).SyncUsers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_users.UserService/SyncUsers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserServiceServer).SyncUsers(ctx, req.(*SyncUsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserService_ServiceDesc is the grpc.ServiceDesc for UserService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -207,6 +239,10 @@ var UserService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteUser",
 			Handler:    _UserService_DeleteUser_Handler,
 		},
+		{
+			MethodName: "SyncUsers",
+			Handler:    _UserService_SyncUsers_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "grpc_users/userservice.proto",
@@ -2,9 +2,11 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type AccountingService interface {
@@ -12,24 +14,33 @@ type AccountingService interface {
 	ReadAccountOptionsFromBook(string) ([]model.AccountOptionDTO, model.TokyError)
 	ReadBookings(string) ([]model.BookingDTO, model.TokyError)
 	CreateAccount(bookID string, account model.AccountOptionDTO) model.TokyError
-	UpdateAccount(accountID string, account model.AccountOptionDTO) model.TokyError
-	DeleteAccount(accountID string) model.TokyError
-	CreateBooking(booking model.BookingDTO) model.TokyError
-	UpdateBooking(bookingID string, booking model.BookingDTO) model.TokyError
-	DeleteBooking(bookingID string) model.TokyError
-	ReadClosingStatements(bookID string) (model.ClosingSheetStatements, model.TokyError)
+	UpdateAccount(bookID, accountID string, account model.AccountOptionDTO) model.TokyError
+	DeleteAccount(bookID, accountID string) model.TokyError
+	CreateBooking(bookID string, booking model.BookingDTO) model.TokyError
+	UpdateBooking(bookID, bookingID string, booking model.BookingDTO) model.TokyError
+	DeleteBooking(bookID, bookingID string) model.TokyError
+	ApproveBooking(bookID, bookingID, userID string) model.TokyError
+	ReadClosingStatements(bookID string, costCenter string) (model.ClosingSheetStatements, model.TokyError)
+	ReadIncomeStatementsByCostCenter(bookID string) ([]model.CostCenterIncomeStatement, model.TokyError)
+	ReadAccruals(bookID string, onlyPending bool) ([]model.AccrualDTO, model.TokyError)
+	CreateInterBookTransfer(bookID string, transfer model.InterBookTransferDTO) (model.InterBookTransferResultDTO, model.TokyError)
+	ReadClearingAccounts(bookID string) ([]model.ClearingAccountDTO, model.TokyError)
+	SaveClearingAccount(bookID, counterpartBookID string, clearingAccount model.ClearingAccountDTO) model.TokyError
+	ReadLinkedBookIdOfBooking(bookID, bookingID string) (string, model.TokyError)
 }
 
 // BookRealmHandler implementaion of Handler
 type accountingHandlerImpl struct {
 	AccountingService AccountingService
 	UserService       userService
+	authorizer        bookAuthorizer
 }
 
-func CreateAccountingHandler(accountingService AccountingService, userService userService) *accountingHandlerImpl {
+func CreateAccountingHandler(accountingService AccountingService, userService userService, authorizer bookAuthorizer) *accountingHandlerImpl {
 	return &accountingHandlerImpl{
 		AccountingService: accountingService,
 		UserService:       userService,
+		authorizer:        authorizer,
 	}
 }
 
@@ -68,7 +79,8 @@ func (h *accountingHandlerImpl) ReadAccountOptions(w http.ResponseWriter, r *htt
 
 func (h *accountingHandlerImpl) ReadClosingStatements(w http.ResponseWriter, r *http.Request) {
 	bookID := r.PathValue("bookID")
-	ClosingSheetStatements, err := h.AccountingService.ReadClosingStatements(bookID)
+	costCenter := r.URL.Query().Get("costCenter")
+	ClosingSheetStatements, err := h.AccountingService.ReadClosingStatements(bookID, costCenter)
 	if model.IsExisting(err) {
 		handleError(err, w)
 		return
@@ -82,6 +94,22 @@ func (h *accountingHandlerImpl) ReadClosingStatements(w http.ResponseWriter, r *
 	w.Write(js)
 }
 
+func (h *accountingHandlerImpl) ReadIncomeStatementsByCostCenter(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	incomeStatements, err := h.AccountingService.ReadIncomeStatementsByCostCenter(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	js, marshalError := json.Marshal(incomeStatements)
+	if marshalError != nil {
+		http.Error(w, marshalError.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
 func (h *accountingHandlerImpl) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	var account model.AccountOptionDTO
 	bookID := r.PathValue("bookID")
@@ -102,6 +130,7 @@ func (h *accountingHandlerImpl) CreateAccount(w http.ResponseWriter, r *http.Req
 
 func (h *accountingHandlerImpl) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 	var account model.AccountOptionDTO
+	bookID := r.PathValue("bookID")
 	accountID := r.PathValue("accountID")
 
 	decoderError := json.NewDecoder(r.Body).Decode(&account)
@@ -110,7 +139,7 @@ func (h *accountingHandlerImpl) UpdateAccount(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	accountCreationError := h.AccountingService.UpdateAccount(accountID, account)
+	accountCreationError := h.AccountingService.UpdateAccount(bookID, accountID, account)
 	if model.IsExisting(accountCreationError) {
 		handleError(accountCreationError, w)
 		return
@@ -118,8 +147,9 @@ func (h *accountingHandlerImpl) UpdateAccount(w http.ResponseWriter, r *http.Req
 	w.WriteHeader(http.StatusCreated)
 }
 func (h *accountingHandlerImpl) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
 	accountID := r.PathValue("accountID")
-	accountDeletionError := h.AccountingService.DeleteAccount(accountID)
+	accountDeletionError := h.AccountingService.DeleteAccount(bookID, accountID)
 	if model.IsExisting(accountDeletionError) {
 		handleError(accountDeletionError, w)
 		return
@@ -144,6 +174,7 @@ func (h *accountingHandlerImpl) ReadBookings(w http.ResponseWriter, r *http.Requ
 }
 func (h *accountingHandlerImpl) CreateBooking(w http.ResponseWriter, r *http.Request) {
 	var booking model.BookingDTO
+	bookID := r.PathValue("bookID")
 
 	err := json.NewDecoder(r.Body).Decode(&booking)
 	if err != nil {
@@ -151,7 +182,7 @@ func (h *accountingHandlerImpl) CreateBooking(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	bookingCreationError := h.AccountingService.CreateBooking(booking)
+	bookingCreationError := h.AccountingService.CreateBooking(bookID, booking)
 	if model.IsExisting(bookingCreationError) {
 		handleError(bookingCreationError, w)
 		return
@@ -162,14 +193,18 @@ func (h *accountingHandlerImpl) CreateBooking(w http.ResponseWriter, r *http.Req
 
 func (h *accountingHandlerImpl) UpdateBooking(w http.ResponseWriter, r *http.Request) {
 	var booking model.BookingDTO
-	bookingId := r.PathValue("bookID")
+	bookID := r.PathValue("bookID")
+	bookingID := r.PathValue("bookingID")
 
 	err := json.NewDecoder(r.Body).Decode(&booking)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	bookingUpdateError := h.AccountingService.UpdateBooking(bookingId, booking)
+	if !h.isPermittedInLinkedBook(w, r, bookID, bookingID) {
+		return
+	}
+	bookingUpdateError := h.AccountingService.UpdateBooking(bookID, bookingID, booking)
 	if model.IsExisting(bookingUpdateError) {
 		handleError(bookingUpdateError, w)
 		return
@@ -178,14 +213,116 @@ func (h *accountingHandlerImpl) UpdateBooking(w http.ResponseWriter, r *http.Req
 }
 
 func (h *accountingHandlerImpl) DeleteBooking(w http.ResponseWriter, r *http.Request) {
-	bookingId := r.PathValue("bookID")
-	bookingDeletionError := h.AccountingService.DeleteBooking(bookingId)
+	bookID := r.PathValue("bookID")
+	bookingID := r.PathValue("bookingID")
+	if !h.isPermittedInLinkedBook(w, r, bookID, bookingID) {
+		return
+	}
+	bookingDeletionError := h.AccountingService.DeleteBooking(bookID, bookingID)
 	if model.IsExisting(bookingDeletionError) {
 		handleError(bookingDeletionError, w)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+func (h *accountingHandlerImpl) ApproveBooking(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	approvalError := h.AccountingService.ApproveBooking(r.PathValue("bookID"), r.PathValue("bookingID"), userID)
+	if model.IsExisting(approvalError) {
+		handleError(approvalError, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 func (h *accountingHandlerImpl) SaveAccountOption(w http.ResponseWriter, r *http.Request) {
 
 }
+
+func (h *accountingHandlerImpl) ReadAccruals(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	accruals, err := h.AccountingService.ReadAccruals(bookID, r.URL.Query().Get("onlyPending") == "true")
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(accruals, w)
+}
+
+// CreateInterBookTransfer requires the permission to book in the target book in addition to the book of the path
+func (h *accountingHandlerImpl) CreateInterBookTransfer(w http.ResponseWriter, r *http.Request) {
+	var transfer model.InterBookTransferDTO
+	bookID := r.PathValue("bookID")
+	if decodeErr := json.NewDecoder(r.Body).Decode(&transfer); decodeErr != nil {
+		http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.isPermittedToBook(w, r, transfer.TargetBookID) {
+		return
+	}
+	result, err := h.AccountingService.CreateInterBookTransfer(bookID, transfer)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *accountingHandlerImpl) ReadClearingAccounts(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	clearingAccounts, err := h.AccountingService.ReadClearingAccounts(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(clearingAccounts, w)
+}
+
+func (h *accountingHandlerImpl) SaveClearingAccount(w http.ResponseWriter, r *http.Request) {
+	var clearingAccount model.ClearingAccountDTO
+	bookID := r.PathValue("bookID")
+	counterpartBookID := r.PathValue("counterpartBookID")
+	if decodeErr := json.NewDecoder(r.Body).Decode(&clearingAccount); decodeErr != nil {
+		http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+		return
+	}
+	saveErr := h.AccountingService.SaveClearingAccount(bookID, counterpartBookID, clearingAccount)
+	if model.IsExisting(saveErr) {
+		handleError(saveErr, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// isPermittedInLinkedBook checks the permission to book in the book of the other side of a transfer,
+// as changes of a linked booking are applied to both books
+func (h *accountingHandlerImpl) isPermittedInLinkedBook(w http.ResponseWriter, r *http.Request, bookID, bookingID string) bool {
+	linkedBookID, err := h.AccountingService.ReadLinkedBookIdOfBooking(bookID, bookingID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return false
+	}
+	if linkedBookID == "" {
+		return true
+	}
+	return h.isPermittedToBook(w, r, linkedBookID)
+}
+
+func (h *accountingHandlerImpl) isPermittedToBook(w http.ResponseWriter, r *http.Request, bookID string) bool {
+	permitted, err := h.authorizer.Authorize(r.Context(), bookID, types.PermissionBook)
+	if model.IsExisting(err) && err.IsTechnicalError() {
+		handleError(err, w)
+		return false
+	}
+	if !permitted || model.IsExisting(err) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(fmt.Sprintf("User is missing the Permission %s for Book %s", types.PermissionBook, bookID)))
+		return false
+	}
+	return true
+}
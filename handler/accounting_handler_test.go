@@ -1,16 +1,56 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/repository"
+	"github.com/toky03/toky-finance-accounting-service/service"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
+func TestLinkedBookingRequiresPermissionInLinkedBook(t *testing.T) {
+	tests := []struct {
+		name       string
+		grants     map[string]types.Permission
+		wantStatus int
+	}{
+		{"permitted in linked book", map[string]types.Permission{"2": types.PermissionBook}, http.StatusNoContent},
+		{"only read permission in linked book", map[string]types.Permission{"2": types.PermissionRead}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockUserService := CreateMockUserService()
+			mockAccountingService := CreateMockAccountingService()
+			mockAccountingService.bookings["default"] = []model.BookingDTO{{BookingID: "10"}}
+			mockAccountingService.linkedBooks["10"] = "2"
+			handler := CreateAccountingHandler(&mockAccountingService, &mockUserService, &mockBookAuthorizer{grants: tt.grants})
+
+			req := httptest.NewRequest(http.MethodDelete, "/book/1/booking/10", nil)
+			req.SetPathValue("bookID", "1")
+			req.SetPathValue("bookingID", "10")
+			rr := httptest.NewRecorder()
+			handler.DeleteBooking(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if deleted := len(mockAccountingService.bookings["default"]) == 0; deleted != (tt.wantStatus == http.StatusNoContent) {
+				t.Errorf("booking deleted = %v with status %d", deleted, rr.Code)
+			}
+		})
+	}
+}
+
 func TestReadAccounts(t *testing.T) {
 	type args struct {
 		bookID          string
@@ -65,7 +105,7 @@ func TestReadAccounts(t *testing.T) {
 
 			mockAccountingService.accountTables["book2"] = []model.AccountTableDTO{otherAccount}
 
-			handler := CreateAccountingHandler(&mockAccountingService, &mockUserService)
+			handler := CreateAccountingHandler(&mockAccountingService, &mockUserService, &mockBookAuthorizer{})
 
 			req, err := http.NewRequest("GET", "api/accounts/", nil)
 			req.SetPathValue("bookID", tt.args.bookID)
@@ -254,3 +294,104 @@ func TestReadAccounts(t *testing.T) {
 // 	handler.DeleteBooking(rr, req)
 
 // }
+
+// TestCrossBookTampering runs the requests through the permission middleware, the accounting service and the
+// sqlite repository. The user may book in both books, so only the book check of the service refuses
+// accounts and bookings of the other book.
+func TestCrossBookTampering(t *testing.T) {
+	r, err := repository.CreateRepositoryWithConnection(openSqliteDatabase(t))
+	if err != nil {
+		t.Fatalf("could not migrate test database: %v", err)
+	}
+	owner := model.ApplicationUserEntity{ID: "user", UserName: "user"}
+	books := []model.BookRealmEntity{{BookName: "Verein", Owner: owner}, {BookName: "Haushalt", OwnerID: owner.ID}}
+	accounts := []model.AccountTableEntity{}
+	bookings := []model.BookingEntity{}
+	for i := range books {
+		if err := r.PersistBookRealm(&books[i]); model.IsExisting(err) {
+			t.Fatalf("could not create book: %v", err)
+		}
+		soll := model.AccountTableEntity{BookRealmEntityID: books[i].ID, AccountName: "Kasse", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive}
+		haben := model.AccountTableEntity{BookRealmEntityID: books[i].ID, AccountName: "Ertrag", Type: types.AccountTypeIncome, Category: types.AccountCategoryGain}
+		for _, account := range []*model.AccountTableEntity{&soll, &haben} {
+			if err := r.CreateAccount(account); model.IsExisting(err) {
+				t.Fatalf("could not create account: %v", err)
+			}
+		}
+		booking := model.BookingEntity{Date: "2024-01-01", SollBookingAccount: soll, HabenBookingAccount: haben, Ammount: "100.00"}
+		if err := r.PersistBooking(&booking); model.IsExisting(err) {
+			t.Fatalf("could not create booking: %v", err)
+		}
+		accounts = append(accounts, soll, haben)
+		bookings = append(bookings, booking)
+	}
+	userService := CreateMockUserService()
+	for _, book := range books {
+		for _, permission := range []types.Permission{types.PermissionBook, types.PermissionManageAccounts} {
+			userService.permissionMap["user"+bookingutils.UintToString(book.ID)+string(permission)] = true
+		}
+	}
+	accountingService := service.CreateAccountingService(r)
+	authentication := &authenticationHandlerImpl{userService: &userService, accountingService: accountingService}
+	accounting := CreateAccountingHandler(accountingService, &userService, &mockBookAuthorizer{})
+	mux := http.NewServeMux()
+	for pattern, route := range map[string]struct {
+		handler    http.HandlerFunc
+		permission types.Permission
+	}{
+		"POST /book/{bookID}/booking":               {accounting.CreateBooking, types.PermissionBook},
+		"PUT /book/{bookID}/booking/{bookingID}":    {accounting.UpdateBooking, types.PermissionBook},
+		"DELETE /book/{bookID}/booking/{bookingID}": {accounting.DeleteBooking, types.PermissionBook},
+		"PUT /book/{bookID}/account/{accountID}":    {accounting.UpdateAccount, types.PermissionManageAccounts},
+		"DELETE /book/{bookID}/account/{accountID}": {accounting.DeleteAccount, types.PermissionManageAccounts},
+	} {
+		mux.Handle(pattern, authentication.RequirePermission(route.permission)(route.handler))
+	}
+
+	id := bookingutils.UintToString
+	ownBooking := func(soll, haben uint) string {
+		return fmt.Sprintf(`{"sollAccount":"%s","habenAccount":"%s","ammount":"50.00","date":"2024-02-01"}`, id(soll), id(haben))
+	}
+	account := `{"accountName":"Bank","type":"inventory","category":"active","subCategory":"workingCapital"}`
+	firstBook, otherBooking, otherAccount := id(books[0].ID), id(bookings[1].ID), id(accounts[2].ID)
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+	}{
+		{"create booking with soll account of other book", http.MethodPost, "/book/" + firstBook + "/booking", ownBooking(accounts[2].ID, accounts[1].ID)},
+		{"create booking with haben account of other book", http.MethodPost, "/book/" + firstBook + "/booking", ownBooking(accounts[0].ID, accounts[3].ID)},
+		{"update booking of other book", http.MethodPut, "/book/" + firstBook + "/booking/" + otherBooking, ownBooking(accounts[0].ID, accounts[1].ID)},
+		{"update booking moving account to other book", http.MethodPut, "/book/" + firstBook + "/booking/" + id(bookings[0].ID), ownBooking(accounts[0].ID, accounts[3].ID)},
+		{"delete booking of other book", http.MethodDelete, "/book/" + firstBook + "/booking/" + otherBooking, ""},
+		{"update account of other book", http.MethodPut, "/book/" + firstBook + "/account/" + otherAccount, account},
+		{"delete account of other book", http.MethodDelete, "/book/" + firstBook + "/account/" + otherAccount, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			request = request.WithContext(context.WithValue(request.Context(), USER_ID, "user"))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, request)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d (%s), want %d", w.Code, w.Body.String(), http.StatusNotFound)
+			}
+		})
+	}
+
+	otherBookings, _ := r.FindBookingsByBookId(books[1].ID)
+	otherAccounts, _ := r.FindAccountsByBookId(books[1].ID)
+	ownBookings, _ := r.FindBookingsByBookId(books[0].ID)
+	if len(otherBookings) != 1 || otherBookings[0].Ammount != "100.00" || len(otherAccounts) != 2 {
+		t.Errorf("the other book was changed: %+v %+v", otherBookings, otherAccounts)
+	}
+	for _, otherAccount := range otherAccounts {
+		if otherAccount.AccountName == "Bank" {
+			t.Errorf("the account of the other book was renamed: %+v", otherAccount)
+		}
+	}
+	if len(ownBookings) != 1 || ownBookings[0].HabenBookingAccountID != accounts[1].ID {
+		t.Errorf("no booking of the book may be created or moved to the other book: %+v", ownBookings)
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type ApiTokenService interface {
+	ReadApiTokens(userID string) ([]model.ApiTokenDTO, model.TokyError)
+	CreateApiToken(userID string, token model.ApiTokenDTO) (model.ApiTokenDTO, model.TokyError)
+	RevokeApiToken(userID, tokenID string) model.TokyError
+}
+
+type apiTokenHandlerImpl struct {
+	apiTokenService ApiTokenService
+}
+
+func CreateApiTokenHandler(apiTokenService ApiTokenService) *apiTokenHandlerImpl {
+	return &apiTokenHandlerImpl{
+		apiTokenService: apiTokenService,
+	}
+}
+
+func (h *apiTokenHandlerImpl) ReadApiTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := h.apiTokenService.ReadApiTokens(userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(tokens, w)
+}
+
+// CreateApiToken answers with the plain token, which is only ever shown in this response
+func (h *apiTokenHandlerImpl) CreateApiToken(w http.ResponseWriter, r *http.Request) {
+	var token model.ApiTokenDTO
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	decoderError := json.NewDecoder(r.Body).Decode(&token)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createdToken, createError := h.apiTokenService.CreateApiToken(userID, token)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdToken)
+}
+
+func (h *apiTokenHandlerImpl) RevokeApiToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.apiTokenService.RevokeApiToken(userID, r.PathValue("tokenID"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
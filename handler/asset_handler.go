@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type AssetService interface {
+	ReadFixedAssets(bookID string) ([]model.FixedAssetDTO, model.TokyError)
+	CreateFixedAsset(bookID string, asset model.FixedAssetDTO) model.TokyError
+	RunDepreciation(bookID string, year int) (model.DepreciationRunDTO, model.TokyError)
+	ReadAssetSchedule(bookID, year string) (model.AssetScheduleDTO, model.TokyError)
+}
+
+type assetHandlerImpl struct {
+	assetService AssetService
+}
+
+func CreateAssetHandler(assetService AssetService) *assetHandlerImpl {
+	return &assetHandlerImpl{
+		assetService: assetService,
+	}
+}
+
+func (h *assetHandlerImpl) ReadFixedAssets(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	assets, err := h.assetService.ReadFixedAssets(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(assets, w)
+}
+
+func (h *assetHandlerImpl) CreateFixedAsset(w http.ResponseWriter, r *http.Request) {
+	var asset model.FixedAssetDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&asset)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createError := h.assetService.CreateFixedAsset(bookID, asset)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *assetHandlerImpl) RunDepreciation(w http.ResponseWriter, r *http.Request) {
+	var request model.DepreciationRunDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&request)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := h.assetService.RunDepreciation(bookID, request.Year)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(run, w)
+}
+
+func (h *assetHandlerImpl) ReadAssetSchedule(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	schedule, err := h.assetService.ReadAssetSchedule(bookID, r.URL.Query().Get("year"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(schedule, w)
+}
@@ -6,18 +6,19 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
-	jwtauthhandler "github.com/toky03/jwt-auth-handler"
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/service"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type customUserIdKey string
 
 const (
-	USER_ID customUserIdKey = "x-user-id"
+	USER_ID     customUserIdKey = "x-user-id"
+	API_TOKEN   customUserIdKey = "x-api-token"
+	CLAIM_ROLES customUserIdKey = "x-claim-roles"
 )
 
 type accountingService interface {
@@ -25,55 +26,47 @@ type accountingService interface {
 	ReadBookIdFromBooking(bookingId string) (string, model.TokyError)
 }
 
-type authenticationHandlerImpl struct {
-	userService        userService
-	accountingService  accountingService
-	jwtAuthService     jwtauthhandler.JwtHandler
-	openIDBaseUrl      string
-	openIDClientSecret string
-	openIDClientID     string
+type apiTokenAuthenticator interface {
+	AuthenticateApiToken(token string) (model.ApiTokenDTO, model.TokyError)
 }
 
-func CreateAuthenticationHandler(accountingService accountingService, userService userService) *authenticationHandlerImpl {
-	openIDProvider := os.Getenv("OPENID_JWKS_URL")
-	if openIDProvider == "" {
-		panic("OPENID_JWKS URL must be provided")
-	}
-	var externalOpenIDProvider string
-	if os.Getenv("OPENID_JWKS_EXTERNAL_URL") != "" {
-		externalOpenIDProvider = os.Getenv("OPENID_JWKS_EXTERNAL_URL")
-	} else {
-		externalOpenIDProvider = openIDProvider
-	}
-	openIDClientSecret := os.Getenv("ID_PROVIDER_CLIENT_SECRET")
-	if openIDClientSecret == "" {
-		panic("ID_PROVIDER_CLIENT_SECRET URL must be provided")
-	}
-	openIDClientId := os.Getenv("ID_PROVIDER_CLIENT_ID")
-	if openIDClientId == "" {
-		panic("ID_PROVIDER_CLIENT_ID URL must be provided")
-	}
+type claimMapper interface {
+	MapClaims(claims map[string]interface{}) model.ClaimRolesDTO
+	GrantsPermission(claimRoles model.ClaimRolesDTO, bookID string, permission types.Permission) bool
+}
 
-	jwtHandler, err := jwtauthhandler.CreateJwtHandler(openIDProvider + "/certs")
-	if err != nil {
-		panic("jwt Handler could not have been initialized")
-	}
+type sessionTokenReader interface {
+	Enabled() bool
+	SessionToken(r *http.Request) (string, bool)
+}
+
+type authenticationHandlerImpl struct {
+	userService       userService
+	accountingService accountingService
+	apiTokenService   apiTokenAuthenticator
+	claimMapper       claimMapper
+	authenticator     Authenticator
+	sessions          sessionTokenReader
+}
+
+func CreateAuthenticationHandler(accountingService accountingService, userService userService, apiTokenService apiTokenAuthenticator, claimMapper claimMapper, authenticator Authenticator, sessions sessionTokenReader) *authenticationHandlerImpl {
 	return &authenticationHandlerImpl{
-		userService:        userService,
-		accountingService:  accountingService,
-		jwtAuthService:     jwtHandler,
-		openIDBaseUrl:      externalOpenIDProvider,
-		openIDClientSecret: openIDClientSecret,
-		openIDClientID:     openIDClientId,
+		userService:       userService,
+		accountingService: accountingService,
+		apiTokenService:   apiTokenService,
+		claimMapper:       claimMapper,
+		authenticator:     authenticator,
+		sessions:          sessions,
 	}
 }
 
 func (h *authenticationHandlerImpl) JwksUrl(w http.ResponseWriter, r *http.Request) {
 
-	loginInformation := model.LoginInformationDto{
-		AuthUrl:      h.openIDBaseUrl + "/token",
-		ClientSecret: h.openIDClientSecret,
-		ClientId:     h.openIDClientID,
+	loginInformation := h.authenticator.LoginInformation()
+	if h.sessions.Enabled() {
+		loginInformation.Flow = "session"
+		loginInformation.LoginUrl = "/auth/login"
+		loginInformation.LogoutUrl = "/auth/logout"
 	}
 
 	js, marshalError := json.Marshal(loginInformation)
@@ -85,121 +78,207 @@ func (h *authenticationHandlerImpl) JwksUrl(w http.ResponseWriter, r *http.Reque
 	w.Write(js)
 }
 
-func (h *authenticationHandlerImpl) HasWritePermissions(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userId, ok := r.Context().Value(USER_ID).(string)
-		if !ok {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Missing " + string(USER_ID)))
-		}
-		var err model.TokyError
-		bookID := r.PathValue("bookID")
-		accountID := r.PathValue("accountID")
-		bookingID := r.PathValue("bookingID")
-		if accountID != "" && bookingID == "" {
-			bookID, err = h.accountingService.ReadBookIdFromAccount(accountID)
-			if model.IsExisting(err) {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("Could not Read Book Id from account Id %s"))
-				return
+// RequirePermission only passes requests of users whose role in the addressed book grants the permission
+func (h *authenticationHandlerImpl) RequirePermission(permission types.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.checkPermission(w, r, h.isPermitted, permission, http.StatusForbidden,
+				fmt.Sprintf("User is missing the Permission %s for this Book", permission)) {
+				next.ServeHTTP(w, r)
 			}
+		})
+	}
+}
 
-		} else if bookingID != "" {
-			bookID, err = h.accountingService.ReadBookIdFromBooking(bookingID)
-			if model.IsExisting(err) {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("Could not Read Book Id from booking Id"))
-				return
+// RequireArchivedBookPermission is RequirePermission for the routes which also work on archived books, like restoring them
+func (h *authenticationHandlerImpl) RequireArchivedBookPermission(permission types.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if h.checkPermission(w, r, h.hasPermission, permission, http.StatusForbidden,
+				fmt.Sprintf("User is missing the Permission %s for this Book", permission)) {
+				next.ServeHTTP(w, r)
 			}
-		}
-		isPermitted, err := h.userService.HasWriteAccessFromBook(userId, bookID)
-		if model.IsExisting(err) {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Could not Read Write Permissions"))
-			return
-		}
-		if isPermitted {
-			next.ServeHTTP(w, r)
-			return
-		} else {
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte("User is not allowed to write to this Book"))
+		})
+	}
+}
+
+// HasReadPermissions answers with 404 instead of 403 so that the existence of foreign books is not revealed
+func (h *authenticationHandlerImpl) HasReadPermissions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.checkPermission(w, r, h.isPermitted, types.PermissionRead, http.StatusNotFound, "Book not found") {
 			next.ServeHTTP(w, r)
-			return
 		}
 	})
 }
 
-func (h *authenticationHandlerImpl) IsOwner(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userId, ok := r.Context().Value(USER_ID).(string)
-		if !ok {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Missing " + string(USER_ID)))
+type permissionCheck func(ctx context.Context, userId, bookID string, permission types.Permission) (bool, model.TokyError)
+
+func (h *authenticationHandlerImpl) checkPermission(w http.ResponseWriter, r *http.Request, permitted permissionCheck, permission types.Permission, deniedStatus int, deniedMessage string) bool {
+	userId, ok := r.Context().Value(USER_ID).(string)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Missing " + string(USER_ID)))
+		return false
+	}
+	bookID, err := h.readBookID(r)
+	if model.IsExisting(err) && err.IsTechnicalError() {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.ErrorMessage()))
+		return false
+	}
+	if model.IsExisting(err) {
+		w.WriteHeader(deniedStatus)
+		w.Write([]byte(deniedMessage))
+		return false
+	}
+	isPermitted, err := permitted(r.Context(), userId, bookID, permission)
+	if model.IsExisting(err) && err.IsTechnicalError() {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Could not Read Permissions"))
+		return false
+	}
+	if !isPermitted || model.IsExisting(err) {
+		w.WriteHeader(deniedStatus)
+		w.Write([]byte(deniedMessage))
+		return false
+	}
+	return true
+}
+
+// isPermitted only grants reading archived books and checks everything else with hasPermission
+func (h *authenticationHandlerImpl) isPermitted(ctx context.Context, userId, bookID string, permission types.Permission) (bool, model.TokyError) {
+	if permission != types.PermissionRead {
+		isArchived, err := h.userService.IsBookArchived(bookID)
+		if model.IsExisting(err) || isArchived {
+			return false, err
 		}
-		bookID := r.PathValue("bookID")
-		isPermitted, err := h.userService.IsOwnerOfBook(userId, bookID)
-		if model.IsExisting(err) {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Could not Read Write Permissions"))
-			return
+	}
+	return h.hasPermission(ctx, userId, bookID, permission)
+}
+
+// hasPermission applies the restrictions of api tokens before the mapped claim roles and the roles stored in the book
+func (h *authenticationHandlerImpl) hasPermission(ctx context.Context, userId, bookID string, permission types.Permission) (bool, model.TokyError) {
+	if apiToken, isApiToken := ctx.Value(API_TOKEN).(model.ApiTokenDTO); isApiToken && !apiToken.Grants(bookID, permission) {
+		return false, nil
+	}
+	if claimRoles, hasClaimRoles := ctx.Value(CLAIM_ROLES).(model.ClaimRolesDTO); hasClaimRoles && h.claimMapper.GrantsPermission(claimRoles, bookID, permission) {
+		return true, nil
+	}
+	return h.userService.HasPermission(userId, bookID, permission)
+}
+
+// readBookID reads the book of the booking or account of the route, business errors mean that they do not exist
+func (h *authenticationHandlerImpl) readBookID(r *http.Request) (string, model.TokyError) {
+	accountID := r.PathValue("accountID")
+	bookingID := r.PathValue("bookingID")
+	if bookingID != "" {
+		bookID, err := h.accountingService.ReadBookIdFromBooking(bookingID)
+		if model.IsExisting(err) && err.IsTechnicalError() {
+			return "", model.CreateTechnicalError("Could not Read Book Id from booking Id", err.Error())
 		}
-		if isPermitted {
-			next.ServeHTTP(w, r)
-			return
-		} else {
-			w.WriteHeader(http.StatusForbidden)
-			w.Write([]byte("User is not allowed to modify this Book"))
-			next.ServeHTTP(w, r)
-			return
+		return bookID, err
+	}
+	if accountID != "" {
+		bookID, err := h.accountingService.ReadBookIdFromAccount(accountID)
+		if model.IsExisting(err) && err.IsTechnicalError() {
+			return "", model.CreateTechnicalError(fmt.Sprintf("Could not Read Book Id from account Id %s", accountID), err.Error())
 		}
-	})
+		return bookID, err
+	}
+	return r.PathValue("bookID"), nil
 }
 
 func (h *authenticationHandlerImpl) AuthenticationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := strings.Split(r.Header.Get("Authorization"), "Bearer ")
+		if r.Header.Get("Authorization") == "" {
+			if sessionToken, ok := h.sessions.SessionToken(r); ok {
+				authHeader = []string{"", sessionToken}
+			}
+		}
 		if len(authHeader) != 2 {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write([]byte("Malformed Token"))
 			return
-		} else {
-			jwtToken := authHeader[1]
-			claims := jwt.MapClaims{}
-			rsaKeys := h.jwtAuthService.ReadPublicKeys()
-			var err error
-			for _, rsaKey := range rsaKeys {
-				token, errParse := jwt.ParseWithClaims(jwtToken, claims, func(token *jwt.Token) (interface{}, error) {
-					if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-						fmt.Printf("signing method not expected\n")
-						return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-					}
-					return &rsaKey, nil
-				})
-				if token.Valid {
-					err = nil
-					break
-				} else {
-					err = errParse
-				}
-			}
-			if err != nil {
-				log.Printf("Error %v \n", err)
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte("Invalid Token"))
-				return
-			}
-			// access := claims["resource_access"]
-			userName := claims["preferred_username"]
-			applicationUser, userServiceErr := h.userService.FindUserByUsername(userName.(string))
-			if model.IsExisting(userServiceErr) {
+		}
+		ctx, err := h.authenticateToken(r.Context(), authHeader[1])
+		if model.IsExisting(err) {
+			if err.IsTechnicalError() {
 				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte(userServiceErr.ErrorMessage()))
-				return
+			} else {
+				w.WriteHeader(http.StatusUnauthorized)
 			}
-			ctx := context.WithValue(r.Context(), USER_ID, applicationUser.UserID)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			w.Write([]byte(err.ErrorMessage()))
+			return
+		}
+		if apiToken, isApiToken := ctx.Value(API_TOKEN).(model.ApiTokenDTO); isApiToken && !h.admitApiToken(apiToken, w, r) {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateToken resolves a bearer token or an api token to a context carrying the user and its grants.
+// Business errors mean that the token is not valid.
+func (h *authenticationHandlerImpl) authenticateToken(ctx context.Context, token string) (context.Context, model.TokyError) {
+	if strings.HasPrefix(token, service.ApiTokenPrefix) {
+		apiToken, err := h.apiTokenService.AuthenticateApiToken(token)
+		if model.IsExisting(err) {
+			return ctx, err
 		}
+		ctx = context.WithValue(ctx, USER_ID, apiToken.UserID)
+		return context.WithValue(ctx, API_TOKEN, apiToken), nil
+	}
+	claims, authErr := h.authenticator.Authenticate(token)
+	if authErr != nil {
+		log.Printf("Error %v \n", authErr)
+		return ctx, model.CreateBusinessError("Invalid Token", authErr)
+	}
+	applicationUser, err := h.readApplicationUser(claims)
+	if model.IsExisting(err) {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, USER_ID, applicationUser.UserID)
+	return context.WithValue(ctx, CLAIM_ROLES, h.claimMapper.MapClaims(claims)), nil
+}
 
+// readApplicationUser provisions the user from the claims of the token.
+// Tokens without sub can only be used by users which are already known by their username.
+// The email is only taken over when the identity provider verified it, as invitations are accepted by email.
+func (h *authenticationHandlerImpl) readApplicationUser(claims map[string]interface{}) (model.ApplicationUserDTO, model.TokyError) {
+	userName, _ := claims["preferred_username"].(string)
+	userID, _ := claims["sub"].(string)
+	if userID == "" {
+		return h.userService.FindUserByUsername(userName)
+	}
+	eMail := ""
+	if verified, _ := claims["email_verified"].(bool); verified {
+		eMail, _ = claims["email"].(string)
+	}
+	firstName, _ := claims["given_name"].(string)
+	lastName, _ := claims["family_name"].(string)
+	return h.userService.ProvisionUser(model.ApplicationUserDTO{
+		UserID:    userID,
+		UserName:  userName,
+		FirstName: firstName,
+		LastName:  lastName,
+		EMail:     eMail,
 	})
 }
+
+// admitApiToken only admits api tokens to routes of the books they are limited to.
+// Tokens with read scope may only be used for reading requests.
+func (h *authenticationHandlerImpl) admitApiToken(apiToken model.ApiTokenDTO, w http.ResponseWriter, r *http.Request) bool {
+	bookID := r.PathValue("bookID")
+	if bookID == "" || !apiToken.Grants(bookID, types.PermissionRead) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Api Token is not valid for this Route"))
+		return false
+	}
+	if apiToken.Scope == types.TokenScopeRead && r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("Api Token is limited to read access"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/repository"
+	"github.com/toky03/toky-finance-accounting-service/service"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPermissionMiddlewares(t *testing.T) {
+	userService := CreateMockUserService()
+	userService.permissionMap["user"+"1"+string(types.PermissionRead)] = true
+	userService.permissionMap["user"+"1"+string(types.PermissionBook)] = true
+	userService.permissionMap["user"+"book-of-7"+string(types.PermissionManageAccounts)] = true
+	userService.permissionMap["user"+"3"+string(types.PermissionRead)] = true
+	userService.permissionMap["user"+"3"+string(types.PermissionBook)] = true
+	userService.permissionMap["user"+"3"+string(types.PermissionAdminister)] = true
+	userService.archivedBooks["3"] = true
+	accountingService := CreateMockAccountingService()
+	h := &authenticationHandlerImpl{userService: &userService, accountingService: &accountingService}
+
+	tests := []struct {
+		name           string
+		middleware     func(http.Handler) http.Handler
+		pattern        string
+		url            string
+		userID         string
+		wantStatus     int
+		wantNextCalled bool
+	}{
+		{"read permitted", h.HasReadPermissions, "/book/{bookID}", "/book/1", "user", http.StatusOK, true},
+		{"read of foreign book is not found", h.HasReadPermissions, "/book/{bookID}", "/book/2", "user", http.StatusNotFound, false},
+		{"missing user", h.HasReadPermissions, "/book/{bookID}", "/book/1", "", http.StatusBadRequest, false},
+		{"book permitted", h.RequirePermission(types.PermissionBook), "/book/{bookID}", "/book/1", "user", http.StatusOK, true},
+		{"administer forbidden", h.RequirePermission(types.PermissionAdminister), "/book/{bookID}", "/book/1", "user", http.StatusForbidden, false},
+		{"book resolved from account", h.RequirePermission(types.PermissionManageAccounts), "/book/{bookID}/account/{accountID}", "/book/1/account/7", "user", http.StatusOK, true},
+		{"read of archived book permitted", h.HasReadPermissions, "/book/{bookID}", "/book/3", "user", http.StatusOK, true},
+		{"archived book is read-only", h.RequirePermission(types.PermissionBook), "/book/{bookID}", "/book/3", "user", http.StatusForbidden, false},
+		{"archived book can be administered", h.RequireArchivedBookPermission(types.PermissionAdminister), "/book/{bookID}", "/book/3", "user", http.StatusOK, true},
+		{"archived book permission is checked", h.RequireArchivedBookPermission(types.PermissionAdminister), "/book/{bookID}", "/book/1", "user", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextCalled := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+			})
+			mux := http.NewServeMux()
+			mux.Handle(tt.pattern, tt.middleware(next))
+			request := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.userID != "" {
+				request = request.WithContext(context.WithValue(request.Context(), USER_ID, tt.userID))
+			}
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, request)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if nextCalled != tt.wantNextCalled {
+				t.Errorf("next called = %v, want %v", nextCalled, tt.wantNextCalled)
+			}
+		})
+	}
+}
+
+// openSqliteDatabase opens a sqlite database of the test for the real repository
+func openSqliteDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "toky.db") + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(0)"
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("could not open test database: %v", err)
+	}
+	return conn
+}
+
+func TestPermissionMiddlewaresWithAccountingService(t *testing.T) {
+	userService := CreateMockUserService()
+	userService.permissionMap["user"+"1"+string(types.PermissionBook)] = true
+	conn := openSqliteDatabase(t)
+	r, err := repository.CreateRepositoryWithConnection(conn)
+	if err != nil {
+		t.Fatalf("could not migrate test database: %v", err)
+	}
+	h := &authenticationHandlerImpl{userService: &userService, accountingService: service.CreateAccountingService(r)}
+	serve := func(pattern, url string) int {
+		mux := http.NewServeMux()
+		mux.Handle(pattern, h.RequirePermission(types.PermissionBook)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+		request := httptest.NewRequest(http.MethodPut, url, nil)
+		request = request.WithContext(context.WithValue(request.Context(), USER_ID, "user"))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, request)
+		return w.Code
+	}
+
+	if status := serve("/book/{bookID}/booking/{bookingID}", "/book/1/booking/99"); status != http.StatusForbidden {
+		t.Errorf("unknown booking status = %d, want %d", status, http.StatusForbidden)
+	}
+	sqlDB, _ := conn.DB()
+	sqlDB.Close()
+	if status := serve("/book/{bookID}/booking/{bookingID}", "/book/1/booking/99"); status != http.StatusInternalServerError {
+		t.Errorf("failing booking lookup status = %d, want %d", status, http.StatusInternalServerError)
+	}
+	if status := serve("/book/{bookID}/account/{accountID}", "/book/1/account/7"); status != http.StatusInternalServerError {
+		t.Errorf("failing account lookup status = %d, want %d", status, http.StatusInternalServerError)
+	}
+}
+
+func TestApiTokenAuthentication(t *testing.T) {
+	userService := CreateMockUserService()
+	userService.permissionMap["user"+"1"+string(types.PermissionRead)] = true
+	userService.permissionMap["user"+"1"+string(types.PermissionBook)] = true
+	userService.permissionMap["user"+"1"+string(types.PermissionAdminister)] = true
+	accountingService := CreateMockAccountingService()
+	apiTokenService := mockApiTokenAuthenticator{tokens: map[string]model.ApiTokenDTO{
+		"tfa_read":  {UserID: "user", Scope: types.TokenScopeRead, BookIDs: []string{"1"}},
+		"tfa_write": {UserID: "user", Scope: types.TokenScopeWrite, BookIDs: []string{"1"}},
+	}}
+	h := &authenticationHandlerImpl{userService: &userService, accountingService: &accountingService, apiTokenService: apiTokenService}
+
+	tests := []struct {
+		name           string
+		method         string
+		middleware     func(http.Handler) http.Handler
+		pattern        string
+		url            string
+		token          string
+		wantStatus     int
+		wantNextCalled bool
+	}{
+		{"read token reads", http.MethodGet, h.HasReadPermissions, "GET /book/{bookID}", "/book/1", "tfa_read", http.StatusOK, true},
+		{"read token can not book", http.MethodPost, h.RequirePermission(types.PermissionBook), "POST /book/{bookID}", "/book/1", "tfa_read", http.StatusForbidden, false},
+		{"write token books", http.MethodPost, h.RequirePermission(types.PermissionBook), "POST /book/{bookID}", "/book/1", "tfa_write", http.StatusOK, true},
+		{"write token can not administer", http.MethodPut, h.RequirePermission(types.PermissionAdminister), "PUT /book/{bookID}", "/book/1", "tfa_write", http.StatusForbidden, false},
+		{"write token can not purge", http.MethodDelete, h.RequireArchivedBookPermission(types.PermissionAdminister), "DELETE /book/{bookID}/purge", "/book/1/purge", "tfa_write", http.StatusForbidden, false},
+		{"book not covered by token", http.MethodGet, h.HasReadPermissions, "GET /book/{bookID}", "/book/2", "tfa_read", http.StatusForbidden, false},
+		{"route without book", http.MethodGet, func(next http.Handler) http.Handler { return next }, "GET /token", "/token", "tfa_write", http.StatusForbidden, false},
+		{"unknown token", http.MethodGet, h.HasReadPermissions, "GET /book/{bookID}", "/book/1", "tfa_unknown", http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextCalled := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+			})
+			mux := http.NewServeMux()
+			mux.Handle(tt.pattern, h.AuthenticationMiddleware(tt.middleware(next)))
+			request := httptest.NewRequest(tt.method, tt.url, nil)
+			request.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, request)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if nextCalled != tt.wantNextCalled {
+				t.Errorf("next called = %v, want %v", nextCalled, tt.wantNextCalled)
+			}
+		})
+	}
+}
+
+func TestClaimRoleMapping(t *testing.T) {
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	userService := CreateMockUserService()
+	userService.existingUsers = []model.ApplicationUserDTO{{UserID: "user", UserName: "user"}}
+	accountingService := CreateMockAccountingService()
+	h := &authenticationHandlerImpl{
+		userService:       &userService,
+		accountingService: &accountingService,
+		authenticator:     CreateOIDCAuthenticator(mockPublicKeyReader{keys: []rsa.PublicKey{signingKey.PublicKey}}, "https://idp", "toky-finance"),
+		claimMapper: service.CreateClaimMapperFromConfig(service.ClaimMappingConfig{
+			ClientID:    "toky-finance",
+			ClientRoles: map[string]types.BookRole{"auditor": types.BookRoleViewer},
+			Groups:      map[string]map[string]types.BookRole{"/verein/vorstand": {"1": types.BookRoleBookkeeper}},
+		}),
+	}
+	expiry := time.Now().Add(time.Minute).Unix()
+	auditorClaims := jwt.MapClaims{
+		"iss": "https://idp", "azp": "toky-finance", "exp": expiry,
+		"preferred_username": "user",
+		"resource_access":    map[string]interface{}{"toky-finance": map[string]interface{}{"roles": []string{"auditor"}}},
+	}
+	groupClaims := jwt.MapClaims{"iss": "https://idp", "aud": "toky-finance", "exp": expiry, "preferred_username": "user", "groups": []string{"/verein/vorstand"}}
+	otherClientClaims := jwt.MapClaims{
+		"iss": "https://idp", "azp": "toky-finance", "exp": expiry,
+		"preferred_username": "user",
+		"resource_access":    map[string]interface{}{"other-client": map[string]interface{}{"roles": []string{"auditor"}}},
+	}
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		method     string
+		url        string
+		key        *rsa.PrivateKey
+		claims     jwt.MapClaims
+		wantStatus int
+	}{
+		{"auditor reads any book", h.HasReadPermissions, http.MethodGet, "/book/2", signingKey, auditorClaims, http.StatusOK},
+		{"auditor can not book", h.RequirePermission(types.PermissionBook), http.MethodPost, "/book/2", signingKey, auditorClaims, http.StatusForbidden},
+		{"group grants book", h.RequirePermission(types.PermissionBook), http.MethodPost, "/book/1", signingKey, groupClaims, http.StatusOK},
+		{"group is limited to its book", h.HasReadPermissions, http.MethodGet, "/book/2", signingKey, groupClaims, http.StatusNotFound},
+		{"roles of other clients are ignored", h.HasReadPermissions, http.MethodGet, "/book/2", signingKey, otherClientClaims, http.StatusNotFound},
+		{"foreign signature", h.HasReadPermissions, http.MethodGet, "/book/2", foreignKey, auditorClaims, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signedToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, tt.claims).SignedString(tt.key)
+			if err != nil {
+				t.Fatalf("could not sign token: %v", err)
+			}
+			mux := http.NewServeMux()
+			mux.Handle(tt.method+" /book/{bookID}", h.AuthenticationMiddleware(tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))))
+			request := httptest.NewRequest(tt.method, tt.url, nil)
+			request.Header.Set("Authorization", "Bearer "+signedToken)
+			w := httptest.NewRecorder()
+
+			mux.ServeHTTP(w, request)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUserProvisioning(t *testing.T) {
+	userService := CreateMockUserService()
+	authenticator, _ := CreateDevAuthenticator(strings.NewReader(
+		`[{"token": "dev-anna", "userId": "1", "userName": "anna", "firstName": "Anna", "eMail": "anna@example.com"}]`))
+	h := &authenticationHandlerImpl{userService: &userService, authenticator: authenticator, claimMapper: service.CreateClaimMapperFromConfig(service.ClaimMappingConfig{})}
+	var userID string
+	request := httptest.NewRequest(http.MethodGet, "/book", nil)
+	request.Header.Set("Authorization", "Bearer dev-anna")
+	w := httptest.NewRecorder()
+
+	h.AuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = r.Context().Value(USER_ID).(string)
+	})).ServeHTTP(w, request)
+
+	if userID != "1" {
+		t.Errorf("user id = %v, want 1", userID)
+	}
+	if len(userService.createdUsers) != 1 || userService.createdUsers[0].EMail != "anna@example.com" || userService.createdUsers[0].FirstName != "Anna" {
+		t.Errorf("provisioned users = %v, want anna from the claims", userService.createdUsers)
+	}
+}
+
+func TestUserProvisioningIgnoresUnverifiedEmail(t *testing.T) {
+	userService := CreateMockUserService()
+	authenticator, _ := CreateDevAuthenticator(strings.NewReader(
+		`[{"token": "dev-anna", "userId": "1", "userName": "anna", "eMail": "kassier@verein.ch", "claims": {"email_verified": false}}]`))
+	h := &authenticationHandlerImpl{userService: &userService, authenticator: authenticator, claimMapper: service.CreateClaimMapperFromConfig(service.ClaimMappingConfig{})}
+	request := httptest.NewRequest(http.MethodGet, "/book", nil)
+	request.Header.Set("Authorization", "Bearer dev-anna")
+
+	h.AuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), request)
+
+	if len(userService.createdUsers) != 1 || userService.createdUsers[0].EMail != "" {
+		t.Errorf("provisioned users = %v, want anna without the unverified email", userService.createdUsers)
+	}
+}
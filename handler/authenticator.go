@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	jwtauthhandler "github.com/toky03/jwt-auth-handler"
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+// Authenticator verifies a bearer token and returns its claims.
+// The claim sub identifies the application user, tokens without sub are matched by preferred_username.
+type Authenticator interface {
+	Authenticate(token string) (jwt.MapClaims, error)
+	LoginInformation() model.LoginInformationDto
+}
+
+type publicKeyReader interface {
+	ReadPublicKeys() []rsa.PublicKey
+}
+
+// CreateAuthenticator selects the authenticator by AUTH_MODE: oidc (default), hmac or dev
+func CreateAuthenticator() Authenticator {
+	switch os.Getenv("AUTH_MODE") {
+	case "", "oidc":
+		return createOIDCAuthenticatorFromEnv()
+	case "hmac":
+		secret := os.Getenv("AUTH_HMAC_SECRET")
+		if secret == "" {
+			panic("AUTH_HMAC_SECRET must be provided")
+		}
+		return CreateHMACAuthenticator([]byte(secret), os.Getenv("AUTH_ISSUER"), os.Getenv("AUTH_AUDIENCE"))
+	case "dev":
+		usersFile := os.Getenv("AUTH_DEV_USERS_FILE")
+		if usersFile == "" {
+			panic("AUTH_DEV_USERS_FILE must be provided")
+		}
+		file, err := os.Open(usersFile)
+		if err != nil {
+			panic(fmt.Sprintf("Dev users %s could not be opened: %v", usersFile, err))
+		}
+		defer file.Close()
+		authenticator, err := CreateDevAuthenticator(file)
+		if err != nil {
+			panic(fmt.Sprintf("Dev users %s are invalid: %v", usersFile, err))
+		}
+		log.Printf("Dev authentication active with %d users, do not use in production", len(authenticator.users))
+		return authenticator
+	}
+	panic(fmt.Sprintf("Unknown AUTH_MODE %s", os.Getenv("AUTH_MODE")))
+}
+
+type oidcAuthenticator struct {
+	keyReader        publicKeyReader
+	issuer           string
+	audience         string
+	loginInformation model.LoginInformationDto
+}
+
+func createOIDCAuthenticatorFromEnv() *oidcAuthenticator {
+	openIDProvider := os.Getenv("OPENID_JWKS_URL")
+	if openIDProvider == "" {
+		panic("OPENID_JWKS URL must be provided")
+	}
+	externalOpenIDProvider := os.Getenv("OPENID_JWKS_EXTERNAL_URL")
+	if externalOpenIDProvider == "" {
+		externalOpenIDProvider = openIDProvider
+	}
+	openIDClientId := os.Getenv("ID_PROVIDER_CLIENT_ID")
+	if openIDClientId == "" {
+		panic("ID_PROVIDER_CLIENT_ID URL must be provided")
+	}
+	issuer := os.Getenv("OPENID_ISSUER")
+	if issuer == "" {
+		issuer = strings.TrimSuffix(externalOpenIDProvider, "/protocol/openid-connect")
+	}
+	audience := os.Getenv("OPENID_AUDIENCE")
+	if audience == "" {
+		audience = openIDClientId
+	}
+
+	jwtHandler, err := jwtauthhandler.CreateJwtHandler(openIDProvider + "/certs")
+	if err != nil {
+		panic("jwt Handler could not have been initialized")
+	}
+	authenticator := CreateOIDCAuthenticator(&jwtHandler, issuer, audience)
+	authenticator.loginInformation = model.LoginInformationDto{
+		Flow:                "pkce",
+		AuthorizationUrl:    externalOpenIDProvider + "/auth",
+		TokenUrl:            externalOpenIDProvider + "/token",
+		ClientId:            openIDClientId,
+		CodeChallengeMethod: "S256",
+	}
+	return authenticator
+}
+
+// CreateOIDCAuthenticator verifies RS256 tokens against the keys of the identity provider.
+// The audience is accepted in aud as well as in azp, as Keycloak only sets the client in azp by default.
+func CreateOIDCAuthenticator(keyReader publicKeyReader, issuer, audience string) *oidcAuthenticator {
+	return &oidcAuthenticator{
+		keyReader: keyReader,
+		issuer:    issuer,
+		audience:  audience,
+	}
+}
+
+func (a *oidcAuthenticator) Authenticate(token string) (jwt.MapClaims, error) {
+	var err error
+	for _, rsaKey := range a.keyReader.ReadPublicKeys() {
+		claims := jwt.MapClaims{}
+		_, err = jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+			return &rsaKey, nil
+		}, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}), jwt.WithIssuer(a.issuer), jwt.WithExpirationRequired())
+		if err == nil {
+			return claims, validateAudience(claims, a.audience)
+		}
+	}
+	if err == nil {
+		err = errors.New("no public key available")
+	}
+	return nil, err
+}
+
+func (a *oidcAuthenticator) LoginInformation() model.LoginInformationDto {
+	return a.loginInformation
+}
+
+type hmacAuthenticator struct {
+	secret   []byte
+	issuer   string
+	audience string
+}
+
+// CreateHMACAuthenticator verifies tokens signed with a shared secret.
+// Issuer and audience are only checked if they are configured.
+func CreateHMACAuthenticator(secret []byte, issuer, audience string) *hmacAuthenticator {
+	return &hmacAuthenticator{
+		secret:   secret,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+func (a *hmacAuthenticator) Authenticate(token string) (jwt.MapClaims, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}), jwt.WithExpirationRequired()}
+	if a.issuer != "" {
+		options = append(options, jwt.WithIssuer(a.issuer))
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, options...)
+	if err != nil {
+		return nil, err
+	}
+	if a.audience != "" {
+		return claims, validateAudience(claims, a.audience)
+	}
+	return claims, nil
+}
+
+func (a *hmacAuthenticator) LoginInformation() model.LoginInformationDto {
+	return model.LoginInformationDto{Flow: "token"}
+}
+
+type devUser struct {
+	model.ApplicationUserDTO
+	Token  string                 `json:"token"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+type devAuthenticator struct {
+	users map[string]devUser
+}
+
+// CreateDevAuthenticator reads users with a fixed token from a JSON list.
+// The token is sent as bearer token unchanged, additional claims can be used for the claim mapping.
+// The users are provisioned on their first request like users of the identity provider.
+func CreateDevAuthenticator(reader io.Reader) (*devAuthenticator, error) {
+	var users []devUser
+	if err := json.NewDecoder(reader).Decode(&users); err != nil {
+		return nil, err
+	}
+	authenticator := &devAuthenticator{users: map[string]devUser{}}
+	for _, user := range users {
+		if user.Token == "" || user.UserID == "" || user.UserName == "" || user.EMail == "" {
+			return nil, errors.New("dev users need a token, userId, userName and eMail")
+		}
+		if _, exists := authenticator.users[user.Token]; exists {
+			return nil, fmt.Errorf("token of dev user %s is not unique", user.UserName)
+		}
+		authenticator.users[user.Token] = user
+	}
+	return authenticator, nil
+}
+
+func (a *devAuthenticator) Authenticate(token string) (jwt.MapClaims, error) {
+	user, ok := a.users[token]
+	if !ok {
+		return nil, errors.New("unknown dev token")
+	}
+	claims := jwt.MapClaims{"email_verified": true}
+	for claim, value := range user.Claims {
+		claims[claim] = value
+	}
+	claims["sub"] = user.UserID
+	claims["preferred_username"] = user.UserName
+	claims["given_name"] = user.FirstName
+	claims["family_name"] = user.LastName
+	claims["email"] = user.EMail
+	return claims, nil
+}
+
+func (a *devAuthenticator) LoginInformation() model.LoginInformationDto {
+	return model.LoginInformationDto{Flow: "token"}
+}
+
+func validateAudience(claims jwt.MapClaims, audience string) error {
+	tokenAudience, err := claims.GetAudience()
+	if err != nil {
+		return err
+	}
+	for _, aud := range tokenAudience {
+		if aud == audience {
+			return nil
+		}
+	}
+	if azp, _ := claims["azp"].(string); azp == audience {
+		return nil
+	}
+	return fmt.Errorf("token is not issued for audience %s", audience)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestOIDCAuthenticator(t *testing.T) {
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+	authenticator := CreateOIDCAuthenticator(mockPublicKeyReader{keys: []rsa.PublicKey{signingKey.PublicKey}}, "https://idp", "toky")
+	valid := time.Now().Add(time.Minute).Unix()
+	expired := time.Now().Add(-time.Minute).Unix()
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		key     interface{}
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"valid with aud", jwt.SigningMethodRS256, signingKey, jwt.MapClaims{"iss": "https://idp", "aud": "toky", "exp": valid}, false},
+		{"valid with azp", jwt.SigningMethodRS256, signingKey, jwt.MapClaims{"iss": "https://idp", "aud": "account", "azp": "toky", "exp": valid}, false},
+		{"expired", jwt.SigningMethodRS256, signingKey, jwt.MapClaims{"iss": "https://idp", "aud": "toky", "exp": expired}, true},
+		{"without expiry", jwt.SigningMethodRS256, signingKey, jwt.MapClaims{"iss": "https://idp", "aud": "toky"}, true},
+		{"foreign issuer", jwt.SigningMethodRS256, signingKey, jwt.MapClaims{"iss": "https://other", "aud": "toky", "exp": valid}, true},
+		{"foreign audience", jwt.SigningMethodRS256, signingKey, jwt.MapClaims{"iss": "https://idp", "aud": "other", "azp": "other", "exp": valid}, true},
+		{"hmac signed", jwt.SigningMethodHS256, []byte("secret"), jwt.MapClaims{"iss": "https://idp", "aud": "toky", "exp": valid}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(tt.method, tt.claims).SignedString(tt.key)
+			if err != nil {
+				t.Fatalf("could not sign token: %v", err)
+			}
+			_, err = authenticator.Authenticate(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticator(t *testing.T) {
+	authenticator := CreateHMACAuthenticator([]byte("secret"), "toky-tests", "")
+	valid := time.Now().Add(time.Minute).Unix()
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"valid", []byte("secret"), jwt.MapClaims{"iss": "toky-tests", "exp": valid, "preferred_username": "anna"}, false},
+		{"wrong secret", []byte("guessed"), jwt.MapClaims{"iss": "toky-tests", "exp": valid}, true},
+		{"foreign issuer", []byte("secret"), jwt.MapClaims{"iss": "other", "exp": valid}, true},
+		{"without expiry", []byte("secret"), jwt.MapClaims{"iss": "toky-tests"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString(tt.secret)
+			if err != nil {
+				t.Fatalf("could not sign token: %v", err)
+			}
+			_, err = authenticator.Authenticate(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDevAuthenticator(t *testing.T) {
+	authenticator, err := CreateDevAuthenticator(strings.NewReader(
+		`[{"token": "dev-anna", "userId": "1", "userName": "anna", "eMail": "anna@example.com", "claims": {"groups": ["/board"]}}]`))
+	if err != nil {
+		t.Fatalf("CreateDevAuthenticator() error = %v", err)
+	}
+	claims, err := authenticator.Authenticate("dev-anna")
+	if err != nil {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+	if claims["sub"] != "1" || claims["preferred_username"] != "anna" || claims["groups"] == nil {
+		t.Errorf("Authenticate() claims = %v, want user anna with groups", claims)
+	}
+	if _, err := authenticator.Authenticate("dev-ben"); err == nil {
+		t.Errorf("Authenticate() with unknown token must fail")
+	}
+	if _, err := CreateDevAuthenticator(strings.NewReader(`[{"token": "dev-anna", "userName": "anna", "eMail": "anna@example.com"}]`)); err == nil {
+		t.Errorf("CreateDevAuthenticator() without userId must fail")
+	}
+}
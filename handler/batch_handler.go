@@ -2,12 +2,14 @@ package handler
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"sync"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/toky03/toky-finance-accounting-service/grpc_accounting"
 	"github.com/toky03/toky-finance-accounting-service/grpc_users"
 	"github.com/toky03/toky-finance-accounting-service/model"
 	"github.com/toky03/toky-finance-accounting-service/service"
@@ -19,51 +21,93 @@ type userServiceBatch interface {
 	UpdateUser(model.ApplicationUserDTO) model.TokyError
 	CreateUser(model.ApplicationUserDTO) model.TokyError
 	DeleteUser(userId string) model.TokyError
+	SyncUsers(users []model.ApplicationUserDTO, revision int64) (model.UserSyncReportDTO, model.TokyError)
 }
 
+// UserServiceServerImpl caches the response of GetAllUsers until the users change.
+// The generation prevents a response read before a change from being cached after it.
 type UserServiceServerImpl struct {
-	cachedUsers *grpc_users.GetUsersResponse
-	userService userServiceBatch
+	cacheMutex      sync.RWMutex
+	cachedUsers     *grpc_users.GetUsersResponse
+	cacheGeneration uint64
+	userService     userServiceBatch
 	grpc_users.UnimplementedUserServiceServer
 }
 
-func CreateAndRegisterUserBatchService() error {
+// CreateAndRegisterUserBatchService serves the user batch and the accounting api on USER_BATCH_PORT
+func CreateAndRegisterUserBatchService(accountingServer grpc_accounting.AccountingServiceServer, interceptors ...grpc.UnaryServerInterceptor) error {
 	userBatchPort := os.Getenv("USER_BATCH_PORT")
 	if userBatchPort == "" {
-		return errors.New("USER_BATCH_PORT must be specified")
+		log.Println("USER_BATCH_PORT not specified, user batch service is disabled")
+		return nil
+	}
+	security, err := readBatchSecurity()
+	if err != nil {
+		return err
 	}
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%v", userBatchPort))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 		return err
 	}
-	grpcServer := grpc.NewServer()
-	batchService := UserServiceServerImpl{
-		cachedUsers: nil,
-		userService: service.CreateApplicationUserService(),
-	}
-	grpc_users.RegisterUserServiceServer(grpcServer, &batchService)
+	grpcServer := createBatchServer(security, createGrpcMetrics(prometheus.DefaultRegisterer), service.CreateApplicationUserService(), accountingServer, interceptors...)
 	go grpcServer.Serve(lis)
 	return nil
 }
 
 func (s *UserServiceServerImpl) GetAllUsers(context.Context, *grpc_users.Empty) (*grpc_users.GetUsersResponse, error) {
-	if s.cachedUsers != nil && len(s.cachedUsers.GetUsers()) > 0 {
-		return s.cachedUsers, nil
+	s.cacheMutex.RLock()
+	cachedUsers, generation := s.cachedUsers, s.cacheGeneration
+	s.cacheMutex.RUnlock()
+	if cachedUsers != nil && len(cachedUsers.GetUsers()) > 0 {
+		return cachedUsers, nil
 	}
 	users, tokyErr := s.userService.ReadAllUsers()
 	if model.IsExisting(tokyErr) {
 		return nil, tokyErr.Error()
 	}
-	s.cachedUsers = mapUserDTOsTogrpcUsers(users)
-	return s.cachedUsers, nil
+	response := mapUserDTOsTogrpcUsers(users)
+	s.cacheMutex.Lock()
+	if s.cacheGeneration == generation {
+		s.cachedUsers = response
+	}
+	s.cacheMutex.Unlock()
+	return response, nil
+}
+
+// SyncUsers applies a complete snapshot of the users and reports the changes
+func (s *UserServiceServerImpl) SyncUsers(ctx context.Context, request *grpc_users.SyncUsersRequest) (*grpc_users.SyncUsersReport, error) {
+	users := make([]model.ApplicationUserDTO, 0, len(request.GetUsers()))
+	for _, user := range request.GetUsers() {
+		users = append(users, mapGrpcUserToDTO(user))
+	}
+	report, tokyErr := s.userService.SyncUsers(users, request.GetRevision())
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	s.invalidateCache()
+	log.Printf("Synced users of revision %d: %d added, %d updated, %d deleted", report.Revision, report.Added, report.Updated, report.Deleted)
+	return &grpc_users.SyncUsersReport{
+		Revision:  report.Revision,
+		Added:     int32(report.Added),
+		Updated:   int32(report.Updated),
+		Deleted:   int32(report.Deleted),
+		Unchanged: int32(report.Unchanged),
+	}, nil
+}
+
+func (s *UserServiceServerImpl) invalidateCache() {
+	s.cacheMutex.Lock()
+	s.cachedUsers = nil
+	s.cacheGeneration++
+	s.cacheMutex.Unlock()
 }
 func (s *UserServiceServerImpl) UpdateUser(ctx context.Context, user *grpc_users.User) (*grpc_users.Empty, error) {
 	tokyErr := s.userService.UpdateUser(mapGrpcUserToDTO(user))
 	if model.IsExisting(tokyErr) {
 		return nil, tokyErr.Error()
 	}
-	s.cachedUsers = nil
+	s.invalidateCache()
 	return &grpc_users.Empty{}, nil
 }
 func (s *UserServiceServerImpl) AddUser(ctx context.Context, user *grpc_users.User) (*grpc_users.Empty, error) {
@@ -71,7 +115,7 @@ func (s *UserServiceServerImpl) AddUser(ctx context.Context, user *grpc_users.Us
 	if model.IsExisting(tokyErr) {
 		return nil, tokyErr.Error()
 	}
-	s.cachedUsers = nil
+	s.invalidateCache()
 	return &grpc_users.Empty{}, nil
 }
 func (s *UserServiceServerImpl) DeleteUser(ctx context.Context, userId *grpc_users.UserId) (*grpc_users.Empty, error) {
@@ -79,7 +123,7 @@ func (s *UserServiceServerImpl) DeleteUser(ctx context.Context, userId *grpc_use
 	if model.IsExisting(tokyErr) {
 		return nil, tokyErr.Error()
 	}
-	s.cachedUsers = nil
+	s.invalidateCache()
 	return &grpc_users.Empty{}, nil
 }
 
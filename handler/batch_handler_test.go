@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/grpc_users"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserServiceServerImpl_SyncUsers(t *testing.T) {
+	userService := &mockUserServiceBatch{users: []model.ApplicationUserDTO{{UserID: "old", UserName: "old"}}}
+	s := &UserServiceServerImpl{userService: userService}
+
+	before, _ := s.GetAllUsers(context.Background(), &grpc_users.Empty{})
+	report, err := s.SyncUsers(context.Background(), &grpc_users.SyncUsersRequest{
+		Revision: 3,
+		Users:    []*grpc_users.User{{Id: "anna", Username: "anna", Email: "anna@example.com"}},
+	})
+	if err != nil {
+		t.Fatalf("SyncUsers() error = %v", err)
+	}
+	if report.GetRevision() != 3 || report.GetAdded() != 1 || report.GetDeleted() != 1 {
+		t.Errorf("SyncUsers() report = %v", report)
+	}
+	after, _ := s.GetAllUsers(context.Background(), &grpc_users.Empty{})
+	if before.GetUsers()[0].GetId() != "old" || len(after.GetUsers()) != 1 || after.GetUsers()[0].GetId() != "anna" {
+		t.Errorf("GetAllUsers() after sync = %v, want the synced users instead of the cached ones", after)
+	}
+
+	tests := []struct {
+		name     string
+		request  *grpc_users.SyncUsersRequest
+		wantCode codes.Code
+	}{
+		{"empty snapshot", &grpc_users.SyncUsersRequest{Revision: 4}, codes.InvalidArgument},
+		{"outdated revision", &grpc_users.SyncUsersRequest{Revision: -1, Users: []*grpc_users.User{{Id: "anna"}}}, codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.SyncUsers(context.Background(), tt.request)
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("SyncUsers() code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestUserServiceServerImpl_CacheIsConcurrencySafe(t *testing.T) {
+	userService := &mockUserServiceBatch{users: []model.ApplicationUserDTO{{UserID: "anna"}}}
+	s := &UserServiceServerImpl{userService: userService}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.GetAllUsers(context.Background(), &grpc_users.Empty{})
+		}()
+		go func() {
+			defer wg.Done()
+			s.AddUser(context.Background(), &grpc_users.User{Id: "ben"})
+		}()
+	}
+	wg.Wait()
+
+	users, _ := s.GetAllUsers(context.Background(), &grpc_users.Empty{})
+	if len(users.GetUsers()) != 21 {
+		t.Errorf("GetAllUsers() = %d users, want 21 after all additions", len(users.GetUsers()))
+	}
+	readCalls := userService.readCalls
+	s.GetAllUsers(context.Background(), &grpc_users.Empty{})
+	if userService.readCalls != readCalls {
+		t.Errorf("GetAllUsers() must be served from the cache")
+	}
+}
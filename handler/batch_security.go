@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/toky03/toky-finance-accounting-service/grpc_accounting"
+	"github.com/toky03/toky-finance-accounting-service/grpc_users"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// batchSecurity protects the user batch service either by client certificates (mTLS)
+// or by a token shared with the batch. Without both every user batch call is rejected.
+type batchSecurity struct {
+	tlsConfig   *tls.Config
+	sharedToken string
+}
+
+// readBatchSecurity reads USER_BATCH_TLS_CERT, USER_BATCH_TLS_KEY, USER_BATCH_TLS_CLIENT_CA and USER_BATCH_TOKEN
+func readBatchSecurity() (batchSecurity, error) {
+	security := batchSecurity{sharedToken: os.Getenv("USER_BATCH_TOKEN")}
+	certFile, keyFile := os.Getenv("USER_BATCH_TLS_CERT"), os.Getenv("USER_BATCH_TLS_KEY")
+	clientCAFile := os.Getenv("USER_BATCH_TLS_CLIENT_CA")
+	if certFile != "" || keyFile != "" || clientCAFile != "" {
+		tlsConfig, err := loadBatchTLSConfig(certFile, keyFile, clientCAFile)
+		if err != nil {
+			return batchSecurity{}, err
+		}
+		security.tlsConfig = tlsConfig
+	}
+	if !security.clientCertificatesRequired() && security.sharedToken == "" {
+		log.Println("Neither USER_BATCH_TLS_CLIENT_CA nor USER_BATCH_TOKEN specified, user batch calls are rejected")
+	}
+	return security, nil
+}
+
+func loadBatchTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("USER_BATCH_TLS_CERT and USER_BATCH_TLS_KEY are required for TLS")
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return tlsConfig, nil
+	}
+	clientCAs, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(clientCAs) {
+		return nil, errors.New("no certificates found in " + clientCAFile)
+	}
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+func (s batchSecurity) clientCertificatesRequired() bool {
+	return s.tlsConfig != nil && s.tlsConfig.ClientAuth == tls.RequireAndVerifyClientCert
+}
+
+func (s batchSecurity) serverOptions() []grpc.ServerOption {
+	if s.tlsConfig == nil {
+		return nil
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(s.tlsConfig))}
+}
+
+// UnaryInterceptor admits user batch calls with a verified client certificate or the shared token
+func (s batchSecurity) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, "/"+grpc_users.UserService_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+	if s.clientCertificatesRequired() && hasVerifiedClientCertificate(ctx) {
+		return handler(ctx, req)
+	}
+	if s.sharedToken != "" {
+		authorization := metadata.ValueFromIncomingContext(ctx, "authorization")
+		if len(authorization) == 1 && subtle.ConstantTimeCompare([]byte(authorization[0]), []byte("Bearer "+s.sharedToken)) == 1 {
+			return handler(ctx, req)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "user batch call not authenticated")
+}
+
+func hasVerifiedClientCertificate(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(tlsInfo.State.VerifiedChains) > 0
+}
+
+// grpcMetrics records the grpc calls like MonitoringHandlerImpl does for http requests
+type grpcMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func createGrpcMetrics(registerer prometheus.Registerer) *grpcMetrics {
+	factory := promauto.With(registerer)
+	return &grpcMetrics{
+		requests: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "toky_grpc_requests_total",
+			Help: "Handled grpc calls by method and status code",
+		}, []string{"Method", "Code"}),
+		duration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "toky_grpc_request_duration_seconds",
+			Help: "Duration of grpc calls in Seconds",
+		}, []string{"Method"}),
+	}
+}
+
+func (m *grpcMetrics) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	m.requests.With(prometheus.Labels{"Method": info.FullMethod, "Code": status.Code(err).String()}).Inc()
+	m.duration.With(prometheus.Labels{"Method": info.FullMethod}).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+func loggingUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	remote := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	log.Printf("grpc %s from %s: %s in %v", info.FullMethod, remote, status.Code(err), time.Since(start))
+	return resp, err
+}
+
+// createBatchServer registers the user batch and the accounting service. The security interceptor runs
+// before the given interceptors so that logging and metrics include rejected calls.
+func createBatchServer(security batchSecurity, metrics *grpcMetrics, userService userServiceBatch, accountingServer grpc_accounting.AccountingServiceServer, interceptors ...grpc.UnaryServerInterceptor) *grpc.Server {
+	chain := append([]grpc.UnaryServerInterceptor{loggingUnaryInterceptor, metrics.UnaryInterceptor, security.UnaryInterceptor}, interceptors...)
+	grpcServer := grpc.NewServer(append(security.serverOptions(), grpc.ChainUnaryInterceptor(chain...))...)
+	grpc_users.RegisterUserServiceServer(grpcServer, &UserServiceServerImpl{userService: userService})
+	grpc_accounting.RegisterAccountingServiceServer(grpcServer, accountingServer)
+	return grpcServer
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/toky03/toky-finance-accounting-service/grpc_accounting"
+	"github.com/toky03/toky-finance-accounting-service/grpc_users"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func startBatchServer(t *testing.T, security batchSecurity, registry *prometheus.Registry) *bufconn.Listener {
+	listener := bufconn.Listen(1024 * 1024)
+	userService := &mockUserServiceBatch{users: []model.ApplicationUserDTO{{UserID: "anna"}}}
+	server := createBatchServer(security, createGrpcMetrics(registry), userService, grpc_accounting.UnimplementedAccountingServiceServer{})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener
+}
+
+func dialBatchServer(t *testing.T, listener *bufconn.Listener, transportCredentials credentials.TransportCredentials) *grpc.ClientConn {
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(transportCredentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestBatchSecuritySharedToken(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	listener := startBatchServer(t, batchSecurity{sharedToken: "secret"}, registry)
+	conn := dialBatchServer(t, listener, insecure.NewCredentials())
+	users := grpc_users.NewUserServiceClient(conn)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      codes.Code
+	}{
+		{"without token", "", codes.Unauthenticated},
+		{"wrong token", "Bearer wrong", codes.Unauthenticated},
+		{"shared token", "Bearer secret", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+			_, err := users.GetAllUsers(ctx, &grpc_users.Empty{})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("GetAllUsers() code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+		})
+	}
+
+	_, err := grpc_accounting.NewAccountingServiceClient(conn).ListBooks(context.Background(), &grpc_accounting.Empty{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("ListBooks() code = %v, want the accounting service to be left to its own authentication", status.Code(err))
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "toky_grpc_requests_total" && len(family.GetMetric()) != 3 {
+			t.Errorf("toky_grpc_requests_total = %v, want series for Unauthenticated, OK and Unimplemented", family.GetMetric())
+		}
+	}
+}
+
+func TestBatchSecurityMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := createCertificate(t, nil, nil, "toky-test-ca")
+	serverCert, serverKey := createCertificate(t, caCert, caKey, "bufnet")
+	clientCert, clientKey := createCertificate(t, caCert, caKey, "user-batch")
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caCert.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", serverCert.Raw)
+	writePEM(t, filepath.Join(dir, "server-key.pem"), "EC PRIVATE KEY", marshalKey(t, serverKey))
+
+	tlsConfig, err := loadBatchTLSConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatalf("loadBatchTLSConfig() error = %v", err)
+	}
+	listener := startBatchServer(t, batchSecurity{tlsConfig: tlsConfig}, prometheus.NewRegistry())
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(caCert)
+	tests := []struct {
+		name         string
+		certificates []tls.Certificate
+		wantCode     codes.Code
+	}{
+		{"client certificate", []tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}}, codes.OK},
+		{"without client certificate", nil, codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dialBatchServer(t, listener, credentials.NewTLS(&tls.Config{RootCAs: rootCAs, ServerName: "bufnet", Certificates: tt.certificates}))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := grpc_users.NewUserServiceClient(conn).GetAllUsers(ctx, &grpc_users.Empty{})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("GetAllUsers() code = %v, want %v (%v)", status.Code(err), tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestLoadBatchTLSConfigRequiresKeyPair(t *testing.T) {
+	if _, err := loadBatchTLSConfig("", "", "ca.pem"); err == nil {
+		t.Errorf("loadBatchTLSConfig() without certificate must fail")
+	}
+}
+
+func createCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key
+}
+
+func marshalKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func writePEM(t *testing.T, path, blockType string, bytes []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+// bookArchiveFileName is the name of the json document inside a zipped book archive
+const bookArchiveFileName = "book.json"
+
+// maxBookArchiveSize limits the size of uploaded book archives
+const maxBookArchiveSize = 64 << 20
+
+type BookArchiveService interface {
+	ExportBookRealm(bookID string) (model.BookArchiveDTO, model.TokyError)
+	ImportBookRealm(archive model.BookArchiveDTO, userID string) (model.BookImportReportDTO, model.TokyError)
+}
+
+type bookArchiveHandlerImpl struct {
+	archiveService BookArchiveService
+}
+
+func CreateBookArchiveHandler(archiveService BookArchiveService) *bookArchiveHandlerImpl {
+	return &bookArchiveHandlerImpl{
+		archiveService: archiveService,
+	}
+}
+
+// ExportBookRealm answers with the archive as json document or with format=zip as zip file containing the document
+func (h *bookArchiveHandlerImpl) ExportBookRealm(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	archive, err := h.archiveService.ExportBookRealm(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	if r.URL.Query().Get("format") != "zip" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"book-%s.json\"", bookID))
+		writeJSON(archive, w)
+		return
+	}
+	zipped, zipErr := zipBookArchive(archive)
+	if zipErr != nil {
+		http.Error(w, zipErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"book-%s.zip\"", bookID))
+	w.Write(zipped)
+}
+
+// ImportBookRealm accepts the archive as json document or as zip file with the Content-Type application/zip
+func (h *bookArchiveHandlerImpl) ImportBookRealm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	archive, decodeErr := readBookArchive(w, r)
+	if decodeErr != nil {
+		http.Error(w, decodeErr.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	report, err := h.archiveService.ImportBookRealm(archive, userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+func readBookArchive(w http.ResponseWriter, r *http.Request) (model.BookArchiveDTO, error) {
+	var archive model.BookArchiveDTO
+	body := http.MaxBytesReader(w, r.Body, maxBookArchiveSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/zip") {
+		decodeErr := json.NewDecoder(body).Decode(&archive)
+		return archive, decodeErr
+	}
+	zipped, readErr := io.ReadAll(body)
+	if readErr != nil {
+		return archive, readErr
+	}
+	return unzipBookArchive(zipped, maxBookArchiveSize)
+}
+
+// unzipBookArchive decodes the document of a zipped archive, the document may not exceed maxSize once unpacked
+func unzipBookArchive(zipped []byte, maxSize int64) (model.BookArchiveDTO, error) {
+	var archive model.BookArchiveDTO
+	zipReader, zipErr := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	if zipErr != nil {
+		return archive, zipErr
+	}
+	file, openErr := zipReader.Open(bookArchiveFileName)
+	if openErr != nil {
+		return archive, errors.New("zip file does not contain " + bookArchiveFileName)
+	}
+	defer file.Close()
+	tooLarge := fmt.Errorf("%s is larger than %d bytes", bookArchiveFileName, maxSize)
+	info, statErr := file.Stat()
+	if statErr != nil {
+		return archive, statErr
+	}
+	if info.Size() > maxSize {
+		return archive, tooLarge
+	}
+	// the size stored in the zip file is not trusted, the unpacked content is limited as well
+	content := &io.LimitedReader{R: file, N: maxSize + 1}
+	decodeErr := json.NewDecoder(content).Decode(&archive)
+	if content.N <= 0 {
+		return archive, tooLarge
+	}
+	return archive, decodeErr
+}
+
+func zipBookArchive(archive model.BookArchiveDTO) ([]byte, error) {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	file, createErr := zipWriter.Create(bookArchiveFileName)
+	if createErr != nil {
+		return nil, createErr
+	}
+	if encodeErr := json.NewEncoder(file).Encode(archive); encodeErr != nil {
+		return nil, encodeErr
+	}
+	if closeErr := zipWriter.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+	return buffer.Bytes(), nil
+}
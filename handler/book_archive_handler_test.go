@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type mockBookArchiveService struct {
+	imported model.BookArchiveDTO
+}
+
+func (mbas *mockBookArchiveService) ExportBookRealm(bookID string) (model.BookArchiveDTO, model.TokyError) {
+	return model.BookArchiveDTO{Version: model.BookArchiveVersion, BookName: "Verein " + bookID}, nil
+}
+
+func (mbas *mockBookArchiveService) ImportBookRealm(archive model.BookArchiveDTO, userID string) (model.BookImportReportDTO, model.TokyError) {
+	mbas.imported = archive
+	return model.BookImportReportDTO{BookID: "2"}, nil
+}
+
+func TestBookArchiveZipRoundTrip(t *testing.T) {
+	archiveService := &mockBookArchiveService{}
+	h := CreateBookArchiveHandler(archiveService)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /book/{bookID}/export", h.ExportBookRealm)
+	mux.HandleFunc("POST /book/import", h.ImportBookRealm)
+
+	exportRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(exportRecorder, httptest.NewRequest(http.MethodGet, "/book/1/export?format=zip", nil))
+	if exportRecorder.Code != http.StatusOK || exportRecorder.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("export status = %d, content type = %s", exportRecorder.Code, exportRecorder.Header().Get("Content-Type"))
+	}
+
+	importRequest := httptest.NewRequest(http.MethodPost, "/book/import", bytes.NewReader(exportRecorder.Body.Bytes()))
+	importRequest.Header.Set("Content-Type", "application/zip")
+	importRequest = importRequest.WithContext(context.WithValue(importRequest.Context(), USER_ID, "user"))
+	importRecorder := httptest.NewRecorder()
+	mux.ServeHTTP(importRecorder, importRequest)
+	if importRecorder.Code != http.StatusCreated {
+		t.Fatalf("import status = %d, body = %s", importRecorder.Code, importRecorder.Body.String())
+	}
+	if archiveService.imported.BookName != "Verein 1" {
+		t.Errorf("imported archive = %+v", archiveService.imported)
+	}
+}
+
+func TestUnzipBookArchiveLimitsSize(t *testing.T) {
+	archive := model.BookArchiveDTO{Version: model.BookArchiveVersion, BookName: strings.Repeat("Verein ", 200)}
+	zipped, err := zipBookArchive(archive)
+	if err != nil {
+		t.Fatalf("zipBookArchive() error = %v", err)
+	}
+	if _, err := unzipBookArchive(zipped, 1<<20); err != nil {
+		t.Errorf("unzipBookArchive() within the limit error = %v", err)
+	}
+	if _, err := unzipBookArchive(zipped, 1<<10); err == nil {
+		t.Errorf("unzipBookArchive() of a document larger than the limit must fail")
+	}
+}
@@ -5,14 +5,18 @@ import (
 	"net/http"
 
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 // BookRealmService interface to define Contract
 type bookRealmService interface {
-	FindBookRealmsPermittedForUser(userId string) ([]model.BookRealmDTO, model.TokyError)
+	FindBookRealmsPermittedForUser(userId string, claimRoles model.ClaimRolesDTO, includeArchived bool) ([]model.BookRealmDTO, model.TokyError)
 	CreateBookRealm(model.BookRealmDTO, string) model.TokyError
 	FindBookRealmById(bookId string) (bookRealmDto model.BookRealmDTO, err model.TokyError)
-	DeleteBookRealm(bookId string) model.TokyError
+	ArchiveBookRealm(bookId string) model.TokyError
+	RestoreBookRealm(bookId, userId string) model.TokyError
+	PurgeBookRealm(bookId, userId string) model.TokyError
+	CloneBookRealm(bookId, userId string, clone model.BookCloneDTO) (model.BookRealmDTO, model.TokyError)
 	UpdateBookRealm(bookRealm model.BookRealmDTO, bookID string) model.TokyError
 }
 
@@ -20,8 +24,9 @@ type userService interface {
 	CreateUser(model.ApplicationUserDTO) model.TokyError
 	SearchUsers(limit, searchTerm string) ([]model.ApplicationUserDTO, model.TokyError)
 	FindUserByUsername(userName string) (model.ApplicationUserDTO, model.TokyError)
-	HasWriteAccessFromBook(userId, bookId string) (bool, model.TokyError)
-	IsOwnerOfBook(userId, bookId string) (bool, model.TokyError)
+	ProvisionUser(applicationUser model.ApplicationUserDTO) (model.ApplicationUserDTO, model.TokyError)
+	HasPermission(userId, bookId string, permission types.Permission) (bool, model.TokyError)
+	IsBookArchived(bookId string) (bool, model.TokyError)
 }
 
 // bookRealmHandler implementaion of Handler
@@ -60,7 +65,9 @@ func (h *bookRealmHandler) ReadBookRealms(w http.ResponseWriter, r *http.Request
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("Missing " + USER_ID))
 	}
-	bookRealms, err := h.bookRealmService.FindBookRealmsPermittedForUser(userId)
+	includeArchived := r.URL.Query().Get("archived") == "true"
+	claimRoles, _ := r.Context().Value(CLAIM_ROLES).(model.ClaimRolesDTO)
+	bookRealms, err := h.bookRealmService.FindBookRealmsPermittedForUser(userId, claimRoles, includeArchived)
 	if model.IsExisting(err) {
 		handleError(err, w)
 		return
@@ -119,9 +126,9 @@ func (h *bookRealmHandler) UpdateBookRealm(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusCreated)
 }
 
-func (h *bookRealmHandler) DeleteBookRealm(w http.ResponseWriter, r *http.Request) {
+func (h *bookRealmHandler) ArchiveBookRealm(w http.ResponseWriter, r *http.Request) {
 	bookID := r.PathValue("bookID")
-	err := h.bookRealmService.DeleteBookRealm(bookID)
+	err := h.bookRealmService.ArchiveBookRealm(bookID)
 	if model.IsExisting(err) {
 		handleError(err, w)
 		return
@@ -129,6 +136,58 @@ func (h *bookRealmHandler) DeleteBookRealm(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusOK)
 }
 
+func (h *bookRealmHandler) RestoreBookRealm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.bookRealmService.RestoreBookRealm(r.PathValue("bookID"), userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *bookRealmHandler) PurgeBookRealm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.bookRealmService.PurgeBookRealm(r.PathValue("bookID"), userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *bookRealmHandler) CloneBookRealm(w http.ResponseWriter, r *http.Request) {
+	var clone model.BookCloneDTO
+	decoderError := json.NewDecoder(r.Body).Decode(&clone)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	bookRealm, err := h.bookRealmService.CloneBookRealm(r.PathValue("bookID"), userID, clone)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	js, marshalErr := json.Marshal(bookRealm)
+	if marshalErr != nil {
+		http.Error(w, marshalErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(js)
+}
+
 func (h *bookRealmHandler) ReadAccountingUsers(w http.ResponseWriter, r *http.Request) {
 	queries := r.URL.Query()
 	limit := queries.Get("limit")
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type ConsolidationService interface {
+	ReadConsolidatedStatements(consolidation model.ConsolidationDTO) (model.ConsolidatedStatementsDTO, model.TokyError)
+}
+
+type bookAuthorizer interface {
+	Authorize(ctx context.Context, bookID string, permission types.Permission) (bool, model.TokyError)
+}
+
+type consolidationHandlerImpl struct {
+	consolidationService ConsolidationService
+	authorizer           bookAuthorizer
+}
+
+func CreateConsolidationHandler(consolidationService ConsolidationService, authorizer bookAuthorizer) *consolidationHandlerImpl {
+	return &consolidationHandlerImpl{
+		consolidationService: consolidationService,
+		authorizer:           authorizer,
+	}
+}
+
+// ReadConsolidatedStatements consolidates the books of the request, every book must be readable by the user
+func (h *consolidationHandlerImpl) ReadConsolidatedStatements(w http.ResponseWriter, r *http.Request) {
+	var consolidation model.ConsolidationDTO
+	if decodeErr := json.NewDecoder(r.Body).Decode(&consolidation); decodeErr != nil {
+		http.Error(w, decodeErr.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, bookID := range consolidation.BookIDs {
+		permitted, err := h.authorizer.Authorize(r.Context(), bookID, types.PermissionRead)
+		if model.IsExisting(err) && err.IsTechnicalError() {
+			handleError(err, w)
+			return
+		}
+		if !permitted || model.IsExisting(err) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(fmt.Sprintf("Book %s not found", bookID)))
+			return
+		}
+	}
+	statements, err := h.consolidationService.ReadConsolidatedStatements(consolidation)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(statements, w)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type mockConsolidationService struct {
+	called bool
+}
+
+func (mcs *mockConsolidationService) ReadConsolidatedStatements(consolidation model.ConsolidationDTO) (model.ConsolidatedStatementsDTO, model.TokyError) {
+	mcs.called = true
+	return model.ConsolidatedStatementsDTO{EliminationDifference: "0.00"}, nil
+}
+
+// mockBookAuthorizer grants one permission per book
+type mockBookAuthorizer struct {
+	grants map[string]types.Permission
+}
+
+func (mba *mockBookAuthorizer) Authorize(ctx context.Context, bookID string, permission types.Permission) (bool, model.TokyError) {
+	return mba.grants[bookID] == permission, nil
+}
+
+func TestReadConsolidatedStatements(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		wantStatus       int
+		wantConsolidated bool
+	}{
+		{"all books readable", `{"bookIds":["1","2"]}`, http.StatusOK, true},
+		{"one book not readable", `{"bookIds":["1","3"]}`, http.StatusNotFound, false},
+		{"malformed request", `{"bookIds":`, http.StatusUnprocessableEntity, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consolidationService := &mockConsolidationService{}
+			h := CreateConsolidationHandler(consolidationService, &mockBookAuthorizer{grants: map[string]types.Permission{"1": types.PermissionRead, "2": types.PermissionRead}})
+			recorder := httptest.NewRecorder()
+			h.ReadConsolidatedStatements(recorder, httptest.NewRequest(http.MethodPost, "/consolidation", strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if consolidationService.called != tt.wantConsolidated {
+				t.Errorf("service called = %v, want %v", consolidationService.called, tt.wantConsolidated)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/toky03/toky-finance-accounting-service/grpc_accounting"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type grpcAuthorizer interface {
+	Authorize(ctx context.Context, bookID string, permission types.Permission) (bool, model.TokyError)
+}
+
+// AccountingServiceServerImpl serves the accounting api over grpc with the same services and permissions as the REST api
+type AccountingServiceServerImpl struct {
+	bookRealmService  bookRealmService
+	accountingService AccountingService
+	authorizer        grpcAuthorizer
+	grpc_accounting.UnimplementedAccountingServiceServer
+}
+
+func CreateAccountingServiceServer(bookRealmService bookRealmService, accountingService AccountingService, authorizer grpcAuthorizer) *AccountingServiceServerImpl {
+	return &AccountingServiceServerImpl{
+		bookRealmService:  bookRealmService,
+		accountingService: accountingService,
+		authorizer:        authorizer,
+	}
+}
+
+// authorize hides books without read permission like the REST api does with 404
+func (s *AccountingServiceServerImpl) authorize(ctx context.Context, bookID string, permission types.Permission) error {
+	permitted, err := s.authorizer.Authorize(ctx, bookID, permission)
+	if model.IsExisting(err) {
+		return grpcError(err)
+	}
+	if permitted {
+		return nil
+	}
+	if permission == types.PermissionRead {
+		return status.Error(codes.NotFound, "Book not found")
+	}
+	return status.Error(codes.PermissionDenied, "Missing permission "+string(permission))
+}
+
+// authorizeLinkedBook requires the permission to book in the other book of a transfer as changes apply to both books
+func (s *AccountingServiceServerImpl) authorizeLinkedBook(ctx context.Context, bookID, bookingID string) error {
+	linkedBookID, err := s.accountingService.ReadLinkedBookIdOfBooking(bookID, bookingID)
+	if model.IsExisting(err) {
+		return grpcError(err)
+	}
+	if linkedBookID == "" {
+		return nil
+	}
+	return s.authorize(ctx, linkedBookID, types.PermissionBook)
+}
+
+func readUserOfContext(ctx context.Context) (string, error) {
+	if _, isApiToken := ctx.Value(API_TOKEN).(model.ApiTokenDTO); isApiToken {
+		return "", status.Error(codes.PermissionDenied, "API tokens are restricted to their books")
+	}
+	userId, ok := ctx.Value(USER_ID).(string)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "Missing "+string(USER_ID))
+	}
+	return userId, nil
+}
+
+func (s *AccountingServiceServerImpl) ListBooks(ctx context.Context, _ *grpc_accounting.Empty) (*grpc_accounting.ListBooksResponse, error) {
+	userId, err := readUserOfContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claimRoles, _ := ctx.Value(CLAIM_ROLES).(model.ClaimRolesDTO)
+	bookRealms, tokyErr := s.bookRealmService.FindBookRealmsPermittedForUser(userId, claimRoles, false)
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	books := make([]*grpc_accounting.Book, 0, len(bookRealms))
+	for _, bookRealm := range bookRealms {
+		books = append(books, mapBookToGrpc(bookRealm))
+	}
+	return &grpc_accounting.ListBooksResponse{Books: books}, nil
+}
+
+func (s *AccountingServiceServerImpl) GetBook(ctx context.Context, request *grpc_accounting.BookRequest) (*grpc_accounting.Book, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionRead); err != nil {
+		return nil, err
+	}
+	bookRealm, tokyErr := s.bookRealmService.FindBookRealmById(request.GetBookId())
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	return mapBookToGrpc(bookRealm), nil
+}
+
+func (s *AccountingServiceServerImpl) CreateBook(ctx context.Context, request *grpc_accounting.CreateBookRequest) (*grpc_accounting.Empty, error) {
+	userId, err := readUserOfContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokyErr := s.bookRealmService.CreateBookRealm(model.BookRealmDTO{BookName: request.GetBookName()}, userId)
+	return emptyOrError(tokyErr)
+}
+
+func (s *AccountingServiceServerImpl) ListAccounts(ctx context.Context, request *grpc_accounting.BookRequest) (*grpc_accounting.ListAccountsResponse, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionRead); err != nil {
+		return nil, err
+	}
+	accountTables, tokyErr := s.accountingService.ReadAccountsFromBook(request.GetBookId())
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	accounts := make([]*grpc_accounting.AccountBalance, 0, len(accountTables))
+	for _, accountTable := range accountTables {
+		accounts = append(accounts, mapAccountTableToGrpc(accountTable))
+	}
+	return &grpc_accounting.ListAccountsResponse{Accounts: accounts}, nil
+}
+
+func (s *AccountingServiceServerImpl) CreateAccount(ctx context.Context, request *grpc_accounting.CreateAccountRequest) (*grpc_accounting.Empty, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionManageAccounts); err != nil {
+		return nil, err
+	}
+	return emptyOrError(s.accountingService.CreateAccount(request.GetBookId(), mapAccountFromGrpc(request.GetAccount())))
+}
+
+func (s *AccountingServiceServerImpl) UpdateAccount(ctx context.Context, request *grpc_accounting.UpdateAccountRequest) (*grpc_accounting.Empty, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionManageAccounts); err != nil {
+		return nil, err
+	}
+	return emptyOrError(s.accountingService.UpdateAccount(request.GetBookId(), request.GetAccountId(), mapAccountFromGrpc(request.GetAccount())))
+}
+
+func (s *AccountingServiceServerImpl) DeleteAccount(ctx context.Context, request *grpc_accounting.AccountRequest) (*grpc_accounting.Empty, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionManageAccounts); err != nil {
+		return nil, err
+	}
+	return emptyOrError(s.accountingService.DeleteAccount(request.GetBookId(), request.GetAccountId()))
+}
+
+func (s *AccountingServiceServerImpl) ListBookings(ctx context.Context, request *grpc_accounting.BookRequest) (*grpc_accounting.ListBookingsResponse, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionRead); err != nil {
+		return nil, err
+	}
+	bookingDtos, tokyErr := s.accountingService.ReadBookings(request.GetBookId())
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	bookings := make([]*grpc_accounting.Booking, 0, len(bookingDtos))
+	for _, booking := range bookingDtos {
+		bookings = append(bookings, mapBookingToGrpc(booking))
+	}
+	return &grpc_accounting.ListBookingsResponse{Bookings: bookings}, nil
+}
+
+func (s *AccountingServiceServerImpl) CreateBooking(ctx context.Context, request *grpc_accounting.CreateBookingRequest) (*grpc_accounting.Empty, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionBook); err != nil {
+		return nil, err
+	}
+	return emptyOrError(s.accountingService.CreateBooking(request.GetBookId(), mapBookingFromGrpc(request.GetBooking())))
+}
+
+func (s *AccountingServiceServerImpl) UpdateBooking(ctx context.Context, request *grpc_accounting.UpdateBookingRequest) (*grpc_accounting.Empty, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionBook); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeLinkedBook(ctx, request.GetBookId(), request.GetBookingId()); err != nil {
+		return nil, err
+	}
+	return emptyOrError(s.accountingService.UpdateBooking(request.GetBookId(), request.GetBookingId(), mapBookingFromGrpc(request.GetBooking())))
+}
+
+func (s *AccountingServiceServerImpl) DeleteBooking(ctx context.Context, request *grpc_accounting.BookingRequest) (*grpc_accounting.Empty, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionBook); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeLinkedBook(ctx, request.GetBookId(), request.GetBookingId()); err != nil {
+		return nil, err
+	}
+	return emptyOrError(s.accountingService.DeleteBooking(request.GetBookId(), request.GetBookingId()))
+}
+
+func (s *AccountingServiceServerImpl) GetClosingStatements(ctx context.Context, request *grpc_accounting.ClosingStatementsRequest) (*grpc_accounting.ClosingStatements, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionRead); err != nil {
+		return nil, err
+	}
+	closingStatements, tokyErr := s.accountingService.ReadClosingStatements(request.GetBookId(), request.GetCostCenter())
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	return &grpc_accounting.ClosingStatements{
+		BalanceSheet: &grpc_accounting.BalanceSheet{
+			WorkingCapital: mapClosingStatementEntriesToGrpc(closingStatements.BalanceSheet.WorkingCapital),
+			Debt:           mapClosingStatementEntriesToGrpc(closingStatements.BalanceSheet.Debt),
+			CapitalAsset:   mapClosingStatementEntriesToGrpc(closingStatements.BalanceSheet.CapitalAsset),
+			Equity:         mapClosingStatementEntriesToGrpc(closingStatements.BalanceSheet.Equity),
+			BalanceSum:     closingStatements.BalanceSheet.BalanceSum,
+		},
+		IncomeStatement: mapIncomeStatementToGrpc(closingStatements.IncomeStatement),
+	}, nil
+}
+
+func (s *AccountingServiceServerImpl) ListIncomeStatementsByCostCenter(ctx context.Context, request *grpc_accounting.BookRequest) (*grpc_accounting.ListCostCenterIncomeStatementsResponse, error) {
+	if err := s.authorize(ctx, request.GetBookId(), types.PermissionRead); err != nil {
+		return nil, err
+	}
+	costCenterStatements, tokyErr := s.accountingService.ReadIncomeStatementsByCostCenter(request.GetBookId())
+	if model.IsExisting(tokyErr) {
+		return nil, grpcError(tokyErr)
+	}
+	incomeStatements := make([]*grpc_accounting.CostCenterIncomeStatement, 0, len(costCenterStatements))
+	for _, costCenterStatement := range costCenterStatements {
+		incomeStatements = append(incomeStatements, &grpc_accounting.CostCenterIncomeStatement{
+			CostCenter:      costCenterStatement.CostCenter,
+			IncomeStatement: mapIncomeStatementToGrpc(costCenterStatement.IncomeStatement),
+		})
+	}
+	return &grpc_accounting.ListCostCenterIncomeStatementsResponse{IncomeStatements: incomeStatements}, nil
+}
+
+func emptyOrError(err model.TokyError) (*grpc_accounting.Empty, error) {
+	if model.IsExisting(err) {
+		return nil, grpcError(err)
+	}
+	return &grpc_accounting.Empty{}, nil
+}
+
+func mapUserToGrpc(user model.ApplicationUserDTO) *grpc_accounting.User {
+	return &grpc_accounting.User{
+		Id:        user.UserID,
+		Username:  user.UserName,
+		Firstname: user.FirstName,
+		Lastname:  user.LastName,
+		Email:     user.EMail,
+	}
+}
+
+func mapBookToGrpc(bookRealm model.BookRealmDTO) *grpc_accounting.Book {
+	members := make([]*grpc_accounting.BookMember, 0, len(bookRealm.Members))
+	for _, member := range bookRealm.Members {
+		members = append(members, &grpc_accounting.BookMember{User: mapUserToGrpc(member.User), Role: string(member.Role)})
+	}
+	return &grpc_accounting.Book{
+		BookId:   bookRealm.BookID,
+		BookName: bookRealm.BookName,
+		Owner:    mapUserToGrpc(bookRealm.Owner),
+		Members:  members,
+	}
+}
+
+func mapAccountTableToGrpc(accountTable model.AccountTableDTO) *grpc_accounting.AccountBalance {
+	bookings := make([]*grpc_accounting.AccountBooking, 0, len(accountTable.Bookings))
+	for _, booking := range accountTable.Bookings {
+		bookings = append(bookings, &grpc_accounting.AccountBooking{
+			BookingId:      booking.BookingID,
+			Date:           booking.Date,
+			Column:         string(booking.Column),
+			BookingAccount: booking.BookingAccount,
+			Amount:         booking.Ammount,
+			Description:    booking.Description,
+			CostCenter:     booking.CostCenter,
+		})
+	}
+	return &grpc_accounting.AccountBalance{
+		Account: &grpc_accounting.Account{
+			AccountId:   accountTable.AccountID,
+			AccountName: accountTable.AccountName,
+			Type:        string(accountTable.Type),
+			Category:    string(accountTable.Category),
+			SubCategory: string(accountTable.SubCategory),
+			Description: accountTable.Description,
+		},
+		Bookings: bookings,
+		Balance:  accountTable.AccountSum,
+	}
+}
+
+func mapAccountFromGrpc(account *grpc_accounting.Account) model.AccountOptionDTO {
+	return model.AccountOptionDTO{
+		AccountName:  account.GetAccountName(),
+		Id:           account.GetAccountId(),
+		Type:         types.AccountType(account.GetType()),
+		Category:     types.AccountCategory(account.GetCategory()),
+		Description:  account.GetDescription(),
+		SubCategory:  types.AccountSubCategory(account.GetSubCategory()),
+		StartBalance: account.GetStartBalance(),
+	}
+}
+
+func mapBookingToGrpc(booking model.BookingDTO) *grpc_accounting.Booking {
+	return &grpc_accounting.Booking{
+		BookingId:         booking.BookingID,
+		SollAccount:       booking.SollAccount,
+		HabenAccount:      booking.HabenAccount,
+		Description:       booking.Description,
+		Date:              booking.Date,
+		Amount:            booking.Ammount,
+		CostCenter:        booking.CostCenter,
+		BookingType:       string(booking.BookingType),
+		ReversalDate:      booking.ReversalDate,
+		ReversalBookingId: booking.ReversalBookingID,
+	}
+}
+
+func mapBookingFromGrpc(booking *grpc_accounting.Booking) model.BookingDTO {
+	return model.BookingDTO{
+		BookingID:         booking.GetBookingId(),
+		SollAccount:       booking.GetSollAccount(),
+		HabenAccount:      booking.GetHabenAccount(),
+		Description:       booking.GetDescription(),
+		Date:              booking.GetDate(),
+		Ammount:           booking.GetAmount(),
+		CostCenter:        booking.GetCostCenter(),
+		BookingType:       types.BookingType(booking.GetBookingType()),
+		ReversalDate:      booking.GetReversalDate(),
+		ReversalBookingID: booking.GetReversalBookingId(),
+	}
+}
+
+func mapClosingStatementEntriesToGrpc(entries []model.ClosingStatementEntry) []*grpc_accounting.ClosingStatementEntry {
+	mapped := make([]*grpc_accounting.ClosingStatementEntry, 0, len(entries))
+	for _, entry := range entries {
+		mapped = append(mapped, &grpc_accounting.ClosingStatementEntry{Name: entry.Name, Amount: entry.Ammount})
+	}
+	return mapped
+}
+
+func mapIncomeStatementToGrpc(incomeStatement model.IncomeStatement) *grpc_accounting.IncomeStatement {
+	return &grpc_accounting.IncomeStatement{
+		Creds:      mapClosingStatementEntriesToGrpc(incomeStatement.Creds),
+		Debts:      mapClosingStatementEntriesToGrpc(incomeStatement.Debts),
+		BalanceSum: incomeStatement.BalanceSum,
+	}
+}
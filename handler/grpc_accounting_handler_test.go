@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/grpc_accounting"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccountingServiceServer(t *testing.T) {
+	userService := CreateMockUserService()
+	userService.permissionMap["user"+"1"+string(types.PermissionRead)] = true
+	userService.permissionMap["user"+"1"+string(types.PermissionBook)] = true
+	accountingService := CreateMockAccountingService()
+	accountingService.bookings["1"] = []model.BookingDTO{{BookingID: "b1", Ammount: "12.50"}}
+	apiTokenService := mockApiTokenAuthenticator{tokens: map[string]model.ApiTokenDTO{
+		"tfa_read":  {UserID: "user", Scope: types.TokenScopeRead, BookIDs: []string{"1", "2"}},
+		"tfa_write": {UserID: "user", Scope: types.TokenScopeWrite, BookIDs: []string{"1", "2"}},
+	}}
+	h := &authenticationHandlerImpl{userService: &userService, accountingService: &accountingService, apiTokenService: apiTokenService}
+	s := CreateAccountingServiceServer(nil, &accountingService, h)
+
+	tests := []struct {
+		name          string
+		authorization []string
+		method        string
+		call          func(ctx context.Context) (interface{}, error)
+		wantCode      codes.Code
+	}{
+		{"list bookings", []string{"Bearer tfa_read"}, "ListBookings", func(ctx context.Context) (interface{}, error) {
+			return s.ListBookings(ctx, &grpc_accounting.BookRequest{BookId: "1"})
+		}, codes.OK},
+		{"missing authorization", nil, "ListBookings", func(ctx context.Context) (interface{}, error) {
+			return s.ListBookings(ctx, &grpc_accounting.BookRequest{BookId: "1"})
+		}, codes.Unauthenticated},
+		{"unknown token", []string{"Bearer tfa_unknown"}, "ListBookings", func(ctx context.Context) (interface{}, error) {
+			return s.ListBookings(ctx, &grpc_accounting.BookRequest{BookId: "1"})
+		}, codes.Unauthenticated},
+		{"unreadable book is hidden", []string{"Bearer tfa_read"}, "ListBookings", func(ctx context.Context) (interface{}, error) {
+			return s.ListBookings(ctx, &grpc_accounting.BookRequest{BookId: "2"})
+		}, codes.NotFound},
+		{"read token can not book", []string{"Bearer tfa_read"}, "CreateBooking", func(ctx context.Context) (interface{}, error) {
+			return s.CreateBooking(ctx, &grpc_accounting.CreateBookingRequest{BookId: "1", Booking: &grpc_accounting.Booking{}})
+		}, codes.PermissionDenied},
+		{"write token books", []string{"Bearer tfa_write"}, "CreateBooking", func(ctx context.Context) (interface{}, error) {
+			return s.CreateBooking(ctx, &grpc_accounting.CreateBookingRequest{BookId: "1", Booking: &grpc_accounting.Booking{}})
+		}, codes.OK},
+		{"write token can not manage accounts", []string{"Bearer tfa_write"}, "DeleteAccount", func(ctx context.Context) (interface{}, error) {
+			return s.DeleteAccount(ctx, &grpc_accounting.AccountRequest{BookId: "1", AccountId: "a1"})
+		}, codes.PermissionDenied},
+		{"api token can not list books", []string{"Bearer tfa_write"}, "ListBooks", func(ctx context.Context) (interface{}, error) {
+			return s.ListBooks(ctx, &grpc_accounting.Empty{})
+		}, codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.authorization != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.authorization[0]))
+			}
+			info := &grpc.UnaryServerInfo{FullMethod: "/" + grpc_accounting.AccountingService_ServiceDesc.ServiceName + "/" + tt.method}
+			_, err := h.UnaryAuthenticationInterceptor(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				return tt.call(ctx)
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("%s code = %v, want %v (%v)", tt.method, status.Code(err), tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestUnaryAuthenticationInterceptorSkipsOtherServices(t *testing.T) {
+	h := &authenticationHandlerImpl{}
+	called := false
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc_users.UserService/GetAllUsers"}
+	_, err := h.UnaryAuthenticationInterceptor(context.Background(), nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	if err != nil || !called {
+		t.Errorf("UnaryAuthenticationInterceptor() error = %v, called = %v, want the user batch call passed unchanged", err, called)
+	}
+}
+
+func TestMapBookingRoundTrip(t *testing.T) {
+	booking := model.BookingDTO{
+		BookingID: "b1", SollAccount: "s", HabenAccount: "h", Description: "d", Date: "2024-01-01",
+		Ammount: "1.20", CostCenter: "cc", BookingType: types.BookingTypeAccrual, ReversalDate: "2024-02-01", ReversalBookingID: "b2",
+	}
+	if mapped := mapBookingFromGrpc(mapBookingToGrpc(booking)); mapped != booking {
+		t.Errorf("mapBookingFromGrpc(mapBookingToGrpc()) = %v, want %v", mapped, booking)
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/toky03/toky-finance-accounting-service/grpc_accounting"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryAuthenticationInterceptor authenticates calls of the accounting service with the token of the authorization metadata.
+// Calls of other services on the same listener are passed unchanged.
+func (h *authenticationHandlerImpl) UnaryAuthenticationInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, "/"+grpc_accounting.AccountingService_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+	authorization := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(authorization) != 1 || !strings.HasPrefix(authorization[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "Malformed Token")
+	}
+	ctx, err := h.authenticateToken(ctx, strings.TrimPrefix(authorization[0], "Bearer "))
+	if model.IsExisting(err) {
+		if err.IsTechnicalError() {
+			return nil, status.Error(codes.Internal, err.ErrorMessage())
+		}
+		return nil, status.Error(codes.Unauthenticated, err.ErrorMessage())
+	}
+	return handler(ctx, req)
+}
+
+// Authorize checks the permission of the authenticated user in the book like the permission middlewares do
+func (h *authenticationHandlerImpl) Authorize(ctx context.Context, bookID string, permission types.Permission) (bool, model.TokyError) {
+	userId, ok := ctx.Value(USER_ID).(string)
+	if !ok {
+		return false, model.CreateBusinessError("Missing "+string(USER_ID), errors.New("unauthenticated call"))
+	}
+	return h.isPermitted(ctx, userId, bookID, permission)
+}
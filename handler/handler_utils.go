@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func handleError(error model.TokyError, w http.ResponseWriter) {
@@ -29,3 +32,36 @@ func handleError(error model.TokyError, w http.ResponseWriter) {
 		return
 	}
 }
+
+// grpcError maps errors to the status codes matching the http status of handleError
+func grpcError(error model.TokyError) error {
+	if error.IsTechnicalError() {
+		return status.Error(codes.Internal, error.ErrorMessage())
+	}
+	if model.IsExistingNotFoundError(error) {
+		return status.Error(codes.NotFound, error.ErrorMessage())
+	}
+	if model.IsExistingValidationError(error) {
+		return status.Error(codes.InvalidArgument, error.ErrorMessage())
+	}
+	return status.Error(codes.FailedPrecondition, error.ErrorMessage())
+}
+
+func writeJSON(payload interface{}, w http.ResponseWriter) {
+	js, marshalError := json.Marshal(payload)
+	if marshalError != nil {
+		http.Error(w, marshalError.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+func readUserID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := r.Context().Value(USER_ID).(string)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Missing " + USER_ID))
+	}
+	return userID, ok
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type InvoiceService interface {
+	ReadInvoiceSettings(bookID string) (model.InvoiceSettingsDTO, model.TokyError)
+	UpdateInvoiceSettings(bookID string, settings model.InvoiceSettingsDTO) model.TokyError
+	ReadInvoices(bookID string) ([]model.InvoiceDTO, model.TokyError)
+	ReadInvoice(bookID, invoiceID string) (model.InvoiceDTO, model.TokyError)
+	CreateInvoice(bookID string, invoice model.InvoiceDTO) model.TokyError
+	RenderInvoicePDF(bookID, invoiceID string, w io.Writer) model.TokyError
+	MatchPaymentByReference(bookID string, payment model.InvoicePaymentDTO) model.TokyError
+	ImportPayments(bookID, bankAccountID string, statement io.Reader) (model.PaymentImportReportDTO, model.TokyError)
+}
+
+// maxBankStatementSize limits the size of uploaded camt statements
+const maxBankStatementSize = 16 << 20
+
+type invoiceHandlerImpl struct {
+	invoiceService InvoiceService
+}
+
+func CreateInvoiceHandler(invoiceService InvoiceService) *invoiceHandlerImpl {
+	return &invoiceHandlerImpl{
+		invoiceService: invoiceService,
+	}
+}
+
+func (h *invoiceHandlerImpl) ReadInvoiceSettings(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	settings, err := h.invoiceService.ReadInvoiceSettings(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(settings, w)
+}
+
+func (h *invoiceHandlerImpl) UpdateInvoiceSettings(w http.ResponseWriter, r *http.Request) {
+	var settings model.InvoiceSettingsDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&settings)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	updateError := h.invoiceService.UpdateInvoiceSettings(bookID, settings)
+	if model.IsExisting(updateError) {
+		handleError(updateError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *invoiceHandlerImpl) ReadInvoices(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	invoices, err := h.invoiceService.ReadInvoices(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(invoices, w)
+}
+
+func (h *invoiceHandlerImpl) ReadInvoice(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	invoiceID := r.PathValue("invoiceID")
+	invoice, err := h.invoiceService.ReadInvoice(bookID, invoiceID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(invoice, w)
+}
+
+func (h *invoiceHandlerImpl) CreateInvoice(w http.ResponseWriter, r *http.Request) {
+	var invoice model.InvoiceDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&invoice)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createError := h.invoiceService.CreateInvoice(bookID, invoice)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *invoiceHandlerImpl) ReadInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	invoiceID := r.PathValue("invoiceID")
+	var pdf bytes.Buffer
+	err := h.invoiceService.RenderInvoicePDF(bookID, invoiceID, &pdf)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"invoice-%s.pdf\"", invoiceID))
+	w.Write(pdf.Bytes())
+}
+
+func (h *invoiceHandlerImpl) MatchPaymentByReference(w http.ResponseWriter, r *http.Request) {
+	var payment model.InvoicePaymentDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&payment)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	matchError := h.invoiceService.MatchPaymentByReference(bookID, payment)
+	if model.IsExisting(matchError) {
+		handleError(matchError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// ImportPayments reads a camt.053 or camt.054 document from the body and books its payments onto the account
+// of the query parameter bankAccountId
+func (h *invoiceHandlerImpl) ImportPayments(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	bankAccountID := r.URL.Query().Get("bankAccountId")
+	report, err := h.invoiceService.ImportPayments(bookID, bankAccountID, http.MaxBytesReader(w, r.Body, maxBankStatementSize))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,30 +1,34 @@
 package handler
 
 import (
+	"crypto/rsa"
 	"errors"
+	"sync"
 
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type mockAccountingService struct {
 	accountTables  map[string][]model.AccountTableDTO
 	accountOptions map[string][]model.AccountOptionDTO
 	bookings       map[string][]model.BookingDTO
+	linkedBooks    map[string]string
 }
 
 type mockUserService struct {
-	createdUsers   []model.ApplicationUserDTO
-	existingUsers  []model.ApplicationUserDTO
-	writeAccessMap map[string]bool
-	ownerMap       map[string]bool
+	createdUsers  []model.ApplicationUserDTO
+	existingUsers []model.ApplicationUserDTO
+	permissionMap map[string]bool
+	archivedBooks map[string]bool
 }
 
 func CreateMockUserService() mockUserService {
 	return mockUserService{
-		createdUsers:   []model.ApplicationUserDTO{},
-		existingUsers:  []model.ApplicationUserDTO{},
-		writeAccessMap: map[string]bool{},
-		ownerMap:       map[string]bool{},
+		createdUsers:  []model.ApplicationUserDTO{},
+		existingUsers: []model.ApplicationUserDTO{},
+		permissionMap: map[string]bool{},
+		archivedBooks: map[string]bool{},
 	}
 }
 
@@ -33,6 +37,7 @@ func CreateMockAccountingService() mockAccountingService {
 		accountTables:  map[string][]model.AccountTableDTO{},
 		accountOptions: map[string][]model.AccountOptionDTO{},
 		bookings:       map[string][]model.BookingDTO{},
+		linkedBooks:    map[string]string{},
 	}
 }
 
@@ -54,13 +59,20 @@ func (mus *mockUserService) FindUserByUsername(
 	return mus.existingUsers[0], nil
 
 }
-func (mus *mockUserService) HasWriteAccessFromBook(userId, bookId string) (bool, model.TokyError) {
-	return mus.writeAccessMap[userId+bookId], nil
+func (mus *mockUserService) ProvisionUser(
+	applicationUser model.ApplicationUserDTO,
+) (model.ApplicationUserDTO, model.TokyError) {
+	mus.createdUsers = append(mus.createdUsers, applicationUser)
+	return applicationUser, nil
+}
+
+func (mus *mockUserService) HasPermission(userId, bookId string, permission types.Permission) (bool, model.TokyError) {
+	return mus.permissionMap[userId+bookId+string(permission)], nil
 
 }
-func (mus *mockUserService) IsOwnerOfBook(userId, bookId string) (bool, model.TokyError) {
-	return mus.ownerMap[userId+bookId], nil
 
+func (mus *mockUserService) IsBookArchived(bookId string) (bool, model.TokyError) {
+	return mus.archivedBooks[bookId], nil
 }
 
 func (mas *mockAccountingService) ReadAccountsFromBook(
@@ -112,7 +124,7 @@ func (mas *mockAccountingService) CreateAccount(
 }
 
 func (mas *mockAccountingService) UpdateAccount(
-	accountID string,
+	bookID, accountID string,
 	updatedAccount model.AccountOptionDTO,
 ) model.TokyError {
 	oldAccounts := mas.accountTables["default"]
@@ -128,7 +140,7 @@ func (mas *mockAccountingService) UpdateAccount(
 	)
 	return nil
 }
-func (mas *mockAccountingService) DeleteAccount(accountID string) model.TokyError {
+func (mas *mockAccountingService) DeleteAccount(bookID, accountID string) model.TokyError {
 	oldAccounts := mas.accountTables["default"]
 	mas.accountTables["default"] = []model.AccountTableDTO{}
 	for _, account := range oldAccounts {
@@ -138,13 +150,13 @@ func (mas *mockAccountingService) DeleteAccount(accountID string) model.TokyErro
 	}
 	return nil
 }
-func (mas *mockAccountingService) CreateBooking(booking model.BookingDTO) model.TokyError {
+func (mas *mockAccountingService) CreateBooking(bookID string, booking model.BookingDTO) model.TokyError {
 	mas.bookings["default"] = append(mas.bookings["default"], booking)
 	return nil
 }
 
 func (mas *mockAccountingService) UpdateBooking(
-	bookingID string,
+	bookID, bookingID string,
 	updatedBooking model.BookingDTO,
 ) model.TokyError {
 	oldBookings := mas.bookings["default"]
@@ -157,7 +169,15 @@ func (mas *mockAccountingService) UpdateBooking(
 	mas.bookings["default"] = append(mas.bookings["default"], updatedBooking)
 	return nil
 }
-func (mas *mockAccountingService) DeleteBooking(bookingID string) model.TokyError {
+func (mas *mockAccountingService) ApproveBooking(bookID, bookingID, userID string) model.TokyError {
+	for i, booking := range mas.bookings["default"] {
+		if booking.BookingID == bookingID {
+			mas.bookings["default"][i].ApprovedBy = userID
+		}
+	}
+	return nil
+}
+func (mas *mockAccountingService) DeleteBooking(bookID, bookingID string) model.TokyError {
 	oldBookings := mas.bookings["default"]
 	mas.bookings["default"] = []model.BookingDTO{}
 	for _, booking := range oldBookings {
@@ -170,6 +190,106 @@ func (mas *mockAccountingService) DeleteBooking(bookingID string) model.TokyErro
 
 func (mas *mockAccountingService) ReadClosingStatements(
 	bookID string,
+	costCenter string,
 ) (model.ClosingSheetStatements, model.TokyError) {
 	return model.ClosingSheetStatements{BalanceSheet: model.BalanceSheet{}}, nil
 }
+
+func (mas *mockAccountingService) ReadIncomeStatementsByCostCenter(
+	bookID string,
+) ([]model.CostCenterIncomeStatement, model.TokyError) {
+	return []model.CostCenterIncomeStatement{}, nil
+}
+
+func (mas *mockAccountingService) ReadAccruals(
+	bookID string,
+	onlyPending bool,
+) ([]model.AccrualDTO, model.TokyError) {
+	return []model.AccrualDTO{}, nil
+}
+
+func (mas *mockAccountingService) CreateInterBookTransfer(bookID string, transfer model.InterBookTransferDTO) (model.InterBookTransferResultDTO, model.TokyError) {
+	return model.InterBookTransferResultDTO{}, nil
+}
+
+func (mas *mockAccountingService) ReadClearingAccounts(bookID string) ([]model.ClearingAccountDTO, model.TokyError) {
+	return []model.ClearingAccountDTO{}, nil
+}
+
+func (mas *mockAccountingService) SaveClearingAccount(bookID, counterpartBookID string, clearingAccount model.ClearingAccountDTO) model.TokyError {
+	return nil
+}
+
+func (mas *mockAccountingService) ReadLinkedBookIdOfBooking(bookID, bookingID string) (string, model.TokyError) {
+	return mas.linkedBooks[bookingID], nil
+}
+
+func (mas *mockAccountingService) ReadBookIdFromAccount(accountId string) (string, model.TokyError) {
+	return "book-of-" + accountId, nil
+}
+
+func (mas *mockAccountingService) ReadBookIdFromBooking(bookingId string) (string, model.TokyError) {
+	return "book-of-" + bookingId, nil
+}
+
+type mockApiTokenAuthenticator struct {
+	tokens map[string]model.ApiTokenDTO
+}
+
+func (mat mockApiTokenAuthenticator) AuthenticateApiToken(token string) (model.ApiTokenDTO, model.TokyError) {
+	apiToken, ok := mat.tokens[token]
+	if !ok {
+		return model.ApiTokenDTO{}, model.CreateBusinessErrorNotFound("not found", errors.ErrUnsupported)
+	}
+	return apiToken, nil
+}
+
+type mockPublicKeyReader struct {
+	keys []rsa.PublicKey
+}
+
+func (mpk mockPublicKeyReader) ReadPublicKeys() []rsa.PublicKey {
+	return mpk.keys
+}
+
+type mockUserServiceBatch struct {
+	mutex     sync.Mutex
+	users     []model.ApplicationUserDTO
+	readCalls int
+}
+
+func (mub *mockUserServiceBatch) ReadAllUsers() ([]model.ApplicationUserDTO, model.TokyError) {
+	mub.mutex.Lock()
+	defer mub.mutex.Unlock()
+	mub.readCalls++
+	return append([]model.ApplicationUserDTO{}, mub.users...), nil
+}
+
+func (mub *mockUserServiceBatch) UpdateUser(user model.ApplicationUserDTO) model.TokyError {
+	return nil
+}
+
+func (mub *mockUserServiceBatch) CreateUser(user model.ApplicationUserDTO) model.TokyError {
+	mub.mutex.Lock()
+	defer mub.mutex.Unlock()
+	mub.users = append(mub.users, user)
+	return nil
+}
+
+func (mub *mockUserServiceBatch) DeleteUser(userId string) model.TokyError {
+	return nil
+}
+
+func (mub *mockUserServiceBatch) SyncUsers(users []model.ApplicationUserDTO, revision int64) (model.UserSyncReportDTO, model.TokyError) {
+	if revision < 0 {
+		return model.UserSyncReportDTO{}, model.CreateBusinessError("outdated", errors.ErrUnsupported)
+	}
+	if len(users) == 0 {
+		return model.UserSyncReportDTO{}, model.CreateBusinessValidationError("empty", errors.ErrUnsupported)
+	}
+	mub.mutex.Lock()
+	defer mub.mutex.Unlock()
+	report := model.UserSyncReportDTO{Revision: revision, Added: len(users), Deleted: len(mub.users)}
+	mub.users = users
+	return report, nil
+}
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie    = "toky_session"
+	loginStateCookie = "toky_login_state"
+	loginValidity    = 10 * time.Minute
+	tokenRefreshLead = 10 * time.Second
+)
+
+type pendingLogin struct {
+	codeVerifier string
+	expiresAt    time.Time
+}
+
+// session holds the tokens of a login. The mutex serializes the refreshes of the session,
+// with refresh token rotation a second refresh with the same refresh token would fail.
+type session struct {
+	mutex           sync.Mutex
+	accessToken     string
+	refreshToken    string
+	accessExpiresAt time.Time
+	expiresAt       time.Time
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+}
+
+// sessionHandlerImpl lets the service act as backend for frontend. It runs the authorization code flow
+// with PKCE against the identity provider and keeps the tokens on the server, the browser only gets
+// an HttpOnly session cookie. The client secret is only used for the token requests.
+// The sessions are only kept in the memory of this instance, so several instances need sticky sessions
+// and a restart ends all sessions.
+type sessionHandlerImpl struct {
+	enabled          bool
+	clientID         string
+	clientSecret     string
+	authorizationUrl string
+	tokenUrl         string
+	redirectUrl      string
+	frontendUrl      string
+	authenticator    Authenticator
+	httpClient       *http.Client
+	mutex            sync.Mutex
+	pendingLogins    map[string]pendingLogin
+	sessions         map[string]*session
+}
+
+// CreateSessionHandler reads the configuration for the session login, it is only active with AUTH_SESSION_ENABLED=true
+func CreateSessionHandler(authenticator Authenticator) *sessionHandlerImpl {
+	if os.Getenv("AUTH_SESSION_ENABLED") != "true" {
+		return &sessionHandlerImpl{}
+	}
+	openIDProvider := os.Getenv("OPENID_JWKS_URL")
+	externalOpenIDProvider := os.Getenv("OPENID_JWKS_EXTERNAL_URL")
+	if externalOpenIDProvider == "" {
+		externalOpenIDProvider = openIDProvider
+	}
+	redirectUrl := os.Getenv("AUTH_SESSION_REDIRECT_URL")
+	if openIDProvider == "" || redirectUrl == "" {
+		panic("OPENID_JWKS_URL and AUTH_SESSION_REDIRECT_URL must be provided for the session login")
+	}
+	frontendUrl := os.Getenv("AUTH_SESSION_FRONTEND_URL")
+	if frontendUrl == "" {
+		frontendUrl = "/"
+	}
+	return CreateSessionHandlerFromConfig(authenticator, os.Getenv("ID_PROVIDER_CLIENT_ID"), os.Getenv("ID_PROVIDER_CLIENT_SECRET"),
+		externalOpenIDProvider+"/auth", openIDProvider+"/token", redirectUrl, frontendUrl)
+}
+
+func CreateSessionHandlerFromConfig(authenticator Authenticator, clientID, clientSecret, authorizationUrl, tokenUrl, redirectUrl, frontendUrl string) *sessionHandlerImpl {
+	return &sessionHandlerImpl{
+		enabled:          true,
+		clientID:         clientID,
+		clientSecret:     clientSecret,
+		authorizationUrl: authorizationUrl,
+		tokenUrl:         tokenUrl,
+		redirectUrl:      redirectUrl,
+		frontendUrl:      frontendUrl,
+		authenticator:    authenticator,
+		httpClient:       &http.Client{Timeout: 10 * time.Second},
+		pendingLogins:    map[string]pendingLogin{},
+		sessions:         map[string]*session{},
+	}
+}
+
+func (h *sessionHandlerImpl) Enabled() bool {
+	return h.enabled
+}
+
+// Login redirects to the identity provider. The state is bound to the browser by a short lived cookie.
+func (h *sessionHandlerImpl) Login(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled {
+		http.NotFound(w, r)
+		return
+	}
+	state, err := randomString()
+	if err != nil {
+		http.Error(w, "Could not start Login", http.StatusInternalServerError)
+		return
+	}
+	codeVerifier, err := randomString()
+	if err != nil {
+		http.Error(w, "Could not start Login", http.StatusInternalServerError)
+		return
+	}
+	h.mutex.Lock()
+	h.removeExpired(time.Now())
+	h.pendingLogins[state] = pendingLogin{codeVerifier: codeVerifier, expiresAt: time.Now().Add(loginValidity)}
+	h.mutex.Unlock()
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {h.clientID},
+		"redirect_uri":          {h.redirectUrl},
+		"scope":                 {"openid"},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Value: state, Path: "/", MaxAge: int(loginValidity.Seconds()),
+		HttpOnly: true, Secure: true, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, h.authorizationUrl+"?"+query.Encode(), http.StatusFound)
+}
+
+// LoginCallback exchanges the code for tokens and starts the session
+func (h *sessionHandlerImpl) LoginCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.enabled {
+		http.NotFound(w, r)
+		return
+	}
+	state := r.URL.Query().Get("state")
+	stateCookie, err := r.Cookie(loginStateCookie)
+	if err != nil || state == "" || stateCookie.Value != state {
+		http.Error(w, "Invalid Login State", http.StatusBadRequest)
+		return
+	}
+	h.mutex.Lock()
+	login, ok := h.pendingLogins[state]
+	delete(h.pendingLogins, state)
+	h.mutex.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		http.Error(w, "Login expired", http.StatusBadRequest)
+		return
+	}
+	tokens, err := h.requestTokens(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {r.URL.Query().Get("code")},
+		"redirect_uri":  {h.redirectUrl},
+		"code_verifier": {login.codeVerifier},
+	})
+	if err != nil {
+		log.Printf("Token exchange failed %v", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	if _, err := h.authenticator.Authenticate(tokens.AccessToken); err != nil {
+		log.Printf("Token of the login is invalid %v", err)
+		http.Error(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+	sessionID, err := randomString()
+	if err != nil {
+		http.Error(w, "Could not start Session", http.StatusInternalServerError)
+		return
+	}
+	h.mutex.Lock()
+	h.removeExpired(time.Now())
+	h.sessions[sessionID] = createSession(tokens, time.Now())
+	h.mutex.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: true})
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: sessionID, Path: "/", HttpOnly: true, Secure: true,
+		SameSite: http.SameSiteStrictMode})
+	http.Redirect(w, r, h.frontendUrl, http.StatusFound)
+}
+
+func (h *sessionHandlerImpl) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		h.mutex.Lock()
+		delete(h.sessions, cookie.Value)
+		h.mutex.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: true,
+		SameSite: http.SameSiteStrictMode})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SessionToken returns the access token of the session cookie and refreshes it shortly before it expires.
+// Concurrent requests of a session wait for the refresh of the first one and use its token.
+func (h *sessionHandlerImpl) SessionToken(r *http.Request) (string, bool) {
+	if !h.enabled {
+		return "", false
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	h.mutex.Lock()
+	currentSession, ok := h.sessions[cookie.Value]
+	h.mutex.Unlock()
+	if !ok {
+		return "", false
+	}
+	currentSession.mutex.Lock()
+	defer currentSession.mutex.Unlock()
+	now := time.Now()
+	if now.After(currentSession.expiresAt) {
+		h.removeSession(cookie.Value, currentSession)
+		return "", false
+	}
+	if now.Add(tokenRefreshLead).Before(currentSession.accessExpiresAt) {
+		return currentSession.accessToken, true
+	}
+	tokens, err := h.requestTokens(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {currentSession.refreshToken},
+	})
+	if err != nil {
+		log.Printf("Token refresh failed %v", err)
+		h.removeSession(cookie.Value, currentSession)
+		return "", false
+	}
+	currentSession.update(tokens, now)
+	return currentSession.accessToken, true
+}
+
+// removeSession drops the session unless it was already replaced
+func (h *sessionHandlerImpl) removeSession(sessionID string, expiredSession *session) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.sessions[sessionID] == expiredSession {
+		delete(h.sessions, sessionID)
+	}
+}
+
+func (h *sessionHandlerImpl) requestTokens(form url.Values) (tokenResponse, error) {
+	form.Set("client_id", h.clientID)
+	if h.clientSecret != "" {
+		form.Set("client_secret", h.clientSecret)
+	}
+	response, err := h.httpClient.Post(h.tokenUrl, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return tokenResponse{}, fmt.Errorf("token endpoint answered with %d", response.StatusCode)
+	}
+	var tokens tokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&tokens); err != nil {
+		return tokenResponse{}, err
+	}
+	if tokens.AccessToken == "" {
+		return tokenResponse{}, errors.New("token endpoint returned no access token")
+	}
+	return tokens, nil
+}
+
+func (h *sessionHandlerImpl) removeExpired(now time.Time) {
+	for state, login := range h.pendingLogins {
+		if now.After(login.expiresAt) {
+			delete(h.pendingLogins, state)
+		}
+	}
+	for sessionID, currentSession := range h.sessions {
+		if currentSession.mutex.TryLock() {
+			expired := now.After(currentSession.expiresAt)
+			currentSession.mutex.Unlock()
+			if expired {
+				delete(h.sessions, sessionID)
+			}
+		}
+	}
+}
+
+func createSession(tokens tokenResponse, now time.Time) *session {
+	createdSession := &session{}
+	createdSession.update(tokens, now)
+	return createdSession
+}
+
+// update stores the tokens of a token response, the caller holds the mutex of the session
+func (s *session) update(tokens tokenResponse, now time.Time) {
+	s.accessToken = tokens.AccessToken
+	s.refreshToken = tokens.RefreshToken
+	s.accessExpiresAt = now.Add(time.Duration(tokens.ExpiresIn) * time.Second)
+	s.expiresAt = s.accessExpiresAt
+	if tokens.RefreshToken != "" && tokens.RefreshExpiresIn > 0 {
+		s.expiresAt = now.Add(time.Duration(tokens.RefreshExpiresIn) * time.Second)
+	}
+}
+
+func randomString() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
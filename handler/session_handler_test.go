@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionHandler_LoginFlow(t *testing.T) {
+	var challenge string
+	refreshed := false
+	identityProvider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "code" || base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(tokenResponse{AccessToken: "dev-anna", RefreshToken: "refresh", ExpiresIn: 0, RefreshExpiresIn: 3600})
+		case "refresh_token":
+			refreshed = true
+			json.NewEncoder(w).Encode(tokenResponse{AccessToken: "dev-anna", RefreshToken: "refresh", ExpiresIn: 300, RefreshExpiresIn: 3600})
+		}
+	}))
+	defer identityProvider.Close()
+	authenticator, _ := CreateDevAuthenticator(strings.NewReader(`[{"token": "dev-anna", "userId": "1", "userName": "anna", "eMail": "anna@example.com"}]`))
+	h := CreateSessionHandlerFromConfig(authenticator, "toky", "secret", "https://idp/auth", identityProvider.URL,
+		"https://toky/auth/callback", "https://toky/")
+
+	loginResponse := httptest.NewRecorder()
+	h.Login(loginResponse, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	location, _ := url.Parse(loginResponse.Header().Get("Location"))
+	challenge = location.Query().Get("code_challenge")
+	state := location.Query().Get("state")
+	if loginResponse.Code != http.StatusFound || challenge == "" || state == "" || location.Query().Get("client_secret") != "" {
+		t.Fatalf("Login() redirect = %v, want authorization request with PKCE", location)
+	}
+	stateCookie := loginResponse.Result().Cookies()[0]
+
+	forgedCallback := httptest.NewRecorder()
+	h.LoginCallback(forgedCallback, httptest.NewRequest(http.MethodGet, "/auth/callback?code=code&state="+state, nil))
+	if forgedCallback.Code != http.StatusBadRequest {
+		t.Errorf("LoginCallback() without state cookie status = %d, want %d", forgedCallback.Code, http.StatusBadRequest)
+	}
+
+	callbackRequest := httptest.NewRequest(http.MethodGet, "/auth/callback?code=code&state="+state, nil)
+	callbackRequest.AddCookie(stateCookie)
+	callbackResponse := httptest.NewRecorder()
+	h.LoginCallback(callbackResponse, callbackRequest)
+	if callbackResponse.Code != http.StatusFound {
+		t.Fatalf("LoginCallback() status = %d, want %d", callbackResponse.Code, http.StatusFound)
+	}
+	var sessionCookieValue *http.Cookie
+	for _, cookie := range callbackResponse.Result().Cookies() {
+		if cookie.Name == sessionCookie {
+			sessionCookieValue = cookie
+		}
+	}
+	if sessionCookieValue == nil || !sessionCookieValue.HttpOnly || strings.Contains(callbackResponse.Body.String(), "dev-anna") {
+		t.Fatalf("LoginCallback() must only hand out an HttpOnly session cookie")
+	}
+
+	apiRequest := httptest.NewRequest(http.MethodGet, "/api/book", nil)
+	apiRequest.AddCookie(sessionCookieValue)
+	token, ok := h.SessionToken(apiRequest)
+	if !ok || token != "dev-anna" || !refreshed {
+		t.Errorf("SessionToken() = %v, %v, want refreshed token of the session", token, ok)
+	}
+
+	h.Logout(httptest.NewRecorder(), apiRequest)
+	if _, ok := h.SessionToken(apiRequest); ok {
+		t.Errorf("SessionToken() after Logout must fail")
+	}
+}
+
+func TestSessionHandler_ConcurrentRefresh(t *testing.T) {
+	var mutex sync.Mutex
+	refreshToken := "refresh-0"
+	refreshes := 0
+	identityProvider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mutex.Lock()
+		defer mutex.Unlock()
+		// the refresh token is rotated, using it a second time fails
+		if r.Form.Get("refresh_token") != refreshToken {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		refreshes++
+		refreshToken = fmt.Sprintf("refresh-%d", refreshes)
+		json.NewEncoder(w).Encode(tokenResponse{AccessToken: "dev-anna", RefreshToken: refreshToken, ExpiresIn: 300, RefreshExpiresIn: 3600})
+	}))
+	defer identityProvider.Close()
+	h := CreateSessionHandlerFromConfig(nil, "toky", "", "https://idp/auth", identityProvider.URL, "https://toky/auth/callback", "https://toky/")
+	h.sessions["session"] = createSession(tokenResponse{AccessToken: "expired", RefreshToken: "refresh-0", ExpiresIn: 0, RefreshExpiresIn: 3600}, time.Now())
+
+	var wait sync.WaitGroup
+	failures := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			request := httptest.NewRequest(http.MethodGet, "/api/book", nil)
+			request.AddCookie(&http.Cookie{Name: sessionCookie, Value: "session"})
+			if token, ok := h.SessionToken(request); !ok || token != "dev-anna" {
+				failures <- token
+			}
+		}()
+	}
+	wait.Wait()
+	close(failures)
+
+	if len(failures) > 0 || refreshes != 1 {
+		t.Errorf("%d of the concurrent requests failed with %d refreshes, want one refresh used by all", len(failures), refreshes)
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type SharingService interface {
+	CreateInvitation(bookID, userID string, invitation model.BookInvitationDTO) (model.BookInvitationDTO, model.TokyError)
+	ReadInvitations(bookID string) ([]model.BookInvitationDTO, model.TokyError)
+	ReadInvitationsOfUser(userID string) ([]model.BookInvitationDTO, model.TokyError)
+	RevokeInvitation(bookID, invitationID string) model.TokyError
+	AcceptInvitation(token, userID string) model.TokyError
+	LeaveBook(bookID, userID string) model.TokyError
+	RequestOwnershipTransfer(bookID, userID string, transfer model.OwnershipTransferDTO) model.TokyError
+	CancelOwnershipTransfer(bookID, userID string) model.TokyError
+	AcceptOwnershipTransfer(bookID, userID string) model.TokyError
+}
+
+type sharingHandlerImpl struct {
+	sharingService SharingService
+}
+
+func CreateSharingHandler(sharingService SharingService) *sharingHandlerImpl {
+	return &sharingHandlerImpl{
+		sharingService: sharingService,
+	}
+}
+
+func (h *sharingHandlerImpl) ReadInvitations(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	invitations, err := h.sharingService.ReadInvitations(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(invitations, w)
+}
+
+func (h *sharingHandlerImpl) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	var invitation model.BookInvitationDTO
+	bookID := r.PathValue("bookID")
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	decoderError := json.NewDecoder(r.Body).Decode(&invitation)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createdInvitation, createError := h.sharingService.CreateInvitation(bookID, userID, invitation)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdInvitation)
+}
+
+func (h *sharingHandlerImpl) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	err := h.sharingService.RevokeInvitation(r.PathValue("bookID"), r.PathValue("invitationID"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *sharingHandlerImpl) ReadInvitationsOfUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	invitations, err := h.sharingService.ReadInvitationsOfUser(userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(invitations, w)
+}
+
+func (h *sharingHandlerImpl) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.sharingService.AcceptInvitation(r.PathValue("token"), userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *sharingHandlerImpl) LeaveBook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.sharingService.LeaveBook(r.PathValue("bookID"), userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *sharingHandlerImpl) RequestOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	var transfer model.OwnershipTransferDTO
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	decoderError := json.NewDecoder(r.Body).Decode(&transfer)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	err := h.sharingService.RequestOwnershipTransfer(r.PathValue("bookID"), userID, transfer)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *sharingHandlerImpl) CancelOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.sharingService.CancelOwnershipTransfer(r.PathValue("bookID"), userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *sharingHandlerImpl) AcceptOwnershipTransfer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := readUserID(w, r)
+	if !ok {
+		return
+	}
+	err := h.sharingService.AcceptOwnershipTransfer(r.PathValue("bookID"), userID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
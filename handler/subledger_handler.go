@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type SubledgerService interface {
+	ReadBusinessPartners(bookID string) ([]model.BusinessPartnerDTO, model.TokyError)
+	CreateBusinessPartner(bookID string, partner model.BusinessPartnerDTO) model.TokyError
+	UpdateBusinessPartner(bookID, partnerID string, partner model.BusinessPartnerDTO) model.TokyError
+	ReadOpenItems(bookID, partnerID string, onlyOpen bool) ([]model.OpenItemDTO, model.TokyError)
+	CreateOpenItem(bookID string, openItem model.OpenItemDTO) model.TokyError
+	CreatePayment(bookID, openItemID string, payment model.OpenItemPaymentDTO) model.TokyError
+	ReadAgingReport(bookID, reportDate string) (model.AgingReportDTO, model.TokyError)
+}
+
+type subledgerHandlerImpl struct {
+	subledgerService SubledgerService
+}
+
+func CreateSubledgerHandler(subledgerService SubledgerService) *subledgerHandlerImpl {
+	return &subledgerHandlerImpl{
+		subledgerService: subledgerService,
+	}
+}
+
+func (h *subledgerHandlerImpl) ReadBusinessPartners(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	partners, err := h.subledgerService.ReadBusinessPartners(bookID)
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(partners, w)
+}
+
+func (h *subledgerHandlerImpl) CreateBusinessPartner(w http.ResponseWriter, r *http.Request) {
+	var partner model.BusinessPartnerDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&partner)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createError := h.subledgerService.CreateBusinessPartner(bookID, partner)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *subledgerHandlerImpl) UpdateBusinessPartner(w http.ResponseWriter, r *http.Request) {
+	var partner model.BusinessPartnerDTO
+	bookID := r.PathValue("bookID")
+	partnerID := r.PathValue("partnerID")
+	decoderError := json.NewDecoder(r.Body).Decode(&partner)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	updateError := h.subledgerService.UpdateBusinessPartner(bookID, partnerID, partner)
+	if model.IsExisting(updateError) {
+		handleError(updateError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *subledgerHandlerImpl) ReadOpenItems(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	queries := r.URL.Query()
+	openItems, err := h.subledgerService.ReadOpenItems(bookID, queries.Get("partnerId"), queries.Get("onlyOpen") == "true")
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(openItems, w)
+}
+
+func (h *subledgerHandlerImpl) CreateOpenItem(w http.ResponseWriter, r *http.Request) {
+	var openItem model.OpenItemDTO
+	bookID := r.PathValue("bookID")
+	decoderError := json.NewDecoder(r.Body).Decode(&openItem)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createError := h.subledgerService.CreateOpenItem(bookID, openItem)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *subledgerHandlerImpl) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	var payment model.OpenItemPaymentDTO
+	bookID := r.PathValue("bookID")
+	openItemID := r.PathValue("openItemID")
+	decoderError := json.NewDecoder(r.Body).Decode(&payment)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createError := h.subledgerService.CreatePayment(bookID, openItemID, payment)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *subledgerHandlerImpl) ReadAgingReport(w http.ResponseWriter, r *http.Request) {
+	bookID := r.PathValue("bookID")
+	report, err := h.subledgerService.ReadAgingReport(bookID, r.URL.Query().Get("date"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(report, w)
+}
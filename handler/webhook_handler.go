@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+type WebhookService interface {
+	ReadWebhooks(bookID string) ([]model.WebhookDTO, model.TokyError)
+	CreateWebhook(bookID string, webhook model.WebhookDTO) (model.WebhookDTO, model.TokyError)
+	DeleteWebhook(bookID, webhookID string) model.TokyError
+	ReadWebhookDeliveries(bookID string) ([]model.WebhookDeliveryDTO, model.TokyError)
+}
+
+type webhookHandlerImpl struct {
+	webhookService WebhookService
+}
+
+func CreateWebhookHandler(webhookService WebhookService) *webhookHandlerImpl {
+	return &webhookHandlerImpl{
+		webhookService: webhookService,
+	}
+}
+
+func (h *webhookHandlerImpl) ReadWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookService.ReadWebhooks(r.PathValue("bookID"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(webhooks, w)
+}
+
+// CreateWebhook answers with the signing secret, which is only ever shown in this response
+func (h *webhookHandlerImpl) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhook model.WebhookDTO
+	decoderError := json.NewDecoder(r.Body).Decode(&webhook)
+	if decoderError != nil {
+		http.Error(w, decoderError.Error(), http.StatusBadRequest)
+		return
+	}
+	createdWebhook, createError := h.webhookService.CreateWebhook(r.PathValue("bookID"), webhook)
+	if model.IsExisting(createError) {
+		handleError(createError, w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdWebhook)
+}
+
+func (h *webhookHandlerImpl) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	err := h.webhookService.DeleteWebhook(r.PathValue("bookID"), r.PathValue("webhookID"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *webhookHandlerImpl) ReadWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.webhookService.ReadWebhookDeliveries(r.PathValue("bookID"))
+	if model.IsExisting(err) {
+		handleError(err, w)
+		return
+	}
+	writeJSON(deliveries, w)
+}
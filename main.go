@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/toky03/toky-finance-accounting-service/api"
 	"github.com/toky03/toky-finance-accounting-service/handler"
@@ -20,20 +21,57 @@ func main() {
 	accountingService :=
 		service.CreateAccountingService(bookRepository)
 	userService := service.CreateApplicationUserService()
+	subledgerService := service.CreateSubledgerService(bookRepository)
+	invoiceService := service.CreateInvoiceService(bookRepository, subledgerService)
+	assetService := service.CreateAssetService(bookRepository)
+	sharingService := service.CreateSharingService(bookRepository)
+	apiTokenService := service.CreateApiTokenService(bookRepository, userService)
+	bookArchiveService := service.CreateBookArchiveService(bookRepository)
+	webhookService := service.CreateWebhookService(bookRepository)
+	eventSinks := []service.EventSink{webhookService}
+	if streamFile := os.Getenv("EVENT_STREAM_FILE"); streamFile != "" {
+		eventSinks = append(eventSinks, service.CreateFileStreamSink(streamFile, "toky.book"))
+	}
+	outboxDispatcher := service.CreateOutboxDispatcher(bookRepository, eventSinks...)
 
 	bookHandler := handler.CreateBookRealmHandler(bookService, userService)
 	monitoringHandler := handler.CreateMonitoringHandler()
-	accountingHandler := handler.CreateAccountingHandler(accountingService, userService)
-	authenticationHandler := handler.CreateAuthenticationHandler(accountingService, userService)
+	authenticator := handler.CreateAuthenticator()
+	sessionHandler := handler.CreateSessionHandler(authenticator)
+	authenticationHandler := handler.CreateAuthenticationHandler(accountingService, userService, apiTokenService, service.CreateClaimMapper(), authenticator, sessionHandler)
+	accountingHandler := handler.CreateAccountingHandler(accountingService, userService, authenticationHandler)
+	subledgerHandler := handler.CreateSubledgerHandler(subledgerService)
+	invoiceHandler := handler.CreateInvoiceHandler(invoiceService)
+	assetHandler := handler.CreateAssetHandler(assetService)
+	sharingHandler := handler.CreateSharingHandler(sharingService)
+	apiTokenHandler := handler.CreateApiTokenHandler(apiTokenService)
+	bookArchiveHandler := handler.CreateBookArchiveHandler(bookArchiveService)
+	consolidationHandler := handler.CreateConsolidationHandler(accountingService, authenticationHandler)
+	webhookHandler := handler.CreateWebhookHandler(webhookService)
 
 	server := api.CreateServer(
 		bookHandler,
 		monitoringHandler,
 		accountingHandler,
 		authenticationHandler,
+		subledgerHandler,
+		invoiceHandler,
+		assetHandler,
+		sharingHandler,
+		apiTokenHandler,
+		sessionHandler,
+		bookArchiveHandler,
+		consolidationHandler,
+		webhookHandler,
 	)
 
-	err := handler.CreateAndRegisterUserBatchService()
+	go accountingService.RunAccrualReversals(time.Hour)
+	go userService.RunOrphanedBookPurge(time.Hour)
+	go outboxDispatcher.RunEventDispatch(5 * time.Second)
+	go webhookService.RunWebhookDeliveries(30 * time.Second)
+
+	accountingServer := handler.CreateAccountingServiceServer(bookService, accountingService, authenticationHandler)
+	err := handler.CreateAndRegisterUserBatchService(accountingServer, authenticationHandler.UnaryAuthenticationInterceptor)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -15,18 +16,110 @@ type ApplicationUserDTO struct {
 	EMail     string `json:"eMail"`
 }
 
+// LoginInformationDto describes how the frontend obtains a token. It must never contain a client secret.
+// With the flow pkce the frontend runs the authorization code flow itself as public client,
+// with the flow session the login is done by the service under LoginUrl and authenticated by a cookie.
 type LoginInformationDto struct {
-	AuthUrl      string `json:"authUrl"`
-	ClientSecret string `json:"clientSecret"`
-	ClientId     string `json:"clientId"`
+	Flow                string `json:"flow"`
+	AuthorizationUrl    string `json:"authorizationUrl,omitempty"`
+	TokenUrl            string `json:"tokenUrl,omitempty"`
+	ClientId            string `json:"clientId,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+	LoginUrl            string `json:"loginUrl,omitempty"`
+	LogoutUrl           string `json:"logoutUrl,omitempty"`
 }
 
 type BookRealmDTO struct {
-	BookID      string               `json:"bookId"`
-	BookName    string               `json:"bookName"`
-	Owner       ApplicationUserDTO   `json:"owner"`
-	WriteAccess []ApplicationUserDTO `json:"writeAccess"`
-	ReadAccess  []ApplicationUserDTO `json:"readAccess"`
+	BookID         string             `json:"bookId"`
+	BookName       string             `json:"bookName"`
+	Owner          ApplicationUserDTO `json:"owner"`
+	PendingOwnerID string             `json:"pendingOwnerId,omitempty"`
+	ArchivedAt     string             `json:"archivedAt,omitempty"`
+	Members        []BookMemberDTO    `json:"members"`
+}
+
+type BookMemberDTO struct {
+	User ApplicationUserDTO `json:"user"`
+	Role types.BookRole     `json:"role"`
+}
+
+type BookInvitationDTO struct {
+	InvitationID string         `json:"invitationId"`
+	BookID       string         `json:"bookId"`
+	BookName     string         `json:"bookName"`
+	EMail        string         `json:"eMail"`
+	Role         types.BookRole `json:"role"`
+	Token        string         `json:"token"`
+	ExpiresAt    string         `json:"expiresAt"`
+}
+
+// BookCloneDTO selects what is copied into the new book, an empty book name keeps the name of the copied book.
+// With closing balances the result of the year is carried forward into the result account, a passive inventory account.
+type BookCloneDTO struct {
+	BookName      string          `json:"bookName"`
+	Mode          types.CloneMode `json:"mode"`
+	ResultAccount string          `json:"resultAccount"`
+}
+
+// BookArchiveVersion is the version of the book archive format written by the export
+const BookArchiveVersion = 1
+
+// BookArchiveDTO is the portable form of a book. The ids of accounts and bookings are only
+// references within the archive, an import creates the book with new ids.
+type BookArchiveDTO struct {
+	Version         int                     `json:"version"`
+	ExportedAt      string                  `json:"exportedAt"`
+	BookName        string                  `json:"bookName"`
+	Owner           ApplicationUserDTO      `json:"owner"`
+	Members         []BookMemberDTO         `json:"members"`
+	InvoiceSettings *InvoiceSettingsDTO     `json:"invoiceSettings,omitempty"`
+	Accounts        []BookArchiveAccountDTO `json:"accounts"`
+	Bookings        []BookingDTO            `json:"bookings"`
+}
+
+// BookArchiveAccountDTO carries the closing balance of the account so that an import can validate the bookings
+type BookArchiveAccountDTO struct {
+	AccountOptionDTO
+	Saldo            string                     `json:"saldo"`
+	SaldierungColumn types.SaldierungColumnType `json:"saldierungColumn,omitempty"`
+}
+
+type BookImportReportDTO struct {
+	BookID   string `json:"bookId"`
+	Accounts int    `json:"accounts"`
+	Bookings int    `json:"bookings"`
+}
+
+type OwnershipTransferDTO struct {
+	NewOwnerID string `json:"newOwnerId"`
+}
+
+// ClaimRolesDTO holds the roles mapped from the claims of an OIDC token.
+// GlobalRoles apply to every book, BookRoles are keyed by the book id.
+type ClaimRolesDTO struct {
+	GlobalRoles []types.BookRole
+	BookRoles   map[string][]types.BookRole
+}
+
+// UserSyncReportDTO counts the changes applied by a user snapshot
+type UserSyncReportDTO struct {
+	Revision  int64
+	Added     int
+	Updated   int
+	Deleted   int
+	Unchanged int
+}
+
+// ApiTokenDTO describes a personal api token. The token itself is only returned once on creation.
+type ApiTokenDTO struct {
+	TokenID   string           `json:"tokenId"`
+	Name      string           `json:"name"`
+	Prefix    string           `json:"prefix"`
+	Scope     types.TokenScope `json:"scope"`
+	BookIDs   []string         `json:"bookIds"`
+	ExpiresAt string           `json:"expiresAt"`
+	Token     string           `json:"token,omitempty"`
+	UserID    string           `json:"-"`
 }
 
 type AccountTableDTO struct {
@@ -49,6 +142,7 @@ type TableBookingDTO struct {
 	BookingAccount string                     `json:"bookingAccount"`
 	Ammount        string                     `json:"ammount"`
 	Description    string                     `json:"description"`
+	CostCenter     string                     `json:"costCenter"`
 }
 
 type AccountOptionDTO struct {
@@ -62,12 +156,253 @@ type AccountOptionDTO struct {
 }
 
 type BookingDTO struct {
-	BookingID    string `json:"bookingId"`
-	SollAccount  string `json:"sollAccount"`
-	HabenAccount string `json:"habenAccount"`
-	Description  string `json:"description"`
-	Date         string `json:"date"`
-	Ammount      string `json:"ammount"`
+	BookingID         string            `json:"bookingId"`
+	SollAccount       string            `json:"sollAccount"`
+	HabenAccount      string            `json:"habenAccount"`
+	Description       string            `json:"description"`
+	Date              string            `json:"date"`
+	Ammount           string            `json:"ammount"`
+	CostCenter        string            `json:"costCenter"`
+	BookingType       types.BookingType `json:"bookingType"`
+	ReversalDate      string            `json:"reversalDate"`
+	ReversalBookingID string            `json:"reversalBookingId"`
+	LinkedBookingID   string            `json:"linkedBookingId,omitempty"`
+	ApprovedBy        string            `json:"approvedBy,omitempty"`
+	ApprovedAt        string            `json:"approvedAt,omitempty"`
+}
+
+// InterBookTransferDTO moves an amount from an account of the book to an account of the target book.
+// Both books book the transfer against their clearing account for the other book.
+type InterBookTransferDTO struct {
+	TargetBookID  string `json:"targetBookId"`
+	SourceAccount string `json:"sourceAccount"`
+	TargetAccount string `json:"targetAccount"`
+	Ammount       string `json:"ammount"`
+	Date          string `json:"date"`
+	Description   string `json:"description"`
+}
+
+type InterBookTransferResultDTO struct {
+	SourceBooking BookingDTO `json:"sourceBooking"`
+	TargetBooking BookingDTO `json:"targetBooking"`
+}
+
+// WebhookDTO subscribes the url to the events of a book, no events means all events.
+// The secret is only returned when the webhook is created.
+type WebhookDTO struct {
+	WebhookID string               `json:"webhookId"`
+	Url       string               `json:"url"`
+	Events    []types.WebhookEvent `json:"events"`
+	Secret    string               `json:"secret,omitempty"`
+}
+
+// WebhookPayloadDTO is the body posted to a webhook, signed with the secret of the webhook
+type WebhookPayloadDTO struct {
+	EventID    string             `json:"eventId"`
+	Event      types.WebhookEvent `json:"event"`
+	BookID     string             `json:"bookId"`
+	OccurredAt string             `json:"occurredAt"`
+	Data       interface{}        `json:"data"`
+}
+
+type WebhookDeliveryDTO struct {
+	DeliveryID     string                      `json:"deliveryId"`
+	WebhookID      string                      `json:"webhookId"`
+	Event          types.WebhookEvent          `json:"event"`
+	Status         types.WebhookDeliveryStatus `json:"status"`
+	Attempts       int                         `json:"attempts"`
+	NextAttemptAt  string                      `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int                         `json:"lastStatusCode,omitempty"`
+	LastError      string                      `json:"lastError,omitempty"`
+	CreatedAt      string                      `json:"createdAt"`
+}
+
+// DomainEventDTO is an event of the outbox as handed to the event sinks.
+// The event id grows with every event, a consumer rebuilds a book by applying its events in the order of their id.
+type DomainEventDTO struct {
+	EventID    string                `json:"eventId"`
+	Type       types.DomainEventType `json:"type"`
+	BookID     string                `json:"bookId"`
+	OccurredAt string                `json:"occurredAt"`
+	Data       json.RawMessage       `json:"data"`
+}
+
+// EventStreamMessageDTO is one line of the event stream file, the subject follows the NATS convention
+type EventStreamMessageDTO struct {
+	Subject string         `json:"subject"`
+	Event   DomainEventDTO `json:"event"`
+}
+
+// BookClonedDTO is the data of the book.cloned and year.closed events, a year is closed by carrying
+// the closing balances into a new book
+type BookClonedDTO struct {
+	BookID    string          `json:"bookId"`
+	NewBookID string          `json:"newBookId"`
+	Mode      types.CloneMode `json:"mode"`
+}
+
+type ClearingAccountDTO struct {
+	CounterpartBookID string `json:"counterpartBookId"`
+	AccountID         string `json:"accountId"`
+}
+
+type AccrualDTO struct {
+	BookingDTO
+	Reversed bool `json:"reversed"`
+	Overdue  bool `json:"overdue"`
+}
+
+type BusinessPartnerDTO struct {
+	PartnerID   string            `json:"partnerId"`
+	PartnerType types.PartnerType `json:"partnerType"`
+	Name        string            `json:"name"`
+	Street      string            `json:"street"`
+	ZipCode     string            `json:"zipCode"`
+	City        string            `json:"city"`
+	Country     string            `json:"country"`
+	EMail       string            `json:"eMail"`
+	AccountID   string            `json:"accountId"`
+}
+
+type OpenItemDTO struct {
+	OpenItemID  string               `json:"openItemId"`
+	PartnerID   string               `json:"partnerId"`
+	PartnerName string               `json:"partnerName"`
+	BookingID   string               `json:"bookingId"`
+	Reference   string               `json:"reference"`
+	Date        string               `json:"date"`
+	DueDate     string               `json:"dueDate"`
+	Ammount     string               `json:"ammount"`
+	OpenAmmount string               `json:"openAmmount"`
+	Status      types.OpenItemStatus `json:"status"`
+	Payments    []OpenItemPaymentDTO `json:"payments"`
+}
+
+type OpenItemPaymentDTO struct {
+	PaymentID string `json:"paymentId"`
+	BookingID string `json:"bookingId"`
+	Date      string `json:"date"`
+	Ammount   string `json:"ammount"`
+}
+
+type AgingReportDTO struct {
+	ReportDate string                `json:"reportDate"`
+	Entries    []AgingReportEntryDTO `json:"entries"`
+	Total      AgingReportEntryDTO   `json:"total"`
+}
+
+type AgingReportEntryDTO struct {
+	PartnerID   string            `json:"partnerId"`
+	PartnerName string            `json:"partnerName"`
+	PartnerType types.PartnerType `json:"partnerType"`
+	NotDue      string            `json:"notDue"`
+	Days1To30   string            `json:"days1To30"`
+	Days31To60  string            `json:"days31To60"`
+	Days61To90  string            `json:"days61To90"`
+	Over90      string            `json:"over90"`
+	Total       string            `json:"total"`
+}
+
+type InvoiceSettingsDTO struct {
+	CreditorName   string `json:"creditorName"`
+	Street         string `json:"street"`
+	BuildingNumber string `json:"buildingNumber"`
+	ZipCode        string `json:"zipCode"`
+	City           string `json:"city"`
+	Country        string `json:"country"`
+	IBAN           string `json:"iban"`
+	Currency       string `json:"currency"`
+}
+
+type InvoiceDTO struct {
+	InvoiceID        string           `json:"invoiceId"`
+	InvoiceNumber    string           `json:"invoiceNumber"`
+	PartnerID        string           `json:"partnerId"`
+	PartnerName      string           `json:"partnerName"`
+	RevenueAccountID string           `json:"revenueAccountId"`
+	BookingID        string           `json:"bookingId"`
+	OpenItemID       string           `json:"openItemId"`
+	Date             string           `json:"date"`
+	DueDate          string           `json:"dueDate"`
+	Currency         string           `json:"currency"`
+	ReferenceType    string           `json:"referenceType"`
+	Reference        string           `json:"reference"`
+	Message          string           `json:"message"`
+	Ammount          string           `json:"ammount"`
+	Lines            []InvoiceLineDTO `json:"lines"`
+}
+
+type InvoiceLineDTO struct {
+	Description string `json:"description"`
+	Quantity    string `json:"quantity"`
+	UnitPrice   string `json:"unitPrice"`
+	Ammount     string `json:"ammount"`
+}
+
+type InvoicePaymentDTO struct {
+	Reference string `json:"reference"`
+	BookingID string `json:"bookingId"`
+	Ammount   string `json:"ammount"`
+}
+
+// PaymentImportReportDTO lists the payments with a reference of an imported bank statement.
+// Unmatched payments are not booked, unless the booking was created but could not be matched, then it carries the BookingID.
+type PaymentImportReportDTO struct {
+	Matched   []ImportedPaymentDTO `json:"matched"`
+	Unmatched []ImportedPaymentDTO `json:"unmatched"`
+}
+
+type ImportedPaymentDTO struct {
+	Reference string `json:"reference"`
+	Date      string `json:"date"`
+	Ammount   string `json:"ammount"`
+	Currency  string `json:"currency"`
+	InvoiceID string `json:"invoiceId,omitempty"`
+	BookingID string `json:"bookingId,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+type FixedAssetDTO struct {
+	AssetID               string                   `json:"assetId"`
+	Name                  string                   `json:"name"`
+	AssetAccountID        string                   `json:"assetAccountId"`
+	DepreciationAccountID string                   `json:"depreciationAccountId"`
+	AcquisitionDate       string                   `json:"acquisitionDate"`
+	AcquisitionCost       string                   `json:"acquisitionCost"`
+	ResidualValue         string                   `json:"residualValue"`
+	UsefulLifeYears       int                      `json:"usefulLifeYears"`
+	Method                types.DepreciationMethod `json:"method"`
+	DecliningRate         string                   `json:"decliningRate"`
+}
+
+type DepreciationRunDTO struct {
+	Year     int                      `json:"year"`
+	Postings []DepreciationPostingDTO `json:"postings"`
+}
+
+type DepreciationPostingDTO struct {
+	AssetID   string `json:"assetId"`
+	AssetName string `json:"assetName"`
+	BookingID string `json:"bookingId"`
+	Ammount   string `json:"ammount"`
+}
+
+type AssetScheduleDTO struct {
+	Year    int                     `json:"year"`
+	Entries []AssetScheduleEntryDTO `json:"entries"`
+	Total   AssetScheduleEntryDTO   `json:"total"`
+}
+
+type AssetScheduleEntryDTO struct {
+	AssetID                 string `json:"assetId"`
+	AssetName               string `json:"assetName"`
+	AcquisitionDate         string `json:"acquisitionDate"`
+	AcquisitionCost         string `json:"acquisitionCost"`
+	OpeningBookValue        string `json:"openingBookValue"`
+	Depreciation            string `json:"depreciation"`
+	DepreciationBooked      bool   `json:"depreciationBooked"`
+	AccumulatedDepreciation string `json:"accumulatedDepreciation"`
+	ClosingBookValue        string `json:"closingBookValue"`
 }
 
 type ClosingSheetStatements struct {
@@ -89,6 +424,48 @@ type IncomeStatement struct {
 	BalanceSum string                  `json:"balanceSum"`
 }
 
+type CostCenterIncomeStatement struct {
+	CostCenter      string          `json:"costCenter"`
+	IncomeStatement IncomeStatement `json:"incomeStatement"`
+}
+
+// ConsolidationDTO selects the books of a consolidated report. Accounts are mapped onto the group chart
+// by their account number, the leading number of the account name, unless an explicit mapping is given.
+type ConsolidationDTO struct {
+	BookIDs             []string                      `json:"bookIds"`
+	AccountMappings     []ConsolidationAccountMapping `json:"accountMappings"`
+	EliminationAccounts []string                      `json:"eliminationAccounts"`
+}
+
+// ConsolidationAccountMapping maps one account of a book onto an account of the group chart
+type ConsolidationAccountMapping struct {
+	BookID       string `json:"bookId"`
+	AccountID    string `json:"accountId"`
+	GroupAccount string `json:"groupAccount"`
+}
+
+type ConsolidatedStatementsDTO struct {
+	ClosingSheetStatements
+	Accounts              []ConsolidatedAccountDTO `json:"accounts"`
+	EliminatedAccounts    []ConsolidatedAccountDTO `json:"eliminatedAccounts"`
+	EliminationDifference string                   `json:"eliminationDifference"`
+}
+
+// ConsolidatedAccountDTO is an account of the group chart with the accounts of the books mapped onto it
+type ConsolidatedAccountDTO struct {
+	GroupAccount string                      `json:"groupAccount"`
+	Category     types.AccountCategory       `json:"category"`
+	Saldo        string                      `json:"saldo"`
+	Sources      []ConsolidatedAccountSource `json:"sources"`
+}
+
+type ConsolidatedAccountSource struct {
+	BookID      string `json:"bookId"`
+	AccountID   string `json:"accountId"`
+	AccountName string `json:"accountName"`
+	Saldo       string `json:"saldo"`
+}
+
 type ClosingStatementEntry struct {
 	Name    string `json:"name"`
 	Ammount string `json:"ammount"`
@@ -103,14 +480,54 @@ func (booking BookingDTO) ReadDateFormatted() (date string) {
 	return
 }
 
+func (booking BookingDTO) ReadCostCenterTrimmed() string {
+	return strings.TrimSpace(booking.CostCenter)
+}
+
 func (account AccountOptionDTO) ToAccountTableDTO(bookingEntity BookRealmEntity) AccountTableEntity {
 	return AccountTableEntity{
-		BookRealmEntity: bookingEntity,
-		Category:        account.Category,
-		Description:     account.Description,
-		AccountName:     account.AccountName,
-		Type:            account.Type,
-		SubCategory:     account.SubCategory,
-		StartBalance:    account.StartBalance,
+		BookRealmEntity:   bookingEntity,
+		BookRealmEntityID: bookingEntity.ID,
+		Category:          account.Category,
+		Description:       account.Description,
+		AccountName:       account.AccountName,
+		Type:              account.Type,
+		SubCategory:       account.SubCategory,
+		StartBalance:      account.StartBalance,
+	}
+}
+
+func (partner BusinessPartnerDTO) ToBusinessPartnerEntity(bookID uint, accountID uint) BusinessPartnerEntity {
+	return BusinessPartnerEntity{
+		BookRealmEntityID:    bookID,
+		PartnerType:          partner.PartnerType,
+		Name:                 strings.TrimSpace(partner.Name),
+		Street:               partner.Street,
+		ZipCode:              partner.ZipCode,
+		City:                 partner.City,
+		Country:              partner.Country,
+		EMail:                partner.EMail,
+		AccountTableEntityID: accountID,
+	}
+}
+
+// Grants checks whether the api token covers the book and whether its scope allows the permission.
+// Administering a book always requires a personal login.
+func (token ApiTokenDTO) Grants(bookID string, permission types.Permission) bool {
+	bookCovered := false
+	for _, tokenBookID := range token.BookIDs {
+		if tokenBookID == bookID {
+			bookCovered = true
+		}
+	}
+	if !bookCovered {
+		return false
+	}
+	switch token.Scope {
+	case types.TokenScopeRead:
+		return permission == types.PermissionRead
+	case types.TokenScopeWrite:
+		return permission != types.PermissionAdminister
 	}
+	return false
 }
@@ -1,6 +1,10 @@
 package model
 
 import (
+	"encoding/json"
+	"strings"
+	"time"
+
 	"github.com/jinzhu/gorm"
 	"github.com/toky03/toky-finance-accounting-service/bookingutils"
 	"github.com/toky03/toky-finance-accounting-service/types"
@@ -12,29 +16,61 @@ type ApplicationUserEntity struct {
 	FirstName string `gorm:"first_name"`
 	LastName  string `gorm:"last_name"`
 	EMail     string `gorm:"email;unique;not null"`
+	// SyncedAt is set once the user was part of a user snapshot, only such users are deleted by a later snapshot
+	SyncedAt *time.Time
 }
 
 type BookRealmEntity struct {
 	gorm.Model
-	BookName    string `gorm:"book_name"`
-	OwnerID     string
-	Owner       ApplicationUserEntity          `gorm:"PRELOAD:true"`
-	WriteAccess []*WriteApplicationUserWrapper `gorm:"many2many:map_write_access;PRELOAD:true;"`
-	ReadAccess  []*ReadApplicationUserWrapper  `gorm:"many2many:map_read_access;PRELOAD:true;"`
+	BookName        string `gorm:"book_name"`
+	OwnerID         string
+	Owner           ApplicationUserEntity      `gorm:"PRELOAD:true"`
+	PendingOwnerID  string                     `gorm:"pending_owner_id"`
+	ArchivedAt      *time.Time                 `gorm:"archived_at;index"`
+	RoleAssignments []BookRoleAssignmentEntity `gorm:"PRELOAD:true"`
 }
 
-type WriteApplicationUserWrapper struct {
+type BookRoleAssignmentEntity struct {
 	gorm.Model
-	ApplicationUserEntityID string
+	BookRealmEntityID       uint                  `gorm:"uniqueIndex:idx_book_user_role"`
+	ApplicationUserEntityID string                `gorm:"uniqueIndex:idx_book_user_role"`
 	ApplicationUserEntity   ApplicationUserEntity `gorm:"PRELOAD:true"`
-	WriteAccessBookRealms   []*BookRealmEntity    `gorm:"many2many:map_write_access;"`
+	Role                    types.BookRole        `gorm:"role"`
 }
 
-type ReadApplicationUserWrapper struct {
+type BookInvitationEntity struct {
 	gorm.Model
-	ApplicationUserEntityID string
-	ApplicationUserEntity   ApplicationUserEntity `gorm:"PRELOAD:true"`
-	ReadAccessBookRealms    []*BookRealmEntity    `gorm:"many2many:map_read_access;"`
+	BookRealmEntityID uint            `gorm:"index"`
+	BookRealmEntity   BookRealmEntity `gorm:"PRELOAD:true"`
+	Token             string          `gorm:"token;uniqueIndex"`
+	EMail             string          `gorm:"email;index"`
+	Role              types.BookRole  `gorm:"role"`
+	InvitedByID       string          `gorm:"invited_by_id"`
+	ExpiresAt         time.Time       `gorm:"expires_at"`
+	AcceptedByID      string          `gorm:"accepted_by_id"`
+}
+
+type ApiTokenEntity struct {
+	gorm.Model
+	ApplicationUserEntityID string `gorm:"index"`
+	Name                    string `gorm:"name"`
+	TokenHash               string `gorm:"token_hash;uniqueIndex"`
+	Prefix                  string `gorm:"prefix"`
+	Scope                   types.TokenScope
+	ExpiresAt               time.Time `gorm:"expires_at"`
+	Books                   []ApiTokenBookEntity
+}
+
+type ApiTokenBookEntity struct {
+	gorm.Model
+	ApiTokenEntityID  uint `gorm:"index"`
+	BookRealmEntityID uint `gorm:"index"`
+}
+
+// UserSyncStateEntity stores the revision of the last user snapshot, there is only one row
+type UserSyncStateEntity struct {
+	ID       uint `gorm:"primaryKey"`
+	Revision int64
 }
 
 type AccountTableEntity struct {
@@ -58,6 +94,182 @@ type BookingEntity struct {
 	SollBookingAccount    AccountTableEntity `gorm:"PRELOAD"`
 	Ammount               string             `gorm:"ammount"`
 	Description           string             `gorm:"description"`
+	CostCenter            string             `gorm:"cost_center;index"`
+	BookingType           types.BookingType  `gorm:"booking_type;index"`
+	ReversalDate          string             `gorm:"reversal_date"`
+	ReversalBookingID     uint               `gorm:"reversal_booking_id"`
+	LinkedBookingID       uint               `gorm:"linked_booking_id;index"`
+	ApprovedBy            string             `gorm:"approved_by"`
+	ApprovedAt            *time.Time         `gorm:"approved_at"`
+}
+
+// WebhookEntity subscribes an url to events of a book. The secret signs the payloads and is therefore stored in plain text.
+type WebhookEntity struct {
+	gorm.Model
+	BookRealmEntityID uint   `gorm:"index"`
+	Url               string `gorm:"url"`
+	Secret            string `gorm:"secret"`
+	Events            string `gorm:"events"`
+}
+
+// WebhookDeliveryEntity is one event for one webhook, it stays pending until it is delivered or out of attempts
+type WebhookDeliveryEntity struct {
+	gorm.Model
+	WebhookEntityID   uint                        `gorm:"index"`
+	BookRealmEntityID uint                        `gorm:"index"`
+	Event             types.WebhookEvent          `gorm:"event"`
+	Payload           string                      `gorm:"payload"`
+	Status            types.WebhookDeliveryStatus `gorm:"status;index"`
+	Attempts          int                         `gorm:"attempts"`
+	NextAttemptAt     time.Time                   `gorm:"next_attempt_at;index"`
+	LastStatusCode    int                         `gorm:"last_status_code"`
+	LastError         string                      `gorm:"last_error"`
+}
+
+// OutboxEventEntity is a domain event written in the transaction of the change it describes.
+// The id orders the events and is never reused. The ids are assigned when an event is written, not on commit,
+// so a long transaction may commit an event after events with higher ids were already delivered.
+type OutboxEventEntity struct {
+	gorm.Model
+	BookRealmEntityID uint                  `gorm:"index"`
+	EventType         types.DomainEventType `gorm:"event_type"`
+	Payload           string                `gorm:"payload"`
+}
+
+// OutboxCursorEntity is the id of the last outbox event a sink has received
+type OutboxCursorEntity struct {
+	gorm.Model
+	Sink        string `gorm:"uniqueIndex"`
+	LastEventID uint
+}
+
+// OutboxGapEntity is an event id the cursor of the sink has passed without finding the event. The transaction
+// which obtained the id either commits later and the event is delivered then, or it was rolled back and the
+// gap expires.
+type OutboxGapEntity struct {
+	gorm.Model
+	Sink    string `gorm:"index"`
+	EventID uint
+}
+
+// DomainEvent is handed to the repository together with the change it describes. Read is called within the
+// transaction after the change was written, so the book and data contain the ids assigned by the database.
+type DomainEvent struct {
+	Type types.DomainEventType
+	Read func() (bookID uint, data interface{})
+}
+
+// BookEvent describes a change of the book for the books which the repository selects itself,
+// like the books handed over on the deletion of their owner
+type BookEvent func(eventType types.DomainEventType, book *BookRealmEntity) DomainEvent
+
+// ClearingAccountEntity is the account of a book against which transfers with the counterpart book are booked
+type ClearingAccountEntity struct {
+	gorm.Model
+	BookRealmEntityID    uint `gorm:"uniqueIndex:idx_clearing_counterpart"`
+	CounterpartBookID    uint `gorm:"uniqueIndex:idx_clearing_counterpart"`
+	AccountTableEntityID uint
+}
+
+type BusinessPartnerEntity struct {
+	gorm.Model
+	BookRealmEntityID    uint
+	PartnerType          types.PartnerType `gorm:"partner_type"`
+	Name                 string            `gorm:"name"`
+	Street               string            `gorm:"street"`
+	ZipCode              string            `gorm:"zip_code"`
+	City                 string            `gorm:"city"`
+	Country              string            `gorm:"country"`
+	EMail                string            `gorm:"email"`
+	AccountTableEntityID uint
+	AccountTableEntity   AccountTableEntity `gorm:"PRELOAD"`
+}
+
+type OpenItemEntity struct {
+	gorm.Model
+	BookRealmEntityID       uint
+	BusinessPartnerEntityID uint
+	BusinessPartnerEntity   BusinessPartnerEntity `gorm:"PRELOAD"`
+	BookingEntityID         uint                  `gorm:"uniqueIndex"`
+	BookingEntity           BookingEntity         `gorm:"PRELOAD"`
+	Reference               string                `gorm:"reference"`
+	DueDate                 string                `gorm:"due_date"`
+	Ammount                 string                `gorm:"ammount"`
+	Payments                []OpenItemPaymentEntity
+}
+
+type OpenItemPaymentEntity struct {
+	gorm.Model
+	OpenItemEntityID uint
+	BookingEntityID  uint
+	BookingEntity    BookingEntity `gorm:"PRELOAD"`
+	Ammount          string        `gorm:"ammount"`
+}
+
+type InvoiceSettingsEntity struct {
+	gorm.Model
+	BookRealmEntityID uint   `gorm:"uniqueIndex"`
+	CreditorName      string `gorm:"creditor_name"`
+	Street            string `gorm:"street"`
+	BuildingNumber    string `gorm:"building_number"`
+	ZipCode           string `gorm:"zip_code"`
+	City              string `gorm:"city"`
+	Country           string `gorm:"country"`
+	IBAN              string `gorm:"iban"`
+	Currency          string `gorm:"currency"`
+}
+
+type InvoiceEntity struct {
+	gorm.Model
+	BookRealmEntityID       uint `gorm:"uniqueIndex:idx_invoice_number"`
+	InvoiceNumber           uint `gorm:"uniqueIndex:idx_invoice_number"`
+	BusinessPartnerEntityID uint
+	BusinessPartnerEntity   BusinessPartnerEntity `gorm:"PRELOAD"`
+	RevenueAccountID        uint
+	BookingEntityID         uint
+	OpenItemEntityID        uint
+	Date                    string `gorm:"date"`
+	DueDate                 string `gorm:"due_date"`
+	Currency                string `gorm:"currency"`
+	ReferenceType           string `gorm:"reference_type"`
+	Reference               string `gorm:"reference;index"`
+	Message                 string `gorm:"message"`
+	Ammount                 string `gorm:"ammount"`
+	Lines                   []InvoiceLineEntity
+}
+
+type InvoiceLineEntity struct {
+	gorm.Model
+	InvoiceEntityID uint
+	Position        int    `gorm:"position"`
+	Description     string `gorm:"description"`
+	Quantity        string `gorm:"quantity"`
+	UnitPrice       string `gorm:"unit_price"`
+	Ammount         string `gorm:"ammount"`
+}
+
+type FixedAssetEntity struct {
+	gorm.Model
+	BookRealmEntityID     uint
+	Name                  string                   `gorm:"name"`
+	AssetAccountID        uint                     `gorm:"asset_account_id"`
+	DepreciationAccountID uint                     `gorm:"depreciation_account_id"`
+	AcquisitionDate       string                   `gorm:"acquisition_date"`
+	AcquisitionCost       string                   `gorm:"acquisition_cost"`
+	ResidualValue         string                   `gorm:"residual_value"`
+	UsefulLifeYears       int                      `gorm:"useful_life_years"`
+	Method                types.DepreciationMethod `gorm:"method"`
+	DecliningRate         string                   `gorm:"declining_rate"`
+	Depreciations         []DepreciationEntity
+}
+
+type DepreciationEntity struct {
+	gorm.Model
+	FixedAssetEntityID uint `gorm:"uniqueIndex:idx_asset_year"`
+	Year               int  `gorm:"uniqueIndex:idx_asset_year"`
+	BookingEntityID    uint `gorm:"booking_entity_id"`
+	BookingEntity      BookingEntity
+	Ammount            string `gorm:"ammount"`
 }
 
 func (applicationUserEntity ApplicationUserEntity) ToApplicationUserDTO() ApplicationUserDTO {
@@ -83,14 +295,36 @@ func (accountEntity AccountTableEntity) ToOptionDTO() AccountOptionDTO {
 }
 
 func (bookingEntity BookingEntity) ToBookingDTO() BookingDTO {
-	return BookingDTO{
+	bookingDTO := BookingDTO{
 		Ammount:      bookingEntity.Ammount,
 		Date:         bookingEntity.Date,
 		Description:  bookingEntity.Description,
 		HabenAccount: bookingutils.UintToString(bookingEntity.HabenBookingAccountID),
 		SollAccount:  bookingutils.UintToString(bookingEntity.SollBookingAccountID),
 		BookingID:    bookingutils.UintToString(bookingEntity.ID),
+		CostCenter:   bookingEntity.CostCenter,
+		BookingType:  bookingEntity.ReadBookingType(),
+		ReversalDate: bookingEntity.ReversalDate,
+	}
+	if bookingEntity.ReversalBookingID != 0 {
+		bookingDTO.ReversalBookingID = bookingutils.UintToString(bookingEntity.ReversalBookingID)
 	}
+	if bookingEntity.LinkedBookingID != 0 {
+		bookingDTO.LinkedBookingID = bookingutils.UintToString(bookingEntity.LinkedBookingID)
+	}
+	if bookingEntity.ApprovedAt != nil {
+		bookingDTO.ApprovedBy = bookingEntity.ApprovedBy
+		bookingDTO.ApprovedAt = bookingEntity.ApprovedAt.Format(time.RFC3339)
+	}
+	return bookingDTO
+}
+
+// ReadBookingType treats bookings without type as standard bookings
+func (bookingEntity BookingEntity) ReadBookingType() types.BookingType {
+	if bookingEntity.BookingType == "" {
+		return types.BookingTypeStandard
+	}
+	return bookingEntity.BookingType
 }
 
 func (bookingEntity BookingEntity) ToTableBookingDTO(column types.SaldierungColumnType) TableBookingDTO {
@@ -107,5 +341,180 @@ func (bookingEntity BookingEntity) ToTableBookingDTO(column types.SaldierungColu
 		Description:    bookingEntity.Description,
 		Column:         column,
 		BookingAccount: bookingAccount,
+		CostCenter:     bookingEntity.CostCenter,
+	}
+}
+
+func (partnerEntity BusinessPartnerEntity) ToBusinessPartnerDTO() BusinessPartnerDTO {
+	return BusinessPartnerDTO{
+		PartnerID:   bookingutils.UintToString(partnerEntity.ID),
+		PartnerType: partnerEntity.PartnerType,
+		Name:        partnerEntity.Name,
+		Street:      partnerEntity.Street,
+		ZipCode:     partnerEntity.ZipCode,
+		City:        partnerEntity.City,
+		Country:     partnerEntity.Country,
+		EMail:       partnerEntity.EMail,
+		AccountID:   bookingutils.UintToString(partnerEntity.AccountTableEntityID),
+	}
+}
+
+func (paymentEntity OpenItemPaymentEntity) ToOpenItemPaymentDTO() OpenItemPaymentDTO {
+	return OpenItemPaymentDTO{
+		PaymentID: bookingutils.UintToString(paymentEntity.ID),
+		BookingID: bookingutils.UintToString(paymentEntity.BookingEntityID),
+		Date:      paymentEntity.BookingEntity.Date,
+		Ammount:   paymentEntity.Ammount,
+	}
+}
+
+func (settingsEntity InvoiceSettingsEntity) ToInvoiceSettingsDTO() InvoiceSettingsDTO {
+	return InvoiceSettingsDTO{
+		CreditorName:   settingsEntity.CreditorName,
+		Street:         settingsEntity.Street,
+		BuildingNumber: settingsEntity.BuildingNumber,
+		ZipCode:        settingsEntity.ZipCode,
+		City:           settingsEntity.City,
+		Country:        settingsEntity.Country,
+		IBAN:           settingsEntity.IBAN,
+		Currency:       settingsEntity.Currency,
+	}
+}
+
+func (invoiceEntity InvoiceEntity) ToInvoiceDTO() InvoiceDTO {
+	lines := make([]InvoiceLineDTO, 0, len(invoiceEntity.Lines))
+	for _, line := range invoiceEntity.Lines {
+		lines = append(lines, InvoiceLineDTO{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			Ammount:     line.Ammount,
+		})
+	}
+	return InvoiceDTO{
+		InvoiceID:        bookingutils.UintToString(invoiceEntity.ID),
+		InvoiceNumber:    bookingutils.UintToString(invoiceEntity.InvoiceNumber),
+		PartnerID:        bookingutils.UintToString(invoiceEntity.BusinessPartnerEntityID),
+		PartnerName:      invoiceEntity.BusinessPartnerEntity.Name,
+		RevenueAccountID: bookingutils.UintToString(invoiceEntity.RevenueAccountID),
+		BookingID:        bookingutils.UintToString(invoiceEntity.BookingEntityID),
+		OpenItemID:       bookingutils.UintToString(invoiceEntity.OpenItemEntityID),
+		Date:             invoiceEntity.Date,
+		DueDate:          invoiceEntity.DueDate,
+		Currency:         invoiceEntity.Currency,
+		ReferenceType:    invoiceEntity.ReferenceType,
+		Reference:        invoiceEntity.Reference,
+		Message:          invoiceEntity.Message,
+		Ammount:          invoiceEntity.Ammount,
+		Lines:            lines,
+	}
+}
+
+func (assetEntity FixedAssetEntity) ToFixedAssetDTO() FixedAssetDTO {
+	return FixedAssetDTO{
+		AssetID:               bookingutils.UintToString(assetEntity.ID),
+		Name:                  assetEntity.Name,
+		AssetAccountID:        bookingutils.UintToString(assetEntity.AssetAccountID),
+		DepreciationAccountID: bookingutils.UintToString(assetEntity.DepreciationAccountID),
+		AcquisitionDate:       assetEntity.AcquisitionDate,
+		AcquisitionCost:       assetEntity.AcquisitionCost,
+		ResidualValue:         assetEntity.ResidualValue,
+		UsefulLifeYears:       assetEntity.UsefulLifeYears,
+		Method:                assetEntity.Method,
+		DecliningRate:         assetEntity.DecliningRate,
+	}
+}
+
+func (assignmentEntity BookRoleAssignmentEntity) ToBookMemberDTO() BookMemberDTO {
+	return BookMemberDTO{
+		User: assignmentEntity.ApplicationUserEntity.ToApplicationUserDTO(),
+		Role: assignmentEntity.Role,
+	}
+}
+
+func (invitationEntity BookInvitationEntity) ToBookInvitationDTO() BookInvitationDTO {
+	return BookInvitationDTO{
+		InvitationID: bookingutils.UintToString(invitationEntity.ID),
+		BookID:       bookingutils.UintToString(invitationEntity.BookRealmEntityID),
+		BookName:     invitationEntity.BookRealmEntity.BookName,
+		EMail:        invitationEntity.EMail,
+		Role:         invitationEntity.Role,
+		Token:        invitationEntity.Token,
+		ExpiresAt:    invitationEntity.ExpiresAt.Format(time.RFC3339),
+	}
+}
+
+func (tokenEntity ApiTokenEntity) ToApiTokenDTO() ApiTokenDTO {
+	bookIDs := make([]string, 0, len(tokenEntity.Books))
+	for _, book := range tokenEntity.Books {
+		bookIDs = append(bookIDs, bookingutils.UintToString(book.BookRealmEntityID))
+	}
+	return ApiTokenDTO{
+		TokenID:   bookingutils.UintToString(tokenEntity.ID),
+		Name:      tokenEntity.Name,
+		Prefix:    tokenEntity.Prefix,
+		Scope:     tokenEntity.Scope,
+		BookIDs:   bookIDs,
+		ExpiresAt: tokenEntity.ExpiresAt.Format(time.RFC3339),
+		UserID:    tokenEntity.ApplicationUserEntityID,
+	}
+}
+
+// ReadEvents returns the subscribed events, an empty list subscribes to all events
+func (webhookEntity WebhookEntity) ReadEvents() []types.WebhookEvent {
+	events := []types.WebhookEvent{}
+	for _, event := range strings.Split(webhookEntity.Events, ",") {
+		if event != "" {
+			events = append(events, types.WebhookEvent(event))
+		}
+	}
+	return events
+}
+
+func (webhookEntity WebhookEntity) ToWebhookDTO() WebhookDTO {
+	return WebhookDTO{
+		WebhookID: bookingutils.UintToString(webhookEntity.ID),
+		Url:       webhookEntity.Url,
+		Events:    webhookEntity.ReadEvents(),
+	}
+}
+
+func (deliveryEntity WebhookDeliveryEntity) ToWebhookDeliveryDTO() WebhookDeliveryDTO {
+	delivery := WebhookDeliveryDTO{
+		DeliveryID:     bookingutils.UintToString(deliveryEntity.ID),
+		WebhookID:      bookingutils.UintToString(deliveryEntity.WebhookEntityID),
+		Event:          deliveryEntity.Event,
+		Status:         deliveryEntity.Status,
+		Attempts:       deliveryEntity.Attempts,
+		LastStatusCode: deliveryEntity.LastStatusCode,
+		LastError:      deliveryEntity.LastError,
+		CreatedAt:      deliveryEntity.CreatedAt.Format(time.RFC3339),
+	}
+	if deliveryEntity.Status == types.WebhookDeliveryPending {
+		delivery.NextAttemptAt = deliveryEntity.NextAttemptAt.Format(time.RFC3339)
+	}
+	return delivery
+}
+
+func (domainEvent DomainEvent) ToOutboxEventEntity() (OutboxEventEntity, error) {
+	bookID, data := domainEvent.Read()
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return OutboxEventEntity{}, err
+	}
+	return OutboxEventEntity{
+		BookRealmEntityID: bookID,
+		EventType:         domainEvent.Type,
+		Payload:           string(payload),
+	}, nil
+}
+
+func (outboxEventEntity OutboxEventEntity) ToDomainEventDTO() DomainEventDTO {
+	return DomainEventDTO{
+		EventID:    bookingutils.UintToString(outboxEventEntity.ID),
+		Type:       outboxEventEntity.EventType,
+		BookID:     bookingutils.UintToString(outboxEventEntity.BookRealmEntityID),
+		OccurredAt: outboxEventEntity.CreatedAt.UTC().Format(time.RFC3339),
+		Data:       json.RawMessage(outboxEventEntity.Payload),
 	}
 }
@@ -0,0 +1,77 @@
+package qrbill
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Address struct {
+	Name           string
+	Street         string
+	BuildingNumber string
+	ZipCode        string
+	City           string
+	Country        string
+}
+
+// Bill contains the data of the payment part of a swiss QR-bill
+type Bill struct {
+	IBAN          string
+	Creditor      Address
+	Debtor        Address
+	Ammount       string
+	Currency      string
+	ReferenceType string
+	Reference     string
+	Message       string
+}
+
+// Validate checks the fields which are mandatory for the swiss payments code
+func (b Bill) Validate() error {
+	if !IsValidIBAN(b.IBAN) {
+		return fmt.Errorf("IBAN %s is not valid", b.IBAN)
+	}
+	if b.Currency != "CHF" && b.Currency != "EUR" {
+		return errors.New("currency must be CHF or EUR")
+	}
+	if strings.TrimSpace(b.Creditor.Name) == "" || strings.TrimSpace(b.Creditor.City) == "" || len(b.Creditor.Country) != 2 {
+		return errors.New("creditor needs a name, a city and a two letter country code")
+	}
+	switch b.ReferenceType {
+	case ReferenceTypeQR:
+		if !IsQRIBAN(b.IBAN) || !IsValidQRReference(b.Reference) {
+			return errors.New("QR reference requires a QR-IBAN and a valid QR reference")
+		}
+	case ReferenceTypeCreditor:
+		if IsQRIBAN(b.IBAN) || !IsValidCreditorReference(b.Reference) {
+			return errors.New("creditor reference requires a regular IBAN and a valid creditor reference")
+		}
+	case ReferenceTypeNone:
+		if IsQRIBAN(b.IBAN) || b.Reference != "" {
+			return errors.New("payments without reference are not allowed with a QR-IBAN")
+		}
+	default:
+		return fmt.Errorf("unknown reference type %s", b.ReferenceType)
+	}
+	return nil
+}
+
+// Payload returns the content of the swiss QR code according to the swiss payments code version 2.0
+func (b Bill) Payload() string {
+	lines := []string{"SPC", "0200", "1", NormalizeReference(b.IBAN)}
+	lines = append(lines, b.Creditor.payloadLines()...)
+	// ultimate creditor is reserved for future use and must stay empty
+	lines = append(lines, "", "", "", "", "", "", "")
+	lines = append(lines, b.Ammount, b.Currency)
+	lines = append(lines, b.Debtor.payloadLines()...)
+	lines = append(lines, b.ReferenceType, NormalizeReference(b.Reference), b.Message, "EPD")
+	return strings.Join(lines, "\n")
+}
+
+func (a Address) payloadLines() []string {
+	if strings.TrimSpace(a.Name) == "" {
+		return []string{"", "", "", "", "", "", ""}
+	}
+	return []string{"S", a.Name, a.Street, a.BuildingNumber, a.ZipCode, a.City, strings.ToUpper(a.Country)}
+}
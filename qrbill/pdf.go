@@ -0,0 +1,268 @@
+package qrbill
+
+import (
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	paymentPartTop    = 192.0
+	receiptWidth      = 62.0
+	qrCodeSize        = 46.0
+	swissCrossSize    = 7.0
+	paymentPartLeft   = receiptWidth + 5
+	paymentInfoLeft   = 118.0
+	invoiceTextWidth  = 170.0
+	invoiceTextBottom = 277.0
+	lineHeight        = 5.0
+)
+
+type Invoice struct {
+	Title   string
+	Date    string
+	DueDate string
+	Lines   []InvoiceLine
+	Ammount string
+	Bill    Bill
+}
+
+type InvoiceLine struct {
+	Description string
+	Quantity    string
+	UnitPrice   string
+	Ammount     string
+}
+
+// RenderInvoice writes an A4 invoice as PDF with the swiss QR-bill payment part at the bottom of the last page
+func RenderInvoice(invoice Invoice, w io.Writer) error {
+	pdf, err := createInvoicePDF(invoice)
+	if err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// createInvoicePDF lays out the invoice. Lines which do not fit on the page continue on the next page,
+// the payment part gets a page of its own if the lines reach into it.
+func createInvoicePDF(invoice Invoice) (*gofpdf.Fpdf, error) {
+	if err := invoice.Bill.Validate(); err != nil {
+		return nil, err
+	}
+	qrCode, err := qrcode.New(invoice.Bill.Payload(), qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	qrCode.DisableBorder = true
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetTitle(invoice.Title, true)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	renderInvoiceText(pdf, tr, invoice)
+	if pdf.GetY() > paymentPartTop-lineHeight {
+		addContinuationPage(pdf, tr, invoice.Title)
+	}
+	renderSeparators(pdf)
+	renderReceipt(pdf, tr, invoice.Bill)
+	renderPaymentPart(pdf, tr, invoice.Bill, qrCode.Bitmap())
+
+	return pdf, pdf.Error()
+}
+
+func renderInvoiceText(pdf *gofpdf.Fpdf, tr func(string) string, invoice Invoice) {
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetXY(20, 20)
+	pdf.MultiCell(80, 5, tr(strings.Join(invoice.Bill.Creditor.lines(), "\n")), "", "L", false)
+	pdf.SetXY(120, 45)
+	pdf.MultiCell(70, 5, tr(strings.Join(invoice.Bill.Debtor.lines(), "\n")), "", "L", false)
+
+	pdf.SetXY(20, 80)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(invoiceTextWidth, 8, tr(invoice.Title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(invoiceTextWidth, 5, tr("Datum: "+invoice.Date), "", 1, "L", false, 0, "")
+	pdf.CellFormat(invoiceTextWidth, 5, tr("Zahlbar bis: "+invoice.DueDate), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	renderLineHeader(pdf, tr)
+	for _, line := range invoice.Lines {
+		// the description wraps within its column, the other columns stay on the first line of the row
+		description := pdf.SplitLines([]byte(tr(line.Description)), 95)
+		height := float64(len(description))*lineHeight + 1
+		if pdf.GetY()+height > invoiceTextBottom {
+			addContinuationPage(pdf, tr, invoice.Title)
+			renderLineHeader(pdf, tr)
+		}
+		y := pdf.GetY()
+		pdf.MultiCell(95, lineHeight, tr(line.Description), "", "L", false)
+		pdf.SetXY(115, y)
+		pdf.CellFormat(20, lineHeight, line.Quantity, "", 0, "R", false, 0, "")
+		pdf.CellFormat(27, lineHeight, line.UnitPrice, "", 0, "R", false, 0, "")
+		pdf.CellFormat(28, lineHeight, line.Ammount, "", 0, "R", false, 0, "")
+		pdf.SetXY(20, y+height)
+	}
+	if pdf.GetY()+7 > invoiceTextBottom {
+		addContinuationPage(pdf, tr, invoice.Title)
+	}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(142, 7, tr("Total "+invoice.Bill.Currency), "T", 0, "L", false, 0, "")
+	pdf.CellFormat(28, 7, invoice.Ammount, "T", 1, "R", false, 0, "")
+}
+
+func renderLineHeader(pdf *gofpdf.Fpdf, tr func(string) string) {
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(95, 6, tr("Beschreibung"), "B", 0, "L", false, 0, "")
+	pdf.CellFormat(20, 6, tr("Menge"), "B", 0, "R", false, 0, "")
+	pdf.CellFormat(27, 6, tr("Preis"), "B", 0, "R", false, 0, "")
+	pdf.CellFormat(28, 6, tr("Betrag"), "B", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+}
+
+// addContinuationPage starts a new page carrying the title of the invoice
+func addContinuationPage(pdf *gofpdf.Fpdf, tr func(string) string, title string) {
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(invoiceTextWidth, 6, tr(title+" (Fortsetzung)"), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "", 10)
+}
+
+func renderSeparators(pdf *gofpdf.Fpdf) {
+	pdf.SetLineWidth(0.2)
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.Line(0, paymentPartTop, 210, paymentPartTop)
+	pdf.Line(receiptWidth, paymentPartTop, receiptWidth, 297)
+	pdf.SetDashPattern([]float64{}, 0)
+}
+
+func renderReceipt(pdf *gofpdf.Fpdf, tr func(string) string, bill Bill) {
+	x := 5.0
+	width := receiptWidth - 10
+	pdf.SetXY(x, paymentPartTop+5)
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(width, 7, tr("Empfangsschein"), "", 2, "L", false, 0, "")
+	renderSection(pdf, tr, x, width, 6, 8, "Konto / Zahlbar an", append([]string{formatIBAN(bill.IBAN)}, bill.Creditor.lines()...))
+	if bill.ReferenceType != ReferenceTypeNone {
+		renderSection(pdf, tr, x, width, 6, 8, "Referenz", []string{formatReference(bill.ReferenceType, bill.Reference)})
+	}
+	renderSection(pdf, tr, x, width, 6, 8, "Zahlbar durch", bill.Debtor.lines())
+	renderAmmount(pdf, tr, x, paymentPartTop+63, 6, 8, bill)
+	pdf.SetXY(x, paymentPartTop+82)
+	pdf.SetFont("Helvetica", "B", 6)
+	pdf.CellFormat(width, 3, tr("Annahmestelle"), "", 0, "R", false, 0, "")
+}
+
+func renderPaymentPart(pdf *gofpdf.Fpdf, tr func(string) string, bill Bill, bitmap [][]bool) {
+	pdf.SetXY(paymentPartLeft, paymentPartTop+5)
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(51, 7, tr("Zahlteil"), "", 0, "L", false, 0, "")
+	renderQRCode(pdf, paymentPartLeft, paymentPartTop+17, bitmap)
+	renderAmmount(pdf, tr, paymentPartLeft, paymentPartTop+68, 8, 10, bill)
+
+	width := 210 - paymentInfoLeft - 5
+	pdf.SetXY(paymentInfoLeft, paymentPartTop+5)
+	renderSection(pdf, tr, paymentInfoLeft, width, 8, 10, "Konto / Zahlbar an", append([]string{formatIBAN(bill.IBAN)}, bill.Creditor.lines()...))
+	if bill.ReferenceType != ReferenceTypeNone {
+		renderSection(pdf, tr, paymentInfoLeft, width, 8, 10, "Referenz", []string{formatReference(bill.ReferenceType, bill.Reference)})
+	}
+	if bill.Message != "" {
+		renderSection(pdf, tr, paymentInfoLeft, width, 8, 10, "Zusätzliche Informationen", []string{bill.Message})
+	}
+	renderSection(pdf, tr, paymentInfoLeft, width, 8, 10, "Zahlbar durch", bill.Debtor.lines())
+}
+
+func renderSection(pdf *gofpdf.Fpdf, tr func(string) string, x, width, headingSize, valueSize float64, heading string, values []string) {
+	pdf.SetX(x)
+	pdf.SetFont("Helvetica", "B", headingSize)
+	pdf.CellFormat(width, headingSize*0.45, tr(heading), "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", valueSize)
+	for _, value := range values {
+		pdf.CellFormat(width, valueSize*0.45, tr(value), "", 2, "L", false, 0, "")
+	}
+	pdf.Ln(valueSize * 0.45)
+}
+
+func renderAmmount(pdf *gofpdf.Fpdf, tr func(string) string, x, y, headingSize, valueSize float64, bill Bill) {
+	pdf.SetXY(x, y)
+	pdf.SetFont("Helvetica", "B", headingSize)
+	pdf.CellFormat(15, headingSize*0.45, tr("Währung"), "", 0, "L", false, 0, "")
+	pdf.CellFormat(30, headingSize*0.45, tr("Betrag"), "", 2, "L", false, 0, "")
+	pdf.SetXY(x, y+headingSize*0.5)
+	pdf.SetFont("Helvetica", "", valueSize)
+	pdf.CellFormat(15, valueSize*0.45, bill.Currency, "", 0, "L", false, 0, "")
+	pdf.CellFormat(30, valueSize*0.45, bill.Ammount, "", 0, "L", false, 0, "")
+}
+
+// renderQRCode draws the modules of the QR code and the swiss cross in its center
+func renderQRCode(pdf *gofpdf.Fpdf, x, y float64, bitmap [][]bool) {
+	moduleSize := qrCodeSize / float64(len(bitmap))
+	pdf.SetFillColor(0, 0, 0)
+	for row, modules := range bitmap {
+		for column, dark := range modules {
+			if dark {
+				pdf.Rect(x+float64(column)*moduleSize, y+float64(row)*moduleSize, moduleSize, moduleSize, "F")
+			}
+		}
+	}
+	crossX := x + (qrCodeSize-swissCrossSize)/2
+	crossY := y + (qrCodeSize-swissCrossSize)/2
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(crossX-0.5, crossY-0.5, swissCrossSize+1, swissCrossSize+1, "F")
+	pdf.SetFillColor(0, 0, 0)
+	pdf.Rect(crossX, crossY, swissCrossSize, swissCrossSize, "F")
+	pdf.SetFillColor(255, 255, 255)
+	pdf.Rect(crossX+3, crossY+1.5, 1, 4, "F")
+	pdf.Rect(crossX+1.5, crossY+3, 4, 1, "F")
+}
+
+func (a Address) lines() []string {
+	lines := []string{}
+	if a.Name != "" {
+		lines = append(lines, a.Name)
+	}
+	if street := strings.TrimSpace(a.Street + " " + a.BuildingNumber); street != "" {
+		lines = append(lines, street)
+	}
+	if place := strings.TrimSpace(a.ZipCode + " " + a.City); place != "" {
+		lines = append(lines, place)
+	}
+	return lines
+}
+
+func formatIBAN(iban string) string {
+	return groupCharacters(NormalizeReference(iban), 4, false)
+}
+
+func formatReference(referenceType, reference string) string {
+	normalized := NormalizeReference(reference)
+	if referenceType == ReferenceTypeQR {
+		// QR references are grouped in blocks of five digits starting from the right
+		return groupCharacters(normalized, 5, true)
+	}
+	return groupCharacters(normalized, 4, false)
+}
+
+func groupCharacters(value string, size int, fromRight bool) string {
+	groups := []string{}
+	if fromRight {
+		first := len(value) % size
+		if first > 0 {
+			groups = append(groups, value[:first])
+		}
+		value = value[first:]
+	}
+	for len(value) > size {
+		groups = append(groups, value[:size])
+		value = value[size:]
+	}
+	if value != "" {
+		groups = append(groups, value)
+	}
+	return strings.Join(groups, " ")
+}
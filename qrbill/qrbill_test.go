@@ -0,0 +1,157 @@
+package qrbill
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQRReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		want    string
+		wantErr bool
+	}{
+		{"reference from the implementation guidelines", "21000000000313947143000901", "210000000003139471430009017", false},
+		{"short base is padded", "1", "000000000000000000000000011", false},
+		{"error with letters", "12A", "", true},
+		{"error too long", "123456789012345678901234567", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := QRReference(tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("QRReference() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("QRReference() = %v, want %v", got, tt.want)
+			}
+			if !tt.wantErr && !IsValidQRReference(got) {
+				t.Errorf("IsValidQRReference(%s) should be true", got)
+			}
+		})
+	}
+}
+
+func TestCreditorReference(t *testing.T) {
+	got, err := CreditorReference("539007547034")
+	if err != nil {
+		t.Errorf("CreditorReference() error = %v", err)
+		return
+	}
+	if got != "RF18539007547034" {
+		t.Errorf("CreditorReference() = %v, want RF18539007547034", got)
+	}
+	if !IsValidCreditorReference("RF18 5390 0754 7034") {
+		t.Errorf("IsValidCreditorReference() formatted reference should be valid")
+	}
+	if IsValidCreditorReference("RF19539007547034") {
+		t.Errorf("IsValidCreditorReference() wrong check digits should be invalid")
+	}
+}
+
+func TestIsQRIBAN(t *testing.T) {
+	tests := []struct {
+		name string
+		iban string
+		want bool
+	}{
+		{"qr iban", "CH44 3199 9123 0008 8901 2", true},
+		{"regular iban", "CH93 0076 2011 6238 5295 7", false},
+		{"foreign iban", "DE89370400440532013000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsQRIBAN(tt.iban); got != tt.want {
+				t.Errorf("IsQRIBAN() = %v, want %v", got, tt.want)
+			}
+			if !IsValidIBAN(tt.iban) {
+				t.Errorf("IsValidIBAN(%s) should be true", tt.iban)
+			}
+		})
+	}
+}
+
+func TestBill_Payload(t *testing.T) {
+	bill := createTestBill()
+	want := strings.Join([]string{
+		"SPC", "0200", "1", "CH4431999123000889012",
+		"S", "Turnverein Muster", "Hauptstrasse", "1", "8000", "Zürich", "CH",
+		"", "", "", "", "", "", "",
+		"50.00", "CHF",
+		"S", "Hans Huber", "Seeweg", "5", "3000", "Bern", "CH",
+		"QRR", "210000000003139471430009017", "Rechnung 1", "EPD",
+	}, "\n")
+	if got := bill.Payload(); got != want {
+		t.Errorf("Payload() = \n%v\nwant\n%v", got, want)
+	}
+	if err := bill.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	bill.IBAN = "CH9300762011623852957"
+	if err := bill.Validate(); err == nil {
+		t.Errorf("Validate() QR reference with regular IBAN should fail")
+	}
+}
+
+func TestRenderInvoice(t *testing.T) {
+	var pdf bytes.Buffer
+	err := RenderInvoice(Invoice{
+		Title:   "Rechnung 1",
+		Date:    "2024-03-01",
+		DueDate: "2024-03-31",
+		Lines:   []InvoiceLine{{Description: "Mitgliederbeitrag", Quantity: "1", UnitPrice: "50.00", Ammount: "50.00"}},
+		Ammount: "50.00",
+		Bill:    createTestBill(),
+	}, &pdf)
+	if err != nil {
+		t.Errorf("RenderInvoice() error = %v", err)
+		return
+	}
+	if !bytes.HasPrefix(pdf.Bytes(), []byte("%PDF")) {
+		t.Errorf("RenderInvoice() should write a PDF document")
+	}
+}
+
+func TestRenderInvoiceContinuesLines(t *testing.T) {
+	line := InvoiceLine{Description: strings.Repeat("Miete Turnhalle für das Training am Mittwochabend ", 3), Quantity: "1", UnitPrice: "50.00", Ammount: "50.00"}
+	tests := []struct {
+		name      string
+		lines     int
+		wantPages int
+	}{
+		{"lines above the payment part", 3, 1},
+		{"lines reaching into the payment part", 10, 2},
+		{"lines exceeding two pages", 40, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([]InvoiceLine, tt.lines)
+			for i := range lines {
+				lines[i] = line
+			}
+			pdf, err := createInvoicePDF(Invoice{Title: "Rechnung 1", Lines: lines, Ammount: "50.00", Bill: createTestBill()})
+			if err != nil {
+				t.Fatalf("createInvoicePDF() error = %v", err)
+			}
+			if pages := pdf.PageCount(); pages != tt.wantPages {
+				t.Errorf("createInvoicePDF() pages = %d, want %d", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func createTestBill() Bill {
+	return Bill{
+		IBAN:          "CH44 3199 9123 0008 8901 2",
+		Creditor:      Address{Name: "Turnverein Muster", Street: "Hauptstrasse", BuildingNumber: "1", ZipCode: "8000", City: "Zürich", Country: "CH"},
+		Debtor:        Address{Name: "Hans Huber", Street: "Seeweg", BuildingNumber: "5", ZipCode: "3000", City: "Bern", Country: "CH"},
+		Ammount:       "50.00",
+		Currency:      "CHF",
+		ReferenceType: ReferenceTypeQR,
+		Reference:     "210000000003139471430009017",
+		Message:       "Rechnung 1",
+	}
+}
@@ -0,0 +1,115 @@
+package qrbill
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	ReferenceTypeQR       = "QRR"
+	ReferenceTypeCreditor = "SCOR"
+	ReferenceTypeNone     = "NON"
+)
+
+var mod10Table = [10]int{0, 9, 4, 6, 8, 2, 7, 1, 3, 5}
+
+// QRReference creates a 27 digit QR reference out of a numeric base with the modulo 10 recursive check digit
+func QRReference(base string) (string, error) {
+	if len(base) > 26 {
+		return "", fmt.Errorf("base of QR reference %s must not be longer than 26 digits", base)
+	}
+	for _, digit := range base {
+		if digit < '0' || digit > '9' {
+			return "", fmt.Errorf("base of QR reference %s must only contain digits", base)
+		}
+	}
+	paddedBase := fmt.Sprintf("%026s", base)
+	return paddedBase + strconv.Itoa(mod10CheckDigit(paddedBase)), nil
+}
+
+func mod10CheckDigit(digits string) int {
+	carry := 0
+	for _, digit := range digits {
+		carry = mod10Table[(carry+int(digit-'0'))%10]
+	}
+	return (10 - carry) % 10
+}
+
+// IsValidQRReference checks the length and the check digit of a QR reference
+func IsValidQRReference(reference string) bool {
+	normalized := NormalizeReference(reference)
+	if len(normalized) != 27 {
+		return false
+	}
+	validReference, err := QRReference(normalized[:26])
+	return err == nil && validReference == normalized
+}
+
+// CreditorReference creates an ISO 11649 creditor reference (RF) out of an alphanumeric base
+func CreditorReference(base string) (string, error) {
+	normalizedBase := NormalizeReference(base)
+	if normalizedBase == "" || len(normalizedBase) > 21 {
+		return "", fmt.Errorf("base of creditor reference %s must contain between 1 and 21 characters", base)
+	}
+	remainder, err := mod97(normalizedBase + "RF00")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("RF%02d%s", 98-remainder, normalizedBase), nil
+}
+
+// IsValidCreditorReference checks the check digits of an ISO 11649 creditor reference
+func IsValidCreditorReference(reference string) bool {
+	normalized := NormalizeReference(reference)
+	if len(normalized) < 5 || len(normalized) > 25 || !strings.HasPrefix(normalized, "RF") {
+		return false
+	}
+	remainder, err := mod97(normalized[4:] + normalized[:4])
+	return err == nil && remainder == 1
+}
+
+// IsQRIBAN returns true if the IBAN is a swiss or liechtenstein QR-IBAN (institution id 30000 to 31999)
+func IsQRIBAN(iban string) bool {
+	normalized := NormalizeReference(iban)
+	if len(normalized) != 21 || (!strings.HasPrefix(normalized, "CH") && !strings.HasPrefix(normalized, "LI")) {
+		return false
+	}
+	institutionID, err := strconv.Atoi(normalized[4:9])
+	return err == nil && institutionID >= 30000 && institutionID <= 31999
+}
+
+// IsValidIBAN checks the check digits of an IBAN
+func IsValidIBAN(iban string) bool {
+	normalized := NormalizeReference(iban)
+	if len(normalized) < 15 || len(normalized) > 34 {
+		return false
+	}
+	remainder, err := mod97(normalized[4:] + normalized[:4])
+	return err == nil && remainder == 1
+}
+
+// NormalizeReference removes all whitespaces and converts the reference to upper case
+func NormalizeReference(reference string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(reference), ""))
+}
+
+func mod97(alphanumeric string) (int, error) {
+	var numeric strings.Builder
+	for _, character := range alphanumeric {
+		switch {
+		case character >= '0' && character <= '9':
+			numeric.WriteRune(character)
+		case character >= 'A' && character <= 'Z':
+			numeric.WriteString(strconv.Itoa(int(character-'A') + 10))
+		default:
+			return 0, fmt.Errorf("invalid character %q in reference", character)
+		}
+	}
+	number, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return 0, fmt.Errorf("could not convert %s to a number", alphanumeric)
+	}
+	return int(new(big.Int).Mod(number, big.NewInt(97)).Int64()), nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func (r *repositoryImpl) FindAccrualsByBookId(bookID uint) (bookingEntities []model.BookingEntity, err model.TokyError) {
+	findError := r.connection.
+		Joins("JOIN account_table_entities ate ON ate.id = booking_entities.haben_booking_account_id").
+		Where("ate.book_realm_entity_id = ? AND booking_entities.booking_type = ?", bookID, types.BookingTypeAccrual).
+		Order("reversal_date").Find(&bookingEntities).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// FindDueAccruals returns the accruals of all books which are not yet reversed and whose reversal date is reached.
+// Accruals of archived books are left out, as archived books must not change.
+func (r *repositoryImpl) FindDueAccruals(date string) (bookingEntities []model.BookingEntity, err model.TokyError) {
+	findError := r.connection.
+		Joins("JOIN account_table_entities ate ON ate.id = booking_entities.haben_booking_account_id").
+		Joins("JOIN book_realm_entities bre ON bre.id = ate.book_realm_entity_id AND bre.deleted_at IS NULL").
+		Where("booking_entities.booking_type = ? AND booking_entities.reversal_booking_id = 0 AND booking_entities.reversal_date <= ?", types.BookingTypeAccrual, date).
+		Where("bre.archived_at IS NULL").
+		Order("booking_entities.reversal_date").Find(&bookingEntities).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// PersistReversal creates the reversing booking and links it to the accrual in one transaction.
+// An accrual which was reversed concurrently is left untouched.
+func (r *repositoryImpl) PersistReversal(accrual model.BookingEntity, reversal *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	tx := r.connection.Begin()
+	saveError := tx.Omit("HabenBookingAccount", "SollBookingAccount").Create(reversal).Error
+	if saveError == nil {
+		update := tx.Model(&model.BookingEntity{}).
+			Where("id = ? AND reversal_booking_id = 0", accrual.ID).
+			Update("reversal_booking_id", reversal.ID)
+		saveError = update.Error
+		if saveError == nil && update.RowsAffected != 1 {
+			saveError = errors.New("accrual is already reversed")
+		}
+	}
+	if saveError == nil {
+		saveError = writeOutboxEvents(tx, events)
+	}
+	if saveError != nil {
+		tx.Rollback()
+		return model.CreateBusinessError(fmt.Sprintf("Could not Persist Reversal of Booking %d", accrual.ID), saveError)
+	}
+	tx.Commit()
+	return nil
+}
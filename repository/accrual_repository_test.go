@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func Test_repositoryImpl_FindDueAccrualsSkipsArchivedBooks(t *testing.T) {
+	r := createTestRepository(t)
+	archivedAt := time.Now()
+	books := []model.BookRealmEntity{{BookName: "Verein"}, {BookName: "Archiv", ArchivedAt: &archivedAt}}
+	insert(t, r, &books[0], &books[1])
+	accruals := []model.BookingEntity{}
+	for _, book := range books {
+		expense := createAccount(t, r, book.ID, "Aufwand")
+		deferred := createAccount(t, r, book.ID, "Passive Rechnungsabgrenzung")
+		accrual := model.BookingEntity{Date: "2024-12-31", SollBookingAccountID: expense.ID, HabenBookingAccountID: deferred.ID,
+			Ammount: "300.00", BookingType: types.BookingTypeAccrual, ReversalDate: "2025-01-01"}
+		insert(t, r, &accrual)
+		accruals = append(accruals, accrual)
+	}
+
+	due, err := r.FindDueAccruals("2025-01-01")
+	if model.IsExisting(err) {
+		t.Fatalf("FindDueAccruals() error = %v", err)
+	}
+	if len(due) != 1 || due[0].ID != accruals[0].ID {
+		t.Errorf("FindDueAccruals() = %+v, want only the accrual of the active book", due)
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+)
+
+func (r *repositoryImpl) PersistApiToken(token *model.ApiTokenEntity) model.TokyError {
+	saveError := r.connection.Create(token).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Api Token", saveError)
+	}
+	return nil
+}
+
+func (r *repositoryImpl) FindApiTokensByUser(userID string) (tokens []model.ApiTokenEntity, err model.TokyError) {
+	findError := r.connection.Preload("Books").
+		Where("application_user_entity_id = ?", userID).
+		Order("created_at").Find(&tokens).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindApiTokenByHash(tokenHash string) (token model.ApiTokenEntity, err model.TokyError) {
+	findError := r.connection.Preload("Books").Where("token_hash = ?", tokenHash).First(&token).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound("No Api Token found", findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) DeleteApiToken(userID string, tokenID uint) model.TokyError {
+	deletion := r.connection.Where("id = ? AND application_user_entity_id = ?", tokenID, userID).Delete(&model.ApiTokenEntity{})
+	if deletion.Error != nil {
+		return model.CreateTechnicalError("Could not Revoke Api Token", deletion.Error)
+	}
+	if deletion.RowsAffected == 0 {
+		return model.CreateBusinessErrorNotFound(fmt.Sprintf("No Api Token with Id %d found", tokenID), errors.New("api token not found"))
+	}
+	return nil
+}
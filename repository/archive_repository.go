@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+)
+
+// PersistImportedBookRealm creates the book with its accounts, bookings, invoice settings and invitations in one transaction.
+// Accounts and bookings carry the ids of the archive, they get new ids and all references between them are remapped.
+func (r *repositoryImpl) PersistImportedBookRealm(bookRealm *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity, settings *model.InvoiceSettingsEntity, invitations []model.BookInvitationEntity, events ...model.DomainEvent) model.TokyError {
+	tx := r.connection.Begin()
+	importErr := persistImportedBookRealm(tx, bookRealm, accounts, bookings, settings, invitations)
+	if importErr == nil {
+		importErr = writeOutboxEvents(tx, events)
+	}
+	if importErr != nil {
+		tx.Rollback()
+		return model.CreateBusinessError("Could not Import Book Realm", importErr)
+	}
+	tx.Commit()
+	return nil
+}
+
+func persistImportedBookRealm(tx *gorm.DB, bookRealm *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity, settings *model.InvoiceSettingsEntity, invitations []model.BookInvitationEntity) error {
+	if err := tx.Create(bookRealm).Error; err != nil {
+		return err
+	}
+	for i := range invitations {
+		invitations[i].BookRealmEntityID = bookRealm.ID
+		if err := tx.Omit("BookRealmEntity").Create(&invitations[i]).Error; err != nil {
+			return err
+		}
+	}
+	// the accounts and bookings are updated in place so that the caller sees their new ids
+	accountIDs := make(map[uint]uint, len(accounts))
+	for i := range accounts {
+		account := &accounts[i]
+		archiveID := account.ID
+		account.ID = 0
+		account.BookRealmEntityID = bookRealm.ID
+		if err := tx.Omit("BookRealmEntity").Create(account).Error; err != nil {
+			return err
+		}
+		accountIDs[archiveID] = account.ID
+	}
+	bookingIDs := make(map[uint]uint, len(bookings))
+	archiveReversalIDs := make(map[int]uint, len(bookings))
+	for i := range bookings {
+		booking := &bookings[i]
+		archiveID := booking.ID
+		if booking.ReversalBookingID != 0 {
+			archiveReversalIDs[i] = booking.ReversalBookingID
+		}
+		booking.ID = 0
+		booking.SollBookingAccountID = accountIDs[booking.SollBookingAccountID]
+		booking.HabenBookingAccountID = accountIDs[booking.HabenBookingAccountID]
+		booking.ReversalBookingID = 0
+		if err := tx.Omit("HabenBookingAccount", "SollBookingAccount").Create(booking).Error; err != nil {
+			return err
+		}
+		bookingIDs[archiveID] = booking.ID
+	}
+	for i, archiveReversalID := range archiveReversalIDs {
+		bookings[i].ReversalBookingID = bookingIDs[archiveReversalID]
+		if err := tx.Model(&model.BookingEntity{}).Where("id = ?", bookings[i].ID).
+			Update("reversal_booking_id", bookings[i].ReversalBookingID).Error; err != nil {
+			return err
+		}
+	}
+	if settings == nil {
+		return nil
+	}
+	settings.BookRealmEntityID = bookRealm.ID
+	return tx.Create(settings).Error
+}
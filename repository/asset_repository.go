@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+)
+
+func (r *repositoryImpl) FindFixedAssetsByBookId(bookID uint) (assets []model.FixedAssetEntity, err model.TokyError) {
+	findError := r.connection.
+		Preload("Depreciations", func(db *gorm.DB) *gorm.DB { return db.Order("year") }).
+		Preload("Depreciations.BookingEntity").
+		Where("book_realm_entity_id = ?", bookID).
+		Order("acquisition_date").Find(&assets).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) PersistFixedAsset(asset model.FixedAssetEntity) model.TokyError {
+	saveError := r.connection.Create(&asset).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Fixed Asset", saveError)
+	}
+	return nil
+}
+
+// PersistDepreciation creates the depreciation booking and records it for the asset in one transaction,
+// the booking is updated in place so that the caller sees its new id
+func (r *repositoryImpl) PersistDepreciation(depreciation model.DepreciationEntity, booking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	saveError := r.persistWithEvents(func(tx *gorm.DB) error {
+		if err := tx.Omit("HabenBookingAccount", "SollBookingAccount").Create(booking).Error; err != nil {
+			return err
+		}
+		depreciation.BookingEntityID = booking.ID
+		return tx.Omit("BookingEntity").Create(&depreciation).Error
+	}, events)
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Depreciation", saveError)
+	}
+	return nil
+}
+
+func deleteFixedAssetsOfBooks(tx *gorm.DB, bookIds []uint) error {
+	deleteErr := tx.Exec("DELETE FROM depreciation_entities WHERE fixed_asset_entity_id in (select id from fixed_asset_entities where book_realm_entity_id in (@bookIds))", sql.Named("bookIds", bookIds)).Error
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return tx.Exec("DELETE FROM fixed_asset_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+}
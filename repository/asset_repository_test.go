@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+func Test_repositoryImpl_FindFixedAssetsWithDepreciationBookings(t *testing.T) {
+	r := createTestRepository(t)
+	assets := createAccount(t, r, 1, "Mobilien")
+	depreciations := createAccount(t, r, 1, "Abschreibungen")
+	asset := model.FixedAssetEntity{BookRealmEntityID: 1, Name: "Zelt", AcquisitionDate: "2024-07-01", AcquisitionCost: "12000.00", UsefulLifeYears: 4}
+	insert(t, r, &asset)
+	booking := model.BookingEntity{SollBookingAccountID: depreciations.ID, HabenBookingAccountID: assets.ID, Ammount: "1500.00"}
+	if err := r.PersistDepreciation(model.DepreciationEntity{FixedAssetEntityID: asset.ID, Year: 2024, Ammount: "1500.00"}, &booking); model.IsExisting(err) {
+		t.Fatalf("PersistDepreciation() error = %v", err)
+	}
+	r.connection.Model(&booking).Update("ammount", "1200.00")
+
+	found, err := r.FindFixedAssetsByBookId(1)
+	if model.IsExisting(err) {
+		t.Fatalf("FindFixedAssetsByBookId() error = %v", err)
+	}
+	if len(found) != 1 || len(found[0].Depreciations) != 1 || found[0].Depreciations[0].BookingEntity.Ammount != "1200.00" {
+		t.Errorf("FindFixedAssetsByBookId() = %+v, want the corrected depreciation booking", found)
+	}
+}
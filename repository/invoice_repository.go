@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (r *repositoryImpl) FindInvoiceSettingsByBookId(bookID uint) (settings model.InvoiceSettingsEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ?", bookID).First(&settings).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Invoice Settings for BookId %d found", bookID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) SaveInvoiceSettings(settings *model.InvoiceSettingsEntity) model.TokyError {
+	saveError := r.connection.Save(settings).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Save Invoice Settings", saveError)
+	}
+	return nil
+}
+
+func (r *repositoryImpl) FindInvoicesByBookId(bookID uint) (invoices []model.InvoiceEntity, err model.TokyError) {
+	findError := r.connection.
+		Preload("BusinessPartnerEntity").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("book_realm_entity_id = ?", bookID).
+		Order("invoice_number").Find(&invoices).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindInvoiceByID(invoiceID uint) (invoice model.InvoiceEntity, err model.TokyError) {
+	findError := r.connection.
+		Preload("BusinessPartnerEntity").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where(invoiceID).First(&invoice).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Invoice with Id %v found", invoiceID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindInvoiceByReference(bookID uint, reference string) (invoice model.InvoiceEntity, err model.TokyError) {
+	findError := r.connection.
+		Where("book_realm_entity_id = ? AND reference = ?", bookID, reference).
+		First(&invoice).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Invoice with Reference %s found", reference), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// nextInvoiceNumber returns the highest invoice number of the book incremented by one. It locks the book until
+// the transaction ends, so concurrent invoices of the book wait for the number of the previous one.
+func nextInvoiceNumber(tx *gorm.DB, bookID uint) (uint, error) {
+	var book model.BookRealmEntity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(bookID).First(&book).Error; err != nil {
+		return 0, err
+	}
+	var lastNumber uint
+	err := tx.Model(&model.InvoiceEntity{}).
+		Where("book_realm_entity_id = ?", bookID).
+		Select("COALESCE(MAX(invoice_number), 0)").Scan(&lastNumber).Error
+	return lastNumber + 1, err
+}
+
+// PersistInvoice creates the revenue booking, the open item on the receivables account
+// and the invoice with its lines in one transaction. The invoice number is assigned within the transaction,
+// numberInvoice completes the invoice and the booking with it.
+func (r *repositoryImpl) PersistInvoice(invoice *model.InvoiceEntity, booking *model.BookingEntity, numberInvoice func(invoiceNumber uint) model.TokyError, events ...model.DomainEvent) model.TokyError {
+	var numberErr model.TokyError
+	saveError := r.persistWithEvents(func(tx *gorm.DB) error {
+		invoiceNumber, err := nextInvoiceNumber(tx, invoice.BookRealmEntityID)
+		if err != nil {
+			return err
+		}
+		if numberErr = numberInvoice(invoiceNumber); model.IsExisting(numberErr) {
+			return numberErr.Error()
+		}
+		if err := tx.Omit("HabenBookingAccount", "SollBookingAccount").Create(booking).Error; err != nil {
+			return err
+		}
+		openItem := model.OpenItemEntity{
+			BookRealmEntityID:       invoice.BookRealmEntityID,
+			BusinessPartnerEntityID: invoice.BusinessPartnerEntityID,
+			BookingEntityID:         booking.ID,
+			Reference:               invoice.Reference,
+			DueDate:                 invoice.DueDate,
+			Ammount:                 invoice.Ammount,
+		}
+		if err := tx.Omit("BusinessPartnerEntity", "BookingEntity").Create(&openItem).Error; err != nil {
+			return err
+		}
+		invoice.BookingEntityID = booking.ID
+		invoice.OpenItemEntityID = openItem.ID
+		return tx.Omit("BusinessPartnerEntity").Create(invoice).Error
+	}, events)
+	if model.IsExisting(numberErr) {
+		return numberErr
+	}
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Invoice", saveError)
+	}
+	return nil
+}
+
+func deleteInvoicesOfBooks(tx *gorm.DB, bookIds []uint) error {
+	for _, statement := range []string{
+		"DELETE FROM invoice_line_entities WHERE invoice_entity_id in (select id from invoice_entities where book_realm_entity_id in (@bookIds))",
+		"DELETE FROM invoice_entities WHERE book_realm_entity_id in (@bookIds)",
+		"DELETE FROM invoice_settings_entities WHERE book_realm_entity_id in (@bookIds)",
+	} {
+		if err := tx.Exec(statement, sql.Named("bookIds", bookIds)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
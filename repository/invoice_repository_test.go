@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+func Test_repositoryImpl_PersistInvoiceAssignsNumbers(t *testing.T) {
+	r := createTestRepository(t)
+	books := []model.BookRealmEntity{{BookName: "Verein"}, {BookName: "Haushalt"}}
+	insert(t, r, &books[0], &books[1])
+	receivables := createAccount(t, r, books[0].ID, "Debitoren")
+	revenue := createAccount(t, r, books[0].ID, "Ertrag")
+	persist := func(bookID uint, numberErr model.TokyError) (model.InvoiceEntity, model.TokyError) {
+		invoice := model.InvoiceEntity{BookRealmEntityID: bookID, Ammount: "100.00"}
+		booking := model.BookingEntity{SollBookingAccountID: receivables.ID, HabenBookingAccountID: revenue.ID, Ammount: "100.00"}
+		err := r.PersistInvoice(&invoice, &booking, func(invoiceNumber uint) model.TokyError {
+			invoice.InvoiceNumber = invoiceNumber
+			invoice.Reference = fmt.Sprintf("%d-%d", bookID, invoiceNumber)
+			return numberErr
+		})
+		return invoice, err
+	}
+
+	for _, want := range []uint{1, 2} {
+		if invoice, err := persist(books[0].ID, nil); model.IsExisting(err) || invoice.InvoiceNumber != want {
+			t.Errorf("PersistInvoice() number = %d, error = %v, want %d", invoice.InvoiceNumber, err, want)
+		}
+	}
+	if invoice, err := persist(books[1].ID, nil); model.IsExisting(err) || invoice.InvoiceNumber != 1 {
+		t.Errorf("PersistInvoice() of another book number = %d, error = %v, want 1", invoice.InvoiceNumber, err)
+	}
+	invalid := model.CreateBusinessValidationError("Invoice can not be paid with a QR-bill", errors.New("too long"))
+	if _, err := persist(books[0].ID, invalid); !model.IsExistingValidationError(err) {
+		t.Errorf("PersistInvoice() with an invalid invoice error = %v, want validation error", err)
+	}
+	if invoices := count(t, r, &model.InvoiceEntity{}, "book_realm_entity_id = ?", books[0].ID); invoices != 2 {
+		t.Errorf("book has %d invoices, want 2", invoices)
+	}
+	if bookings := count(t, r, &model.BookingEntity{}, "1 = 1"); bookings != 3 {
+		t.Errorf("%d bookings were persisted, want 3", bookings)
+	}
+}
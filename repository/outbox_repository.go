@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+)
+
+// outboxGapBatch limits the gaps written per statement, the ids of a purged book may leave a large gap
+const outboxGapBatch = 500
+
+// FindOutboxEventsAfter returns the events following the given event id in the order of their ids.
+// Only events created before createdBefore are returned, which gives most transactions that obtained
+// a smaller id the time to commit before the cursor passes it.
+func (r *repositoryImpl) FindOutboxEventsAfter(eventID uint, createdBefore time.Time, limit int) (events []model.OutboxEventEntity, err model.TokyError) {
+	findError := r.connection.Where("id > ? AND created_at < ?", eventID, createdBefore).Order("id").Limit(limit).Find(&events).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// FindOutboxCursor returns the position of the sink, a new sink starts before the first event
+func (r *repositoryImpl) FindOutboxCursor(sink string) (cursor model.OutboxCursorEntity, err model.TokyError) {
+	findError := r.connection.Where("sink = ?", sink).Limit(1).Find(&cursor).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	cursor.Sink = sink
+	return
+}
+
+// FindOutboxEventsByIDs returns the events of the ids which exist by now in the order of their ids
+func (r *repositoryImpl) FindOutboxEventsByIDs(eventIDs []uint) (events []model.OutboxEventEntity, err model.TokyError) {
+	findError := r.connection.Where("id IN ?", eventIDs).Order("id").Find(&events).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// FindOutboxGaps returns the event ids the cursor of the sink has passed without finding the event
+func (r *repositoryImpl) FindOutboxGaps(sink string) (gaps []model.OutboxGapEntity, err model.TokyError) {
+	findError := r.connection.Where("sink = ?", sink).Order("event_id").Find(&gaps).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// SaveOutboxCursor saves the position of the sink together with the gaps it opened and the ids of the gaps it closed
+func (r *repositoryImpl) SaveOutboxCursor(cursor *model.OutboxCursorEntity, openedGaps []model.OutboxGapEntity, closedGapIDs []uint) model.TokyError {
+	saveError := r.persistWithEvents(func(tx *gorm.DB) error {
+		if err := tx.Save(cursor).Error; err != nil {
+			return err
+		}
+		if len(openedGaps) > 0 {
+			if err := tx.CreateInBatches(&openedGaps, outboxGapBatch).Error; err != nil {
+				return err
+			}
+		}
+		if len(closedGapIDs) == 0 {
+			return nil
+		}
+		return tx.Unscoped().Where("id IN ?", closedGapIDs).Delete(&model.OutboxGapEntity{}).Error
+	}, nil)
+	if saveError != nil {
+		return model.CreateTechnicalError("Could not Save Outbox Cursor", saveError)
+	}
+	return nil
+}
+
+// persistWithEvents runs the change and writes its events to the outbox in one transaction
+func (r *repositoryImpl) persistWithEvents(change func(tx *gorm.DB) error, events []model.DomainEvent) error {
+	tx := r.connection.Begin()
+	changeErr := change(tx)
+	if changeErr == nil {
+		changeErr = writeOutboxEvents(tx, events)
+	}
+	if changeErr != nil {
+		tx.Rollback()
+		return changeErr
+	}
+	return tx.Commit().Error
+}
+
+func writeOutboxEvents(tx *gorm.DB, events []model.DomainEvent) error {
+	for _, event := range events {
+		outboxEvent, err := event.ToOutboxEventEntity()
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&outboxEvent).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteOutboxEventsOfBooks(tx *gorm.DB, bookIds []uint) error {
+	return tx.Exec("DELETE FROM outbox_event_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -67,14 +68,35 @@ func instantiate() *repositoryImpl {
 
 	log.Println("Successfully connected to DB")
 
-	if err := conn.AutoMigrate(&model.ApplicationUserEntity{}, &model.BookRealmEntity{}, &model.AccountTableEntity{}, &model.BookingEntity{}); err != nil {
+	if err := autoMigrate(conn); err != nil {
 		log.Printf("Error with Automigrate: %v", err)
 	}
+	if err := migrateAccessLists(conn); err != nil {
+		log.Printf("Error while migrating access lists to roles: %v", err)
+	}
 	return &repositoryImpl{
 		connection: conn,
 	}
 }
 
+// CreateRepositoryWithConnection migrates the database of the given connection and works on it instead of the
+// configured postgres database, integration tests use it with sqlite
+func CreateRepositoryWithConnection(conn *gorm.DB) (*repositoryImpl, error) {
+	if err := autoMigrate(conn); err != nil {
+		return nil, err
+	}
+	return &repositoryImpl{connection: conn}, nil
+}
+
+func autoMigrate(conn *gorm.DB) error {
+	return conn.AutoMigrate(&model.ApplicationUserEntity{}, &model.BookRealmEntity{}, &model.AccountTableEntity{}, &model.BookingEntity{},
+		&model.BusinessPartnerEntity{}, &model.OpenItemEntity{}, &model.OpenItemPaymentEntity{},
+		&model.InvoiceSettingsEntity{}, &model.InvoiceEntity{}, &model.InvoiceLineEntity{},
+		&model.FixedAssetEntity{}, &model.DepreciationEntity{}, &model.BookRoleAssignmentEntity{}, &model.BookInvitationEntity{},
+		&model.ApiTokenEntity{}, &model.ApiTokenBookEntity{}, &model.UserSyncStateEntity{}, &model.ClearingAccountEntity{},
+		&model.WebhookEntity{}, &model.WebhookDeliveryEntity{}, &model.OutboxEventEntity{}, &model.OutboxCursorEntity{}, &model.OutboxGapEntity{})
+}
+
 func (r *repositoryImpl) GetOpenConnections() int {
 	sqlDB, err := r.connection.DB()
 	if err != nil {
@@ -83,15 +105,22 @@ func (r *repositoryImpl) GetOpenConnections() int {
 	return sqlDB.Stats().OpenConnections
 }
 
-// FindAllBookRealms returns all Bookrealms
-func (r *repositoryImpl) FindAllBookRealmsCorrespondingToUser(userId string) (bookRealms []model.BookRealmEntity, err model.TokyError) {
+// FindAllBookRealmsCorrespondingToUser returns the books the user owns, has a role in or is granted by claimedBookIds.
+// Archived books are only returned if includeArchived is set.
+func (r *repositoryImpl) FindAllBookRealmsCorrespondingToUser(userId string, claimedBookIds []uint, includeArchived bool) (bookRealms []model.BookRealmEntity, err model.TokyError) {
 
-	findError := r.connection.Preload("Owner").Preload("WriteAccess.ApplicationUserEntity").Preload("ReadAccess.ApplicationUserEntity").
-		Joins("LEFT JOIN map_read_access mra on book_realm_entities.id = mra.book_realm_entity_id").
-		Joins("LEFT JOIN read_application_user_wrappers arw ON arw.id = mra.read_application_user_wrapper_id").
-		Joins("LEFT JOIN map_write_access mwa on book_realm_entities.id = mwa.book_realm_entity_id").
-		Joins("LEFT JOIN write_application_user_wrappers aww ON aww.id = mwa.write_application_user_wrapper_id").
-		Where("owner_id = @userId or arw.application_user_entity_id = @userId or aww.application_user_entity_id = @userId", sql.Named("userId", userId)).
+	query := r.connection.Preload("Owner").Preload("RoleAssignments.ApplicationUserEntity").
+		Joins("LEFT JOIN book_role_assignment_entities bra on book_realm_entities.id = bra.book_realm_entity_id AND bra.deleted_at IS NULL")
+	if len(claimedBookIds) == 0 {
+		query = query.Where("(owner_id = @userId or bra.application_user_entity_id = @userId)", sql.Named("userId", userId))
+	} else {
+		query = query.Where("(owner_id = @userId or bra.application_user_entity_id = @userId or book_realm_entities.id in (@bookIds))",
+			sql.Named("userId", userId), sql.Named("bookIds", claimedBookIds))
+	}
+	if !includeArchived {
+		query = query.Where("book_realm_entities.archived_at IS NULL")
+	}
+	findError := query.
 		Group("book_realm_entities.id, book_realm_entities.created_at, book_realm_entities.updated_at, book_realm_entities.deleted_at, book_realm_entities.book_name,book_realm_entities.owner_id").
 		Find(&bookRealms).Error
 	if findError == nil {
@@ -105,11 +134,23 @@ func (r *repositoryImpl) FindAllBookRealmsCorrespondingToUser(userId string) (bo
 	return
 }
 
+// FindAllBookRealms returns every book for users whose claims grant reading all books
+func (r *repositoryImpl) FindAllBookRealms(includeArchived bool) (bookRealms []model.BookRealmEntity, err model.TokyError) {
+	query := r.connection.Preload("Owner").Preload("RoleAssignments.ApplicationUserEntity")
+	if !includeArchived {
+		query = query.Where("archived_at IS NULL")
+	}
+	findError := query.Order("id").Find(&bookRealms).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
 func (r *repositoryImpl) FindBookRealmByID(bookingID uint) (bookRealm model.BookRealmEntity, err model.TokyError) {
 	findError := r.connection.
 		Preload("Owner").
-		Preload("WriteAccess.ApplicationUserEntity").
-		Preload("ReadAccess.ApplicationUserEntity").
+		Preload("RoleAssignments.ApplicationUserEntity").
 		Where(bookingID).Find(&bookRealm).Error
 	if findError == nil {
 		return
@@ -190,35 +231,49 @@ func (r *repositoryImpl) FindAllApplicationUsersByUserName(userName string) (app
 	return
 }
 
-func (r *repositoryImpl) UpdateBookRealm(bookRealmEntity *model.BookRealmEntity) model.TokyError {
-	r.connection.Model(bookRealmEntity).Association("WriteAccess").Replace(bookRealmEntity.WriteAccess)
-	r.connection.Model(bookRealmEntity).Association("ReadAccess").Replace(bookRealmEntity.ReadAccess)
-	updateError := r.connection.Save(bookRealmEntity).Error
+// UpdateBookRealm saves the book and replaces all of its role assignments
+func (r *repositoryImpl) UpdateBookRealm(bookRealmEntity *model.BookRealmEntity, events ...model.DomainEvent) model.TokyError {
+	tx := r.connection.Begin()
+	updateError := deleteUserMapsFromBook(tx, []uint{bookRealmEntity.ID})
+	if updateError == nil {
+		updateError = tx.Save(bookRealmEntity).Error
+	}
+	if updateError == nil {
+		updateError = writeOutboxEvents(tx, events)
+	}
 	if updateError != nil {
+		tx.Rollback()
 		return model.CreateBusinessError("Could not Save Book Realm", updateError)
 	}
+	tx.Commit()
 	return nil
 }
 
-func (r *repositoryImpl) UpdateAccount(accountTableEntity *model.AccountTableEntity) model.TokyError {
-	updateError := r.connection.Save(accountTableEntity).Error
+func (r *repositoryImpl) UpdateAccount(accountTableEntity *model.AccountTableEntity, events ...model.DomainEvent) model.TokyError {
+	updateError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Save(accountTableEntity).Error }, events)
 	if updateError != nil {
 		return model.CreateBusinessError("Could not Save Account Entity", updateError)
 	}
 	return nil
 }
 
-func (r *repositoryImpl) UpdateBooking(bookingEntity *model.BookingEntity) model.TokyError {
-	updateError := r.connection.Save(bookingEntity).Error
+// UpdateBooking saves the booking and carries a changed amount over to its open item
+func (r *repositoryImpl) UpdateBooking(bookingEntity *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	updateError := r.persistWithEvents(func(tx *gorm.DB) error {
+		if err := syncOpenItemAmmounts(tx, bookingEntity); err != nil {
+			return err
+		}
+		return tx.Save(bookingEntity).Error
+	}, events)
 	if updateError != nil {
-		return model.CreateBusinessError("Could not Save Booking Entity", updateError)
+		return subledgerError("Could not Save Booking Entity", updateError)
 	}
 	return nil
 }
 
 // PersistBookRealm Create new incance of a BookRealm
-func (r *repositoryImpl) PersistBookRealm(bookRealm model.BookRealmEntity) model.TokyError {
-	saveError := r.connection.Create(&bookRealm).Error
+func (r *repositoryImpl) PersistBookRealm(bookRealm *model.BookRealmEntity, events ...model.DomainEvent) model.TokyError {
+	saveError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Create(bookRealm).Error }, events)
 	if saveError != nil {
 		return model.CreateBusinessError("Could not Persist Book Realm", saveError)
 	}
@@ -234,20 +289,34 @@ func (r *repositoryImpl) PersistApplicationUser(applicationUser model.Applicatio
 	return nil
 }
 
-// UpdateApplicationUser creates new instance of a ApplicationUser
+// UpdateApplicationUser updates the ApplicationUser, the sync state of the user is kept
 func (r *repositoryImpl) UpdateApplicationUser(applicationUser model.ApplicationUserEntity) model.TokyError {
-	saveError := r.connection.Save(&applicationUser).Error
+	saveError := r.connection.Omit("SyncedAt").Save(&applicationUser).Error
 	if saveError != nil {
 		return model.CreateBusinessError("Could not Update ApplicationUser", saveError)
 	}
 	return nil
 }
-func (r *repositoryImpl) DeleteBookRealmByID(bookID uint) model.TokyError {
+
+// UpdateBookRealmArchivedAt archives the book, a nil archivedAt restores it
+func (r *repositoryImpl) UpdateBookRealmArchivedAt(bookID uint, archivedAt *time.Time, events ...model.DomainEvent) model.TokyError {
+	updateError := r.persistWithEvents(func(tx *gorm.DB) error {
+		return tx.Model(&model.BookRealmEntity{}).Where("id = ?", bookID).Update("archived_at", archivedAt).Error
+	}, events)
+	if updateError != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not Update Archive State of Book %d", bookID), updateError)
+	}
+	return nil
+}
+
+// DeleteBookRealmByID purges the book with all of its accounts, bookings, roles and invitations.
+// The events are written after the purge removed the earlier events of the book from the outbox.
+func (r *repositoryImpl) DeleteBookRealmByID(bookID uint, events ...model.DomainEvent) model.TokyError {
 	tx := r.connection.Begin()
-	deleteErr := deleteUserMapsFromBook(tx, []uint{bookID})
-	deleteErr = deleteBookingTables(tx, []uint{bookID})
-	deleteErr = deleteAccountingTables(tx, []uint{bookID})
-	deleteErr = tx.Where("id = ?", bookID).Delete(&model.BookRealmEntity{}).Error
+	deleteErr := purgeBookRealms(tx, []uint{bookID})
+	if deleteErr == nil {
+		deleteErr = writeOutboxEvents(tx, events)
+	}
 	if deleteErr != nil {
 		tx.Rollback()
 		return model.CreateTechnicalError(fmt.Sprintf("Could not Delete Realm f Id %v", bookID), deleteErr)
@@ -255,17 +324,9 @@ func (r *repositoryImpl) DeleteBookRealmByID(bookID uint) model.TokyError {
 	tx.Commit()
 	return nil
 }
-func (r *repositoryImpl) DeleteUserWithAssociations(userId string) model.TokyError {
+func (r *repositoryImpl) DeleteUserWithAssociations(userId string, bookEvent model.BookEvent) model.TokyError {
 	tx := r.connection.Begin()
-	var bookIds []uint
-	tx.Where("owner_id = ?", userId).Find(&model.BookRealmEntity{}).Select("id").Pluck("id", &bookIds)
-	deleteErr := deleteUser(tx, userId)
-	deleteErr = deleteUserMapsFromBook(tx, bookIds)
-	deleteErr = deleteBookingTables(tx, bookIds)
-	deleteErr = deleteAccountingTables(tx, bookIds)
-	deleteErr = tx.Where("owner_id = ?", userId).Delete(&model.BookRealmEntity{}).Error
-	deleteErr = tx.Where("id = ?", userId).Delete(&model.ApplicationUserEntity{}).Error
-
+	deleteErr := deleteUserWithAssociations(tx, userId, bookEvent)
 	if deleteErr != nil {
 		tx.Rollback()
 		return model.CreateTechnicalError(fmt.Sprintf("Could not Delete Realm for User with Id %v", userId), deleteErr)
@@ -273,20 +334,142 @@ func (r *repositoryImpl) DeleteUserWithAssociations(userId string) model.TokyErr
 	tx.Commit()
 	return nil
 }
+
+// successorRoles are the roles that can take over the books of a deleted owner, the most privileged first
+var successorRoles = []types.BookRole{types.BookRoleAdmin, types.BookRoleAccountManager, types.BookRoleApprover, types.BookRoleBookkeeper}
+
+// deleteUserWithAssociations removes the user and hands each owned book over to its most privileged other writer.
+// Books without another writer are archived and purged by PurgeOrphanedBookRealms after the grace period.
+// Each book is announced as updated or archived by the event of bookEvent.
+func deleteUserWithAssociations(tx *gorm.DB, userId string, bookEvent model.BookEvent) error {
+	var bookIds []uint
+	if err := tx.Model(&model.BookRealmEntity{}).Where("owner_id = ?", userId).Pluck("id", &bookIds).Error; err != nil {
+		return err
+	}
+	for _, bookId := range bookIds {
+		if err := handOverBook(tx, bookId, userId, bookEvent); err != nil {
+			return err
+		}
+	}
+	if err := deleteUser(tx, userId); err != nil {
+		return err
+	}
+	return tx.Where("id = ?", userId).Delete(&model.ApplicationUserEntity{}).Error
+}
+
+func handOverBook(tx *gorm.DB, bookId uint, previousOwnerId string, bookEvent model.BookEvent) error {
+	var candidates []model.BookRoleAssignmentEntity
+	if err := tx.Where("book_realm_entity_id = ? AND application_user_entity_id <> ? AND role IN ?", bookId, previousOwnerId, successorRoles).
+		Order("id").Find(&candidates).Error; err != nil {
+		return err
+	}
+	successorId, found := selectSuccessor(candidates)
+	if !found {
+		if err := tx.Model(&model.BookRealmEntity{}).Where("id = ?", bookId).
+			Updates(map[string]interface{}{"archived_at": time.Now().UTC(), "pending_owner_id": ""}).Error; err != nil {
+			return err
+		}
+		return writeBookEvent(tx, bookId, types.DomainEventBookArchived, bookEvent)
+	}
+	if err := tx.Model(&model.BookRealmEntity{}).Where("id = ?", bookId).
+		Updates(map[string]interface{}{"owner_id": successorId, "pending_owner_id": ""}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("book_realm_entity_id = ? AND application_user_entity_id = ?", bookId, successorId).
+		Delete(&model.BookRoleAssignmentEntity{}).Error; err != nil {
+		return err
+	}
+	return writeBookEvent(tx, bookId, types.DomainEventBookUpdated, bookEvent)
+}
+
+// writeBookEvent reads the changed book and writes the event of bookEvent describing it to the outbox
+func writeBookEvent(tx *gorm.DB, bookId uint, eventType types.DomainEventType, bookEvent model.BookEvent) error {
+	var book model.BookRealmEntity
+	if err := tx.Preload("Owner").Preload("RoleAssignments.ApplicationUserEntity").First(&book, bookId).Error; err != nil {
+		return err
+	}
+	return writeOutboxEvents(tx, []model.DomainEvent{bookEvent(eventType, &book)})
+}
+
+func selectSuccessor(candidates []model.BookRoleAssignmentEntity) (string, bool) {
+	for _, role := range successorRoles {
+		for _, candidate := range candidates {
+			if candidate.Role == role {
+				return candidate.ApplicationUserEntityID, true
+			}
+		}
+	}
+	return "", false
+}
+
+// PurgeOrphanedBookRealms deletes the books archived before the given time whose owner no longer exists.
+// Like in DeleteBookRealmByID the purge event of each book is written after its earlier events were removed.
+func (r *repositoryImpl) PurgeOrphanedBookRealms(archivedBefore time.Time, bookEvent model.BookEvent) (int, model.TokyError) {
+	var books []model.BookRealmEntity
+	findErr := r.connection.
+		Where("archived_at < ? AND owner_id NOT IN (?)", archivedBefore, r.connection.Model(&model.ApplicationUserEntity{}).Select("id")).
+		Find(&books).Error
+	if findErr != nil {
+		return 0, model.CreateTechnicalError("Could not read orphaned Books", findErr)
+	}
+	if len(books) == 0 {
+		return 0, nil
+	}
+	bookIds := make([]uint, 0, len(books))
+	events := make([]model.DomainEvent, 0, len(books))
+	for i := range books {
+		bookIds = append(bookIds, books[i].ID)
+		events = append(events, bookEvent(types.DomainEventBookPurged, &books[i]))
+	}
+	tx := r.connection.Begin()
+	purgeErr := purgeBookRealms(tx, bookIds)
+	if purgeErr == nil {
+		purgeErr = writeOutboxEvents(tx, events)
+	}
+	if purgeErr != nil {
+		tx.Rollback()
+		return 0, model.CreateTechnicalError("Could not purge orphaned Books", purgeErr)
+	}
+	tx.Commit()
+	return len(books), nil
+}
+
+func purgeBookRealms(tx *gorm.DB, bookIds []uint) error {
+	deletions := []func() error{
+		func() error { return deleteUserMapsFromBook(tx, bookIds) },
+		func() error { return deleteInvitationsFromBook(tx, bookIds) },
+		func() error { return deleteWebhooksOfBooks(tx, bookIds) },
+		func() error { return deleteOutboxEventsOfBooks(tx, bookIds) },
+		func() error { return deleteTransferLinksOfBooks(tx, bookIds) },
+		func() error { return deleteInvoicesOfBooks(tx, bookIds) },
+		func() error { return deleteSubledgersOfBooks(tx, bookIds) },
+		func() error { return deleteFixedAssetsOfBooks(tx, bookIds) },
+		func() error { return deleteBookingTables(tx, bookIds) },
+		func() error { return deleteAccountingTables(tx, bookIds) },
+		func() error { return tx.Unscoped().Where("id IN ?", bookIds).Delete(&model.BookRealmEntity{}).Error },
+	}
+	for _, deletion := range deletions {
+		if err := deletion(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func deleteBookingTables(tx *gorm.DB, bookIds []uint) error {
 	return tx.Exec("DELETE from booking_entities where haben_booking_account_id in (select id from account_table_entities where book_realm_entity_id in (@bookIds))", sql.Named("bookIds", bookIds)).Error
 }
 
-func (r *repositoryImpl) DeleteAccount(accountEntity *model.AccountTableEntity) model.TokyError {
-	deleteError := r.connection.Delete(accountEntity).Error
+func (r *repositoryImpl) DeleteAccount(accountEntity *model.AccountTableEntity, events ...model.DomainEvent) model.TokyError {
+	deleteError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Delete(accountEntity).Error }, events)
 	if deleteError != nil {
 		return model.CreateTechnicalError("Could not Delte Account", deleteError)
 	}
 	return nil
 }
 
-func (r *repositoryImpl) DeleteBooking(booking *model.BookingEntity) model.TokyError {
-	deleteError := r.connection.Delete(booking).Error
+func (r *repositoryImpl) DeleteBooking(booking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	deleteError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Delete(booking).Error }, events)
 	if deleteError != nil {
 		return model.CreateTechnicalError("Could not Delte Booking", deleteError)
 	}
@@ -297,17 +480,27 @@ func deleteAccountingTables(tx *gorm.DB, bookbookIds []uint) error {
 	return tx.Exec("DELETE from account_table_entities where book_realm_entity_id in (@bookIds) ", sql.Named("bookIds", bookbookIds)).Error
 }
 func deleteUserMapsFromBook(tx *gorm.DB, bookIds []uint) error {
-	deleteErr := tx.Exec("DELETE FROM map_write_access WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
-	deleteErr = tx.Exec("DELETE FROM map_read_access WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
-	return deleteErr
+	return tx.Exec("DELETE FROM book_role_assignment_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+}
+
+func deleteInvitationsFromBook(tx *gorm.DB, bookIds []uint) error {
+	deleteErr := tx.Exec("DELETE FROM book_invitation_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return tx.Exec("DELETE FROM api_token_book_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
 }
 
 func deleteUser(tx *gorm.DB, userId string) error {
-	deleteErr := tx.Debug().Exec("DELETE FROM map_write_access WHERE write_application_user_wrapper_id in (select id from write_application_user_wrappers where application_user_entity_id = @userId)", sql.Named("userId", userId)).Error
-	deleteErr = tx.Debug().Exec("DELETE FROM map_read_access WHERE read_application_user_wrapper_id in (select id from read_application_user_wrappers where application_user_entity_id = @userId)", sql.Named("userId", userId)).Error
-	deleteErr = tx.Debug().Where("application_user_entity_id = ?", userId).Delete(&model.WriteApplicationUserWrapper{}).Error
-	deleteErr = tx.Debug().Where("application_user_entity_id = ?", userId).Delete(&model.ReadApplicationUserWrapper{}).Error
-	return deleteErr
+	deleteErr := tx.Exec("DELETE FROM book_role_assignment_entities WHERE application_user_entity_id = @userId", sql.Named("userId", userId)).Error
+	if deleteErr != nil {
+		return deleteErr
+	}
+	deleteErr = tx.Exec("DELETE FROM api_token_book_entities WHERE api_token_entity_id in (select id from api_token_entities where application_user_entity_id = @userId)", sql.Named("userId", userId)).Error
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return tx.Exec("DELETE FROM api_token_entities WHERE application_user_entity_id = @userId", sql.Named("userId", userId)).Error
 }
 
 func (r *repositoryImpl) FindAccountsByBookId(bookId uint) (accountTableEntities []model.AccountTableEntity, err model.TokyError) {
@@ -378,8 +571,8 @@ func (r *repositoryImpl) FindRelatedSollBuchungen(accountTable model.AccountTabl
 	return
 }
 
-func (r *repositoryImpl) CreateAccount(entity model.AccountTableEntity) model.TokyError {
-	createAccountError := r.connection.Create(&entity).Error
+func (r *repositoryImpl) CreateAccount(entity *model.AccountTableEntity, events ...model.DomainEvent) model.TokyError {
+	createAccountError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Create(entity).Error }, events)
 	if createAccountError != nil {
 		return model.CreateBusinessError("Could not Create Account", createAccountError)
 	}
@@ -398,8 +591,8 @@ func (r *repositoryImpl) FindAccountByID(id uint) (accountTable model.AccountTab
 	}
 	return
 }
-func (r *repositoryImpl) PersistBooking(entity model.BookingEntity) model.TokyError {
-	createBookingError := r.connection.Create(&entity).Error
+func (r *repositoryImpl) PersistBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	createBookingError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Create(entity).Error }, events)
 	if createBookingError != nil {
 		return model.CreateBusinessError("Could not Create Booking", createBookingError)
 	}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// createTestRepository opens a repository on a migrated sqlite database of the test
+func createTestRepository(t *testing.T) *repositoryImpl {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "toky.db") + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(0)"
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("could not open test database: %v", err)
+	}
+	r, err := CreateRepositoryWithConnection(conn)
+	if err != nil {
+		t.Fatalf("could not migrate test database: %v", err)
+	}
+	return r
+}
+
+// insert creates the entities without touching their associations
+func insert(t *testing.T, r *repositoryImpl, entities ...interface{}) {
+	t.Helper()
+	for _, entity := range entities {
+		if err := r.connection.Omit(clause.Associations).Create(entity).Error; err != nil {
+			t.Fatalf("could not insert %T: %v", entity, err)
+		}
+	}
+}
+
+// count returns the number of rows of the entity including soft deleted ones
+func count(t *testing.T, r *repositoryImpl, entity interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var rows int64
+	if err := r.connection.Unscoped().Model(entity).Where(query, args...).Count(&rows).Error; err != nil {
+		t.Fatalf("could not count %T: %v", entity, err)
+	}
+	return rows
+}
+
+func createAccount(t *testing.T, r *repositoryImpl, bookID uint, name string) model.AccountTableEntity {
+	t.Helper()
+	account := model.AccountTableEntity{BookRealmEntityID: bookID, AccountName: name}
+	insert(t, r, &account)
+	return account
+}
+
+func createBooking(t *testing.T, r *repositoryImpl, soll, haben model.AccountTableEntity, ammount string) model.BookingEntity {
+	t.Helper()
+	booking := model.BookingEntity{Date: "01.01.2025", SollBookingAccountID: soll.ID, HabenBookingAccountID: haben.ID, Ammount: ammount}
+	insert(t, r, &booking)
+	return booking
+}
+
+// describeBook stands in for the events of the service, the payload is the owner of the book
+func describeBook(eventType types.DomainEventType, book *model.BookRealmEntity) model.DomainEvent {
+	return model.DomainEvent{Type: eventType, Read: func() (uint, interface{}) {
+		return book.ID, book.OwnerID
+	}}
+}
+
+func Test_repositoryImpl_FindAllBookRealmsCorrespondingToUser(t *testing.T) {
+	r := createTestRepository(t)
+	insert(t, r,
+		&model.ApplicationUserEntity{ID: "owner", UserName: "owner"},
+		&model.BookRealmEntity{BookName: "Eigenes Buch", OwnerID: "owner"},
+		&model.BookRealmEntity{BookName: "Fremdes Buch", OwnerID: "other"},
+		&model.BookRealmEntity{BookName: "Drittes Buch", OwnerID: "other"},
+	)
+
+	tests := []struct {
+		name           string
+		claimedBookIds []uint
+		wantBooks      int
+	}{
+		{"owned books", nil, 1},
+		{"owned and claimed books", []uint{3}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookRealms, err := r.FindAllBookRealmsCorrespondingToUser("owner", tt.claimedBookIds, false)
+			if model.IsExisting(err) || len(bookRealms) != tt.wantBooks {
+				t.Errorf("FindAllBookRealmsCorrespondingToUser() = %d books, %v, want %d", len(bookRealms), err, tt.wantBooks)
+			}
+		})
+	}
+}
+
+func Test_repositoryImpl_PurgeOrphanedBookRealms(t *testing.T) {
+	r := createTestRepository(t)
+	archivedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	orphaned := model.BookRealmEntity{BookName: "Verwaist", OwnerID: "deleted", ArchivedAt: &archivedAt}
+	kept := model.BookRealmEntity{BookName: "Partnerbuch", OwnerID: "owner"}
+	insert(t, r, &model.ApplicationUserEntity{ID: "owner", UserName: "owner", EMail: "owner@example.com"}, &orphaned, &kept)
+	bank := createAccount(t, r, orphaned.ID, "Bank")
+	revenue := createAccount(t, r, orphaned.ID, "Ertrag")
+	invoiceBooking := createBooking(t, r, bank, revenue, "100.00")
+	paymentBooking := createBooking(t, r, bank, revenue, "100.00")
+	partner := model.BusinessPartnerEntity{BookRealmEntityID: orphaned.ID, Name: "Kunde"}
+	asset := model.FixedAssetEntity{BookRealmEntityID: orphaned.ID, Name: "Maschine"}
+	token := model.ApiTokenEntity{ApplicationUserEntityID: "owner", TokenHash: "hash"}
+	insert(t, r, &partner, &asset, &token)
+	insert(t, r, &model.InvoiceSettingsEntity{BookRealmEntityID: orphaned.ID},
+		&model.ClearingAccountEntity{BookRealmEntityID: orphaned.ID, CounterpartBookID: kept.ID},
+		&model.ClearingAccountEntity{BookRealmEntityID: kept.ID, CounterpartBookID: orphaned.ID},
+		&model.ApiTokenBookEntity{ApiTokenEntityID: token.ID, BookRealmEntityID: orphaned.ID})
+	openItem := model.OpenItemEntity{BookRealmEntityID: orphaned.ID, BusinessPartnerEntityID: partner.ID, BookingEntityID: invoiceBooking.ID, Ammount: "100.00"}
+	invoice := model.InvoiceEntity{BookRealmEntityID: orphaned.ID, InvoiceNumber: 1, BusinessPartnerEntityID: partner.ID, BookingEntityID: invoiceBooking.ID}
+	insert(t, r, &openItem, &invoice, &model.DepreciationEntity{FixedAssetEntityID: asset.ID, Year: 2023, BookingEntityID: paymentBooking.ID})
+	insert(t, r, &model.OpenItemPaymentEntity{OpenItemEntityID: openItem.ID, BookingEntityID: paymentBooking.ID, Ammount: "100.00"},
+		&model.InvoiceLineEntity{InvoiceEntityID: invoice.ID, Position: 1})
+
+	purged, err := r.PurgeOrphanedBookRealms(archivedAt.Add(time.Hour), describeBook)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeOrphanedBookRealms() = %d, %v, want the orphaned book purged", purged, err)
+	}
+	for _, entity := range []interface{}{
+		&model.BookRealmEntity{}, &model.AccountTableEntity{}, &model.BookingEntity{}, &model.BusinessPartnerEntity{},
+		&model.OpenItemEntity{}, &model.OpenItemPaymentEntity{}, &model.InvoiceEntity{}, &model.InvoiceLineEntity{},
+		&model.InvoiceSettingsEntity{}, &model.FixedAssetEntity{}, &model.DepreciationEntity{},
+		&model.ClearingAccountEntity{}, &model.ApiTokenBookEntity{},
+	} {
+		remaining := count(t, r, entity, "1 = 1")
+		if _, isBook := entity.(*model.BookRealmEntity); isBook {
+			remaining--
+		}
+		if remaining != 0 {
+			t.Errorf("PurgeOrphanedBookRealms() left %d rows of %T", remaining, entity)
+		}
+	}
+	if count(t, r, &model.OutboxEventEntity{}, "book_realm_entity_id = ? AND event_type = ?", orphaned.ID, types.DomainEventBookPurged) != 1 {
+		t.Errorf("PurgeOrphanedBookRealms() must announce the purge of the book")
+	}
+}
+
+func Test_selectSuccessor(t *testing.T) {
+	assignment := func(userID string, role types.BookRole) model.BookRoleAssignmentEntity {
+		return model.BookRoleAssignmentEntity{ApplicationUserEntityID: userID, Role: role}
+	}
+	tests := []struct {
+		name       string
+		candidates []model.BookRoleAssignmentEntity
+		want       string
+		wantFound  bool
+	}{
+		{"no candidates", nil, "", false},
+		{"admin before account manager", []model.BookRoleAssignmentEntity{
+			assignment("carl", types.BookRoleBookkeeper), assignment("ben", types.BookRoleAccountManager), assignment("anna", types.BookRoleAdmin)},
+			"anna", true},
+		{"account manager before bookkeeper", []model.BookRoleAssignmentEntity{
+			assignment("carl", types.BookRoleBookkeeper), assignment("ben", types.BookRoleAccountManager)},
+			"ben", true},
+		{"first of the same role", []model.BookRoleAssignmentEntity{
+			assignment("carl", types.BookRoleBookkeeper), assignment("dora", types.BookRoleBookkeeper)},
+			"carl", true},
+		{"viewers do not take over", []model.BookRoleAssignmentEntity{assignment("eva", types.BookRoleViewer)}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := selectSuccessor(tt.candidates)
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("selectSuccessor() = %q, %v, want %q, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func Test_repositoryImpl_DeleteUserWithAssociationsHandsOverBooks(t *testing.T) {
+	r := createTestRepository(t)
+	handedOver := model.BookRealmEntity{BookName: "Verein", OwnerID: "owner"}
+	archived := model.BookRealmEntity{BookName: "Haushalt", OwnerID: "owner"}
+	insert(t, r, &model.ApplicationUserEntity{ID: "owner", UserName: "owner", EMail: "owner@example.com"}, &handedOver, &archived)
+	insert(t, r,
+		&model.BookRoleAssignmentEntity{BookRealmEntityID: handedOver.ID, ApplicationUserEntityID: "ben", Role: types.BookRoleBookkeeper},
+		&model.BookRoleAssignmentEntity{BookRealmEntityID: handedOver.ID, ApplicationUserEntityID: "anna", Role: types.BookRoleAccountManager},
+		&model.BookRoleAssignmentEntity{BookRealmEntityID: archived.ID, ApplicationUserEntityID: "eva", Role: types.BookRoleViewer})
+
+	if err := r.DeleteUserWithAssociations("owner", describeBook); err != nil {
+		t.Fatalf("DeleteUserWithAssociations() = %v", err)
+	}
+	var books []model.BookRealmEntity
+	r.connection.Order("id").Find(&books)
+	if books[0].OwnerID != "anna" || books[0].ArchivedAt != nil {
+		t.Errorf("book with writers is owned by %q, archived %v, want account manager anna", books[0].OwnerID, books[0].ArchivedAt)
+	}
+	if count(t, r, &model.BookRoleAssignmentEntity{}, "book_realm_entity_id = ? AND application_user_entity_id = ?", handedOver.ID, "anna") != 0 {
+		t.Errorf("the role of the successor must be removed, the owner has all permissions")
+	}
+	if books[1].OwnerID != "owner" || books[1].ArchivedAt == nil {
+		t.Errorf("book without writers must be archived for the purge")
+	}
+	if count(t, r, &model.ApplicationUserEntity{}, "id = ?", "owner") != 0 {
+		t.Errorf("DeleteUserWithAssociations() must delete the user")
+	}
+	var events []model.OutboxEventEntity
+	r.connection.Order("id").Find(&events)
+	if len(events) != 2 || events[0].EventType != types.DomainEventBookUpdated || events[0].Payload != `"anna"` ||
+		events[1].EventType != types.DomainEventBookArchived || events[1].BookRealmEntityID != archived.ID {
+		t.Errorf("events = %+v, want the hand over and the archiving announced", events)
+	}
+}
+
+func Test_repositoryImpl_SaveOutboxCursorWithGaps(t *testing.T) {
+	r := createTestRepository(t)
+	cursor, _ := r.FindOutboxCursor("channel")
+	cursor.LastEventID = 4
+	if err := r.SaveOutboxCursor(&cursor, []model.OutboxGapEntity{{Sink: "channel", EventID: 2}, {Sink: "channel", EventID: 3}}, nil); err != nil {
+		t.Fatalf("SaveOutboxCursor() = %v", err)
+	}
+	gaps, err := r.FindOutboxGaps("channel")
+	if err != nil || len(gaps) != 2 || gaps[0].EventID != 2 {
+		t.Fatalf("FindOutboxGaps() = %+v, %v, want the gaps 2 and 3", gaps, err)
+	}
+
+	cursor.LastEventID = 5
+	if err := r.SaveOutboxCursor(&cursor, nil, []uint{gaps[0].ID}); err != nil {
+		t.Fatalf("SaveOutboxCursor() = %v", err)
+	}
+	gaps, _ = r.FindOutboxGaps("channel")
+	saved, _ := r.FindOutboxCursor("channel")
+	if len(gaps) != 1 || gaps[0].EventID != 3 || saved.LastEventID != 5 {
+		t.Errorf("gaps = %+v at cursor %d, want the gap 3 left at cursor 5", gaps, saved.LastEventID)
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrateAccessLists converts the former write and read access lists into role assignments
+// and drops the old mapping tables so that the migration only runs once
+func migrateAccessLists(conn *gorm.DB) error {
+	if !conn.Migrator().HasTable("map_write_access") && !conn.Migrator().HasTable("map_read_access") {
+		return nil
+	}
+	return conn.Transaction(func(tx *gorm.DB) error {
+		if tx.Migrator().HasTable("map_write_access") {
+			if err := copyAccessList(tx, "map_write_access", "write_application_user_wrappers", "write_application_user_wrapper_id", types.BookRoleAccountManager); err != nil {
+				return err
+			}
+		}
+		if tx.Migrator().HasTable("map_read_access") {
+			if err := copyAccessList(tx, "map_read_access", "read_application_user_wrappers", "read_application_user_wrapper_id", types.BookRoleViewer); err != nil {
+				return err
+			}
+		}
+		for _, table := range []string{"map_write_access", "map_read_access", "write_application_user_wrappers", "read_application_user_wrappers"} {
+			if err := tx.Migrator().DropTable(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func copyAccessList(tx *gorm.DB, mapTable, wrapperTable, wrapperColumn string, role types.BookRole) error {
+	return tx.Exec(fmt.Sprintf(`INSERT INTO book_role_assignment_entities (created_at, updated_at, book_realm_entity_id, application_user_entity_id, role)
+		SELECT DISTINCT NOW(), NOW(), m.book_realm_entity_id, w.application_user_entity_id, ?
+		FROM %s m JOIN %s w ON w.id = m.%s
+		WHERE w.deleted_at IS NULL
+		ON CONFLICT (book_realm_entity_id, application_user_entity_id) DO NOTHING`, mapTable, wrapperTable, wrapperColumn), role).Error
+}
+
+func (r *repositoryImpl) FindRoleAssignment(bookID uint, userID string) (assignment model.BookRoleAssignmentEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ? AND application_user_entity_id = ?", bookID, userID).First(&assignment).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Role for User %s in Book %d found", userID, bookID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) DeleteRoleAssignment(bookID uint, userID string) model.TokyError {
+	deleteError := r.connection.Unscoped().
+		Where("book_realm_entity_id = ? AND application_user_entity_id = ?", bookID, userID).
+		Delete(&model.BookRoleAssignmentEntity{}).Error
+	if deleteError != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not Delete Role of User %s in Book %d", userID, bookID), deleteError)
+	}
+	return nil
+}
+
+// assignRole creates or replaces the role of the user in the book
+func assignRole(tx *gorm.DB, bookID uint, userID string, role types.BookRole) error {
+	return tx.Omit("ApplicationUserEntity").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "book_realm_entity_id"}, {Name: "application_user_entity_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(&model.BookRoleAssignmentEntity{
+		BookRealmEntityID:       bookID,
+		ApplicationUserEntityID: userID,
+		Role:                    role,
+	}).Error
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"gorm.io/gorm"
+)
+
+func (r *repositoryImpl) PersistInvitation(invitation *model.BookInvitationEntity) model.TokyError {
+	saveError := r.connection.Omit("BookRealmEntity").Create(invitation).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Invitation", saveError)
+	}
+	return nil
+}
+
+// FindPendingInvitationsByBookId returns the invitations of the book which are neither accepted nor expired
+func (r *repositoryImpl) FindPendingInvitationsByBookId(bookID uint) (invitations []model.BookInvitationEntity, err model.TokyError) {
+	findError := r.connection.Preload("BookRealmEntity").
+		Where("book_realm_entity_id = ? AND accepted_by_id = '' AND expires_at > ?", bookID, time.Now()).
+		Order("created_at").Find(&invitations).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// FindPendingInvitationsByEMail returns the open invitations of all books addressed to the email
+func (r *repositoryImpl) FindPendingInvitationsByEMail(email string) (invitations []model.BookInvitationEntity, err model.TokyError) {
+	findError := r.connection.Preload("BookRealmEntity").
+		Where("LOWER(email) = LOWER(?) AND accepted_by_id = '' AND expires_at > ?", email, time.Now()).
+		Order("created_at").Find(&invitations).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindInvitationByToken(token string) (invitation model.BookInvitationEntity, err model.TokyError) {
+	findError := r.connection.Preload("BookRealmEntity").Where("token = ?", token).First(&invitation).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound("No Invitation found", findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) DeleteInvitation(bookID, invitationID uint) model.TokyError {
+	deletion := r.connection.Where("id = ? AND book_realm_entity_id = ?", invitationID, bookID).Delete(&model.BookInvitationEntity{})
+	if deletion.Error != nil {
+		return model.CreateTechnicalError("Could not Delete Invitation", deletion.Error)
+	}
+	if deletion.RowsAffected == 0 {
+		return model.CreateBusinessErrorNotFound(fmt.Sprintf("No Invitation with Id %d found", invitationID), errors.New("invitation not found"))
+	}
+	return nil
+}
+
+// AcceptInvitation marks the invitation as accepted and grants its role in one transaction.
+// An invitation can only be accepted once.
+func (r *repositoryImpl) AcceptInvitation(invitation model.BookInvitationEntity, userID string) model.TokyError {
+	tx := r.connection.Begin()
+	update := tx.Model(&model.BookInvitationEntity{}).
+		Where("id = ? AND accepted_by_id = ''", invitation.ID).
+		Update("accepted_by_id", userID)
+	saveError := update.Error
+	if saveError == nil && update.RowsAffected != 1 {
+		saveError = errors.New("invitation is already accepted")
+	}
+	if saveError == nil {
+		saveError = assignRole(tx, invitation.BookRealmEntityID, userID, invitation.Role)
+	}
+	if saveError != nil {
+		tx.Rollback()
+		return model.CreateBusinessError("Could not Accept Invitation", saveError)
+	}
+	tx.Commit()
+	return nil
+}
+
+func (r *repositoryImpl) UpdatePendingOwner(bookID uint, pendingOwnerID string) model.TokyError {
+	updateError := r.connection.Model(&model.BookRealmEntity{}).Where("id = ?", bookID).
+		Update("pending_owner_id", pendingOwnerID).Error
+	if updateError != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not Update pending Owner of Book %d", bookID), updateError)
+	}
+	return nil
+}
+
+// TransferOwnership makes the pending owner the owner of the book. The previous owner stays in the book as admin.
+func (r *repositoryImpl) TransferOwnership(bookID uint, previousOwnerID, newOwnerID string) model.TokyError {
+	tx := r.connection.Begin()
+	update := tx.Model(&model.BookRealmEntity{}).
+		Where("id = ? AND owner_id = ? AND pending_owner_id = ?", bookID, previousOwnerID, newOwnerID).
+		Updates(map[string]interface{}{"owner_id": newOwnerID, "pending_owner_id": ""})
+	saveError := update.Error
+	if saveError == nil && update.RowsAffected != 1 {
+		saveError = errors.New("ownership transfer is no longer pending")
+	}
+	if saveError == nil {
+		saveError = tx.Unscoped().Where("book_realm_entity_id = ? AND application_user_entity_id = ?", bookID, newOwnerID).
+			Delete(&model.BookRoleAssignmentEntity{}).Error
+	}
+	if saveError == nil {
+		saveError = assignRole(tx, bookID, previousOwnerID, types.BookRoleAdmin)
+	}
+	if saveError != nil {
+		tx.Rollback()
+		return model.CreateBusinessError(fmt.Sprintf("Could not Transfer Ownership of Book %d", bookID), saveError)
+	}
+	tx.Commit()
+	return nil
+}
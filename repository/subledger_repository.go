@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// subledgerConflict rejects a change which would leave an open item or a payment booking overpaid
+type subledgerConflict struct {
+	cause string
+}
+
+func (c subledgerConflict) Error() string {
+	return c.cause
+}
+
+// subledgerError reports a subledgerConflict as validation error and every other error with the given cause
+func subledgerError(cause string, err error) model.TokyError {
+	var conflict subledgerConflict
+	if errors.As(err, &conflict) {
+		return model.CreateBusinessValidationError(conflict.cause, err)
+	}
+	return model.CreateBusinessError(cause, err)
+}
+
+func (r *repositoryImpl) FindBusinessPartnersByBookId(bookID uint) (partners []model.BusinessPartnerEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ?", bookID).Order("name").Find(&partners).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Business Partners for BookId %d found", bookID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindBusinessPartnerByID(partnerID uint) (partner model.BusinessPartnerEntity, err model.TokyError) {
+	findError := r.connection.Where(partnerID).First(&partner).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Business Partner with Id %v found", partnerID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) PersistBusinessPartner(partner model.BusinessPartnerEntity) model.TokyError {
+	saveError := r.connection.Create(&partner).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Business Partner", saveError)
+	}
+	return nil
+}
+
+func (r *repositoryImpl) UpdateBusinessPartner(partner *model.BusinessPartnerEntity) model.TokyError {
+	updateError := r.connection.Omit("AccountTableEntity").Save(partner).Error
+	if updateError != nil {
+		return model.CreateBusinessError("Could not Save Business Partner", updateError)
+	}
+	return nil
+}
+
+func (r *repositoryImpl) FindOpenItemsByBookId(bookID uint) (openItems []model.OpenItemEntity, err model.TokyError) {
+	findError := r.connection.
+		Preload("BusinessPartnerEntity").
+		Preload("BookingEntity").
+		Preload("Payments.BookingEntity").
+		Where("book_realm_entity_id = ?", bookID).
+		Order("due_date").Find(&openItems).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Open Items for BookId %d found", bookID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindOpenItemByID(openItemID uint) (openItem model.OpenItemEntity, err model.TokyError) {
+	findError := r.connection.
+		Preload("BusinessPartnerEntity").
+		Preload("BookingEntity").
+		Preload("Payments.BookingEntity").
+		Where(openItemID).First(&openItem).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Open Item with Id %v found", openItemID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) PersistOpenItem(openItem model.OpenItemEntity) model.TokyError {
+	saveError := r.connection.Omit("BusinessPartnerEntity", "BookingEntity").Create(&openItem).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Open Item", saveError)
+	}
+	return nil
+}
+
+// PersistOpenItemPayment locks the open item and the payment booking and checks the open amount of the item and the
+// unmatched amount of the booking again before the payment is written, so concurrent payments can not overpay
+func (r *repositoryImpl) PersistOpenItemPayment(payment model.OpenItemPaymentEntity) model.TokyError {
+	saveError := r.persistWithEvents(func(tx *gorm.DB) error {
+		if err := checkOpenItemPayment(tx, payment); err != nil {
+			return err
+		}
+		return tx.Omit("BookingEntity").Create(&payment).Error
+	}, nil)
+	if saveError != nil {
+		return subledgerError("Could not Persist Payment", saveError)
+	}
+	return nil
+}
+
+func checkOpenItemPayment(tx *gorm.DB, payment model.OpenItemPaymentEntity) error {
+	var openItem model.OpenItemEntity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(payment.OpenItemEntityID).First(&openItem).Error; err != nil {
+		return err
+	}
+	var booking model.BookingEntity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(payment.BookingEntityID).First(&booking).Error; err != nil {
+		return err
+	}
+	ammount, err := bookingutils.StrToFloat(payment.Ammount)
+	if err != nil {
+		return err
+	}
+	if err := checkPaidAmmount(tx, "open_item_entity_id = ?", openItem.ID, openItem.Ammount, ammount,
+		"Payment exceeds the open amount of the open item"); err != nil {
+		return err
+	}
+	return checkPaidAmmount(tx, "booking_entity_id = ?", booking.ID, booking.Ammount, ammount,
+		"Payment exceeds the unmatched amount of the booking")
+}
+
+// syncOpenItemAmmounts is called with a changed booking. It keeps the amount of the open item of the booking equal
+// to the booking amount and rejects an amount below the payments of the open item or the payments matched by the booking.
+func syncOpenItemAmmounts(tx *gorm.DB, booking *model.BookingEntity) error {
+	var openItems []model.OpenItemEntity
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_entity_id = ?", booking.ID).Find(&openItems).Error; err != nil {
+		return err
+	}
+	for _, openItem := range openItems {
+		if err := checkPaidAmmount(tx, "open_item_entity_id = ?", openItem.ID, booking.Ammount, 0,
+			"Amount of the booking is lower than the payments of its open item"); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.OpenItemEntity{}).Where("id = ?", openItem.ID).Update("ammount", booking.Ammount).Error; err != nil {
+			return err
+		}
+	}
+	return checkPaidAmmount(tx, "booking_entity_id = ?", booking.ID, booking.Ammount, 0,
+		"Amount of the booking is lower than the payments matched with it")
+}
+
+// checkPaidAmmount fails with the cause if the payments selected by the query plus the additional amount exceed the limit
+func checkPaidAmmount(tx *gorm.DB, query string, id uint, limit string, additionalAmmount float64, cause string) error {
+	var payments []model.OpenItemPaymentEntity
+	if err := tx.Where(query, id).Find(&payments).Error; err != nil {
+		return err
+	}
+	limitAmmount, err := bookingutils.StrToFloat(limit)
+	if err != nil {
+		return err
+	}
+	paidAmmount := additionalAmmount
+	for _, payment := range payments {
+		ammount, err := bookingutils.StrToFloat(payment.Ammount)
+		if err != nil {
+			return err
+		}
+		paidAmmount += ammount
+	}
+	if paidAmmount > limitAmmount+0.005 {
+		return subledgerConflict{cause: cause}
+	}
+	return nil
+}
+
+func (r *repositoryImpl) FindOpenItemPaymentsByBookingID(bookingID uint) (payments []model.OpenItemPaymentEntity, err model.TokyError) {
+	findError := r.connection.Where("booking_entity_id = ?", bookingID).Find(&payments).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// CountSubledgerReferences counts the open items, payments and depreciations which are based on the given booking
+func (r *repositoryImpl) CountSubledgerReferences(bookingID uint) (int64, model.TokyError) {
+	var openItems, payments, depreciations int64
+	countError := r.connection.Model(&model.OpenItemEntity{}).Where("booking_entity_id = ?", bookingID).Count(&openItems).Error
+	if countError == nil {
+		countError = r.connection.Model(&model.OpenItemPaymentEntity{}).Where("booking_entity_id = ?", bookingID).Count(&payments).Error
+	}
+	if countError == nil {
+		countError = r.connection.Model(&model.DepreciationEntity{}).Where("booking_entity_id = ?", bookingID).Count(&depreciations).Error
+	}
+	if countError != nil {
+		return 0, model.CreateTechnicalError("Could not count Subledger References", countError)
+	}
+	return openItems + payments + depreciations, nil
+}
+
+func deleteSubledgersOfBooks(tx *gorm.DB, bookIds []uint) error {
+	for _, statement := range []string{
+		"DELETE FROM open_item_payment_entities WHERE open_item_entity_id in (select id from open_item_entities where book_realm_entity_id in (@bookIds))",
+		"DELETE FROM open_item_entities WHERE book_realm_entity_id in (@bookIds)",
+		"DELETE FROM business_partner_entities WHERE book_realm_entity_id in (@bookIds)",
+	} {
+		if err := tx.Exec(statement, sql.Named("bookIds", bookIds)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+func createOpenItemWithPayment(t *testing.T, r *repositoryImpl) (model.OpenItemEntity, model.BookingEntity) {
+	receivables := createAccount(t, r, 1, "Debitoren")
+	revenue := createAccount(t, r, 1, "Ertrag")
+	bank := createAccount(t, r, 1, "Bank")
+	invoice := createBooking(t, r, receivables, revenue, "100.00")
+	payment := createBooking(t, r, bank, receivables, "100.00")
+	openItem := model.OpenItemEntity{BookRealmEntityID: 1, BookingEntityID: invoice.ID, DueDate: "2025-01-31", Ammount: invoice.Ammount}
+	insert(t, r, &openItem)
+	return openItem, payment
+}
+
+func Test_repositoryImpl_PersistOpenItemPayment(t *testing.T) {
+	r := createTestRepository(t)
+	openItem, payment := createOpenItemWithPayment(t, r)
+
+	if err := r.PersistOpenItemPayment(model.OpenItemPaymentEntity{OpenItemEntityID: openItem.ID, BookingEntityID: payment.ID, Ammount: "60.00"}); model.IsExisting(err) {
+		t.Fatalf("PersistOpenItemPayment() error = %v", err)
+	}
+	err := r.PersistOpenItemPayment(model.OpenItemPaymentEntity{OpenItemEntityID: openItem.ID, BookingEntityID: payment.ID, Ammount: "60.00"})
+	if !model.IsExistingValidationError(err) {
+		t.Errorf("PersistOpenItemPayment() exceeding the open amount error = %v, want validation error", err)
+	}
+	if payments := count(t, r, &model.OpenItemPaymentEntity{}, "open_item_entity_id = ?", openItem.ID); payments != 1 {
+		t.Errorf("open item has %d payments, want 1", payments)
+	}
+}
+
+func Test_repositoryImpl_UpdateBookingSyncsOpenItem(t *testing.T) {
+	r := createTestRepository(t)
+	openItem, payment := createOpenItemWithPayment(t, r)
+	insert(t, r, &model.OpenItemPaymentEntity{OpenItemEntityID: openItem.ID, BookingEntityID: payment.ID, Ammount: "60.00"})
+
+	var invoice model.BookingEntity
+	r.connection.First(&invoice, openItem.BookingEntityID)
+	invoice.Ammount = "80.00"
+	if err := r.UpdateBooking(&invoice); model.IsExisting(err) {
+		t.Fatalf("UpdateBooking() error = %v", err)
+	}
+	var updated model.OpenItemEntity
+	r.connection.First(&updated, openItem.ID)
+	if updated.Ammount != "80.00" {
+		t.Errorf("amount of the open item = %s, want 80.00", updated.Ammount)
+	}
+
+	invoice.Ammount = "50.00"
+	if err := r.UpdateBooking(&invoice); !model.IsExistingValidationError(err) {
+		t.Errorf("UpdateBooking() below the paid amount error = %v, want validation error", err)
+	}
+	payment.Ammount = "40.00"
+	if err := r.UpdateBooking(&payment); !model.IsExistingValidationError(err) {
+		t.Errorf("UpdateBooking() of a payment below its matched amount error = %v, want validation error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm"
+)
+
+func (r *repositoryImpl) FindClearingAccountsByBookId(bookID uint) (clearingAccounts []model.ClearingAccountEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ?", bookID).Order("counterpart_book_id").Find(&clearingAccounts).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindClearingAccount(bookID, counterpartBookID uint) (clearingAccount model.ClearingAccountEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ? AND counterpart_book_id = ?", bookID, counterpartBookID).First(&clearingAccount).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Clearing Account of Book %d for Book %d found", bookID, counterpartBookID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+// SaveClearingAccount replaces the clearing account of the book for the counterpart book
+func (r *repositoryImpl) SaveClearingAccount(clearingAccount *model.ClearingAccountEntity, events ...model.DomainEvent) model.TokyError {
+	var existing model.ClearingAccountEntity
+	findError := r.connection.Where("book_realm_entity_id = ? AND counterpart_book_id = ?", clearingAccount.BookRealmEntityID, clearingAccount.CounterpartBookID).
+		Limit(1).Find(&existing).Error
+	if findError != nil {
+		return model.CreateTechnicalError("Unknown Error", findError)
+	}
+	clearingAccount.ID = existing.ID
+	saveError := r.persistWithEvents(func(tx *gorm.DB) error { return tx.Save(clearingAccount).Error }, events)
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Save Clearing Account", saveError)
+	}
+	return nil
+}
+
+// PersistInterBookTransfer creates both bookings of a transfer and links them to each other in one transaction
+func (r *repositoryImpl) PersistInterBookTransfer(source, target *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	transferErr := r.persistWithEvents(func(tx *gorm.DB) error { return persistInterBookTransfer(tx, source, target) }, events)
+	if transferErr != nil {
+		return model.CreateTechnicalError("Could not Persist Transfer", transferErr)
+	}
+	return nil
+}
+
+func persistInterBookTransfer(tx *gorm.DB, source, target *model.BookingEntity) error {
+	for _, booking := range []*model.BookingEntity{source, target} {
+		if err := tx.Omit("HabenBookingAccount", "SollBookingAccount").Create(booking).Error; err != nil {
+			return err
+		}
+	}
+	source.LinkedBookingID = target.ID
+	target.LinkedBookingID = source.ID
+	for _, booking := range []*model.BookingEntity{source, target} {
+		if err := tx.Model(&model.BookingEntity{}).Where("id = ?", booking.ID).
+			Update("linked_booking_id", booking.LinkedBookingID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateLinkedBookings saves both sides of a transfer in one transaction and carries a changed amount over to their open items
+func (r *repositoryImpl) UpdateLinkedBookings(booking, linkedBooking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	updateErr := r.persistWithEvents(func(tx *gorm.DB) error {
+		for _, changedBooking := range []*model.BookingEntity{booking, linkedBooking} {
+			if err := syncOpenItemAmmounts(tx, changedBooking); err != nil {
+				return err
+			}
+			if err := tx.Save(changedBooking).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}, events)
+	if updateErr != nil {
+		return subledgerError("Could not Save Transfer", updateErr)
+	}
+	return nil
+}
+
+// DeleteLinkedBookings deletes both sides of a transfer in one transaction
+func (r *repositoryImpl) DeleteLinkedBookings(booking, linkedBooking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	deleteErr := r.persistWithEvents(func(tx *gorm.DB) error {
+		if err := tx.Delete(booking).Error; err != nil {
+			return err
+		}
+		return tx.Delete(linkedBooking).Error
+	}, events)
+	if deleteErr != nil {
+		return model.CreateTechnicalError("Could not Delete Transfer", deleteErr)
+	}
+	return nil
+}
+
+// deleteTransferLinksOfBooks unlinks the bookings of other books from the bookings of the purged books
+// and removes the clearing accounts configured for or against the purged books
+func deleteTransferLinksOfBooks(tx *gorm.DB, bookIds []uint) error {
+	unlinkErr := tx.Exec("UPDATE booking_entities SET linked_booking_id = 0 WHERE linked_booking_id IN (select id from booking_entities where haben_booking_account_id in (select id from account_table_entities where book_realm_entity_id in (@bookIds)))", sql.Named("bookIds", bookIds)).Error
+	if unlinkErr != nil {
+		return unlinkErr
+	}
+	return tx.Exec("DELETE FROM clearing_account_entities WHERE book_realm_entity_id in (@bookIds) OR counterpart_book_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+func Test_repositoryImpl_PersistInterBookTransfer(t *testing.T) {
+	r := createTestRepository(t)
+	bank := createAccount(t, r, 1, "Bank")
+	clearing := createAccount(t, r, 1, "Verrechnung Haushalt")
+	counterpartClearing := createAccount(t, r, 2, "Verrechnung Verein")
+	cash := createAccount(t, r, 2, "Kasse")
+	source := model.BookingEntity{Date: "2025-01-01", SollBookingAccountID: clearing.ID, HabenBookingAccountID: bank.ID, Ammount: "100.00"}
+	target := model.BookingEntity{Date: "2025-01-01", SollBookingAccountID: cash.ID, HabenBookingAccountID: counterpartClearing.ID, Ammount: "100.00"}
+
+	if err := r.PersistInterBookTransfer(&source, &target); model.IsExisting(err) {
+		t.Fatalf("PersistInterBookTransfer() error = %v", err)
+	}
+	var stored model.BookingEntity
+	r.connection.First(&stored, source.ID)
+	if stored.LinkedBookingID != target.ID || target.LinkedBookingID != source.ID {
+		t.Errorf("PersistInterBookTransfer() linked %d and %d, want %d and %d", stored.LinkedBookingID, target.LinkedBookingID, target.ID, source.ID)
+	}
+
+	sqlDB, _ := r.connection.DB()
+	sqlDB.Close()
+	err := r.PersistInterBookTransfer(&model.BookingEntity{Ammount: "10.00"}, &model.BookingEntity{Ammount: "10.00"})
+	if !model.IsExisting(err) || !err.IsTechnicalError() {
+		t.Errorf("PersistInterBookTransfer() on a closed database error = %v, want technical error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"gorm.io/gorm/clause"
+)
+
+// SyncApplicationUsers replaces all users by the snapshot in one transaction.
+// Snapshots with a lower revision than the last applied one are rejected.
+// Only users of an earlier snapshot are deleted, a user provisioned from its token in the meantime
+// might have been created after the snapshot was taken and is kept until a snapshot contains it.
+func (r *repositoryImpl) SyncApplicationUsers(users []model.ApplicationUserEntity, revision int64, bookEvent model.BookEvent) (model.UserSyncReportDTO, model.TokyError) {
+	tx := r.connection.Begin()
+	syncState := model.UserSyncStateEntity{ID: 1}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).FirstOrCreate(&syncState).Error; err != nil {
+		tx.Rollback()
+		return model.UserSyncReportDTO{}, model.CreateTechnicalError("Could not read User Sync State", err)
+	}
+	if revision < syncState.Revision {
+		tx.Rollback()
+		return model.UserSyncReportDTO{}, model.CreateBusinessError(
+			fmt.Sprintf("Revision %d is older than the last synced revision %d", revision, syncState.Revision),
+			errors.New("outdated user snapshot"))
+	}
+	var existingUsers []model.ApplicationUserEntity
+	if err := tx.Find(&existingUsers).Error; err != nil {
+		tx.Rollback()
+		return model.UserSyncReportDTO{}, model.CreateTechnicalError("Could not read Users", err)
+	}
+	snapshot := make(map[string]model.ApplicationUserEntity, len(users))
+	for _, user := range users {
+		snapshot[user.ID] = user
+	}
+	existing := make(map[string]model.ApplicationUserEntity, len(existingUsers))
+	report := model.UserSyncReportDTO{Revision: revision}
+	for _, existingUser := range existingUsers {
+		existing[existingUser.ID] = existingUser
+		if _, ok := snapshot[existingUser.ID]; ok || existingUser.SyncedAt == nil {
+			continue
+		}
+		if err := deleteUserWithAssociations(tx, existingUser.ID, bookEvent); err != nil {
+			tx.Rollback()
+			return model.UserSyncReportDTO{}, model.CreateTechnicalError(fmt.Sprintf("Could not delete User %s", existingUser.ID), err)
+		}
+		report.Deleted++
+	}
+	syncedAt := time.Now().UTC()
+	for _, user := range users {
+		existingUser, ok := existing[user.ID]
+		user.SyncedAt = existingUser.SyncedAt
+		if user.SyncedAt == nil {
+			user.SyncedAt = &syncedAt
+		}
+		var err error
+		switch {
+		case !ok:
+			err = tx.Create(&user).Error
+			report.Added++
+		case !sameUser(existingUser, user):
+			err = tx.Save(&user).Error
+			report.Updated++
+		case existingUser.SyncedAt == nil:
+			// a provisioned user is marked as synced without reporting a change
+			err = tx.Save(&user).Error
+			report.Unchanged++
+		default:
+			report.Unchanged++
+		}
+		if err != nil {
+			tx.Rollback()
+			return model.UserSyncReportDTO{}, model.CreateTechnicalError(fmt.Sprintf("Could not sync User %s", user.ID), err)
+		}
+	}
+	syncState.Revision = revision
+	if err := tx.Save(&syncState).Error; err != nil {
+		tx.Rollback()
+		return model.UserSyncReportDTO{}, model.CreateTechnicalError("Could not store User Sync State", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return model.UserSyncReportDTO{}, model.CreateTechnicalError("Could not commit User Sync", err)
+	}
+	return report, nil
+}
+
+func sameUser(a, b model.ApplicationUserEntity) bool {
+	return a.ID == b.ID && a.UserName == b.UserName && a.FirstName == b.FirstName && a.LastName == b.LastName && a.EMail == b.EMail
+}
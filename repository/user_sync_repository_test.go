@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func Test_repositoryImpl_SyncApplicationUsers(t *testing.T) {
+	r := createTestRepository(t)
+	anna := model.ApplicationUserEntity{ID: "anna", UserName: "anna", EMail: "anna@example.com"}
+	ben := model.ApplicationUserEntity{ID: "ben", UserName: "ben", EMail: "ben@example.com"}
+	report, err := r.SyncApplicationUsers([]model.ApplicationUserEntity{anna, ben}, 1, describeBook)
+	if err != nil || report.Added != 2 {
+		t.Fatalf("SyncApplicationUsers() = %+v, %v, want two added users", report, err)
+	}
+	// carl signs in after the next snapshot was taken and is provisioned from the token
+	if err := r.PersistApplicationUser(model.ApplicationUserEntity{ID: "carl", UserName: "carl", EMail: "carl@example.com"}); err != nil {
+		t.Fatalf("PersistApplicationUser() = %v", err)
+	}
+	book := model.BookRealmEntity{BookName: "Buch von Ben", OwnerID: "ben"}
+	insert(t, r, &book)
+	insert(t, r, &model.BookRoleAssignmentEntity{BookRealmEntityID: book.ID, ApplicationUserEntityID: "anna", Role: types.BookRoleAdmin})
+
+	anna.FirstName = "Anna"
+	report, err = r.SyncApplicationUsers([]model.ApplicationUserEntity{anna}, 2, describeBook)
+	want := model.UserSyncReportDTO{Revision: 2, Updated: 1, Deleted: 1}
+	if err != nil || report != want {
+		t.Fatalf("SyncApplicationUsers() = %+v, %v, want %+v", report, err, want)
+	}
+	if count(t, r, &model.ApplicationUserEntity{}, "id = ?", "ben") != 0 {
+		t.Errorf("user missing in the snapshot must be deleted")
+	}
+	if count(t, r, &model.ApplicationUserEntity{}, "id = ?", "carl") != 1 {
+		t.Errorf("user provisioned after the last snapshot must be kept")
+	}
+	var owner string
+	r.connection.Model(&model.BookRealmEntity{}).Where("id = ?", book.ID).Select("owner_id").Scan(&owner)
+	if owner != "anna" {
+		t.Errorf("book of the deleted user is owned by %q, want successor anna", owner)
+	}
+
+	if _, err := r.SyncApplicationUsers([]model.ApplicationUserEntity{anna}, 1, describeBook); err == nil {
+		t.Errorf("SyncApplicationUsers() with an older revision must fail")
+	}
+
+	carl := model.ApplicationUserEntity{ID: "carl", UserName: "carl", EMail: "carl@example.com"}
+	report, err = r.SyncApplicationUsers([]model.ApplicationUserEntity{anna, carl}, 3, describeBook)
+	want = model.UserSyncReportDTO{Revision: 3, Unchanged: 2}
+	if err != nil || report != want {
+		t.Fatalf("SyncApplicationUsers() = %+v, %v, want %+v", report, err, want)
+	}
+	carl.LastName = "Meier"
+	if err := r.UpdateApplicationUser(carl); err != nil {
+		t.Fatalf("UpdateApplicationUser() = %v", err)
+	}
+	report, err = r.SyncApplicationUsers([]model.ApplicationUserEntity{anna}, 4, describeBook)
+	want = model.UserSyncReportDTO{Revision: 4, Unchanged: 1, Deleted: 1}
+	if err != nil || report != want {
+		t.Fatalf("SyncApplicationUsers() = %+v, %v, want %+v", report, err, want)
+	}
+	if count(t, r, &model.ApplicationUserEntity{}, "id = ?", "carl") != 0 {
+		t.Errorf("user missing in a snapshot after being synced must be deleted")
+	}
+}
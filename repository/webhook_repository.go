@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+	"gorm.io/gorm"
+)
+
+func (r *repositoryImpl) FindWebhooksByBookId(bookID uint) (webhooks []model.WebhookEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ?", bookID).Order("id").Find(&webhooks).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) FindWebhookByID(webhookID uint) (webhook model.WebhookEntity, err model.TokyError) {
+	findError := r.connection.Where("id = ?", webhookID).First(&webhook).Error
+	if findError == nil {
+		return
+	}
+	if gorm.ErrRecordNotFound == findError {
+		err = model.CreateBusinessErrorNotFound(fmt.Sprintf("No Webhook with Id %d found", webhookID), findError)
+	} else {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) PersistWebhook(webhook *model.WebhookEntity) model.TokyError {
+	saveError := r.connection.Create(webhook).Error
+	if saveError != nil {
+		return model.CreateBusinessError("Could not Persist Webhook", saveError)
+	}
+	return nil
+}
+
+// DeleteWebhook deletes the webhook of the book together with its delivery log
+func (r *repositoryImpl) DeleteWebhook(bookID, webhookID uint) model.TokyError {
+	tx := r.connection.Begin()
+	deleteErr := tx.Exec("DELETE FROM webhook_delivery_entities WHERE webhook_entity_id = ?", webhookID).Error
+	if deleteErr == nil {
+		deleteErr = tx.Unscoped().Where("id = ? AND book_realm_entity_id = ?", webhookID, bookID).Delete(&model.WebhookEntity{}).Error
+	}
+	if deleteErr != nil {
+		tx.Rollback()
+		return model.CreateTechnicalError("Could not Delete Webhook", deleteErr)
+	}
+	tx.Commit()
+	return nil
+}
+
+func (r *repositoryImpl) PersistWebhookDeliveries(deliveries []model.WebhookDeliveryEntity) model.TokyError {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	saveError := r.connection.Create(&deliveries).Error
+	if saveError != nil {
+		return model.CreateTechnicalError("Could not Persist Webhook Deliveries", saveError)
+	}
+	return nil
+}
+
+// FindDueWebhookDeliveries returns the oldest pending deliveries whose next attempt is due
+func (r *repositoryImpl) FindDueWebhookDeliveries(now time.Time, limit int) (deliveries []model.WebhookDeliveryEntity, err model.TokyError) {
+	findError := r.connection.Where("status = ? AND next_attempt_at <= ?", types.WebhookDeliveryPending, now).
+		Order("next_attempt_at").Limit(limit).Find(&deliveries).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func (r *repositoryImpl) UpdateWebhookDelivery(delivery *model.WebhookDeliveryEntity) model.TokyError {
+	updateError := r.connection.Save(delivery).Error
+	if updateError != nil {
+		return model.CreateTechnicalError("Could not Save Webhook Delivery", updateError)
+	}
+	return nil
+}
+
+// FindWebhookDeliveriesByBookId returns the latest deliveries of the book, newest first
+func (r *repositoryImpl) FindWebhookDeliveriesByBookId(bookID uint, limit int) (deliveries []model.WebhookDeliveryEntity, err model.TokyError) {
+	findError := r.connection.Where("book_realm_entity_id = ?", bookID).Order("id desc").Limit(limit).Find(&deliveries).Error
+	if findError != nil {
+		err = model.CreateTechnicalError("Unknown Error", findError)
+	}
+	return
+}
+
+func deleteWebhooksOfBooks(tx *gorm.DB, bookIds []uint) error {
+	deleteErr := tx.Exec("DELETE FROM webhook_delivery_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+	if deleteErr != nil {
+		return deleteErr
+	}
+	return tx.Exec("DELETE FROM webhook_entities WHERE book_realm_entity_id in (@bookIds)", sql.Named("bookIds", bookIds)).Error
+}
@@ -4,20 +4,87 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/toky03/toky-finance-accounting-service/bookingutils"
 	"github.com/toky03/toky-finance-accounting-service/model"
 	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
+// ReadClosingStatements calculates the balance sheet and income statement of a book.
+// If a cost center is given only bookings of this cost center are considered and
+// start balances are left out as they can not be attributed to a cost center.
 func (s *accountingServiceImpl) ReadClosingStatements(
 	bookId string,
+	costCenter string,
 ) (model.ClosingSheetStatements, model.TokyError) {
-	accountTables, err := s.ReadAccountsFromBook(bookId)
-	if err != nil {
+	accounts, err := s.readAccountsWithBookings(bookId)
+	if model.IsExisting(err) {
+		return model.ClosingSheetStatements{}, err
+	}
+	var accountTables []model.AccountTableDTO
+	if strings.TrimSpace(costCenter) == "" {
+		accountTables, err = buildAccountTables(accounts, allBookings, true)
+	} else {
+		accountTables, err = buildAccountTables(accounts, costCenterFilter(strings.TrimSpace(costCenter)), false)
+	}
+	if model.IsExisting(err) {
 		return model.ClosingSheetStatements{}, err
 	}
+	return calculateClosingStatements(accountTables)
+}
+
+// ReadIncomeStatementsByCostCenter calculates one income statement per cost center used in the book.
+// Bookings without a cost center are grouped under an empty cost center.
+func (s *accountingServiceImpl) ReadIncomeStatementsByCostCenter(
+	bookId string,
+) ([]model.CostCenterIncomeStatement, model.TokyError) {
+	accounts, err := s.readAccountsWithBookings(bookId)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	costCenters := collectCostCenters(accounts)
+	incomeStatements := make([]model.CostCenterIncomeStatement, 0, len(costCenters))
+	for _, costCenter := range costCenters {
+		accountTables, err := buildAccountTables(accounts, costCenterFilter(costCenter), false)
+		if model.IsExisting(err) {
+			return nil, err
+		}
+		closingStatements, err := calculateClosingStatements(accountTables)
+		if model.IsExisting(err) {
+			return nil, err
+		}
+		incomeStatements = append(incomeStatements, model.CostCenterIncomeStatement{
+			CostCenter:      costCenter,
+			IncomeStatement: closingStatements.IncomeStatement,
+		})
+	}
+	return incomeStatements, nil
+}
+
+func collectCostCenters(accounts []accountWithBookings) []string {
+	uniqueCostCenters := map[string]bool{}
+	for _, account := range accounts {
+		for _, booking := range account.sollBuchungen {
+			uniqueCostCenters[booking.CostCenter] = true
+		}
+		for _, booking := range account.habenBuchungen {
+			uniqueCostCenters[booking.CostCenter] = true
+		}
+	}
+	costCenters := make([]string, 0, len(uniqueCostCenters))
+	for costCenter := range uniqueCostCenters {
+		costCenters = append(costCenters, costCenter)
+	}
+	sort.Strings(costCenters)
+	return costCenters
+}
+
+func calculateClosingStatements(
+	accountTables []model.AccountTableDTO,
+) (model.ClosingSheetStatements, model.TokyError) {
 	workingCapitalEntries := []model.ClosingStatementEntry{}
 	capitalAssets := []model.ClosingStatementEntry{}
 	borrowedCapital := []model.ClosingStatementEntry{}
@@ -206,7 +206,7 @@ func Test_accountingServiceImpl_ReadClosingStatements(t *testing.T) {
 			mockAccountingRepository.Clear()
 			mockAccountingRepository.SetAccounts(tt.fields.accounts)
 			mockAccountingRepository.SetBookings(tt.fields.bookings)
-			got, got1 := s.ReadClosingStatements(tt.args.bookId)
+			got, got1 := s.ReadClosingStatements(tt.args.bookId, "")
 			if !reflect.DeepEqual(got, tt.wantClosingStatements) {
 				t.Errorf(
 					"ReadClosingStatements() got = \n%+v,\n want\n %+v",
@@ -221,6 +221,132 @@ func Test_accountingServiceImpl_ReadClosingStatements(t *testing.T) {
 	}
 }
 
+func Test_accountingServiceImpl_ReadClosingStatements_CostCenter(t *testing.T) {
+	mockAccountingRepository := CreateMockAccountingRepository()
+	mockAccountingRepository.SetAccounts(costCenterAccounts())
+	mockAccountingRepository.SetBookings(costCenterBookings())
+	s := CreateAccountingService(mockAccountingRepository)
+
+	got, err := s.ReadClosingStatements("0", "Sommerfest")
+	if err != nil {
+		t.Errorf("ReadClosingStatements() unexpected error %v", err)
+		return
+	}
+	wantIncomeStatement := model.IncomeStatement{
+		Creds: []model.ClosingStatementEntry{
+			{Name: "Erlös", Ammount: "100.00"},
+		},
+		Debts: []model.ClosingStatementEntry{
+			{Name: "Material", Ammount: "30.00"},
+			{Name: "Gewinn", Ammount: "70.00"},
+		},
+		BalanceSum: "100.00",
+	}
+	if !reflect.DeepEqual(got.IncomeStatement, wantIncomeStatement) {
+		t.Errorf("ReadClosingStatements() got = \n%+v,\n want\n %+v", got.IncomeStatement, wantIncomeStatement)
+	}
+	wantWorkingCapital := []model.ClosingStatementEntry{
+		{Name: "Bank", Ammount: "70.00"},
+	}
+	if !reflect.DeepEqual(got.BalanceSheet.WorkingCapital, wantWorkingCapital) {
+		t.Errorf("start balance should not be part of a cost center, got %+v", got.BalanceSheet.WorkingCapital)
+	}
+}
+
+func Test_accountingServiceImpl_ReadIncomeStatementsByCostCenter(t *testing.T) {
+	mockAccountingRepository := CreateMockAccountingRepository()
+	mockAccountingRepository.SetAccounts(costCenterAccounts())
+	mockAccountingRepository.SetBookings(costCenterBookings())
+	s := CreateAccountingService(mockAccountingRepository)
+
+	got, err := s.ReadIncomeStatementsByCostCenter("0")
+	if err != nil {
+		t.Errorf("ReadIncomeStatementsByCostCenter() unexpected error %v", err)
+		return
+	}
+	want := []model.CostCenterIncomeStatement{
+		{
+			CostCenter: "",
+			IncomeStatement: model.IncomeStatement{
+				Creds: []model.ClosingStatementEntry{
+					{Name: "Erlös", Ammount: "0"},
+					{Name: "Verlust", Ammount: "15.00"},
+				},
+				Debts: []model.ClosingStatementEntry{
+					{Name: "Material", Ammount: "15.00"},
+				},
+				BalanceSum: "15.00",
+			},
+		},
+		{
+			CostCenter: "Sommerfest",
+			IncomeStatement: model.IncomeStatement{
+				Creds: []model.ClosingStatementEntry{
+					{Name: "Erlös", Ammount: "100.00"},
+				},
+				Debts: []model.ClosingStatementEntry{
+					{Name: "Material", Ammount: "30.00"},
+					{Name: "Gewinn", Ammount: "70.00"},
+				},
+				BalanceSum: "100.00",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadIncomeStatementsByCostCenter() got = \n%+v,\n want\n %+v", got, want)
+	}
+}
+
+func costCenterAccounts() []model.AccountTableEntity {
+	return []model.AccountTableEntity{
+		{
+			Model:        gorm.Model{ID: 1},
+			AccountName:  "Bank",
+			Type:         types.AccountTypeInventory,
+			Category:     types.AccountCategoryActive,
+			SubCategory:  types.AccountSubCategoryWorkingCapital,
+			StartBalance: "1000",
+		},
+		{
+			Model:       gorm.Model{ID: 2},
+			AccountName: "Erlös",
+			Type:        types.AccountTypeIncome,
+			Category:    types.AccountCategoryGain,
+		},
+		{
+			Model:       gorm.Model{ID: 3},
+			AccountName: "Material",
+			Type:        types.AccountTypeIncome,
+			Category:    types.AccountCategoryLoss,
+		},
+	}
+}
+
+func costCenterBookings() []model.BookingEntity {
+	return []model.BookingEntity{
+		{
+			Description:           "Getränkeverkauf",
+			SollBookingAccountID:  1,
+			HabenBookingAccountID: 2,
+			Ammount:               "100",
+			CostCenter:            "Sommerfest",
+		},
+		{
+			Description:           "Dekoration",
+			SollBookingAccountID:  3,
+			HabenBookingAccountID: 1,
+			Ammount:               "30",
+			CostCenter:            "Sommerfest",
+		},
+		{
+			Description:           "Büromaterial",
+			SollBookingAccountID:  3,
+			HabenBookingAccountID: 1,
+			Ammount:               "15",
+		},
+	}
+}
+
 func Test_appendIncomeSaldo(t *testing.T) {
 	type args struct {
 		incomeStatement *model.IncomeStatement
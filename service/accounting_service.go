@@ -7,25 +7,37 @@ import (
 	"math"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/toky03/toky-finance-accounting-service/bookingutils"
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type accontingRepository interface {
 	FindAccountsByBookId(uint) ([]model.AccountTableEntity, model.TokyError)
 	FindRelatedHabenBuchungen(model.AccountTableEntity) ([]model.BookingEntity, model.TokyError)
 	FindRelatedSollBuchungen(model.AccountTableEntity) ([]model.BookingEntity, model.TokyError)
-	CreateAccount(entity model.AccountTableEntity) model.TokyError
-	UpdateAccount(entity *model.AccountTableEntity) model.TokyError
-	DeleteAccount(entity *model.AccountTableEntity) model.TokyError
+	CreateAccount(entity *model.AccountTableEntity, events ...model.DomainEvent) model.TokyError
+	UpdateAccount(entity *model.AccountTableEntity, events ...model.DomainEvent) model.TokyError
+	DeleteAccount(entity *model.AccountTableEntity, events ...model.DomainEvent) model.TokyError
 	FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError)
-	PersistBooking(entity model.BookingEntity) model.TokyError
-	UpdateBooking(entity *model.BookingEntity) model.TokyError
-	DeleteBooking(entity *model.BookingEntity) model.TokyError
+	PersistBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError
+	UpdateBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError
+	DeleteBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError
 	FindBookRealmByID(id uint) (model.BookRealmEntity, model.TokyError)
 	FindBookingsByBookId(uint) ([]model.BookingEntity, model.TokyError)
 	FindBookingByID(uint) (model.BookingEntity, model.TokyError)
+	CountSubledgerReferences(bookingID uint) (int64, model.TokyError)
+	FindAccrualsByBookId(uint) ([]model.BookingEntity, model.TokyError)
+	FindDueAccruals(date string) ([]model.BookingEntity, model.TokyError)
+	PersistReversal(accrual model.BookingEntity, reversal *model.BookingEntity, events ...model.DomainEvent) model.TokyError
+	FindClearingAccountsByBookId(bookID uint) ([]model.ClearingAccountEntity, model.TokyError)
+	FindClearingAccount(bookID, counterpartBookID uint) (model.ClearingAccountEntity, model.TokyError)
+	SaveClearingAccount(clearingAccount *model.ClearingAccountEntity, events ...model.DomainEvent) model.TokyError
+	PersistInterBookTransfer(source, target *model.BookingEntity, events ...model.DomainEvent) model.TokyError
+	UpdateLinkedBookings(booking, linkedBooking *model.BookingEntity, events ...model.DomainEvent) model.TokyError
+	DeleteLinkedBookings(booking, linkedBooking *model.BookingEntity, events ...model.DomainEvent) model.TokyError
 }
 type accountingServiceImpl struct {
 	AccountingRepository accontingRepository
@@ -113,6 +125,34 @@ func appendBalanceSaldo(balanceSheet *model.BalanceSheet, difference float64) {
 }
 
 func (s *accountingServiceImpl) ReadAccountsFromBook(bookId string) ([]model.AccountTableDTO, model.TokyError) {
+	accountsWithBookings, err := s.readAccountsWithBookings(bookId)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	return buildAccountTables(accountsWithBookings, allBookings, true)
+}
+
+// accountWithBookings holds an account together with all bookings on its soll and haben side
+type accountWithBookings struct {
+	account        model.AccountTableEntity
+	sollBuchungen  []model.BookingEntity
+	habenBuchungen []model.BookingEntity
+}
+
+// bookingFilter decides whether a booking is taken into account when building the account tables
+type bookingFilter func(model.BookingEntity) bool
+
+func allBookings(model.BookingEntity) bool {
+	return true
+}
+
+func costCenterFilter(costCenter string) bookingFilter {
+	return func(booking model.BookingEntity) bool {
+		return booking.CostCenter == costCenter
+	}
+}
+
+func (s *accountingServiceImpl) readAccountsWithBookings(bookId string) ([]accountWithBookings, model.TokyError) {
 	bookIDConv, err := strconv.Atoi(bookId)
 	if err != nil {
 		return nil, model.CreateBusinessError(fmt.Sprintf("Could not convert bookId %v to as number", bookId), err)
@@ -121,27 +161,53 @@ func (s *accountingServiceImpl) ReadAccountsFromBook(bookId string) ([]model.Acc
 	if model.IsExisting(repoError) {
 		return nil, repoError
 	}
-	accountDtos := make([]model.AccountTableDTO, 0, len(accountEntities))
+	accounts := make([]accountWithBookings, 0, len(accountEntities))
 	for _, accountEntity := range accountEntities {
 		sollBuchungen, err := s.AccountingRepository.FindRelatedSollBuchungen(accountEntity)
 		if err != nil && !model.IsExistingNotFoundError(err) {
 			return nil, err
 		}
-		sollBuchungenDTOs := convertBookingEntitiesToDTOs(sollBuchungen, "soll")
 		habenBuchungen, err := s.AccountingRepository.FindRelatedHabenBuchungen(accountEntity)
 		if err != nil && !model.IsExistingNotFoundError(err) {
 			return nil, err
 		}
-		habenBuchungenDTOs := convertBookingEntitiesToDTOs(habenBuchungen, "haben")
-		concatenatedBuchungDTOS := concatenateSorted(sollBuchungenDTOs, habenBuchungenDTOs)
-		buchungenWithStartBalance := appendStartBalance(accountEntity, concatenatedBuchungDTOS)
-		buchungenWithSaldo, sum, saldo, saldoColumn, err := appendSaldo(buchungenWithStartBalance)
-		accountDto := convertAccountEntityToDTO(accountEntity, buchungenWithSaldo, sum, saldo, saldoColumn)
-		accountDtos = append(accountDtos, accountDto)
+		accounts = append(accounts, accountWithBookings{
+			account:        accountEntity,
+			sollBuchungen:  sollBuchungen,
+			habenBuchungen: habenBuchungen,
+		})
+	}
+	return accounts, nil
+}
+
+func buildAccountTables(accounts []accountWithBookings, filter bookingFilter, withStartBalance bool) ([]model.AccountTableDTO, model.TokyError) {
+	accountDtos := make([]model.AccountTableDTO, 0, len(accounts))
+	for _, account := range accounts {
+		sollBuchungenDTOs := convertBookingEntitiesToDTOs(filterBookings(account.sollBuchungen, filter), "soll")
+		habenBuchungenDTOs := convertBookingEntitiesToDTOs(filterBookings(account.habenBuchungen, filter), "haben")
+		buchungen := concatenateSorted(sollBuchungenDTOs, habenBuchungenDTOs)
+		if withStartBalance {
+			buchungen = appendStartBalance(account.account, buchungen)
+		}
+		buchungenWithSaldo, sum, saldo, saldoColumn, err := appendSaldo(buchungen)
+		if model.IsExisting(err) {
+			return nil, err
+		}
+		accountDtos = append(accountDtos, convertAccountEntityToDTO(account.account, buchungenWithSaldo, sum, saldo, saldoColumn))
 	}
 	return accountDtos, nil
 }
 
+func filterBookings(bookings []model.BookingEntity, filter bookingFilter) []model.BookingEntity {
+	filtered := make([]model.BookingEntity, 0, len(bookings))
+	for _, booking := range bookings {
+		if filter(booking) {
+			filtered = append(filtered, booking)
+		}
+	}
+	return filtered
+}
+
 func (s *accountingServiceImpl) CreateAccount(bookID string, account model.AccountOptionDTO) model.TokyError {
 	bookIdUint, err := bookingutils.StringToUint(bookID)
 	if err != nil {
@@ -155,20 +221,20 @@ func (s *accountingServiceImpl) CreateAccount(bookID string, account model.Accou
 		return repoError
 	}
 	accountEntity := account.ToAccountTableDTO(bookingEntity)
-	return s.AccountingRepository.CreateAccount(accountEntity)
+	return s.AccountingRepository.CreateAccount(&accountEntity, accountEvent(types.DomainEventAccountCreated, &accountEntity))
 }
 
-func (s *accountingServiceImpl) UpdateAccount(accountID string, account model.AccountOptionDTO) model.TokyError {
+func (s *accountingServiceImpl) UpdateAccount(bookID, accountID string, account model.AccountOptionDTO) model.TokyError {
 	if valid, err := validateAccount(account); !valid {
 		return err
 	}
-	accountEntity, accountReadError := s.readAccountById(accountID)
+	accountEntity, accountReadError := s.readAccountOfBook(bookID, accountID)
 	if model.IsExisting(accountReadError) {
 		return accountReadError
 	}
 	mergeAccount(&accountEntity, account)
 
-	return s.AccountingRepository.UpdateAccount(&accountEntity)
+	return s.AccountingRepository.UpdateAccount(&accountEntity, accountEvent(types.DomainEventAccountUpdated, &accountEntity))
 }
 
 func (s *accountingServiceImpl) readAccountById(accountID string) (model.AccountTableEntity, model.TokyError) {
@@ -178,14 +244,17 @@ func (s *accountingServiceImpl) readAccountById(accountID string) (model.Account
 	}
 
 	accountEntity, repoErr := s.AccountingRepository.FindAccountByID(accountIDUint)
+	if model.IsExisting(repoErr) && repoErr.IsTechnicalError() {
+		return model.AccountTableEntity{}, repoErr
+	}
 	if model.IsExisting(repoErr) {
 		return model.AccountTableEntity{}, model.CreateBusinessError(fmt.Sprintf("Could not read Account with id %s", accountID), repoErr.Error())
 	}
 	return accountEntity, nil
 }
 
-func (s *accountingServiceImpl) DeleteAccount(accountID string) model.TokyError {
-	accountEntity, accountReadError := s.readAccountById(accountID)
+func (s *accountingServiceImpl) DeleteAccount(bookID, accountID string) model.TokyError {
+	accountEntity, accountReadError := s.readAccountOfBook(bookID, accountID)
 	if model.IsExisting(accountReadError) {
 		return accountReadError
 	}
@@ -197,7 +266,7 @@ func (s *accountingServiceImpl) DeleteAccount(accountID string) model.TokyError
 	if len(sollBuchungen) > 0 {
 		return model.CreateBusinessError("Konto hat Buchungen und kann deswegen nicht gelöscht werden", errors.New("Account has Bookings"))
 	}
-	return s.AccountingRepository.DeleteAccount(&accountEntity)
+	return s.AccountingRepository.DeleteAccount(&accountEntity, accountEvent(types.DomainEventAccountDeleted, &accountEntity))
 }
 
 func mergeAccount(accountEntity *model.AccountTableEntity, account model.AccountOptionDTO) {
@@ -209,29 +278,38 @@ func mergeAccount(accountEntity *model.AccountTableEntity, account model.Account
 	accountEntity.StartBalance = account.StartBalance
 }
 
-func (s *accountingServiceImpl) CreateBooking(booking model.BookingDTO) model.TokyError {
+func (s *accountingServiceImpl) CreateBooking(bookID string, booking model.BookingDTO) model.TokyError {
 	err := validateBooking(booking)
 	if model.IsExisting(err) {
 		return err
 	}
 
-	sollBookingAccount, readErr := s.readAccountFromBooking(booking.SollAccount)
+	sollBookingAccount, readErr := s.readAccountOfBook(bookID, booking.SollAccount)
+	if model.IsExisting(readErr) {
+		return readErr
+	}
+	habenBookingAccount, readErr := s.readAccountOfBook(bookID, booking.HabenAccount)
 	if model.IsExisting(readErr) {
 		return readErr
 	}
-	habenBookingAccount, readErr := s.readAccountFromBooking(booking.HabenAccount)
+	date := booking.ReadDateFormatted()
+	bookingType, reversalDate, readErr := readAccrualFields(booking, date)
 	if model.IsExisting(readErr) {
 		return readErr
 	}
 
 	bookingEntity := model.BookingEntity{
-		Date:                booking.ReadDateFormatted(),
+		Date:                date,
 		HabenBookingAccount: habenBookingAccount,
 		SollBookingAccount:  sollBookingAccount,
 		Ammount:             booking.Ammount,
 		Description:         booking.Description,
+		CostCenter:          booking.ReadCostCenterTrimmed(),
+		BookingType:         bookingType,
+		ReversalDate:        reversalDate,
 	}
-	return s.AccountingRepository.PersistBooking(bookingEntity)
+	return s.AccountingRepository.PersistBooking(&bookingEntity,
+		bookingEvent(types.DomainEventBookingCreated, sollBookingAccount.BookRealmEntityID, &bookingEntity))
 }
 
 func (s *accountingServiceImpl) readAccountFromBooking(accountId string) (model.AccountTableEntity, model.TokyError) {
@@ -242,6 +320,42 @@ func (s *accountingServiceImpl) readAccountFromBooking(accountId string) (model.
 	return s.AccountingRepository.FindAccountByID(accountIDUint)
 
 }
+
+// readAccountOfBook reads the account and reports it as not found if it belongs to another book
+func (s *accountingServiceImpl) readAccountOfBook(bookID, accountID string) (model.AccountTableEntity, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	accountEntity, err := s.readAccountFromBooking(accountID)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	if accountEntity.BookRealmEntityID != bookIDUint {
+		return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound(
+			fmt.Sprintf("No Account with Id %s found in Book %s", accountID, bookID), errors.New("Account belongs to another Book"))
+	}
+	return accountEntity, nil
+}
+
+// readBookingOfBook reads the booking and reports it as not found unless both of its accounts belong to the book
+func (s *accountingServiceImpl) readBookingOfBook(bookID, bookingID string) (model.BookingEntity, model.TokyError) {
+	bookingEntity, err := s.readBookingByID(bookingID)
+	if model.IsExisting(err) {
+		return model.BookingEntity{}, err
+	}
+	for _, accountID := range []uint{bookingEntity.SollBookingAccountID, bookingEntity.HabenBookingAccountID} {
+		if _, err := s.readAccountOfBook(bookID, bookingutils.UintToString(accountID)); model.IsExisting(err) {
+			if model.IsExistingNotFoundError(err) {
+				return model.BookingEntity{}, model.CreateBusinessErrorNotFound(
+					fmt.Sprintf("No Booking with Id %s found in Book %s", bookingID, bookID), err.Error())
+			}
+			return model.BookingEntity{}, err
+		}
+	}
+	return bookingEntity, nil
+}
+
 func (s *accountingServiceImpl) readBookingByID(bookingID string) (model.BookingEntity, model.TokyError) {
 	bookingIDUint, err := bookingutils.StringToUint(bookingID)
 	if err != nil {
@@ -249,47 +363,151 @@ func (s *accountingServiceImpl) readBookingByID(bookingID string) (model.Booking
 	}
 
 	bookingEntity, repoErr := s.AccountingRepository.FindBookingByID(bookingIDUint)
+	if model.IsExisting(repoErr) && repoErr.IsTechnicalError() {
+		return model.BookingEntity{}, repoErr
+	}
 	if model.IsExisting(repoErr) {
 		return model.BookingEntity{}, model.CreateBusinessError(fmt.Sprintf("Could not read Account with id %s", bookingID), repoErr.Error())
 	}
 	return bookingEntity, nil
 }
-func (s *accountingServiceImpl) UpdateBooking(bookingID string, booking model.BookingDTO) model.TokyError {
+func (s *accountingServiceImpl) UpdateBooking(bookID, bookingID string, booking model.BookingDTO) model.TokyError {
 	validationError := validateBooking(booking)
 	if model.IsExisting(validationError) {
 		return validationError
 	}
-	bookingEntity, readError := s.readBookingByID(bookingID)
+	bookingEntity, readError := s.readBookingOfBook(bookID, bookingID)
 	if model.IsExisting(readError) {
 		return readError
 	}
-	if booking.HabenAccount != bookingutils.UintToString(bookingEntity.HabenBookingAccountID) {
-		habenAccount, readErr := s.readAccountFromBooking(booking.HabenAccount)
+	if mutableError := checkBookingIsMutable(bookingEntity); model.IsExisting(mutableError) {
+		return mutableError
+	}
+	date := booking.ReadDateFormatted()
+	bookingType, reversalDate, readError := readAccrualFields(booking, date)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	sollChanged := booking.SollAccount != bookingutils.UintToString(bookingEntity.SollBookingAccountID)
+	habenChanged := booking.HabenAccount != bookingutils.UintToString(bookingEntity.HabenBookingAccountID)
+	if habenChanged {
+		habenAccount, readErr := s.readAccountOfBook(bookID, booking.HabenAccount)
 		if model.IsExisting(readErr) {
 			return readErr
 		}
 		bookingEntity.HabenBookingAccount = habenAccount
 	}
-	if booking.SollAccount != bookingutils.UintToString(bookingEntity.SollBookingAccountID) {
-		sollAccount, readErr := s.readAccountFromBooking(booking.SollAccount)
+	if sollChanged {
+		sollAccount, readErr := s.readAccountOfBook(bookID, booking.SollAccount)
 		if model.IsExisting(readErr) {
 			return readErr
 		}
 		bookingEntity.SollBookingAccount = sollAccount
 	}
-	bookingEntity.Date = booking.ReadDateFormatted()
+	if sollChanged || habenChanged {
+		// open items, payments and depreciations rely on the accounts of their booking
+		if referenceError := s.checkNoSubledgerReferences(bookingEntity, "auf andere Konten umgebucht"); model.IsExisting(referenceError) {
+			return referenceError
+		}
+	}
+	bookingEntity.Date = date
 	bookingEntity.Description = booking.Description
 	bookingEntity.Ammount = booking.Ammount
-	return s.AccountingRepository.UpdateBooking(&bookingEntity)
+	bookingEntity.CostCenter = booking.ReadCostCenterTrimmed()
+	bookingEntity.BookingType = bookingType
+	bookingEntity.ReversalDate = reversalDate
+	withdrawApproval(&bookingEntity)
+	bookIDUint, readError := readBookIDFromString(bookID)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	linkedBooking, linked, readError := s.readLinkedBooking(bookingEntity)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	bookingUpdated := bookingEvent(types.DomainEventBookingUpdated, bookIDUint, &bookingEntity)
+	if !linked {
+		return s.AccountingRepository.UpdateBooking(&bookingEntity, bookingUpdated)
+	}
+	if propagateError := propagateToLinkedBooking(bookingEntity, &linkedBooking); model.IsExisting(propagateError) {
+		return propagateError
+	}
+	linkedBookID, readError := s.readBookIdOfBooking(linkedBooking)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	return s.AccountingRepository.UpdateLinkedBookings(&bookingEntity, &linkedBooking,
+		bookingUpdated, bookingEvent(types.DomainEventBookingUpdated, linkedBookID, &linkedBooking))
+}
+
+// ApproveBooking records who approved the booking, a later change of the booking withdraws the approval
+func (s *accountingServiceImpl) ApproveBooking(bookID, bookingID, userID string) model.TokyError {
+	bookingEntity, readError := s.readBookingOfBook(bookID, bookingID)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	if bookingEntity.ApprovedAt != nil {
+		return model.CreateBusinessError("Buchung ist bereits freigegeben", errors.New("booking is already approved"))
+	}
+	bookIDUint, readError := readBookIDFromString(bookID)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	approvedAt := time.Now().UTC()
+	bookingEntity.ApprovedBy = userID
+	bookingEntity.ApprovedAt = &approvedAt
+	return s.AccountingRepository.UpdateBooking(&bookingEntity, bookingEvent(types.DomainEventBookingUpdated, bookIDUint, &bookingEntity))
+}
 
+func withdrawApproval(bookingEntity *model.BookingEntity) {
+	bookingEntity.ApprovedBy = ""
+	bookingEntity.ApprovedAt = nil
 }
 
-func (s *accountingServiceImpl) DeleteBooking(bookingID string) model.TokyError {
-	bookingEntity, readError := s.readBookingByID(bookingID)
+func (s *accountingServiceImpl) DeleteBooking(bookID, bookingID string) model.TokyError {
+	bookingEntity, readError := s.readBookingOfBook(bookID, bookingID)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	if mutableError := checkBookingIsMutable(bookingEntity); model.IsExisting(mutableError) {
+		return mutableError
+	}
+	if referenceError := s.checkNoSubledgerReferences(bookingEntity, "gelöscht"); model.IsExisting(referenceError) {
+		return referenceError
+	}
+	bookIDUint, readError := readBookIDFromString(bookID)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	linkedBooking, linked, readError := s.readLinkedBooking(bookingEntity)
 	if model.IsExisting(readError) {
 		return readError
 	}
-	return s.AccountingRepository.DeleteBooking(&bookingEntity)
+	bookingDeleted := bookingEvent(types.DomainEventBookingDeleted, bookIDUint, &bookingEntity)
+	if !linked {
+		return s.AccountingRepository.DeleteBooking(&bookingEntity, bookingDeleted)
+	}
+	if referenceError := s.checkNoSubledgerReferences(linkedBooking, "gelöscht"); model.IsExisting(referenceError) {
+		return referenceError
+	}
+	linkedBookID, readError := s.readBookIdOfBooking(linkedBooking)
+	if model.IsExisting(readError) {
+		return readError
+	}
+	return s.AccountingRepository.DeleteLinkedBookings(&bookingEntity, &linkedBooking,
+		bookingDeleted, bookingEvent(types.DomainEventBookingDeleted, linkedBookID, &linkedBooking))
+}
+
+// checkNoSubledgerReferences refuses the action on a booking which open items, payments or depreciations are based on
+func (s *accountingServiceImpl) checkNoSubledgerReferences(bookingEntity model.BookingEntity, action string) model.TokyError {
+	references, err := s.AccountingRepository.CountSubledgerReferences(bookingEntity.ID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if references > 0 {
+		return model.CreateBusinessError(fmt.Sprintf("Buchung ist einem offenen Posten oder einer Abschreibung zugeordnet und kann deswegen nicht %s werden", action), errors.New("Booking has Subledger References"))
+	}
+	return nil
 }
 
 func concatenateSorted(sollBuchungen, habenBuchungen []model.TableBookingDTO) []model.TableBookingDTO {
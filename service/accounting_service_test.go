@@ -0,0 +1,124 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+func createCrossBookRepository() *mockAccountingRepository {
+	repository := CreateMockAccountingRepository()
+	repository.SetAccounts([]model.AccountTableEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, AccountName: "Kasse"},
+		{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, AccountName: "Ertrag"},
+		{Model: gorm.Model{ID: 3}, BookRealmEntityID: 2, AccountName: "Bank"},
+		{Model: gorm.Model{ID: 4}, BookRealmEntityID: 2, AccountName: "Aufwand"},
+	})
+	repository.SetBookings([]model.BookingEntity{
+		{Model: gorm.Model{ID: 10}, SollBookingAccountID: 1, HabenBookingAccountID: 2, Ammount: "100", Description: "Buch 1"},
+		{Model: gorm.Model{ID: 20}, SollBookingAccountID: 3, HabenBookingAccountID: 4, Ammount: "200", Description: "Buch 2"},
+		{Model: gorm.Model{ID: 30}, SollBookingAccountID: 1, HabenBookingAccountID: 4, Ammount: "300", Description: "Gemischt"},
+	})
+	return repository
+}
+
+func Test_accountingServiceImpl_crossBookTampering(t *testing.T) {
+	income := model.AccountOptionDTO{AccountName: "Ertrag", Type: "income", Category: "gain"}
+	tests := []struct {
+		name         string
+		call         func(s *accountingServiceImpl) model.TokyError
+		wantNotFound bool
+	}{
+		{"create booking within book", func(s *accountingServiceImpl) model.TokyError {
+			return s.CreateBooking("1", model.BookingDTO{SollAccount: "1", HabenAccount: "2", Ammount: "50", Date: "2024-01-01"})
+		}, false},
+		{"create booking with soll account of other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.CreateBooking("1", model.BookingDTO{SollAccount: "3", HabenAccount: "2", Ammount: "50", Date: "2024-01-01"})
+		}, true},
+		{"create booking with haben account of other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.CreateBooking("1", model.BookingDTO{SollAccount: "1", HabenAccount: "4", Ammount: "50", Date: "2024-01-01"})
+		}, true},
+		{"update booking of other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.UpdateBooking("2", "10", model.BookingDTO{SollAccount: "1", HabenAccount: "2", Ammount: "50", Date: "2024-01-01"})
+		}, true},
+		{"update booking moving account to other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.UpdateBooking("1", "10", model.BookingDTO{SollAccount: "1", HabenAccount: "4", Ammount: "50", Date: "2024-01-01"})
+		}, true},
+		{"update booking spanning two books", func(s *accountingServiceImpl) model.TokyError {
+			return s.UpdateBooking("1", "30", model.BookingDTO{SollAccount: "1", HabenAccount: "2", Ammount: "50", Date: "2024-01-01"})
+		}, true},
+		{"delete booking of other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.DeleteBooking("2", "10")
+		}, true},
+		{"delete booking within book", func(s *accountingServiceImpl) model.TokyError {
+			return s.DeleteBooking("1", "10")
+		}, false},
+		{"update account of other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.UpdateAccount("2", "2", income)
+		}, true},
+		{"update account within book", func(s *accountingServiceImpl) model.TokyError {
+			return s.UpdateAccount("1", "2", income)
+		}, false},
+		{"delete account of other book", func(s *accountingServiceImpl) model.TokyError {
+			return s.DeleteAccount("1", "3")
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := CreateAccountingService(createCrossBookRepository())
+			err := tt.call(s)
+			if tt.wantNotFound != model.IsExistingNotFoundError(err) {
+				t.Errorf("got error %v, want not found %v", err, tt.wantNotFound)
+			}
+			if !tt.wantNotFound && model.IsExisting(err) {
+				t.Errorf("got unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func Test_accountingServiceImpl_ApproveBooking(t *testing.T) {
+	repository := createCrossBookRepository()
+	s := CreateAccountingService(repository)
+	if err := s.ApproveBooking("2", "10", "approver"); !model.IsExistingNotFoundError(err) {
+		t.Errorf("ApproveBooking() of a booking of another book = %v, want not found", err)
+	}
+	if err := s.ApproveBooking("1", "10", "approver"); model.IsExisting(err) {
+		t.Fatalf("ApproveBooking() error = %v", err)
+	}
+	approved, _ := repository.FindBookingByID(10)
+	if dto := approved.ToBookingDTO(); dto.ApprovedBy != "approver" || dto.ApprovedAt == "" {
+		t.Errorf("approved booking = %+v, want approved by approver", dto)
+	}
+	if err := s.ApproveBooking("1", "10", "approver"); !model.IsExisting(err) {
+		t.Errorf("ApproveBooking() twice must fail")
+	}
+
+	if err := s.UpdateBooking("1", "10", model.BookingDTO{SollAccount: "1", HabenAccount: "2", Ammount: "120", Date: "2024-01-01", Description: "Buch 1"}); model.IsExisting(err) {
+		t.Fatalf("UpdateBooking() error = %v", err)
+	}
+	if changed, _ := repository.FindBookingByID(10); changed.ApprovedAt != nil || changed.ApprovedBy != "" {
+		t.Errorf("UpdateBooking() must withdraw the approval, got %+v", changed)
+	}
+}
+
+func Test_accountingServiceImpl_UpdateBookingWithSubledgerReferences(t *testing.T) {
+	repository := createCrossBookRepository()
+	repository.SetAccounts(append(repository.accounts, model.AccountTableEntity{Model: gorm.Model{ID: 5}, BookRealmEntityID: 1, AccountName: "Bank"}))
+	repository.subledgerReferences = map[uint]int64{10: 1}
+	s := CreateAccountingService(repository)
+
+	if err := s.UpdateBooking("1", "10", model.BookingDTO{SollAccount: "5", HabenAccount: "2", Ammount: "100", Date: "2024-01-01"}); !model.IsExistingBuisnessError(err) {
+		t.Errorf("UpdateBooking() changing the soll account = %v, want business error", err)
+	}
+	if err := s.UpdateBooking("1", "10", model.BookingDTO{SollAccount: "1", HabenAccount: "5", Ammount: "100", Date: "2024-01-01"}); !model.IsExistingBuisnessError(err) {
+		t.Errorf("UpdateBooking() changing the haben account = %v, want business error", err)
+	}
+	if booking, _ := repository.FindBookingByID(10); booking.SollBookingAccountID != 1 || booking.HabenBookingAccountID != 2 {
+		t.Errorf("accounts of the booking were changed to %d and %d", booking.SollBookingAccountID, booking.HabenBookingAccountID)
+	}
+	if err := s.UpdateBooking("1", "10", model.BookingDTO{SollAccount: "1", HabenAccount: "2", Ammount: "100", Date: "2024-01-01", Description: "Korrigiert"}); model.IsExisting(err) {
+		t.Errorf("UpdateBooking() keeping the accounts error = %v", err)
+	}
+}
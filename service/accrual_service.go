@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// ReadAccruals lists the accruals of a book together with the state of their reversal
+func (s *accountingServiceImpl) ReadAccruals(bookID string, onlyPending bool) ([]model.AccrualDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	accruals, err := s.AccountingRepository.FindAccrualsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	today := time.Now().UTC().Format(bookingutils.DateLayout)
+	accrualDTOs := make([]model.AccrualDTO, 0, len(accruals))
+	for _, accrual := range accruals {
+		reversed := accrual.ReversalBookingID != 0
+		if onlyPending && reversed {
+			continue
+		}
+		accrualDTOs = append(accrualDTOs, model.AccrualDTO{
+			BookingDTO: accrual.ToBookingDTO(),
+			Reversed:   reversed,
+			Overdue:    !reversed && accrual.ReversalDate <= today,
+		})
+	}
+	return accrualDTOs, nil
+}
+
+// ReverseDueAccruals creates the reversing entries of all accruals whose reversal date is reached at the given date.
+// The reversal swaps soll and haben account and is booked at the reversal date. An accrual which can not be reversed
+// is logged and skipped, so it does not hold up the accruals of other books; the next run retries it.
+func (s *accountingServiceImpl) ReverseDueAccruals(date time.Time) (int, model.TokyError) {
+	accruals, err := s.AccountingRepository.FindDueAccruals(date.Format(bookingutils.DateLayout))
+	if model.IsExisting(err) {
+		return 0, err
+	}
+	reversed := 0
+	for _, accrual := range accruals {
+		reversal := model.BookingEntity{
+			Date:                  accrual.ReversalDate,
+			SollBookingAccountID:  accrual.HabenBookingAccountID,
+			HabenBookingAccountID: accrual.SollBookingAccountID,
+			Ammount:               accrual.Ammount,
+			Description:           fmt.Sprintf("Auflösung %s", accrual.Description),
+			CostCenter:            accrual.CostCenter,
+			BookingType:           types.BookingTypeReversal,
+		}
+		bookID, err := s.readBookIdOfBooking(accrual)
+		if model.IsExisting(err) {
+			log.Printf("Could not reverse accrual %d: %s", accrual.ID, err.ErrorMessage())
+			continue
+		}
+		err = s.AccountingRepository.PersistReversal(accrual, &reversal, bookingEvent(types.DomainEventBookingCreated, bookID, &reversal))
+		if model.IsExisting(err) {
+			log.Printf("Could not reverse accrual %d of book %d: %s", accrual.ID, bookID, err.ErrorMessage())
+			continue
+		}
+		reversed++
+	}
+	return reversed, nil
+}
+
+// RunAccrualReversals reverses due accruals immediately and then periodically in the given interval
+func (s *accountingServiceImpl) RunAccrualReversals(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		reversed, err := s.ReverseDueAccruals(time.Now().UTC())
+		if model.IsExisting(err) {
+			log.Printf("Could not reverse accruals: %s", err.ErrorMessage())
+		} else if reversed > 0 {
+			log.Printf("Reversed %d accruals", reversed)
+		}
+		<-ticker.C
+	}
+}
+
+// readAccrualFields validates the booking type and defaults the reversal date of an accrual to the first day of the next year
+func readAccrualFields(booking model.BookingDTO, bookingDate string) (types.BookingType, string, model.TokyError) {
+	switch booking.BookingType {
+	case "", types.BookingTypeStandard:
+		return types.BookingTypeStandard, "", nil
+	case types.BookingTypeAccrual:
+	case types.BookingTypeReversal:
+		return "", "", createValidationError("Reversal bookings are created automatically from accruals")
+	default:
+		return "", "", createValidationError("BookingType must be 'standard' or 'accrual'")
+	}
+	date, convErr := bookingutils.ParseDate(bookingDate)
+	if convErr != nil {
+		return "", "", model.CreateBusinessValidationError(fmt.Sprintf("Could not read booking date %s of accrual", bookingDate), convErr)
+	}
+	reversalDate := time.Date(date.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if strings.TrimSpace(booking.ReversalDate) != "" {
+		parsedDate, convErr := bookingutils.ParseDate(booking.ReversalDate)
+		if convErr != nil {
+			return "", "", model.CreateBusinessValidationError(fmt.Sprintf("Could not read reversal date %s", booking.ReversalDate), convErr)
+		}
+		reversalDate = parsedDate
+	}
+	if !reversalDate.After(date) {
+		return "", "", createValidationError("Reversal date must be after the booking date of the accrual")
+	}
+	return types.BookingTypeAccrual, reversalDate.Format(bookingutils.DateLayout), nil
+}
+
+// checkBookingIsMutable prevents changes which would break the link between an accrual and its reversal
+func checkBookingIsMutable(booking model.BookingEntity) model.TokyError {
+	if booking.BookingType == types.BookingTypeReversal {
+		return model.CreateBusinessError("Auflösungsbuchungen können nicht verändert werden", errors.New("booking is a reversal"))
+	}
+	if booking.ReversalBookingID != 0 {
+		return model.CreateBusinessError("Transitorische Buchung wurde bereits aufgelöst und kann nicht mehr verändert werden", errors.New("accrual is already reversed"))
+	}
+	return nil
+}
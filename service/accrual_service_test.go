@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func Test_readAccrualFields(t *testing.T) {
+	tests := []struct {
+		name             string
+		booking          model.BookingDTO
+		date             string
+		wantType         types.BookingType
+		wantReversalDate string
+		wantErr          bool
+	}{
+		{"standard booking", model.BookingDTO{}, "2024-12-31", types.BookingTypeStandard, "", false},
+		{"accrual defaults to next year", model.BookingDTO{BookingType: types.BookingTypeAccrual}, "2024-12-31", types.BookingTypeAccrual, "2025-01-01", false},
+		{"accrual with reversal date", model.BookingDTO{BookingType: types.BookingTypeAccrual, ReversalDate: "2025-03-31"}, "2024-12-31T00:00:00Z", types.BookingTypeAccrual, "2025-03-31", false},
+		{"reversal before booking date", model.BookingDTO{BookingType: types.BookingTypeAccrual, ReversalDate: "2024-12-31"}, "2024-12-31", "", "", true},
+		{"reversal can not be created manually", model.BookingDTO{BookingType: types.BookingTypeReversal}, "2024-12-31", "", "", true},
+		{"unknown type", model.BookingDTO{BookingType: "other"}, "2024-12-31", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotReversalDate, err := readAccrualFields(tt.booking, tt.date)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readAccrualFields() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotType != tt.wantType || gotReversalDate != tt.wantReversalDate {
+				t.Errorf("readAccrualFields() = %v, %v, want %v, %v", gotType, gotReversalDate, tt.wantType, tt.wantReversalDate)
+			}
+		})
+	}
+}
+
+func Test_accountingServiceImpl_ReverseDueAccruals(t *testing.T) {
+	repository := CreateMockAccountingRepository()
+	repository.SetBookings([]model.BookingEntity{
+		{Model: gorm.Model{ID: 1}, Date: "2024-12-31", SollBookingAccountID: 1, HabenBookingAccountID: 2, Ammount: "300",
+			Description: "Miete Januar", BookingType: types.BookingTypeAccrual, ReversalDate: "2025-01-01"},
+		{Model: gorm.Model{ID: 2}, Date: "2024-12-31", SollBookingAccountID: 3, HabenBookingAccountID: 4, Ammount: "50",
+			Description: "Zins", BookingType: types.BookingTypeAccrual, ReversalDate: "2025-06-30"},
+		{Model: gorm.Model{ID: 3}, Date: "2024-12-31", SollBookingAccountID: 1, HabenBookingAccountID: 2, Ammount: "10"},
+		// the account of the accrual is missing, its reversal fails
+		{Model: gorm.Model{ID: 4}, Date: "2024-12-30", SollBookingAccountID: 9, HabenBookingAccountID: 2, Ammount: "20",
+			Description: "Versicherung", BookingType: types.BookingTypeAccrual, ReversalDate: "2024-12-31"},
+	})
+	repository.SetAccounts([]model.AccountTableEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1}, {Model: gorm.Model{ID: 2}, BookRealmEntityID: 1},
+		{Model: gorm.Model{ID: 3}, BookRealmEntityID: 1}, {Model: gorm.Model{ID: 4}, BookRealmEntityID: 1},
+	})
+	s := CreateAccountingService(repository)
+
+	reversed, err := s.ReverseDueAccruals(time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC))
+	if err != nil || reversed != 1 {
+		t.Errorf("ReverseDueAccruals() = %d, %v, want 1 reversal", reversed, err)
+		return
+	}
+	reversal := repository.bookings[4]
+	if reversal.SollBookingAccountID != 2 || reversal.HabenBookingAccountID != 1 || reversal.Date != "2025-01-01" ||
+		reversal.Ammount != "300" || reversal.BookingType != types.BookingTypeReversal {
+		t.Errorf("ReverseDueAccruals() created %+v", reversal)
+	}
+	reversed, _ = s.ReverseDueAccruals(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+	if reversed != 0 {
+		t.Errorf("ReverseDueAccruals() must not reverse an accrual twice")
+	}
+	if err := s.UpdateBooking("1", "1", model.BookingDTO{Ammount: "400", SollAccount: "1", HabenAccount: "2"}); err == nil {
+		t.Errorf("UpdateBooking() of a reversed accrual must fail")
+	}
+
+	pending, err := s.ReadAccruals("1", true)
+	if err != nil || len(pending) != 2 || pending[0].BookingID != "2" || pending[0].Reversed {
+		t.Errorf("ReadAccruals() pending = %+v, %v", pending, err)
+	}
+}
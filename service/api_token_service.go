@@ -0,0 +1,173 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// ApiTokenPrefix marks personal api tokens so that they can be told apart from JWTs
+const ApiTokenPrefix = "tfa_"
+
+const (
+	defaultApiTokenValidity = 90 * 24 * time.Hour
+	maxApiTokenValidity     = 366 * 24 * time.Hour
+)
+
+type apiTokenRepository interface {
+	PersistApiToken(token *model.ApiTokenEntity) model.TokyError
+	FindApiTokensByUser(userID string) ([]model.ApiTokenEntity, model.TokyError)
+	FindApiTokenByHash(tokenHash string) (model.ApiTokenEntity, model.TokyError)
+	DeleteApiToken(userID string, tokenID uint) model.TokyError
+}
+
+type permissionChecker interface {
+	HasPermission(userId, bookID string, permission types.Permission) (bool, model.TokyError)
+}
+
+type apiTokenServiceImpl struct {
+	apiTokenRepository apiTokenRepository
+	permissionChecker  permissionChecker
+}
+
+func CreateApiTokenService(repository apiTokenRepository, permissionChecker permissionChecker) *apiTokenServiceImpl {
+	return &apiTokenServiceImpl{
+		apiTokenRepository: repository,
+		permissionChecker:  permissionChecker,
+	}
+}
+
+func (s *apiTokenServiceImpl) ReadApiTokens(userID string) ([]model.ApiTokenDTO, model.TokyError) {
+	tokenEntities, err := s.apiTokenRepository.FindApiTokensByUser(userID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	tokens := make([]model.ApiTokenDTO, 0, len(tokenEntities))
+	for _, tokenEntity := range tokenEntities {
+		tokens = append(tokens, tokenEntity.ToApiTokenDTO())
+	}
+	return tokens, nil
+}
+
+// CreateApiToken creates a token limited to the given books and scope. The user must hold the
+// permission matching the scope in every book. The returned token is not stored and can not be read again.
+func (s *apiTokenServiceImpl) CreateApiToken(userID string, token model.ApiTokenDTO) (model.ApiTokenDTO, model.TokyError) {
+	if strings.TrimSpace(token.Name) == "" {
+		return model.ApiTokenDTO{}, createValidationError("Name of the Api Token must not be empty")
+	}
+	requiredPermission, err := scopePermission(token.Scope)
+	if model.IsExisting(err) {
+		return model.ApiTokenDTO{}, err
+	}
+	expiresAt, err := readApiTokenExpiry(token.ExpiresAt)
+	if model.IsExisting(err) {
+		return model.ApiTokenDTO{}, err
+	}
+	if len(token.BookIDs) == 0 {
+		return model.ApiTokenDTO{}, createValidationError("Api Token must be limited to at least one Book")
+	}
+	books := make([]model.ApiTokenBookEntity, 0, len(token.BookIDs))
+	for _, bookID := range token.BookIDs {
+		bookIDUint, err := readBookIDFromString(bookID)
+		if model.IsExisting(err) {
+			return model.ApiTokenDTO{}, err
+		}
+		permitted, err := s.permissionChecker.HasPermission(userID, bookID, requiredPermission)
+		if model.IsExisting(err) {
+			return model.ApiTokenDTO{}, err
+		}
+		if !permitted {
+			return model.ApiTokenDTO{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Book with Id %s found", bookID), errors.New("missing permission for api token"))
+		}
+		books = append(books, model.ApiTokenBookEntity{BookRealmEntityID: bookIDUint})
+	}
+	plainToken, tokenErr := createApiToken()
+	if tokenErr != nil {
+		return model.ApiTokenDTO{}, model.CreateTechnicalError("Could not create Api Token", tokenErr)
+	}
+	tokenEntity := model.ApiTokenEntity{
+		ApplicationUserEntityID: userID,
+		Name:                    strings.TrimSpace(token.Name),
+		TokenHash:               hashApiToken(plainToken),
+		Prefix:                  plainToken[:len(ApiTokenPrefix)+4],
+		Scope:                   token.Scope,
+		ExpiresAt:               expiresAt,
+		Books:                   books,
+	}
+	err = s.apiTokenRepository.PersistApiToken(&tokenEntity)
+	if model.IsExisting(err) {
+		return model.ApiTokenDTO{}, err
+	}
+	createdToken := tokenEntity.ToApiTokenDTO()
+	createdToken.Token = plainToken
+	return createdToken, nil
+}
+
+func (s *apiTokenServiceImpl) RevokeApiToken(userID, tokenID string) model.TokyError {
+	tokenIDUint, convErr := bookingutils.StringToUint(tokenID)
+	if convErr != nil {
+		return model.CreateBusinessError(fmt.Sprintf("Could not read Api Token Id: %s", tokenID), convErr)
+	}
+	return s.apiTokenRepository.DeleteApiToken(userID, tokenIDUint)
+}
+
+// AuthenticateApiToken resolves a presented token to its owner, scope and books.
+// Unknown, revoked and expired tokens are reported as not found.
+func (s *apiTokenServiceImpl) AuthenticateApiToken(plainToken string) (model.ApiTokenDTO, model.TokyError) {
+	tokenEntity, err := s.apiTokenRepository.FindApiTokenByHash(hashApiToken(plainToken))
+	if model.IsExisting(err) {
+		return model.ApiTokenDTO{}, err
+	}
+	if time.Now().After(tokenEntity.ExpiresAt) {
+		return model.ApiTokenDTO{}, model.CreateBusinessErrorNotFound("No Api Token found", errors.New("api token is expired"))
+	}
+	return tokenEntity.ToApiTokenDTO(), nil
+}
+
+func scopePermission(scope types.TokenScope) (types.Permission, model.TokyError) {
+	switch scope {
+	case types.TokenScopeRead:
+		return types.PermissionRead, nil
+	case types.TokenScopeWrite:
+		return types.PermissionBook, nil
+	}
+	return "", createValidationError(fmt.Sprintf("Unknown Scope %s", scope))
+}
+
+func readApiTokenExpiry(expiresAt string) (time.Time, model.TokyError) {
+	now := time.Now()
+	if expiresAt == "" {
+		return now.Add(defaultApiTokenValidity), nil
+	}
+	expiry, err := bookingutils.ParseDate(expiresAt)
+	if err != nil {
+		return time.Time{}, createValidationError(fmt.Sprintf("Could not read Expiry %s", expiresAt))
+	}
+	if !expiry.After(now) || expiry.After(now.Add(maxApiTokenValidity)) {
+		return time.Time{}, createValidationError("Expiry of the Api Token must be in the future and at most one year ahead")
+	}
+	return expiry, nil
+}
+
+func createApiToken() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return ApiTokenPrefix + base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// hashApiToken uses a plain SHA-256 because the tokens are random and long enough to withstand guessing
+func hashApiToken(plainToken string) string {
+	hash := sha256.Sum256([]byte(plainToken))
+	return hex.EncodeToString(hash[:])
+}
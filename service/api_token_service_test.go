@@ -0,0 +1,95 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createApiTokenTestService() (*apiTokenServiceImpl, *mockApiTokenRepository) {
+	repository := &mockApiTokenRepository{}
+	permissionChecker := mockPermissionChecker{permissions: map[string]bool{
+		"anna1read": true,
+		"anna1book": true,
+		"anna2read": true,
+	}}
+	return CreateApiTokenService(repository, permissionChecker), repository
+}
+
+func Test_apiTokenServiceImpl_CreateApiToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   model.ApiTokenDTO
+		wantErr bool
+	}{
+		{"read token", model.ApiTokenDTO{Name: "export", Scope: types.TokenScopeRead, BookIDs: []string{"1", "2"}}, false},
+		{"write token", model.ApiTokenDTO{Name: "import", Scope: types.TokenScopeWrite, BookIDs: []string{"1"}}, false},
+		{"write token for viewed book", model.ApiTokenDTO{Name: "import", Scope: types.TokenScopeWrite, BookIDs: []string{"2"}}, true},
+		{"foreign book", model.ApiTokenDTO{Name: "export", Scope: types.TokenScopeRead, BookIDs: []string{"3"}}, true},
+		{"without books", model.ApiTokenDTO{Name: "export", Scope: types.TokenScopeRead}, true},
+		{"without name", model.ApiTokenDTO{Scope: types.TokenScopeRead, BookIDs: []string{"1"}}, true},
+		{"unknown scope", model.ApiTokenDTO{Name: "export", Scope: "admin", BookIDs: []string{"1"}}, true},
+		{"expired", model.ApiTokenDTO{Name: "export", Scope: types.TokenScopeRead, BookIDs: []string{"1"}, ExpiresAt: "2001-01-01"}, true},
+		{"too long valid", model.ApiTokenDTO{Name: "export", Scope: types.TokenScopeRead, BookIDs: []string{"1"}, ExpiresAt: time.Now().AddDate(2, 0, 0).Format(time.RFC3339)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repository := createApiTokenTestService()
+			createdToken, err := s.CreateApiToken("anna", tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateApiToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !strings.HasPrefix(createdToken.Token, ApiTokenPrefix) {
+				t.Errorf("CreateApiToken() token = %v, want prefix %v", createdToken.Token, ApiTokenPrefix)
+			}
+			if repository.tokens[0].TokenHash == createdToken.Token {
+				t.Errorf("CreateApiToken() must not store the plain token")
+			}
+			tokens, _ := s.ReadApiTokens("anna")
+			if len(tokens) != 1 || tokens[0].Token != "" {
+				t.Errorf("ReadApiTokens() = %v, want one token without plain value", tokens)
+			}
+		})
+	}
+}
+
+func Test_apiTokenServiceImpl_AuthenticateApiToken(t *testing.T) {
+	s, repository := createApiTokenTestService()
+	createdToken, err := s.CreateApiToken("anna", model.ApiTokenDTO{Name: "export", Scope: types.TokenScopeRead, BookIDs: []string{"1"}})
+	if err != nil {
+		t.Fatalf("CreateApiToken() error = %v", err)
+	}
+
+	apiToken, err := s.AuthenticateApiToken(createdToken.Token)
+	if err != nil {
+		t.Errorf("AuthenticateApiToken() error = %v", err)
+	}
+	if apiToken.UserID != "anna" || !apiToken.Grants("1", types.PermissionRead) || apiToken.Grants("1", types.PermissionBook) {
+		t.Errorf("AuthenticateApiToken() = %v, want read token of anna for book 1", apiToken)
+	}
+	if _, err := s.AuthenticateApiToken(ApiTokenPrefix + "guessed"); !model.IsExistingNotFoundError(err) {
+		t.Errorf("AuthenticateApiToken() with unknown token error = %v, want not found", err)
+	}
+
+	repository.tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+	if _, err := s.AuthenticateApiToken(createdToken.Token); !model.IsExistingNotFoundError(err) {
+		t.Errorf("AuthenticateApiToken() with expired token error = %v, want not found", err)
+	}
+
+	if err := s.RevokeApiToken("ben", createdToken.TokenID); err == nil {
+		t.Errorf("RevokeApiToken() of foreign token must fail")
+	}
+	if err := s.RevokeApiToken("anna", createdToken.TokenID); err != nil {
+		t.Errorf("RevokeApiToken() error = %v", err)
+	}
+	if _, err := s.AuthenticateApiToken(createdToken.Token); err == nil {
+		t.Errorf("AuthenticateApiToken() with revoked token must fail")
+	}
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type assetRepository interface {
+	FindFixedAssetsByBookId(uint) ([]model.FixedAssetEntity, model.TokyError)
+	PersistFixedAsset(model.FixedAssetEntity) model.TokyError
+	PersistDepreciation(model.DepreciationEntity, *model.BookingEntity, ...model.DomainEvent) model.TokyError
+	FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError)
+}
+
+type assetServiceImpl struct {
+	assetRepository assetRepository
+}
+
+func CreateAssetService(repository assetRepository) *assetServiceImpl {
+	return &assetServiceImpl{
+		assetRepository: repository,
+	}
+}
+
+func (s *assetServiceImpl) ReadFixedAssets(bookID string) ([]model.FixedAssetDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	assetEntities, err := s.assetRepository.FindFixedAssetsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	assetDTOs := make([]model.FixedAssetDTO, 0, len(assetEntities))
+	for _, assetEntity := range assetEntities {
+		assetDTOs = append(assetDTOs, assetEntity.ToFixedAssetDTO())
+	}
+	return assetDTOs, nil
+}
+
+func (s *assetServiceImpl) CreateFixedAsset(bookID string, asset model.FixedAssetDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	assetAccount, err := s.readAccount(bookIDUint, asset.AssetAccountID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if assetAccount.Category != types.AccountCategoryActive || assetAccount.SubCategory != types.AccountSubCategoryCapitalAsset {
+		return createValidationError("Asset account must be an active capital asset account")
+	}
+	depreciationAccount, err := s.readAccount(bookIDUint, asset.DepreciationAccountID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if depreciationAccount.Category != types.AccountCategoryLoss {
+		return createValidationError("Depreciation account must be a loss account")
+	}
+	assetEntity, err := createFixedAssetEntity(bookIDUint, asset)
+	if model.IsExisting(err) {
+		return err
+	}
+	assetEntity.AssetAccountID = assetAccount.ID
+	assetEntity.DepreciationAccountID = depreciationAccount.ID
+	return s.assetRepository.PersistFixedAsset(assetEntity)
+}
+
+func (s *assetServiceImpl) readAccount(bookID uint, accountID string) (model.AccountTableEntity, model.TokyError) {
+	accountIDUint, convErr := bookingutils.StringToUint(accountID)
+	if convErr != nil {
+		return model.AccountTableEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Account Id %s as AccountId", accountID), convErr)
+	}
+	account, err := s.assetRepository.FindAccountByID(accountIDUint)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	if account.BookRealmEntityID != bookID {
+		return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Account with Id %s found in Book", accountID), errors.New("account belongs to another book"))
+	}
+	return account, nil
+}
+
+// RunDepreciation posts the planned depreciation of the given year for every asset of the book.
+// Assets which are already depreciated for the year are skipped, so the run can be repeated safely.
+// The run is refused as long as an earlier year of an asset is not posted, as its book value would be unknown.
+func (s *assetServiceImpl) RunDepreciation(bookID string, year int) (model.DepreciationRunDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.DepreciationRunDTO{}, err
+	}
+	if year < 1900 || year > 9999 {
+		return model.DepreciationRunDTO{}, createValidationError(fmt.Sprintf("Year %d is not valid", year))
+	}
+	assets, err := s.assetRepository.FindFixedAssetsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return model.DepreciationRunDTO{}, err
+	}
+	for _, asset := range assets {
+		if err := checkEarlierYearsPosted(asset, year); model.IsExisting(err) {
+			return model.DepreciationRunDTO{}, err
+		}
+	}
+	run := model.DepreciationRunDTO{Year: year, Postings: []model.DepreciationPostingDTO{}}
+	for _, asset := range assets {
+		if _, booked := findDepreciation(asset, year); booked {
+			continue
+		}
+		_, depreciation, convErr := planDepreciation(asset, year)
+		if convErr != nil {
+			return run, model.CreateTechnicalError(fmt.Sprintf("Could not calculate depreciation of asset %s", asset.Name), convErr)
+		}
+		if bookingutils.AlmostZero(depreciation) {
+			continue
+		}
+		ammount := bookingutils.FormatFloatToAmmount(depreciation)
+		booking := model.BookingEntity{
+			Date:                  fmt.Sprintf("%d-12-31", year),
+			SollBookingAccountID:  asset.DepreciationAccountID,
+			HabenBookingAccountID: asset.AssetAccountID,
+			Ammount:               ammount,
+			Description:           fmt.Sprintf("Abschreibung %s %d", asset.Name, year),
+		}
+		err := s.assetRepository.PersistDepreciation(
+			model.DepreciationEntity{FixedAssetEntityID: asset.ID, Year: year, Ammount: ammount},
+			&booking, bookingEvent(types.DomainEventBookingCreated, bookIDUint, &booking))
+		if model.IsExisting(err) {
+			return run, err
+		}
+		run.Postings = append(run.Postings, model.DepreciationPostingDTO{
+			AssetID:   bookingutils.UintToString(asset.ID),
+			AssetName: asset.Name,
+			BookingID: bookingutils.UintToString(booking.ID),
+			Ammount:   ammount,
+		})
+	}
+	return run, nil
+}
+
+// checkEarlierYearsPosted refuses to depreciate the year while an earlier year with planned depreciation is not posted
+func checkEarlierYearsPosted(asset model.FixedAssetEntity, year int) model.TokyError {
+	acquisitionDate, convErr := bookingutils.ParseDate(asset.AcquisitionDate)
+	if convErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not read acquisition date of asset %s", asset.Name), convErr)
+	}
+	for earlierYear := acquisitionDate.Year(); earlierYear < year; earlierYear++ {
+		if _, booked := findDepreciation(asset, earlierYear); booked {
+			continue
+		}
+		_, depreciation, convErr := planDepreciation(asset, earlierYear)
+		if convErr != nil {
+			return model.CreateTechnicalError(fmt.Sprintf("Could not calculate depreciation of asset %s", asset.Name), convErr)
+		}
+		if !bookingutils.AlmostZero(depreciation) {
+			return createValidationError(fmt.Sprintf("Depreciation of asset %s for %d must be posted before %d", asset.Name, earlierYear, year))
+		}
+	}
+	return nil
+}
+
+// ReadAssetSchedule lists book values and depreciation of every asset for the given year
+func (s *assetServiceImpl) ReadAssetSchedule(bookID, reportYear string) (model.AssetScheduleDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.AssetScheduleDTO{}, err
+	}
+	year := time.Now().UTC().Year()
+	if strings.TrimSpace(reportYear) != "" {
+		parsedYear, convErr := strconv.Atoi(strings.TrimSpace(reportYear))
+		if convErr != nil {
+			return model.AssetScheduleDTO{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not read year %s", reportYear), convErr)
+		}
+		year = parsedYear
+	}
+	assets, err := s.assetRepository.FindFixedAssetsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return model.AssetScheduleDTO{}, err
+	}
+	schedule, convErr := calculateAssetSchedule(assets, year)
+	if convErr != nil {
+		return model.AssetScheduleDTO{}, model.CreateTechnicalError("Could not calculate asset schedule", convErr)
+	}
+	return schedule, nil
+}
+
+func calculateAssetSchedule(assets []model.FixedAssetEntity, year int) (model.AssetScheduleDTO, error) {
+	schedule := model.AssetScheduleDTO{Year: year, Entries: []model.AssetScheduleEntryDTO{}}
+	var totalCost, totalOpening, totalDepreciation float64
+	for _, asset := range assets {
+		acquisitionDate, err := bookingutils.ParseDate(asset.AcquisitionDate)
+		if err != nil {
+			return model.AssetScheduleDTO{}, err
+		}
+		if acquisitionDate.Year() > year {
+			continue
+		}
+		cost, err := bookingutils.StrToFloat(asset.AcquisitionCost)
+		if err != nil {
+			return model.AssetScheduleDTO{}, err
+		}
+		opening, depreciation, err := planDepreciation(asset, year)
+		if err != nil {
+			return model.AssetScheduleDTO{}, err
+		}
+		posted, isBooked, err := postedDepreciation(asset, year)
+		if err != nil {
+			return model.AssetScheduleDTO{}, err
+		}
+		if isBooked {
+			depreciation = posted
+		}
+		totalCost += cost
+		totalOpening += opening
+		totalDepreciation += depreciation
+		schedule.Entries = append(schedule.Entries, model.AssetScheduleEntryDTO{
+			AssetID:                 bookingutils.UintToString(asset.ID),
+			AssetName:               asset.Name,
+			AcquisitionDate:         asset.AcquisitionDate,
+			AcquisitionCost:         bookingutils.FormatFloatToAmmount(cost),
+			OpeningBookValue:        bookingutils.FormatFloatToAmmount(opening),
+			Depreciation:            bookingutils.FormatFloatToAmmount(depreciation),
+			DepreciationBooked:      isBooked,
+			AccumulatedDepreciation: bookingutils.FormatFloatToAmmount(cost - opening + depreciation),
+			ClosingBookValue:        bookingutils.FormatFloatToAmmount(opening - depreciation),
+		})
+	}
+	schedule.Total = model.AssetScheduleEntryDTO{
+		AcquisitionCost:         bookingutils.FormatFloatToAmmount(totalCost),
+		OpeningBookValue:        bookingutils.FormatFloatToAmmount(totalOpening),
+		Depreciation:            bookingutils.FormatFloatToAmmount(totalDepreciation),
+		AccumulatedDepreciation: bookingutils.FormatFloatToAmmount(totalCost - totalOpening + totalDepreciation),
+		ClosingBookValue:        bookingutils.FormatFloatToAmmount(totalOpening - totalDepreciation),
+	}
+	return schedule, nil
+}
+
+// planDepreciation returns the book value at the beginning of the year and the planned depreciation of the year.
+// The book value is reduced by the posted depreciation of the earlier years and by the planned one of years not posted.
+// The acquisition year is depreciated pro rata by month, in the last year of the useful life
+// the remaining value down to the residual value is written off.
+func planDepreciation(asset model.FixedAssetEntity, year int) (float64, float64, error) {
+	acquisitionDate, err := bookingutils.ParseDate(asset.AcquisitionDate)
+	if err != nil {
+		return 0, 0, err
+	}
+	cost, err := bookingutils.StrToFloat(asset.AcquisitionCost)
+	if err != nil {
+		return 0, 0, err
+	}
+	residual, err := readOptionalAmmount(asset.ResidualValue)
+	if err != nil {
+		return 0, 0, err
+	}
+	rate, err := readOptionalAmmount(asset.DecliningRate)
+	if err != nil {
+		return 0, 0, err
+	}
+	if year < acquisitionDate.Year() || asset.UsefulLifeYears < 1 {
+		return 0, 0, nil
+	}
+	// depreciation starts with the month of acquisition
+	firstMonth := time.Date(acquisitionDate.Year(), acquisitionDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	lastYear := firstMonth.AddDate(asset.UsefulLifeYears, 0, -1).Year()
+	bookValue := cost
+	for currentYear := acquisitionDate.Year(); ; currentYear++ {
+		remaining := bookValue - residual
+		depreciation := 0.0
+		if remaining > 0.005 {
+			factor := 1.0
+			if currentYear == acquisitionDate.Year() {
+				factor = float64(13-int(firstMonth.Month())) / 12
+			}
+			if asset.Method == types.DepreciationMethodDecliningBalance {
+				depreciation = bookValue * rate / 100 * factor
+			} else {
+				depreciation = (cost - residual) / float64(asset.UsefulLifeYears) * factor
+			}
+			if currentYear >= lastYear {
+				depreciation = remaining
+			}
+			depreciation = math.Round(math.Min(depreciation, remaining)*100) / 100
+		}
+		if currentYear == year {
+			return bookValue, depreciation, nil
+		}
+		posted, isPosted, err := postedDepreciation(asset, currentYear)
+		if err != nil {
+			return 0, 0, err
+		}
+		if isPosted {
+			depreciation = posted
+		}
+		bookValue -= depreciation
+	}
+}
+
+// postedDepreciation returns the amount of the depreciation booking of the year, which may have been corrected
+// after the depreciation run
+func postedDepreciation(asset model.FixedAssetEntity, year int) (float64, bool, error) {
+	depreciation, isPosted := findDepreciation(asset, year)
+	if !isPosted {
+		return 0, false, nil
+	}
+	ammount := depreciation.Ammount
+	if depreciation.BookingEntity.ID != 0 {
+		ammount = depreciation.BookingEntity.Ammount
+	}
+	posted, err := bookingutils.StrToFloat(ammount)
+	return posted, true, err
+}
+
+func findDepreciation(asset model.FixedAssetEntity, year int) (model.DepreciationEntity, bool) {
+	for _, depreciation := range asset.Depreciations {
+		if depreciation.Year == year {
+			return depreciation, true
+		}
+	}
+	return model.DepreciationEntity{}, false
+}
+
+func createFixedAssetEntity(bookID uint, asset model.FixedAssetDTO) (model.FixedAssetEntity, model.TokyError) {
+	if strings.TrimSpace(asset.Name) == "" {
+		return model.FixedAssetEntity{}, createValidationError("Name of an asset must not be empty")
+	}
+	acquisitionDate, convErr := bookingutils.ParseDate(asset.AcquisitionDate)
+	if convErr != nil {
+		return model.FixedAssetEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not read acquisition date %s", asset.AcquisitionDate), convErr)
+	}
+	cost, convErr := bookingutils.StrToFloat(strings.TrimSpace(asset.AcquisitionCost))
+	if convErr != nil || cost <= 0 {
+		return model.FixedAssetEntity{}, createValidationError("AcquisitionCost must be a positive number")
+	}
+	residual, convErr := readOptionalAmmount(asset.ResidualValue)
+	if convErr != nil || residual < 0 || residual >= cost {
+		return model.FixedAssetEntity{}, createValidationError("ResidualValue must be a number between zero and the acquisition cost")
+	}
+	if asset.UsefulLifeYears < 1 {
+		return model.FixedAssetEntity{}, createValidationError("UsefulLifeYears must be at least one year")
+	}
+	assetEntity := model.FixedAssetEntity{
+		BookRealmEntityID: bookID,
+		Name:              strings.TrimSpace(asset.Name),
+		AcquisitionDate:   acquisitionDate.Format(bookingutils.DateLayout),
+		AcquisitionCost:   bookingutils.FormatFloatToAmmount(cost),
+		ResidualValue:     bookingutils.FormatFloatToAmmount(residual),
+		UsefulLifeYears:   asset.UsefulLifeYears,
+		Method:            asset.Method,
+	}
+	switch asset.Method {
+	case types.DepreciationMethodLinear:
+	case types.DepreciationMethodDecliningBalance:
+		// default rate is twice the linear rate
+		rate := math.Min(200/float64(asset.UsefulLifeYears), 100)
+		if strings.TrimSpace(asset.DecliningRate) != "" {
+			requestedRate, convErr := bookingutils.StrToFloat(strings.TrimSpace(asset.DecliningRate))
+			if convErr != nil || requestedRate <= 0 || requestedRate > 100 {
+				return model.FixedAssetEntity{}, createValidationError("DecliningRate must be a percentage between 0 and 100")
+			}
+			rate = requestedRate
+		}
+		assetEntity.DecliningRate = bookingutils.FormatFloatToAmmount(rate)
+	default:
+		return model.FixedAssetEntity{}, createValidationError("Method must be 'linear' or 'decliningBalance'")
+	}
+	return assetEntity, nil
+}
+
+func readOptionalAmmount(ammount string) (float64, error) {
+	if strings.TrimSpace(ammount) == "" {
+		return 0, nil
+	}
+	return bookingutils.StrToFloat(strings.TrimSpace(ammount))
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func Test_planDepreciation(t *testing.T) {
+	linear := model.FixedAssetEntity{AcquisitionDate: "2024-07-01", AcquisitionCost: "12000", UsefulLifeYears: 4,
+		Method: types.DepreciationMethodLinear}
+	declining := model.FixedAssetEntity{AcquisitionDate: "2024-01-15", AcquisitionCost: "10000", UsefulLifeYears: 5,
+		Method: types.DepreciationMethodDecliningBalance, DecliningRate: "40"}
+	// the depreciation booking of 2024 was corrected after the run
+	corrected := declining
+	corrected.Depreciations = []model.DepreciationEntity{{Year: 2024, Ammount: "4000.00", BookingEntity: model.BookingEntity{Model: gorm.Model{ID: 1}, Ammount: "3000.00"}}}
+	tests := []struct {
+		name             string
+		asset            model.FixedAssetEntity
+		year             int
+		wantOpening      float64
+		wantDepreciation float64
+	}{
+		{"linear before acquisition", linear, 2023, 0, 0},
+		{"linear acquisition year pro rata", linear, 2024, 12000, 1500},
+		{"linear full year", linear, 2025, 10500, 3000},
+		{"linear last year writes off the rest", linear, 2028, 1500, 1500},
+		{"linear after useful life", linear, 2029, 0, 0},
+		{"declining first year", declining, 2024, 10000, 4000},
+		{"declining second year", declining, 2025, 6000, 2400},
+		{"declining last year writes off the rest", declining, 2028, 1296, 1296},
+		{"declining after corrected posting", corrected, 2025, 7000, 2800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opening, depreciation, err := planDepreciation(tt.asset, tt.year)
+			if err != nil {
+				t.Errorf("planDepreciation() error = %v", err)
+				return
+			}
+			if opening != tt.wantOpening || depreciation != tt.wantDepreciation {
+				t.Errorf("planDepreciation() = %v, %v, want %v, %v", opening, depreciation, tt.wantOpening, tt.wantDepreciation)
+			}
+		})
+	}
+}
+
+func Test_assetServiceImpl_RunDepreciation(t *testing.T) {
+	repository := &mockAssetRepository{
+		accounts: []model.AccountTableEntity{
+			{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, AccountName: "Mobilien", Type: types.AccountTypeInventory,
+				Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryCapitalAsset},
+			{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, AccountName: "Abschreibungen", Type: types.AccountTypeIncome,
+				Category: types.AccountCategoryLoss},
+		},
+	}
+	s := CreateAssetService(repository)
+	asset := model.FixedAssetDTO{Name: "Zelt", AssetAccountID: "1", DepreciationAccountID: "2", AcquisitionDate: "2024-07-01",
+		AcquisitionCost: "12000", UsefulLifeYears: 4, Method: types.DepreciationMethodLinear}
+	if err := s.CreateFixedAsset("1", model.FixedAssetDTO{Name: "Zelt", AssetAccountID: "2", DepreciationAccountID: "2",
+		AcquisitionDate: "2024-07-01", AcquisitionCost: "12000", UsefulLifeYears: 4, Method: types.DepreciationMethodLinear}); err == nil {
+		t.Errorf("CreateFixedAsset() asset account must be a capital asset account")
+	}
+	if err := s.CreateFixedAsset("1", asset); err != nil {
+		t.Errorf("CreateFixedAsset() error = %v", err)
+		return
+	}
+
+	run, err := s.RunDepreciation("1", 2024)
+	if err != nil {
+		t.Errorf("RunDepreciation() error = %v", err)
+		return
+	}
+	if len(run.Postings) != 1 || run.Postings[0].Ammount != "1500.00" {
+		t.Errorf("RunDepreciation() got %+v", run)
+	}
+	booking := repository.bookings[0]
+	if booking.SollBookingAccountID != 2 || booking.HabenBookingAccountID != 1 || booking.Date != "2024-12-31" {
+		t.Errorf("RunDepreciation() booked %+v", booking)
+	}
+	if len(repository.events) != 1 || repository.events[0].Type != types.DomainEventBookingCreated {
+		t.Errorf("RunDepreciation() events = %v, want booking.created", repository.events)
+	}
+	run, _ = s.RunDepreciation("1", 2024)
+	if len(run.Postings) != 0 || len(repository.bookings) != 1 {
+		t.Errorf("RunDepreciation() second run of the same year must not post again, got %+v", run)
+	}
+	if _, err := s.RunDepreciation("1", 2026); !model.IsExistingValidationError(err) || len(repository.bookings) != 1 {
+		t.Errorf("RunDepreciation() skipping 2025 error = %v, want validation error without posting", err)
+	}
+
+	schedule, err := s.ReadAssetSchedule("1", "2024")
+	if err != nil {
+		t.Errorf("ReadAssetSchedule() error = %v", err)
+		return
+	}
+	entry := schedule.Entries[0]
+	if !entry.DepreciationBooked || entry.ClosingBookValue != "10500.00" || entry.AccumulatedDepreciation != "1500.00" {
+		t.Errorf("ReadAssetSchedule() got %+v", entry)
+	}
+}
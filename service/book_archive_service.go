@@ -0,0 +1,300 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type bookArchiveRepository interface {
+	FindBookRealmByID(bookID uint) (model.BookRealmEntity, model.TokyError)
+	FindAccountsByBookId(bookID uint) ([]model.AccountTableEntity, model.TokyError)
+	FindBookingsByBookId(bookID uint) ([]model.BookingEntity, model.TokyError)
+	FindInvoiceSettingsByBookId(bookID uint) (model.InvoiceSettingsEntity, model.TokyError)
+	FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError)
+	FindAllApplicationUsersByUserName(userName string) (model.ApplicationUserEntity, model.TokyError)
+	PersistImportedBookRealm(bookRealm *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity, settings *model.InvoiceSettingsEntity, invitations []model.BookInvitationEntity, events ...model.DomainEvent) model.TokyError
+}
+
+type bookArchiveServiceImpl struct {
+	archiveRepository bookArchiveRepository
+}
+
+func CreateBookArchiveService(repository bookArchiveRepository) *bookArchiveServiceImpl {
+	return &bookArchiveServiceImpl{
+		archiveRepository: repository,
+	}
+}
+
+// ExportBookRealm writes the book with its accounts, bookings, invoice settings and members into an archive
+func (s *bookArchiveServiceImpl) ExportBookRealm(bookID string) (model.BookArchiveDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.BookArchiveDTO{}, err
+	}
+	bookRealm, err := s.archiveRepository.FindBookRealmByID(bookIDUint)
+	if model.IsExisting(err) {
+		return model.BookArchiveDTO{}, err
+	}
+	accountEntities, err := s.archiveRepository.FindAccountsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return model.BookArchiveDTO{}, err
+	}
+	bookingEntities, err := s.archiveRepository.FindBookingsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return model.BookArchiveDTO{}, err
+	}
+	accountTables, err := buildAccountTables(groupBookingsByAccount(accountEntities, bookingEntities), allBookings, true)
+	if model.IsExisting(err) {
+		return model.BookArchiveDTO{}, err
+	}
+	accounts := make([]model.BookArchiveAccountDTO, 0, len(accountEntities))
+	for i, accountEntity := range accountEntities {
+		accounts = append(accounts, model.BookArchiveAccountDTO{
+			AccountOptionDTO: accountEntity.ToOptionDTO(),
+			Saldo:            accountTables[i].Saldo,
+			SaldierungColumn: accountTables[i].SaldierungColumn,
+		})
+	}
+	members := make([]model.BookMemberDTO, 0, len(bookRealm.RoleAssignments))
+	for _, roleAssignment := range bookRealm.RoleAssignments {
+		members = append(members, roleAssignment.ToBookMemberDTO())
+	}
+	archive := model.BookArchiveDTO{
+		Version:    model.BookArchiveVersion,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		BookName:   bookRealm.BookName,
+		Owner:      bookRealm.Owner.ToApplicationUserDTO(),
+		Members:    members,
+		Accounts:   accounts,
+		Bookings:   convertBookings(bookingEntities),
+	}
+	settings, err := s.archiveRepository.FindInvoiceSettingsByBookId(bookIDUint)
+	if model.IsExisting(err) && !model.IsExistingNotFoundError(err) {
+		return model.BookArchiveDTO{}, err
+	}
+	if !model.IsExisting(err) {
+		settingsDTO := settings.ToInvoiceSettingsDTO()
+		archive.InvoiceSettings = &settingsDTO
+	}
+	return archive, nil
+}
+
+// ImportBookRealm recreates an exported book with new ids, the importing user becomes its owner.
+// The balances of the archive have to match its bookings. Members found by id or user name are invited
+// with their role instead of being added, the previous owner is invited as admin.
+func (s *bookArchiveServiceImpl) ImportBookRealm(archive model.BookArchiveDTO, userID string) (model.BookImportReportDTO, model.TokyError) {
+	if archive.Version != model.BookArchiveVersion {
+		return model.BookImportReportDTO{}, createValidationError(fmt.Sprintf("Unsupported Archive Version %d", archive.Version))
+	}
+	if strings.TrimSpace(archive.BookName) == "" {
+		return model.BookImportReportDTO{}, createValidationError("Archive has no Book Name")
+	}
+	owner, err := s.archiveRepository.FindApplicationUserByID(userID)
+	if model.IsExisting(err) {
+		return model.BookImportReportDTO{}, err
+	}
+	accounts, accountIDs, err := readArchiveAccounts(archive.Accounts)
+	if model.IsExisting(err) {
+		return model.BookImportReportDTO{}, err
+	}
+	bookings, err := readArchiveBookings(archive.Bookings, accountIDs)
+	if model.IsExisting(err) {
+		return model.BookImportReportDTO{}, err
+	}
+	if err := validateArchiveBalances(archive.Accounts, accounts, bookings); model.IsExisting(err) {
+		return model.BookImportReportDTO{}, err
+	}
+	invitations, err := s.inviteArchiveMembers(archive, userID)
+	if model.IsExisting(err) {
+		return model.BookImportReportDTO{}, err
+	}
+	bookRealm := model.BookRealmEntity{
+		BookName: archive.BookName,
+		Owner:    owner,
+	}
+	var settings *model.InvoiceSettingsEntity
+	if archive.InvoiceSettings != nil {
+		if err := validateInvoiceSettings(*archive.InvoiceSettings); model.IsExisting(err) {
+			return model.BookImportReportDTO{}, err
+		}
+		settings = &model.InvoiceSettingsEntity{}
+		mergeInvoiceSettings(settings, *archive.InvoiceSettings)
+	}
+	err = s.archiveRepository.PersistImportedBookRealm(&bookRealm, accounts, bookings, settings, invitations,
+		bookCreatedEvents(&bookRealm, accounts, bookings)...)
+	if model.IsExisting(err) {
+		return model.BookImportReportDTO{}, err
+	}
+	return model.BookImportReportDTO{
+		BookID:   bookingutils.UintToString(bookRealm.ID),
+		Accounts: len(accounts),
+		Bookings: len(bookings),
+	}, nil
+}
+
+// readArchiveAccounts numbers the accounts of the archive, the returned map resolves the archive ids to these numbers
+func readArchiveAccounts(archiveAccounts []model.BookArchiveAccountDTO) ([]model.AccountTableEntity, map[string]uint, model.TokyError) {
+	accounts := make([]model.AccountTableEntity, 0, len(archiveAccounts))
+	accountIDs := make(map[string]uint, len(archiveAccounts))
+	for i, archiveAccount := range archiveAccounts {
+		if _, duplicate := accountIDs[archiveAccount.Id]; duplicate {
+			return nil, nil, createValidationError(fmt.Sprintf("Account Id %s is used more than once", archiveAccount.Id))
+		}
+		if valid, err := validateAccount(archiveAccount.AccountOptionDTO); !valid {
+			return nil, nil, err
+		}
+		accountIDs[archiveAccount.Id] = uint(i + 1)
+		accountEntity := model.AccountTableEntity{}
+		mergeAccount(&accountEntity, archiveAccount.AccountOptionDTO)
+		accountEntity.ID = uint(i + 1)
+		accounts = append(accounts, accountEntity)
+	}
+	return accounts, accountIDs, nil
+}
+
+func readArchiveBookings(archiveBookings []model.BookingDTO, accountIDs map[string]uint) ([]model.BookingEntity, model.TokyError) {
+	bookingIDs := make(map[string]uint, len(archiveBookings))
+	for i, archiveBooking := range archiveBookings {
+		if _, duplicate := bookingIDs[archiveBooking.BookingID]; duplicate {
+			return nil, createValidationError(fmt.Sprintf("Booking Id %s is used more than once", archiveBooking.BookingID))
+		}
+		bookingIDs[archiveBooking.BookingID] = uint(i + 1)
+	}
+	bookings := make([]model.BookingEntity, 0, len(archiveBookings))
+	for i, archiveBooking := range archiveBookings {
+		if err := validateBooking(archiveBooking); model.IsExisting(err) {
+			return nil, err
+		}
+		if _, parseErr := bookingutils.StrToFloat(archiveBooking.Ammount); parseErr != nil {
+			return nil, createValidationError(fmt.Sprintf("Ammount %s of Booking %s is not a number", archiveBooking.Ammount, archiveBooking.BookingID))
+		}
+		sollAccountID, sollFound := accountIDs[archiveBooking.SollAccount]
+		habenAccountID, habenFound := accountIDs[archiveBooking.HabenAccount]
+		if !sollFound || !habenFound {
+			return nil, createValidationError(fmt.Sprintf("Booking %s refers to an Account which is not part of the Archive", archiveBooking.BookingID))
+		}
+		bookingEntity := model.BookingEntity{
+			Date:                  archiveBooking.Date,
+			SollBookingAccountID:  sollAccountID,
+			HabenBookingAccountID: habenAccountID,
+			Ammount:               archiveBooking.Ammount,
+			Description:           archiveBooking.Description,
+			CostCenter:            archiveBooking.ReadCostCenterTrimmed(),
+			BookingType:           archiveBooking.BookingType,
+			ReversalDate:          archiveBooking.ReversalDate,
+		}
+		bookingEntity.ID = uint(i + 1)
+		if archiveBooking.ReversalBookingID != "" {
+			reversalBookingID, found := bookingIDs[archiveBooking.ReversalBookingID]
+			if !found {
+				return nil, createValidationError(fmt.Sprintf("Reversal of Booking %s is not part of the Archive", archiveBooking.BookingID))
+			}
+			bookingEntity.ReversalBookingID = reversalBookingID
+		}
+		bookings = append(bookings, bookingEntity)
+	}
+	return bookings, nil
+}
+
+// validateArchiveBalances recalculates the balance of every account from the bookings of the archive
+func validateArchiveBalances(archiveAccounts []model.BookArchiveAccountDTO, accounts []model.AccountTableEntity, bookings []model.BookingEntity) model.TokyError {
+	accountTables, err := buildAccountTables(groupBookingsByAccount(accounts, bookings), allBookings, true)
+	if model.IsExisting(err) {
+		return err
+	}
+	for i, accountTable := range accountTables {
+		if !isSameBalance(archiveAccounts[i], accountTable) {
+			return createValidationError(fmt.Sprintf("Balance of Account %s does not match the Bookings of the Archive", accountTable.AccountName))
+		}
+	}
+	return nil
+}
+
+func isSameBalance(archiveAccount model.BookArchiveAccountDTO, accountTable model.AccountTableDTO) bool {
+	archivedSaldo := 0.0
+	if strings.TrimSpace(archiveAccount.Saldo) != "" {
+		parsedSaldo, parseErr := bookingutils.StrToFloat(archiveAccount.Saldo)
+		if parseErr != nil {
+			return false
+		}
+		archivedSaldo = parsedSaldo
+	}
+	calculatedSaldo, parseErr := bookingutils.StrToFloat(accountTable.Saldo)
+	if parseErr != nil {
+		return false
+	}
+	if bookingutils.FormatFloatToAmmount(archivedSaldo) != bookingutils.FormatFloatToAmmount(calculatedSaldo) {
+		return false
+	}
+	return calculatedSaldo == 0 || archiveAccount.SaldierungColumn == accountTable.SaldierungColumn
+}
+
+// groupBookingsByAccount assigns every booking to the soll and haben side of its accounts
+func groupBookingsByAccount(accounts []model.AccountTableEntity, bookings []model.BookingEntity) []accountWithBookings {
+	accountIndex := make(map[uint]int, len(accounts))
+	grouped := make([]accountWithBookings, 0, len(accounts))
+	for i, account := range accounts {
+		accountIndex[account.ID] = i
+		grouped = append(grouped, accountWithBookings{account: account})
+	}
+	for _, booking := range bookings {
+		if i, found := accountIndex[booking.SollBookingAccountID]; found {
+			booking.HabenBookingAccount = accounts[accountIndex[booking.HabenBookingAccountID]]
+			grouped[i].sollBuchungen = append(grouped[i].sollBuchungen, booking)
+		}
+		if i, found := accountIndex[booking.HabenBookingAccountID]; found {
+			booking.SollBookingAccount = accounts[accountIndex[booking.SollBookingAccountID]]
+			grouped[i].habenBuchungen = append(grouped[i].habenBuchungen, booking)
+		}
+	}
+	return grouped
+}
+
+// inviteArchiveMembers creates an invitation for the email of every member of the archive which is found by id or
+// user name. Members have to accept before they get access, the import does not tell which members were not found.
+func (s *bookArchiveServiceImpl) inviteArchiveMembers(archive model.BookArchiveDTO, userID string) ([]model.BookInvitationEntity, model.TokyError) {
+	members := archive.Members
+	if archive.Owner.UserID != "" || archive.Owner.UserName != "" {
+		members = append([]model.BookMemberDTO{{User: archive.Owner, Role: types.BookRoleAdmin}}, members...)
+	}
+	invitations := make([]model.BookInvitationEntity, 0, len(members))
+	invited := map[string]bool{userID: true}
+	for _, member := range members {
+		if !isKnownRole(member.Role) {
+			return nil, createValidationError(fmt.Sprintf("Unknown Role %s", member.Role))
+		}
+		user, err := s.findArchiveUser(member.User)
+		if model.IsExisting(err) && !model.IsExistingNotFoundError(err) {
+			return nil, err
+		}
+		if model.IsExisting(err) || invited[user.ID] {
+			continue
+		}
+		invited[user.ID] = true
+		token, tokenErr := createInvitationToken()
+		if tokenErr != nil {
+			return nil, model.CreateTechnicalError("Could not create Invitation Token", tokenErr)
+		}
+		invitations = append(invitations, model.BookInvitationEntity{
+			Token:       token,
+			EMail:       user.EMail,
+			Role:        member.Role,
+			InvitedByID: userID,
+			ExpiresAt:   time.Now().Add(invitationValidity),
+		})
+	}
+	return invitations, nil
+}
+
+func (s *bookArchiveServiceImpl) findArchiveUser(archiveUser model.ApplicationUserDTO) (model.ApplicationUserEntity, model.TokyError) {
+	user, err := s.archiveRepository.FindApplicationUserByID(archiveUser.UserID)
+	if !model.IsExistingNotFoundError(err) || archiveUser.UserName == "" {
+		return user, err
+	}
+	return s.archiveRepository.FindAllApplicationUsersByUserName(archiveUser.UserName)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createBookArchiveRepository() *mockBookArchiveRepository {
+	owner := model.ApplicationUserEntity{ID: "owner", UserName: "owner", EMail: "owner@example.com"}
+	anna := model.ApplicationUserEntity{ID: "anna", UserName: "anna"}
+	return &mockBookArchiveRepository{
+		bookRealms: []model.BookRealmEntity{{
+			Model: gorm.Model{ID: 1}, BookName: "Verein", OwnerID: "owner", Owner: owner,
+			RoleAssignments: []model.BookRoleAssignmentEntity{
+				{ApplicationUserEntityID: "anna", ApplicationUserEntity: anna, Role: types.BookRoleBookkeeper},
+			},
+		}},
+		accounts: []model.AccountTableEntity{
+			{Model: gorm.Model{ID: 10}, AccountName: "Bank", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital, StartBalance: "100"},
+			{Model: gorm.Model{ID: 11}, AccountName: "Beiträge", Type: types.AccountTypeIncome, Category: types.AccountCategoryGain},
+			{Model: gorm.Model{ID: 12}, AccountName: "Transitorische Passiven", Type: types.AccountTypeInventory, Category: types.AccountCategoryPassive, SubCategory: types.AccountSubCategoryBorrowedCapital},
+		},
+		bookings: []model.BookingEntity{
+			{Model: gorm.Model{ID: 20}, Date: "2024-03-01", SollBookingAccountID: 10, HabenBookingAccountID: 11, Ammount: "50"},
+			{Model: gorm.Model{ID: 21}, Date: "2024-12-31", SollBookingAccountID: 11, HabenBookingAccountID: 12, Ammount: "20", BookingType: types.BookingTypeAccrual, ReversalBookingID: 22},
+			{Model: gorm.Model{ID: 22}, Date: "2025-01-01", SollBookingAccountID: 12, HabenBookingAccountID: 11, Ammount: "20", BookingType: types.BookingTypeReversal},
+		},
+		users: []model.ApplicationUserEntity{owner, {ID: "other-id", UserName: "anna", EMail: "anna@example.com"}, {ID: "importer", UserName: "importer"}},
+	}
+}
+
+func Test_bookArchiveServiceImpl_ExportAndImport(t *testing.T) {
+	repository := createBookArchiveRepository()
+	s := CreateBookArchiveService(repository)
+
+	archive, err := s.ExportBookRealm("1")
+	if model.IsExisting(err) {
+		t.Fatalf("ExportBookRealm() error = %v", err)
+	}
+	if archive.Version != model.BookArchiveVersion || len(archive.Accounts) != 3 || len(archive.Bookings) != 3 {
+		t.Fatalf("ExportBookRealm() = %+v", archive)
+	}
+	if archive.Accounts[0].Saldo != "150.00" || archive.Accounts[0].SaldierungColumn != types.SaldierungColumnHaben {
+		t.Errorf("balance of Bank = %s %s, want 150.00 haben", archive.Accounts[0].Saldo, archive.Accounts[0].SaldierungColumn)
+	}
+
+	report, err := s.ImportBookRealm(archive, "importer")
+	if model.IsExisting(err) {
+		t.Fatalf("ImportBookRealm() error = %v", err)
+	}
+	if report.BookID != "99" || report.Accounts != 3 || report.Bookings != 3 {
+		t.Errorf("ImportBookRealm() = %+v", report)
+	}
+	if events := repository.importedEvents; len(events) != 7 || events[0].Type != types.DomainEventBookCreated {
+		t.Errorf("import events = %d, want book.created followed by the created accounts and bookings", len(events))
+	}
+	if repository.importedBook.Owner.ID != "importer" || len(repository.importedBook.RoleAssignments) != 0 {
+		t.Errorf("imported book = %+v, want importer as only user", repository.importedBook)
+	}
+	invitations := repository.importedInvitations
+	if len(invitations) != 2 || invitations[0].EMail != "owner@example.com" || invitations[0].Role != types.BookRoleAdmin {
+		t.Fatalf("invitations = %+v, want previous owner invited as admin and anna", invitations)
+	}
+	if invitations[1].EMail != "anna@example.com" || invitations[1].Role != types.BookRoleBookkeeper || invitations[1].Token == "" {
+		t.Errorf("member anna was not invited by the email of the user found by user name")
+	}
+	accrual := repository.importedBookings[1]
+	if accrual.SollBookingAccountID != 2 || accrual.HabenBookingAccountID != 3 || accrual.ReversalBookingID != 3 {
+		t.Errorf("references of imported accrual = %+v, want remapped to archive positions", accrual)
+	}
+}
+
+func Test_bookArchiveServiceImpl_ImportRejectsInvalidArchives(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(archive *model.BookArchiveDTO)
+	}{
+		{"unsupported version", func(archive *model.BookArchiveDTO) { archive.Version = 2 }},
+		{"tampered balance", func(archive *model.BookArchiveDTO) { archive.Accounts[0].Saldo = "160.00" }},
+		{"missing booking", func(archive *model.BookArchiveDTO) { archive.Bookings = archive.Bookings[1:] }},
+		{"unknown account", func(archive *model.BookArchiveDTO) { archive.Bookings[0].SollAccount = "77" }},
+		{"unknown reversal", func(archive *model.BookArchiveDTO) { archive.Bookings[1].ReversalBookingID = "77" }},
+		{"duplicate account", func(archive *model.BookArchiveDTO) { archive.Accounts[1].Id = archive.Accounts[0].Id }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createBookArchiveRepository()
+			s := CreateBookArchiveService(repository)
+			archive, err := s.ExportBookRealm("1")
+			if model.IsExisting(err) {
+				t.Fatalf("ExportBookRealm() error = %v", err)
+			}
+			tt.modify(&archive)
+			if _, err := s.ImportBookRealm(archive, "importer"); !model.IsExisting(err) {
+				t.Errorf("ImportBookRealm() should fail")
+			}
+			if repository.importedBook.ID != 0 {
+				t.Errorf("invalid archive was imported")
+			}
+		})
+	}
+}
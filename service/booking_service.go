@@ -1,21 +1,31 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/toky03/toky-finance-accounting-service/bookingutils"
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type BookingRepository interface {
-	FindAllBookRealmsCorrespondingToUser(userId string) ([]model.BookRealmEntity, model.TokyError)
+	FindAllBookRealmsCorrespondingToUser(userId string, claimedBookIds []uint, includeArchived bool) ([]model.BookRealmEntity, model.TokyError)
+	FindAllBookRealms(includeArchived bool) ([]model.BookRealmEntity, model.TokyError)
 	FindApplicationUsersByID([]string) ([]model.ApplicationUserEntity, model.TokyError)
 	FindApplicationUserByID(string) (model.ApplicationUserEntity, model.TokyError)
 	FindBookRealmByID(bookingID uint) (bookRealm model.BookRealmEntity, err model.TokyError)
-	PersistBookRealm(model.BookRealmEntity) model.TokyError
-	DeleteBookRealmByID(bookingID uint) model.TokyError
-	UpdateBookRealm(*model.BookRealmEntity) model.TokyError
+	PersistBookRealm(bookRealm *model.BookRealmEntity, events ...model.DomainEvent) model.TokyError
+	DeleteBookRealmByID(bookingID uint, events ...model.DomainEvent) model.TokyError
+	UpdateBookRealmArchivedAt(bookID uint, archivedAt *time.Time, events ...model.DomainEvent) model.TokyError
+	UpdateBookRealm(bookRealm *model.BookRealmEntity, events ...model.DomainEvent) model.TokyError
+	FindAccountsByBookId(bookID uint) ([]model.AccountTableEntity, model.TokyError)
+	FindBookingsByBookId(bookID uint) ([]model.BookingEntity, model.TokyError)
+	FindInvoiceSettingsByBookId(bookID uint) (model.InvoiceSettingsEntity, model.TokyError)
+	PersistImportedBookRealm(bookRealm *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity, settings *model.InvoiceSettingsEntity, invitations []model.BookInvitationEntity, events ...model.DomainEvent) model.TokyError
 }
 type bookServiceImpl struct {
 	bookingRepository BookingRepository
@@ -37,8 +47,15 @@ func (r *bookServiceImpl) FindBookRealmById(bookID string) (bookRealmDto model.B
 	return
 }
 
-func (r *bookServiceImpl) FindBookRealmsPermittedForUser(userId string) (bookRealmDtos []model.BookRealmDTO, err model.TokyError) {
-	bookRealmEntities, err := r.bookingRepository.FindAllBookRealmsCorrespondingToUser(userId)
+// FindBookRealmsPermittedForUser lists the books the user has a role in and the books the mapped claim roles grant reading
+func (r *bookServiceImpl) FindBookRealmsPermittedForUser(userId string, claimRoles model.ClaimRolesDTO, includeArchived bool) (bookRealmDtos []model.BookRealmDTO, err model.TokyError) {
+	var bookRealmEntities []model.BookRealmEntity
+	allBooks, claimedBookIds := readableBookIDs(claimRoles)
+	if allBooks {
+		bookRealmEntities, err = r.bookingRepository.FindAllBookRealms(includeArchived)
+	} else {
+		bookRealmEntities, err = r.bookingRepository.FindAllBookRealmsCorrespondingToUser(userId, claimedBookIds, includeArchived)
+	}
 
 	bookRealmDtos = make([]model.BookRealmDTO, 0, len(bookRealmEntities))
 	for _, bookRealmEntity := range bookRealmEntities {
@@ -47,20 +64,295 @@ func (r *bookServiceImpl) FindBookRealmsPermittedForUser(userId string) (bookRea
 	return
 }
 
-func (r *bookServiceImpl) DeleteBookRealm(bookID string) (err model.TokyError) {
-	bookIdUint, convErr := readBookIDFromString(bookID)
-	if model.IsExisting(convErr) {
-		return convErr
+// ArchiveBookRealm makes the book read-only and hides it from the list of books
+func (r *bookServiceImpl) ArchiveBookRealm(bookID string) model.TokyError {
+	bookRealmEntity, err := r.readBook(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if bookRealmEntity.ArchivedAt != nil {
+		return model.CreateBusinessError("Buch ist bereits archiviert", errors.New("book is already archived"))
 	}
-	return r.bookingRepository.DeleteBookRealmByID(bookIdUint)
+	archivedAt := time.Now().UTC()
+	bookRealmEntity.ArchivedAt = &archivedAt
+	return r.bookingRepository.UpdateBookRealmArchivedAt(bookRealmEntity.ID, &archivedAt,
+		bookEvent(types.DomainEventBookArchived, &bookRealmEntity))
 }
 
-func (r *bookServiceImpl) CreateBookRealm(bookRealm model.BookRealmDTO, userId string) (err model.TokyError) {
-	writeUsers, err := r.readWriteUsersFromDTO(bookRealm.WriteAccess)
+// RestoreBookRealm makes an archived book writable again, only the owner may restore it
+func (r *bookServiceImpl) RestoreBookRealm(bookID, userID string) model.TokyError {
+	bookRealmEntity, err := r.readArchivedBookOfOwner(bookID, userID)
+	if model.IsExisting(err) {
+		return err
+	}
+	bookRealmEntity.ArchivedAt = nil
+	return r.bookingRepository.UpdateBookRealmArchivedAt(bookRealmEntity.ID, nil,
+		bookEvent(types.DomainEventBookRestored, &bookRealmEntity))
+}
+
+// PurgeBookRealm deletes an archived book with all accounts and bookings, only the owner may purge it
+func (r *bookServiceImpl) PurgeBookRealm(bookID, userID string) model.TokyError {
+	bookRealmEntity, err := r.readArchivedBookOfOwner(bookID, userID)
 	if model.IsExisting(err) {
 		return err
 	}
-	readUsers, err := r.readReadUsersFromDTO(bookRealm.ReadAccess)
+	return r.bookingRepository.DeleteBookRealmByID(bookRealmEntity.ID, bookPurgedEvent(&bookRealmEntity))
+}
+
+// CloneBookRealm creates a new book owned by the user with the chart of accounts of the book. With closingBalances
+// the closing balances become the start balances of the new book, with full all bookings and the invoice settings
+// are copied as well. The members keep their roles and the owner of the copied book becomes admin.
+// A result of the year is carried forward into the result account of the clone, without one it is rejected.
+func (r *bookServiceImpl) CloneBookRealm(bookID, userID string, clone model.BookCloneDTO) (model.BookRealmDTO, model.TokyError) {
+	if clone.Mode != types.CloneModeChartOfAccounts && clone.Mode != types.CloneModeClosingBalances && clone.Mode != types.CloneModeFull {
+		return model.BookRealmDTO{}, createValidationError(fmt.Sprintf("Unknown Clone Mode %s", clone.Mode))
+	}
+	source, err := r.readBook(bookID)
+	if model.IsExisting(err) {
+		return model.BookRealmDTO{}, err
+	}
+	owner, err := r.bookingRepository.FindApplicationUserByID(userID)
+	if model.IsExisting(err) {
+		return model.BookRealmDTO{}, err
+	}
+	accounts, err := r.bookingRepository.FindAccountsByBookId(source.ID)
+	if model.IsExisting(err) {
+		return model.BookRealmDTO{}, err
+	}
+	var bookings []model.BookingEntity
+	if clone.Mode != types.CloneModeChartOfAccounts {
+		bookings, err = r.bookingRepository.FindBookingsByBookId(source.ID)
+		if model.IsExisting(err) {
+			return model.BookRealmDTO{}, err
+		}
+	}
+	clonedAccounts, err := cloneAccounts(accounts, bookings, clone.Mode)
+	if model.IsExisting(err) {
+		return model.BookRealmDTO{}, err
+	}
+	if clone.Mode == types.CloneModeClosingBalances {
+		if err := carryResultForward(clonedAccounts, clone.ResultAccount); model.IsExisting(err) {
+			return model.BookRealmDTO{}, err
+		}
+	}
+	var clonedBookings []model.BookingEntity
+	var settings *model.InvoiceSettingsEntity
+	if clone.Mode == types.CloneModeFull {
+		clonedBookings = cloneBookings(bookings)
+		settings, err = r.readClonedInvoiceSettings(source.ID)
+		if model.IsExisting(err) {
+			return model.BookRealmDTO{}, err
+		}
+	}
+	bookName := strings.TrimSpace(clone.BookName)
+	if bookName == "" {
+		bookName = source.BookName
+	}
+	bookRealmEntity := model.BookRealmEntity{
+		BookName:        bookName,
+		Owner:           owner,
+		RoleAssignments: cloneRoleAssignments(source, userID),
+	}
+	events := cloneEvents(&source, &bookRealmEntity, clonedAccounts, clonedBookings, clone.Mode)
+	err = r.bookingRepository.PersistImportedBookRealm(&bookRealmEntity, clonedAccounts, clonedBookings, settings, nil, events...)
+	if model.IsExisting(err) {
+		return model.BookRealmDTO{}, err
+	}
+	return convertBookRealmEntityToDto(bookRealmEntity), nil
+}
+
+// cloneEvents describe the new book with its accounts and bookings and tell the source book about the clone.
+// Carrying the closing balances into a new book is how a year is closed.
+func cloneEvents(
+	source, clone *model.BookRealmEntity,
+	accounts []model.AccountTableEntity,
+	bookings []model.BookingEntity,
+	mode types.CloneMode,
+) []model.DomainEvent {
+	events := bookCreatedEvents(clone, accounts, bookings)
+	events = append(events, bookClonedEvent(types.DomainEventBookCloned, source, clone, mode))
+	if mode == types.CloneModeClosingBalances {
+		events = append(events, bookClonedEvent(types.DomainEventYearClosed, source, clone, mode))
+	}
+	return events
+}
+
+// cloneAccounts copies the accounts with their ids, which the repository replaces by new ids
+func cloneAccounts(accounts []model.AccountTableEntity, bookings []model.BookingEntity, mode types.CloneMode) ([]model.AccountTableEntity, model.TokyError) {
+	var accountTables []model.AccountTableDTO
+	if mode == types.CloneModeClosingBalances {
+		var err model.TokyError
+		accountTables, err = buildAccountTables(groupBookingsByAccount(accounts, bookings), allBookings, true)
+		if model.IsExisting(err) {
+			return nil, err
+		}
+	}
+	clonedAccounts := make([]model.AccountTableEntity, 0, len(accounts))
+	for i, account := range accounts {
+		clonedAccount := model.AccountTableEntity{
+			Category:    account.Category,
+			Description: account.Description,
+			AccountName: account.AccountName,
+			Type:        account.Type,
+			SubCategory: account.SubCategory,
+		}
+		clonedAccount.ID = account.ID
+		switch mode {
+		case types.CloneModeFull:
+			clonedAccount.StartBalance = account.StartBalance
+		case types.CloneModeClosingBalances:
+			clonedAccount.StartBalance = readClosingBalance(account, accountTables[i])
+		}
+		clonedAccounts = append(clonedAccounts, clonedAccount)
+	}
+	return clonedAccounts, nil
+}
+
+// readClosingBalance returns the balance of an inventory account on the side of its start balance
+func readClosingBalance(account model.AccountTableEntity, accountTable model.AccountTableDTO) string {
+	if account.Type != types.AccountTypeInventory || accountTable.SaldierungColumn == "" {
+		return ""
+	}
+	saldo, err := bookingutils.StrToFloat(accountTable.Saldo)
+	if err != nil || bookingutils.AlmostZero(saldo) {
+		return ""
+	}
+	startBalanceColumn := types.SaldierungColumnHaben
+	if account.Category == types.AccountCategoryActive {
+		startBalanceColumn = types.SaldierungColumnSoll
+	}
+	// the saldierung is written on the opposite side of the balance
+	if accountTable.SaldierungColumn == startBalanceColumn {
+		saldo = -saldo
+	}
+	return bookingutils.FormatFloatToAmmount(saldo)
+}
+
+// carryResultForward adds the result of the year to the start balance of the result account,
+// so that the active start balances equal the passive ones
+func carryResultForward(accounts []model.AccountTableEntity, resultAccountID string) model.TokyError {
+	var resultAccount *model.AccountTableEntity
+	result := 0.0
+	for i, account := range accounts {
+		if resultAccountID != "" && bookingutils.UintToString(account.ID) == resultAccountID {
+			resultAccount = &accounts[i]
+		}
+		if account.StartBalance == "" {
+			continue
+		}
+		startBalance, err := bookingutils.StrToFloat(account.StartBalance)
+		if err != nil {
+			return model.CreateTechnicalError(fmt.Sprintf("Start Balance of Account %s is not a number", account.AccountName), err)
+		}
+		if account.Category == types.AccountCategoryActive {
+			result += startBalance
+		} else {
+			result -= startBalance
+		}
+	}
+	if resultAccountID != "" && (resultAccount == nil || resultAccount.Type != types.AccountTypeInventory || resultAccount.Category != types.AccountCategoryPassive) {
+		return createValidationError(fmt.Sprintf("Result Account %s has to be a passive inventory account of the book", resultAccountID))
+	}
+	if bookingutils.AlmostZero(result) {
+		return nil
+	}
+	if resultAccount == nil {
+		return createValidationError(fmt.Sprintf("The result of %s needs a Result Account to be carried forward", bookingutils.FormatFloatToAmmount(result)))
+	}
+	startBalance := 0.0
+	if resultAccount.StartBalance != "" {
+		startBalance, _ = bookingutils.StrToFloat(resultAccount.StartBalance)
+	}
+	resultAccount.StartBalance = bookingutils.FormatFloatToAmmount(startBalance + result)
+	return nil
+}
+
+func cloneBookings(bookings []model.BookingEntity) []model.BookingEntity {
+	clonedBookings := make([]model.BookingEntity, 0, len(bookings))
+	for _, booking := range bookings {
+		clonedBooking := model.BookingEntity{
+			Date:                  booking.Date,
+			HabenBookingAccountID: booking.HabenBookingAccountID,
+			SollBookingAccountID:  booking.SollBookingAccountID,
+			Ammount:               booking.Ammount,
+			Description:           booking.Description,
+			CostCenter:            booking.CostCenter,
+			BookingType:           booking.BookingType,
+			ReversalDate:          booking.ReversalDate,
+			ReversalBookingID:     booking.ReversalBookingID,
+		}
+		clonedBooking.ID = booking.ID
+		clonedBookings = append(clonedBookings, clonedBooking)
+	}
+	return clonedBookings
+}
+
+func cloneRoleAssignments(source model.BookRealmEntity, userID string) []model.BookRoleAssignmentEntity {
+	roleAssignments := make([]model.BookRoleAssignmentEntity, 0, len(source.RoleAssignments)+1)
+	if source.OwnerID != userID {
+		roleAssignments = append(roleAssignments, model.BookRoleAssignmentEntity{
+			ApplicationUserEntityID: source.OwnerID,
+			ApplicationUserEntity:   source.Owner,
+			Role:                    types.BookRoleAdmin,
+		})
+	}
+	for _, roleAssignment := range source.RoleAssignments {
+		if roleAssignment.ApplicationUserEntityID == userID {
+			continue
+		}
+		roleAssignments = append(roleAssignments, model.BookRoleAssignmentEntity{
+			ApplicationUserEntityID: roleAssignment.ApplicationUserEntityID,
+			ApplicationUserEntity:   roleAssignment.ApplicationUserEntity,
+			Role:                    roleAssignment.Role,
+		})
+	}
+	return roleAssignments
+}
+
+func (r *bookServiceImpl) readClonedInvoiceSettings(bookID uint) (*model.InvoiceSettingsEntity, model.TokyError) {
+	settings, err := r.bookingRepository.FindInvoiceSettingsByBookId(bookID)
+	if model.IsExistingNotFoundError(err) {
+		return nil, nil
+	}
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	return &model.InvoiceSettingsEntity{
+		CreditorName:   settings.CreditorName,
+		Street:         settings.Street,
+		BuildingNumber: settings.BuildingNumber,
+		ZipCode:        settings.ZipCode,
+		City:           settings.City,
+		Country:        settings.Country,
+		IBAN:           settings.IBAN,
+		Currency:       settings.Currency,
+	}, nil
+}
+
+func (r *bookServiceImpl) readBook(bookID string) (model.BookRealmEntity, model.TokyError) {
+	bookIdUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.BookRealmEntity{}, err
+	}
+	return r.bookingRepository.FindBookRealmByID(bookIdUint)
+}
+
+func (r *bookServiceImpl) readArchivedBookOfOwner(bookID, userID string) (model.BookRealmEntity, model.TokyError) {
+	bookRealmEntity, err := r.readBook(bookID)
+	if model.IsExisting(err) {
+		return model.BookRealmEntity{}, err
+	}
+	if bookRealmEntity.OwnerID != userID {
+		return model.BookRealmEntity{}, model.CreateBusinessErrorNotFound("Nur der Besitzer kann ein archiviertes Buch wiederherstellen oder löschen", errors.New("user is not the owner"))
+	}
+	if bookRealmEntity.ArchivedAt == nil {
+		return model.BookRealmEntity{}, model.CreateBusinessError("Buch ist nicht archiviert", errors.New("book is not archived"))
+	}
+	return bookRealmEntity, nil
+}
+
+func (r *bookServiceImpl) CreateBookRealm(bookRealm model.BookRealmDTO, userId string) (err model.TokyError) {
+	roleAssignments, err := r.readRoleAssignmentsFromDTO(bookRealm.Members)
 	if model.IsExisting(err) {
 		return err
 	}
@@ -72,38 +364,52 @@ func (r *bookServiceImpl) CreateBookRealm(bookRealm model.BookRealmDTO, userId s
 	}
 	owner, err := r.bookingRepository.FindApplicationUserByID(ownerId)
 	bookRealmEntity := model.BookRealmEntity{
-		BookName:    bookRealm.BookName,
-		Owner:       owner,
-		WriteAccess: writeUsers,
-		ReadAccess:  readUsers,
+		BookName:        bookRealm.BookName,
+		Owner:           owner,
+		RoleAssignments: roleAssignments,
 	}
 
-	return r.bookingRepository.PersistBookRealm(bookRealmEntity)
+	return r.bookingRepository.PersistBookRealm(&bookRealmEntity, bookEvent(types.DomainEventBookCreated, &bookRealmEntity))
 
 }
 
-func (r *bookServiceImpl) readWriteUsersFromDTO(writeAccess []model.ApplicationUserDTO) ([]*model.WriteApplicationUserWrapper, model.TokyError) {
-	var writeUsers []*model.WriteApplicationUserWrapper
-	if len(writeAccess) > 0 {
-		writeUsersRaw, err := r.bookingRepository.FindApplicationUsersByID(extractUserIDs(writeAccess))
-		if model.IsExisting(err) {
-			return nil, err
+func (r *bookServiceImpl) readRoleAssignmentsFromDTO(members []model.BookMemberDTO) ([]model.BookRoleAssignmentEntity, model.TokyError) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+	userIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		if !isKnownRole(member.Role) {
+			return nil, createValidationError(fmt.Sprintf("Unknown Role %s", member.Role))
 		}
-		writeUsers = toSlicePointersWrite(writeUsersRaw)
+		userIDs = append(userIDs, member.User.UserID)
 	}
-	return writeUsers, nil
-}
-
-func (r *bookServiceImpl) readReadUsersFromDTO(readAccess []model.ApplicationUserDTO) ([]*model.ReadApplicationUserWrapper, model.TokyError) {
-	var readUsers []*model.ReadApplicationUserWrapper
-	if len(readAccess) > 0 {
-		readUsersRaw, err := r.bookingRepository.FindApplicationUsersByID(extractUserIDs(readAccess))
-		if model.IsExisting(err) {
-			return nil, err
+	users, err := r.bookingRepository.FindApplicationUsersByID(userIDs)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	usersByID := make(map[string]model.ApplicationUserEntity, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+	roleAssignments := make([]model.BookRoleAssignmentEntity, 0, len(members))
+	assigned := make(map[string]bool, len(members))
+	for _, member := range members {
+		user, found := usersByID[member.User.UserID]
+		if !found {
+			return nil, model.CreateBusinessErrorNotFound(fmt.Sprintf("No User with Id %s found", member.User.UserID), errors.New("unknown member"))
+		}
+		if assigned[user.ID] {
+			return nil, createValidationError(fmt.Sprintf("User %s has more than one Role", user.UserName))
 		}
-		readUsers = toSlicePointersRead(readUsersRaw)
+		assigned[user.ID] = true
+		roleAssignments = append(roleAssignments, model.BookRoleAssignmentEntity{
+			ApplicationUserEntityID: user.ID,
+			ApplicationUserEntity:   user,
+			Role:                    member.Role,
+		})
 	}
-	return readUsers, nil
+	return roleAssignments, nil
 }
 
 func readBookIDFromString(bookID string) (uint, model.TokyError) {
@@ -128,64 +434,34 @@ func (r *bookServiceImpl) UpdateBookRealm(bookRealm model.BookRealmDTO, bookID s
 		return err
 	}
 
-	return r.bookingRepository.UpdateBookRealm(&bookRealmEntity)
+	return r.bookingRepository.UpdateBookRealm(&bookRealmEntity, bookEvent(types.DomainEventBookUpdated, &bookRealmEntity))
 }
 
 func (r *bookServiceImpl) mergeRealm(bookRealmEntity *model.BookRealmEntity, bookRealmDTO model.BookRealmDTO) model.TokyError {
 	bookRealmEntity.BookName = bookRealmDTO.BookName
-	writeUsers, err := r.readWriteUsersFromDTO(bookRealmDTO.WriteAccess)
-	if model.IsExisting(err) {
-		return err
-	}
-	readUsers, err := r.readReadUsersFromDTO(bookRealmDTO.ReadAccess)
+	roleAssignments, err := r.readRoleAssignmentsFromDTO(bookRealmDTO.Members)
 	if model.IsExisting(err) {
 		return err
 	}
-	bookRealmEntity.WriteAccess = writeUsers
-	bookRealmEntity.ReadAccess = readUsers
+	bookRealmEntity.RoleAssignments = roleAssignments
 	return nil
 }
 
-func extractUserIDs(applicationUsers []model.ApplicationUserDTO) []string {
-	userIDs := make([]string, 0, len(applicationUsers))
-	for _, user := range applicationUsers {
-		userIDs = append(userIDs, user.UserID)
-	}
-	return userIDs
-}
-func toSlicePointersWrite(applicationUsers []model.ApplicationUserEntity) (pointerUsers []*model.WriteApplicationUserWrapper) {
-	for _, writeUser := range applicationUsers {
-		pointerUsers = append(pointerUsers, &model.WriteApplicationUserWrapper{
-			ApplicationUserEntity: writeUser})
-	}
-	return
-}
-func toSlicePointersRead(applicationUsers []model.ApplicationUserEntity) (pointerUsers []*model.ReadApplicationUserWrapper) {
-	for _, readUser := range applicationUsers {
-		pointerUsers = append(pointerUsers, &model.ReadApplicationUserWrapper{
-			ApplicationUserEntity: readUser})
-	}
-	return
-}
-
 func convertBookRealmEntityToDto(bookRealm model.BookRealmEntity) (bookRealmDTO model.BookRealmDTO) {
-	writeAccessUsers := make([]model.ApplicationUserDTO, 0, len(bookRealm.WriteAccess))
-	for _, writeAccessUser := range bookRealm.WriteAccess {
-		writeAccessUsers = append(writeAccessUsers, writeAccessUser.ApplicationUserEntity.ToApplicationUserDTO())
-	}
-
-	readAccessUsers := make([]model.ApplicationUserDTO, 0, len(bookRealm.ReadAccess))
-
-	for _, readAccessUser := range bookRealm.ReadAccess {
-		readAccessUsers = append(readAccessUsers, readAccessUser.ApplicationUserEntity.ToApplicationUserDTO())
+	members := make([]model.BookMemberDTO, 0, len(bookRealm.RoleAssignments))
+	for _, roleAssignment := range bookRealm.RoleAssignments {
+		members = append(members, roleAssignment.ToBookMemberDTO())
 	}
 
 	bookRealmDTO = model.BookRealmDTO{
-		BookID:      strconv.FormatUint(uint64(bookRealm.Model.ID), 10),
-		BookName:    bookRealm.BookName,
-		Owner:       bookRealm.Owner.ToApplicationUserDTO(),
-		WriteAccess: writeAccessUsers,
-		ReadAccess:  readAccessUsers,
+		BookID:         strconv.FormatUint(uint64(bookRealm.Model.ID), 10),
+		BookName:       bookRealm.BookName,
+		Owner:          bookRealm.Owner.ToApplicationUserDTO(),
+		PendingOwnerID: bookRealm.PendingOwnerID,
+		Members:        members,
+	}
+	if bookRealm.ArchivedAt != nil {
+		bookRealmDTO.ArchivedAt = bookRealm.ArchivedAt.Format(time.RFC3339)
 	}
 	return
 }
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createBookingRepository() *mockBookingRepository {
+	archivedAt := time.Now().Add(-time.Hour)
+	return &mockBookingRepository{
+		bookRealms: []model.BookRealmEntity{
+			{Model: gorm.Model{ID: 1}, BookName: "Verein", OwnerID: "owner"},
+			{Model: gorm.Model{ID: 2}, BookName: "Verein 2019", OwnerID: "owner", ArchivedAt: &archivedAt},
+		},
+	}
+}
+
+func Test_bookServiceImpl_FindBookRealmsPermittedForUser(t *testing.T) {
+	s := CreateBookService(createBookingRepository())
+
+	active, err := s.FindBookRealmsPermittedForUser("owner", model.ClaimRolesDTO{}, false)
+	if model.IsExisting(err) || len(active) != 1 || active[0].ArchivedAt != "" {
+		t.Errorf("FindBookRealmsPermittedForUser() = %v, %v, want only the active book", active, err)
+	}
+	all, err := s.FindBookRealmsPermittedForUser("owner", model.ClaimRolesDTO{}, true)
+	if model.IsExisting(err) || len(all) != 2 || all[1].ArchivedAt == "" {
+		t.Errorf("FindBookRealmsPermittedForUser() = %v, %v, want both books", all, err)
+	}
+}
+
+func Test_bookServiceImpl_FindBookRealmsPermittedByClaims(t *testing.T) {
+	tests := []struct {
+		name        string
+		claimRoles  model.ClaimRolesDTO
+		wantBookIDs []string
+	}{
+		{"without claim roles", model.ClaimRolesDTO{}, []string{}},
+		{"group role of a book", model.ClaimRolesDTO{BookRoles: map[string][]types.BookRole{"2": {types.BookRoleViewer}}}, []string{"2"}},
+		{"global role", model.ClaimRolesDTO{GlobalRoles: []types.BookRole{types.BookRoleViewer}}, []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := CreateBookService(createBookingRepository())
+			bookRealms, err := s.FindBookRealmsPermittedForUser("auditor", tt.claimRoles, true)
+			if model.IsExisting(err) {
+				t.Fatalf("FindBookRealmsPermittedForUser() error = %v", err)
+			}
+			bookIDs := make([]string, 0, len(bookRealms))
+			for _, bookRealm := range bookRealms {
+				bookIDs = append(bookIDs, bookRealm.BookID)
+			}
+			if !reflect.DeepEqual(bookIDs, tt.wantBookIDs) {
+				t.Errorf("FindBookRealmsPermittedForUser() = %v, want %v", bookIDs, tt.wantBookIDs)
+			}
+		})
+	}
+}
+
+func Test_bookServiceImpl_ArchiveAndRestore(t *testing.T) {
+	repository := createBookingRepository()
+	s := CreateBookService(repository)
+
+	if err := s.ArchiveBookRealm("1"); model.IsExisting(err) {
+		t.Fatalf("ArchiveBookRealm() error = %v", err)
+	}
+	if repository.bookRealms[0].ArchivedAt == nil {
+		t.Errorf("book was not archived")
+	}
+	if err := s.ArchiveBookRealm("1"); !model.IsExisting(err) {
+		t.Errorf("ArchiveBookRealm() of archived book should fail")
+	}
+	if err := s.RestoreBookRealm("1", "admin"); !model.IsExistingNotFoundError(err) {
+		t.Errorf("RestoreBookRealm() by other user than the owner should fail")
+	}
+	if err := s.RestoreBookRealm("1", "owner"); model.IsExisting(err) {
+		t.Fatalf("RestoreBookRealm() error = %v", err)
+	}
+	if repository.bookRealms[0].ArchivedAt != nil {
+		t.Errorf("book was not restored")
+	}
+}
+
+func Test_bookServiceImpl_PurgeBookRealm(t *testing.T) {
+	tests := []struct {
+		name       string
+		bookID     string
+		userID     string
+		wantErr    bool
+		wantPurged []uint
+	}{
+		{"owner purges archived book", "2", "owner", false, []uint{2}},
+		{"active book can not be purged", "1", "owner", true, nil},
+		{"only the owner may purge", "2", "admin", true, nil},
+		{"unknown book", "3", "owner", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createBookingRepository()
+			s := CreateBookService(repository)
+			err := s.PurgeBookRealm(tt.bookID, tt.userID)
+			if model.IsExisting(err) != tt.wantErr {
+				t.Errorf("PurgeBookRealm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(repository.purgedBookIDs) != len(tt.wantPurged) {
+				t.Errorf("purged books = %v, want %v", repository.purgedBookIDs, tt.wantPurged)
+			}
+		})
+	}
+}
+
+func Test_bookServiceImpl_UpdateBookRealmRejectsInvalidMembers(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []model.BookMemberDTO
+	}{
+		{"unknown role", []model.BookMemberDTO{{User: model.ApplicationUserDTO{UserID: "anna"}, Role: "superuser"}}},
+		{"unknown user", []model.BookMemberDTO{{User: model.ApplicationUserDTO{UserID: "nobody"}, Role: types.BookRoleViewer}}},
+		{"duplicate member", []model.BookMemberDTO{
+			{User: model.ApplicationUserDTO{UserID: "anna"}, Role: types.BookRoleViewer},
+			{User: model.ApplicationUserDTO{UserID: "anna"}, Role: types.BookRoleAdmin},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createBookingRepository()
+			repository.users = []model.ApplicationUserEntity{{ID: "anna"}}
+			existingMembers := []model.BookRoleAssignmentEntity{{ApplicationUserEntityID: "anna", Role: types.BookRoleBookkeeper}}
+			repository.bookRealms[0].RoleAssignments = existingMembers
+			s := CreateBookService(repository)
+
+			if err := s.UpdateBookRealm(model.BookRealmDTO{BookName: "Verein", Members: tt.members}, "1"); !model.IsExisting(err) {
+				t.Errorf("UpdateBookRealm() should reject the members")
+			}
+			if members := repository.bookRealms[0].RoleAssignments; len(members) != 1 || members[0] != existingMembers[0] {
+				t.Errorf("members = %+v, want the existing members", members)
+			}
+			if err := s.CreateBookRealm(model.BookRealmDTO{BookName: "Neu", Members: tt.members}, "owner"); !model.IsExisting(err) {
+				t.Errorf("CreateBookRealm() should reject the members")
+			}
+			if len(repository.bookRealms) != 2 {
+				t.Errorf("CreateBookRealm() persisted a book with invalid members")
+			}
+		})
+	}
+}
+
+func createCloneRepository() *mockBookingRepository {
+	owner := model.ApplicationUserEntity{ID: "owner"}
+	repository := createBookingRepository()
+	repository.bookRealms[0].Owner = owner
+	repository.bookRealms[0].RoleAssignments = []model.BookRoleAssignmentEntity{
+		{ApplicationUserEntityID: "anna", Role: types.BookRoleBookkeeper},
+		{ApplicationUserEntityID: "ben", Role: types.BookRoleAdmin},
+	}
+	repository.users = []model.ApplicationUserEntity{owner, {ID: "ben"}}
+	repository.accounts = []model.AccountTableEntity{
+		{Model: gorm.Model{ID: 10}, AccountName: "Bank", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital, StartBalance: "100"},
+		{Model: gorm.Model{ID: 11}, AccountName: "Beiträge", Type: types.AccountTypeIncome, Category: types.AccountCategoryGain},
+		{Model: gorm.Model{ID: 12}, AccountName: "Darlehen", Type: types.AccountTypeInventory, Category: types.AccountCategoryPassive, SubCategory: types.AccountSubCategoryBorrowedCapital, StartBalance: "100"},
+		{Model: gorm.Model{ID: 13}, AccountName: "Vereinsvermögen", Type: types.AccountTypeInventory, Category: types.AccountCategoryPassive, SubCategory: types.AccountSubCategoryEquity},
+	}
+	repository.bookings = []model.BookingEntity{
+		{Model: gorm.Model{ID: 20}, SollBookingAccountID: 10, HabenBookingAccountID: 11, Ammount: "50"},
+		{Model: gorm.Model{ID: 21}, SollBookingAccountID: 12, HabenBookingAccountID: 10, Ammount: "30"},
+	}
+	return repository
+}
+
+func Test_bookServiceImpl_CloneBookRealm(t *testing.T) {
+	tests := []struct {
+		name              string
+		mode              types.CloneMode
+		wantStartBalances []string
+		wantBookings      int
+		wantSettings      bool
+		wantEvents        int
+		wantLastEvent     types.DomainEventType
+	}{
+		{"chart of accounts", types.CloneModeChartOfAccounts, []string{"", "", "", ""}, 0, false, 6, types.DomainEventBookCloned},
+		{"closing balances", types.CloneModeClosingBalances, []string{"120.00", "", "70.00", "50.00"}, 0, false, 7, types.DomainEventYearClosed},
+		{"full copy", types.CloneModeFull, []string{"100", "", "100", ""}, 2, true, 8, types.DomainEventBookCloned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createCloneRepository()
+			s := CreateBookService(repository)
+			clone, err := s.CloneBookRealm("1", "ben", model.BookCloneDTO{BookName: "Verein 2025", Mode: tt.mode, ResultAccount: "13"})
+			if model.IsExisting(err) {
+				t.Fatalf("CloneBookRealm() error = %v", err)
+			}
+			if clone.BookID != "3" || clone.BookName != "Verein 2025" || clone.Owner.UserID != "ben" {
+				t.Errorf("CloneBookRealm() = %+v", clone)
+			}
+			if len(clone.Members) != 2 || clone.Members[0].Role != types.BookRoleAdmin || clone.Members[1].Role != types.BookRoleBookkeeper {
+				t.Errorf("members = %+v, want previous owner as admin and anna", clone.Members)
+			}
+			openingBalance := 0.0
+			for i, account := range repository.clonedAccounts {
+				if account.StartBalance != tt.wantStartBalances[i] {
+					t.Errorf("start balance of %s = %s, want %s", account.AccountName, account.StartBalance, tt.wantStartBalances[i])
+				}
+				startBalance, _ := bookingutils.StrToFloat(account.StartBalance)
+				if account.Category == types.AccountCategoryActive {
+					openingBalance += startBalance
+				} else {
+					openingBalance -= startBalance
+				}
+			}
+			if !bookingutils.AlmostZero(openingBalance) {
+				t.Errorf("active and passive start balances differ by %.2f", openingBalance)
+			}
+			if len(repository.clonedBookings) != tt.wantBookings || (repository.clonedSettings != nil) != tt.wantSettings {
+				t.Errorf("cloned %d bookings and settings %v", len(repository.clonedBookings), repository.clonedSettings)
+			}
+			events := repository.events
+			if len(events) != tt.wantEvents || events[len(events)-1].Type != tt.wantLastEvent {
+				t.Fatalf("wrote %d events, want %d ending with %s", len(events), tt.wantEvents, tt.wantLastEvent)
+			}
+			if bookID, _ := events[0].Read(); events[0].Type != types.DomainEventBookCreated || bookID != 3 {
+				t.Errorf("first event = %s of book %d, want book.created of the clone", events[0].Type, bookID)
+			}
+		})
+	}
+}
+
+func Test_bookServiceImpl_CloneBookRealmRequiresResultAccount(t *testing.T) {
+	tests := []struct {
+		name          string
+		resultAccount string
+	}{
+		{"missing result account", ""},
+		{"unknown result account", "99"},
+		{"active result account", "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createCloneRepository()
+			s := CreateBookService(repository)
+			clone := model.BookCloneDTO{Mode: types.CloneModeClosingBalances, ResultAccount: tt.resultAccount}
+			if _, err := s.CloneBookRealm("1", "ben", clone); !model.IsExistingValidationError(err) {
+				t.Errorf("CloneBookRealm() error = %v, want validation error", err)
+			}
+			if len(repository.bookRealms) != 2 {
+				t.Errorf("book was cloned without carrying the result forward")
+			}
+		})
+	}
+}
+
+func Test_bookServiceImpl_CloneBookRealmRejectsUnknownMode(t *testing.T) {
+	s := CreateBookService(createCloneRepository())
+	if _, err := s.CloneBookRealm("1", "ben", model.BookCloneDTO{Mode: "everything"}); !model.IsExisting(err) {
+		t.Errorf("CloneBookRealm() with unknown mode should fail")
+	}
+}
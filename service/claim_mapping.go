@@ -0,0 +1,161 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// ClaimMappingConfig maps roles and groups of the identity provider to book roles.
+// Client and realm roles apply to all books, groups to the listed books only.
+type ClaimMappingConfig struct {
+	ClientID    string                               `json:"clientId"`
+	ClientRoles map[string]types.BookRole            `json:"clientRoles"`
+	RealmRoles  map[string]types.BookRole            `json:"realmRoles"`
+	Groups      map[string]map[string]types.BookRole `json:"groups"`
+}
+
+type claimMapperImpl struct {
+	config ClaimMappingConfig
+}
+
+// CreateClaimMapper reads the mapping from the file in CLAIM_MAPPING_FILE.
+// Without a file no claims are mapped and only the roles stored in the books apply.
+func CreateClaimMapper() *claimMapperImpl {
+	mappingFile := os.Getenv("CLAIM_MAPPING_FILE")
+	if mappingFile == "" {
+		return &claimMapperImpl{}
+	}
+	file, err := os.Open(mappingFile)
+	if err != nil {
+		panic(fmt.Sprintf("Claim mapping %s could not be opened: %v", mappingFile, err))
+	}
+	defer file.Close()
+	config, err := ReadClaimMappingConfig(file)
+	if err != nil {
+		panic(fmt.Sprintf("Claim mapping %s is invalid: %v", mappingFile, err))
+	}
+	if config.ClientID == "" {
+		config.ClientID = os.Getenv("ID_PROVIDER_CLIENT_ID")
+	}
+	log.Printf("Loaded claim mapping from %s", mappingFile)
+	return CreateClaimMapperFromConfig(config)
+}
+
+func CreateClaimMapperFromConfig(config ClaimMappingConfig) *claimMapperImpl {
+	return &claimMapperImpl{config: config}
+}
+
+// ReadClaimMappingConfig decodes the mapping and rejects unknown roles
+func ReadClaimMappingConfig(reader io.Reader) (ClaimMappingConfig, error) {
+	var config ClaimMappingConfig
+	if err := json.NewDecoder(reader).Decode(&config); err != nil {
+		return ClaimMappingConfig{}, err
+	}
+	for claimRole, role := range config.ClientRoles {
+		if !isKnownRole(role) {
+			return ClaimMappingConfig{}, fmt.Errorf("unknown role %s for client role %s", role, claimRole)
+		}
+	}
+	for claimRole, role := range config.RealmRoles {
+		if !isKnownRole(role) {
+			return ClaimMappingConfig{}, fmt.Errorf("unknown role %s for realm role %s", role, claimRole)
+		}
+	}
+	for group, bookRoles := range config.Groups {
+		for bookID, role := range bookRoles {
+			if !isKnownRole(role) {
+				return ClaimMappingConfig{}, fmt.Errorf("unknown role %s for group %s in book %s", role, group, bookID)
+			}
+		}
+	}
+	return config, nil
+}
+
+// MapClaims reads the Keycloak claims resource_access, realm_access and groups
+func (m *claimMapperImpl) MapClaims(claims map[string]interface{}) model.ClaimRolesDTO {
+	claimRoles := model.ClaimRolesDTO{BookRoles: map[string][]types.BookRole{}}
+	if resourceAccess, ok := claims["resource_access"].(map[string]interface{}); ok {
+		for _, clientRole := range readRoles(resourceAccess[m.config.ClientID]) {
+			if role, ok := m.config.ClientRoles[clientRole]; ok {
+				claimRoles.GlobalRoles = append(claimRoles.GlobalRoles, role)
+			}
+		}
+	}
+	for _, realmRole := range readRoles(claims["realm_access"]) {
+		if role, ok := m.config.RealmRoles[realmRole]; ok {
+			claimRoles.GlobalRoles = append(claimRoles.GlobalRoles, role)
+		}
+	}
+	for _, group := range readStrings(claims["groups"]) {
+		for bookID, role := range m.config.Groups[group] {
+			claimRoles.BookRoles[bookID] = append(claimRoles.BookRoles[bookID], role)
+		}
+	}
+	return claimRoles
+}
+
+// GrantsPermission checks whether one of the mapped roles grants the permission in the book
+func (m *claimMapperImpl) GrantsPermission(claimRoles model.ClaimRolesDTO, bookID string, permission types.Permission) bool {
+	for _, role := range claimRoles.GlobalRoles {
+		if roleGrants(role, permission) {
+			return true
+		}
+	}
+	for _, role := range claimRoles.BookRoles[bookID] {
+		if roleGrants(role, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// readableBookIDs returns the books in which a mapped role grants reading, allBooks if a global role does
+func readableBookIDs(claimRoles model.ClaimRolesDTO) (allBooks bool, bookIDs []uint) {
+	for _, role := range claimRoles.GlobalRoles {
+		if roleGrants(role, types.PermissionRead) {
+			return true, nil
+		}
+	}
+	for bookID, roles := range claimRoles.BookRoles {
+		bookIDUint, err := bookingutils.StringToUint(bookID)
+		if err != nil {
+			continue
+		}
+		for _, role := range roles {
+			if roleGrants(role, types.PermissionRead) {
+				bookIDs = append(bookIDs, bookIDUint)
+				break
+			}
+		}
+	}
+	return false, bookIDs
+}
+
+func readRoles(access interface{}) []string {
+	accessMap, ok := access.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return readStrings(accessMap["roles"])
+}
+
+func readStrings(value interface{}) []string {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	stringValues := make([]string, 0, len(values))
+	for _, value := range values {
+		if stringValue, ok := value.(string); ok {
+			stringValues = append(stringValues, stringValue)
+		}
+	}
+	return stringValues
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func TestReadClaimMappingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"valid", `{"clientId":"toky","clientRoles":{"auditor":"viewer"},"realmRoles":{"finance":"admin"},"groups":{"/board":{"1":"bookkeeper"}}}`, false},
+		{"unknown client role", `{"clientRoles":{"auditor":"reader"}}`, true},
+		{"unknown group role", `{"groups":{"/board":{"1":"owner"}}}`, true},
+		{"malformed", `{"groups":`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadClaimMappingConfig(strings.NewReader(tt.config))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadClaimMappingConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_claimMapperImpl_MapClaims(t *testing.T) {
+	mapper := CreateClaimMapperFromConfig(ClaimMappingConfig{
+		ClientID:    "toky",
+		ClientRoles: map[string]types.BookRole{"auditor": types.BookRoleViewer},
+		RealmRoles:  map[string]types.BookRole{"finance": types.BookRoleAdmin},
+		Groups:      map[string]map[string]types.BookRole{"/board": {"1": types.BookRoleBookkeeper}},
+	})
+	claimRoles := mapper.MapClaims(map[string]interface{}{
+		"resource_access": map[string]interface{}{"toky": map[string]interface{}{"roles": []interface{}{"auditor", "unmapped"}}},
+		"groups":          []interface{}{"/board", "/other"},
+	})
+
+	if !mapper.GrantsPermission(claimRoles, "2", types.PermissionRead) {
+		t.Errorf("client role auditor must grant read on every book")
+	}
+	if mapper.GrantsPermission(claimRoles, "2", types.PermissionBook) {
+		t.Errorf("client role auditor must not grant book")
+	}
+	if !mapper.GrantsPermission(claimRoles, "1", types.PermissionBook) {
+		t.Errorf("group /board must grant book on book 1")
+	}
+
+	realmRoles := mapper.MapClaims(map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []interface{}{"finance"}},
+	})
+	if !mapper.GrantsPermission(realmRoles, "3", types.PermissionAdminister) {
+		t.Errorf("realm role finance must grant administer")
+	}
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// consolidatedAccount collects the balances of all accounts mapped onto one account of the group chart.
+// The balance is kept as soll minus haben so that accounts of different categories can be added up.
+type consolidatedAccount struct {
+	groupAccount string
+	accountType  types.AccountType
+	category     types.AccountCategory
+	subCategory  types.AccountSubCategory
+	balance      float64
+	sources      []model.ConsolidatedAccountSource
+}
+
+// ReadConsolidatedStatements aggregates the closing statements of several books on a common group chart.
+// Group accounts listed as elimination accounts, usually the clearing accounts of transfers between the books,
+// are left out of the statements. Their remaining balance is reported as elimination difference and is zero
+// if the transfers were booked on both sides.
+func (s *accountingServiceImpl) ReadConsolidatedStatements(
+	consolidation model.ConsolidationDTO,
+) (model.ConsolidatedStatementsDTO, model.TokyError) {
+	bookIDs := uniqueBookIDs(consolidation.BookIDs)
+	if len(bookIDs) == 0 {
+		return model.ConsolidatedStatementsDTO{}, createValidationError("At least one book is required for a consolidation")
+	}
+	mappings, err := readConsolidationMappings(consolidation.AccountMappings)
+	if model.IsExisting(err) {
+		return model.ConsolidatedStatementsDTO{}, err
+	}
+	groupAccounts := map[string]*consolidatedAccount{}
+	for _, bookID := range bookIDs {
+		accounts, err := s.readAccountsWithBookings(bookID)
+		if model.IsExisting(err) {
+			return model.ConsolidatedStatementsDTO{}, err
+		}
+		accountTables, err := buildAccountTables(accounts, allBookings, true)
+		if model.IsExisting(err) {
+			return model.ConsolidatedStatementsDTO{}, err
+		}
+		for _, accountTable := range accountTables {
+			if err := addToGroupAccount(groupAccounts, bookID, accountTable, mappings); model.IsExisting(err) {
+				return model.ConsolidatedStatementsDTO{}, err
+			}
+		}
+	}
+	return buildConsolidatedStatements(groupAccounts, consolidation.EliminationAccounts)
+}
+
+func uniqueBookIDs(bookIDs []string) []string {
+	unique := make([]string, 0, len(bookIDs))
+	seen := map[string]bool{}
+	for _, bookID := range bookIDs {
+		trimmed := strings.TrimSpace(bookID)
+		if trimmed == "" || seen[trimmed] {
+			continue
+		}
+		seen[trimmed] = true
+		unique = append(unique, trimmed)
+	}
+	return unique
+}
+
+func readConsolidationMappings(accountMappings []model.ConsolidationAccountMapping) (map[string]string, model.TokyError) {
+	mappings := make(map[string]string, len(accountMappings))
+	for _, mapping := range accountMappings {
+		groupAccount := strings.TrimSpace(mapping.GroupAccount)
+		if groupAccount == "" {
+			return nil, createValidationError(fmt.Sprintf("Group account for account %s of book %s is missing", mapping.AccountID, mapping.BookID))
+		}
+		mappings[consolidationMappingKey(mapping.BookID, mapping.AccountID)] = groupAccount
+	}
+	return mappings, nil
+}
+
+func consolidationMappingKey(bookID, accountID string) string {
+	return strings.TrimSpace(bookID) + "/" + strings.TrimSpace(accountID)
+}
+
+// readAccountNumber returns the leading number of the account name like 1020 of "1020 Bank".
+// Accounts without a number are matched by their name.
+func readAccountNumber(accountName string) string {
+	trimmed := strings.TrimSpace(accountName)
+	number := strings.SplitN(trimmed, " ", 2)[0]
+	if strings.IndexFunc(number, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+		return number
+	}
+	return trimmed
+}
+
+func addToGroupAccount(
+	groupAccounts map[string]*consolidatedAccount,
+	bookID string,
+	accountTable model.AccountTableDTO,
+	mappings map[string]string,
+) model.TokyError {
+	groupAccount, mapped := mappings[consolidationMappingKey(bookID, accountTable.AccountID)]
+	if !mapped {
+		groupAccount = readAccountNumber(accountTable.AccountName)
+	}
+	balance, err := readSollBalance(accountTable)
+	if model.IsExisting(err) {
+		return err
+	}
+	consolidated, exists := groupAccounts[groupAccount]
+	if !exists {
+		consolidated = &consolidatedAccount{
+			groupAccount: groupAccount,
+			accountType:  accountTable.Type,
+			category:     accountTable.Category,
+			subCategory:  accountTable.SubCategory,
+		}
+		groupAccounts[groupAccount] = consolidated
+	} else if consolidated.accountType != accountTable.Type {
+		return createValidationError(fmt.Sprintf(
+			"Account %s of book %s can not be mapped onto group account %s as it is of type %s and not %s",
+			accountTable.AccountName, bookID, groupAccount, accountTable.Type, consolidated.accountType,
+		))
+	}
+	consolidated.balance += balance
+	consolidated.sources = append(consolidated.sources, model.ConsolidatedAccountSource{
+		BookID:      bookID,
+		AccountID:   accountTable.AccountID,
+		AccountName: accountTable.AccountName,
+		Saldo:       formatCategoryBalance(accountTable.Category, balance),
+	})
+	return nil
+}
+
+// readSollBalance converts the saldo of an account table to soll minus haben.
+// The saldierung is written on the opposite side, a saldierung in haben means the soll side is bigger.
+func readSollBalance(accountTable model.AccountTableDTO) (float64, model.TokyError) {
+	saldo, err := bookingutils.StrToFloat(accountTable.Saldo)
+	if err != nil {
+		return 0, model.CreateTechnicalError(
+			fmt.Sprintf("Could not parse Saldo %v from Account Table %s", accountTable.Saldo, accountTable.AccountName),
+			err,
+		)
+	}
+	switch accountTable.SaldierungColumn {
+	case types.SaldierungColumnHaben:
+		return saldo, nil
+	case types.SaldierungColumnSoll:
+		return -saldo, nil
+	}
+	return 0, nil
+}
+
+// formatCategoryBalance shows the balance positive on the side where the category grows
+func formatCategoryBalance(category types.AccountCategory, balance float64) string {
+	if category == types.AccountCategoryPassive || category == types.AccountCategoryGain {
+		balance = -balance
+	}
+	return bookingutils.FormatFloatToAmmount(balance)
+}
+
+func buildConsolidatedStatements(
+	groupAccounts map[string]*consolidatedAccount,
+	eliminationAccounts []string,
+) (model.ConsolidatedStatementsDTO, model.TokyError) {
+	eliminated := map[string]bool{}
+	for _, groupAccount := range eliminationAccounts {
+		trimmed := strings.TrimSpace(groupAccount)
+		if _, exists := groupAccounts[trimmed]; !exists {
+			return model.ConsolidatedStatementsDTO{}, createValidationError(
+				fmt.Sprintf("Elimination account %s is not part of the group chart", trimmed),
+			)
+		}
+		eliminated[trimmed] = true
+	}
+	keys := make([]string, 0, len(groupAccounts))
+	for key := range groupAccounts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	accounts := make([]model.ConsolidatedAccountDTO, 0, len(keys))
+	eliminatedAccounts := make([]model.ConsolidatedAccountDTO, 0, len(eliminated))
+	accountTables := make([]model.AccountTableDTO, 0, len(keys))
+	eliminationDifference := 0.0
+	for _, key := range keys {
+		consolidated := groupAccounts[key]
+		account := model.ConsolidatedAccountDTO{
+			GroupAccount: consolidated.groupAccount,
+			Category:     consolidated.category,
+			Saldo:        formatCategoryBalance(consolidated.category, consolidated.balance),
+			Sources:      consolidated.sources,
+		}
+		if eliminated[key] {
+			eliminationDifference += consolidated.balance
+			eliminatedAccounts = append(eliminatedAccounts, account)
+			continue
+		}
+		accounts = append(accounts, account)
+		accountTables = append(accountTables, consolidated.toAccountTable())
+	}
+	closingStatements, err := calculateClosingStatements(accountTables)
+	if model.IsExisting(err) {
+		return model.ConsolidatedStatementsDTO{}, err
+	}
+	return model.ConsolidatedStatementsDTO{
+		ClosingSheetStatements: closingStatements,
+		Accounts:               accounts,
+		EliminatedAccounts:     eliminatedAccounts,
+		EliminationDifference:  bookingutils.FormatFloatToAmmount(eliminationDifference),
+	}, nil
+}
+
+// toAccountTable writes the balance back as saldo with the saldierung column expected by calculateClosingStatements
+func (a *consolidatedAccount) toAccountTable() model.AccountTableDTO {
+	accountTable := model.AccountTableDTO{
+		AccountName: a.groupAccount,
+		Type:        a.accountType,
+		Category:    a.category,
+		SubCategory: a.subCategory,
+		Saldo:       bookingutils.FormatFloatToAmmount(math.Abs(a.balance)),
+	}
+	if a.balance > 0 {
+		accountTable.SaldierungColumn = types.SaldierungColumnHaben
+	} else if a.balance < 0 {
+		accountTable.SaldierungColumn = types.SaldierungColumnSoll
+	}
+	return accountTable
+}
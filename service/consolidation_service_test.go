@@ -0,0 +1,159 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// mockConsolidationRepository returns only the accounts of the requested book
+type mockConsolidationRepository struct {
+	*mockAccountingRepository
+}
+
+func (mcr *mockConsolidationRepository) FindAccountsByBookId(bookID uint) ([]model.AccountTableEntity, model.TokyError) {
+	accounts, err := mcr.mockAccountingRepository.FindAccountsByBookId(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	bookAccounts := []model.AccountTableEntity{}
+	for _, account := range accounts {
+		if account.BookRealmEntityID == bookID {
+			bookAccounts = append(bookAccounts, account)
+		}
+	}
+	return bookAccounts, nil
+}
+
+func createConsolidationService() *accountingServiceImpl {
+	repository := CreateMockAccountingRepository()
+	repository.SetAccounts([]model.AccountTableEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, AccountName: "1000 Kasse", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+		{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, AccountName: "3000 Beiträge", Type: types.AccountTypeIncome, Category: types.AccountCategoryGain},
+		{Model: gorm.Model{ID: 3}, BookRealmEntityID: 1, AccountName: "1100 Forderung Sektion", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+		{Model: gorm.Model{ID: 11}, BookRealmEntityID: 2, AccountName: "1000 Bank", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+		{Model: gorm.Model{ID: 12}, BookRealmEntityID: 2, AccountName: "6000 Abgaben", Type: types.AccountTypeIncome, Category: types.AccountCategoryLoss},
+		{Model: gorm.Model{ID: 13}, BookRealmEntityID: 2, AccountName: "2100 Verbindlichkeit Dachverband", Type: types.AccountTypeInventory, Category: types.AccountCategoryPassive, SubCategory: types.AccountSubCategoryBorrowedCapital},
+		{Model: gorm.Model{ID: 14}, BookRealmEntityID: 2, AccountName: "3000 Spenden", Type: types.AccountTypeIncome, Category: types.AccountCategoryGain},
+	})
+	repository.SetBookings([]model.BookingEntity{
+		{SollBookingAccountID: 1, HabenBookingAccountID: 2, Ammount: "100"},
+		{SollBookingAccountID: 3, HabenBookingAccountID: 2, Ammount: "50"},
+		{SollBookingAccountID: 11, HabenBookingAccountID: 14, Ammount: "30"},
+		{SollBookingAccountID: 12, HabenBookingAccountID: 13, Ammount: "50"},
+	})
+	return CreateAccountingService(&mockConsolidationRepository{repository})
+}
+
+func readConsolidatedSaldos(accounts []model.ConsolidatedAccountDTO) map[string]string {
+	saldos := map[string]string{}
+	for _, account := range accounts {
+		saldos[account.GroupAccount] = account.Saldo
+	}
+	return saldos
+}
+
+func Test_accountingServiceImpl_ReadConsolidatedStatements_AccountNumber(t *testing.T) {
+	statements, err := createConsolidationService().ReadConsolidatedStatements(model.ConsolidationDTO{
+		BookIDs: []string{"1", "2", "1"},
+	})
+	if model.IsExisting(err) {
+		t.Fatalf("ReadConsolidatedStatements() error = %v", err)
+	}
+	saldos := readConsolidatedSaldos(statements.Accounts)
+	want := map[string]string{"1000": "130.00", "1100": "50.00", "2100": "50.00", "3000": "180.00", "6000": "50.00"}
+	for groupAccount, saldo := range want {
+		if saldos[groupAccount] != saldo {
+			t.Errorf("saldo of %s = %v, want %v", groupAccount, saldos[groupAccount], saldo)
+		}
+	}
+	if len(saldos) != len(want) {
+		t.Errorf("group accounts = %v, want %v", saldos, want)
+	}
+	if statements.BalanceSheet.BalanceSum != "180.00" {
+		t.Errorf("BalanceSheet.BalanceSum = %v, want 180.00", statements.BalanceSheet.BalanceSum)
+	}
+	if statements.IncomeStatement.BalanceSum != "180.00" {
+		t.Errorf("IncomeStatement.BalanceSum = %v, want 180.00", statements.IncomeStatement.BalanceSum)
+	}
+	if statements.EliminationDifference != "0.00" || len(statements.EliminatedAccounts) != 0 {
+		t.Errorf("expected no elimination, got %v %v", statements.EliminatedAccounts, statements.EliminationDifference)
+	}
+}
+
+func Test_accountingServiceImpl_ReadConsolidatedStatements_Elimination(t *testing.T) {
+	statements, err := createConsolidationService().ReadConsolidatedStatements(model.ConsolidationDTO{
+		BookIDs: []string{"1", "2"},
+		AccountMappings: []model.ConsolidationAccountMapping{
+			{BookID: "1", AccountID: "3", GroupAccount: "Verrechnung"},
+			{BookID: "2", AccountID: "13", GroupAccount: "Verrechnung"},
+		},
+		EliminationAccounts: []string{"Verrechnung"},
+	})
+	if model.IsExisting(err) {
+		t.Fatalf("ReadConsolidatedStatements() error = %v", err)
+	}
+	saldos := readConsolidatedSaldos(statements.Accounts)
+	if _, exists := saldos["Verrechnung"]; exists {
+		t.Errorf("eliminated account is part of the statements: %v", saldos)
+	}
+	if len(statements.EliminatedAccounts) != 1 || len(statements.EliminatedAccounts[0].Sources) != 2 {
+		t.Fatalf("EliminatedAccounts = %v, want one account with two sources", statements.EliminatedAccounts)
+	}
+	if statements.EliminationDifference != "0.00" {
+		t.Errorf("EliminationDifference = %v, want 0.00", statements.EliminationDifference)
+	}
+	if statements.BalanceSheet.BalanceSum != "130.00" {
+		t.Errorf("BalanceSheet.BalanceSum = %v, want 130.00", statements.BalanceSheet.BalanceSum)
+	}
+}
+
+func Test_accountingServiceImpl_ReadConsolidatedStatements_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		consolidation model.ConsolidationDTO
+		wantNotFound  bool
+	}{
+		{"no books", model.ConsolidationDTO{BookIDs: []string{" "}}, false},
+		{"unknown book", model.ConsolidationDTO{BookIDs: []string{"1", "99"}}, true},
+		{"missing group account", model.ConsolidationDTO{
+			BookIDs:         []string{"1"},
+			AccountMappings: []model.ConsolidationAccountMapping{{BookID: "1", AccountID: "1"}},
+		}, false},
+		{"mixed account types", model.ConsolidationDTO{
+			BookIDs:         []string{"1"},
+			AccountMappings: []model.ConsolidationAccountMapping{{BookID: "1", AccountID: "2", GroupAccount: "1000"}},
+		}, false},
+		{"unknown elimination account", model.ConsolidationDTO{
+			BookIDs:             []string{"1"},
+			EliminationAccounts: []string{"9999"},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createConsolidationService().ReadConsolidatedStatements(tt.consolidation)
+			if !model.IsExisting(err) {
+				t.Fatalf("ReadConsolidatedStatements() expected error")
+			}
+			if model.IsExistingNotFoundError(err) != tt.wantNotFound {
+				t.Errorf("ReadConsolidatedStatements() error = %v, wantNotFound %v", err, tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func Test_readAccountNumber(t *testing.T) {
+	tests := map[string]string{
+		"1020 Bank": "1020",
+		" 3000 ":    "3000",
+		"Kasse":     "Kasse",
+		"10a Konto": "10a Konto",
+	}
+	for accountName, want := range tests {
+		if got := readAccountNumber(accountName); got != want {
+			t.Errorf("readAccountNumber(%q) = %v, want %v", accountName, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// The events read the entities only when the repository writes them to the outbox,
+// therefore they describe the entities with the ids assigned by the change.
+
+func accountEvent(eventType types.DomainEventType, account *model.AccountTableEntity) model.DomainEvent {
+	return model.DomainEvent{Type: eventType, Read: func() (uint, interface{}) {
+		return account.BookRealmEntityID, account.ToOptionDTO()
+	}}
+}
+
+func bookingEvent(eventType types.DomainEventType, bookID uint, booking *model.BookingEntity) model.DomainEvent {
+	return model.DomainEvent{Type: eventType, Read: func() (uint, interface{}) {
+		return bookID, booking.ToBookingDTO()
+	}}
+}
+
+// bookingOfBookEvent describes a booking of a book which is created in the same transaction
+func bookingOfBookEvent(eventType types.DomainEventType, book *model.BookRealmEntity, booking *model.BookingEntity) model.DomainEvent {
+	return model.DomainEvent{Type: eventType, Read: func() (uint, interface{}) {
+		return book.ID, booking.ToBookingDTO()
+	}}
+}
+
+func bookEvent(eventType types.DomainEventType, book *model.BookRealmEntity) model.DomainEvent {
+	return model.DomainEvent{Type: eventType, Read: func() (uint, interface{}) {
+		return book.ID, convertBookRealmEntityToDto(*book)
+	}}
+}
+
+// bookPurgedEvent only carries the id, nothing else of the book is left after the purge
+func bookPurgedEvent(book *model.BookRealmEntity) model.DomainEvent {
+	return model.DomainEvent{Type: types.DomainEventBookPurged, Read: func() (uint, interface{}) {
+		return book.ID, model.BookRealmDTO{BookID: bookingutils.UintToString(book.ID)}
+	}}
+}
+
+// bookChangeEvent describes the books which the repository changes on its own, like on the deletion of their owner
+func bookChangeEvent(eventType types.DomainEventType, book *model.BookRealmEntity) model.DomainEvent {
+	if eventType == types.DomainEventBookPurged {
+		return bookPurgedEvent(book)
+	}
+	return bookEvent(eventType, book)
+}
+
+// bookCreatedEvents describe a new book which is created together with its accounts and bookings
+func bookCreatedEvents(book *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity) []model.DomainEvent {
+	events := make([]model.DomainEvent, 0, len(accounts)+len(bookings)+3)
+	events = append(events, bookEvent(types.DomainEventBookCreated, book))
+	for i := range accounts {
+		events = append(events, accountEvent(types.DomainEventAccountCreated, &accounts[i]))
+	}
+	for i := range bookings {
+		events = append(events, bookingOfBookEvent(types.DomainEventBookingCreated, book, &bookings[i]))
+	}
+	return events
+}
+
+// bookClonedEvent is written to the source book of a clone
+func bookClonedEvent(eventType types.DomainEventType, source, clone *model.BookRealmEntity, mode types.CloneMode) model.DomainEvent {
+	return model.DomainEvent{Type: eventType, Read: func() (uint, interface{}) {
+		return source.ID, model.BookClonedDTO{
+			BookID:    bookingutils.UintToString(source.ID),
+			NewBookID: bookingutils.UintToString(clone.ID),
+			Mode:      mode,
+		}
+	}}
+}
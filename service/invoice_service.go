@@ -0,0 +1,540 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/camt"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/qrbill"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+const defaultPaymentTermDays = 30
+
+// maxInvoiceLineDescription keeps a wrapped line description well within one page of the invoice
+const maxInvoiceLineDescription = 500
+
+type invoiceRepository interface {
+	FindInvoiceSettingsByBookId(uint) (model.InvoiceSettingsEntity, model.TokyError)
+	SaveInvoiceSettings(*model.InvoiceSettingsEntity) model.TokyError
+	FindInvoicesByBookId(uint) ([]model.InvoiceEntity, model.TokyError)
+	FindInvoiceByID(uint) (model.InvoiceEntity, model.TokyError)
+	FindInvoiceByReference(bookID uint, reference string) (model.InvoiceEntity, model.TokyError)
+	PersistInvoice(*model.InvoiceEntity, *model.BookingEntity, func(invoiceNumber uint) model.TokyError, ...model.DomainEvent) model.TokyError
+	FindBusinessPartnerByID(uint) (model.BusinessPartnerEntity, model.TokyError)
+	FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError)
+	PersistBooking(*model.BookingEntity, ...model.DomainEvent) model.TokyError
+}
+
+type paymentMatcher interface {
+	ReadOpenItems(bookID, partnerID string, onlyOpen bool) ([]model.OpenItemDTO, model.TokyError)
+	CreatePayment(bookID, openItemID string, payment model.OpenItemPaymentDTO) model.TokyError
+}
+
+type invoiceServiceImpl struct {
+	invoiceRepository invoiceRepository
+	paymentMatcher    paymentMatcher
+}
+
+func CreateInvoiceService(repository invoiceRepository, matcher paymentMatcher) *invoiceServiceImpl {
+	return &invoiceServiceImpl{
+		invoiceRepository: repository,
+		paymentMatcher:    matcher,
+	}
+}
+
+func (s *invoiceServiceImpl) ReadInvoiceSettings(bookID string) (model.InvoiceSettingsDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.InvoiceSettingsDTO{}, err
+	}
+	settings, err := s.invoiceRepository.FindInvoiceSettingsByBookId(bookIDUint)
+	if model.IsExistingNotFoundError(err) {
+		return model.InvoiceSettingsDTO{Currency: "CHF"}, nil
+	}
+	if model.IsExisting(err) {
+		return model.InvoiceSettingsDTO{}, err
+	}
+	return settings.ToInvoiceSettingsDTO(), nil
+}
+
+func (s *invoiceServiceImpl) UpdateInvoiceSettings(bookID string, settings model.InvoiceSettingsDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if err := validateInvoiceSettings(settings); model.IsExisting(err) {
+		return err
+	}
+	settingsEntity, err := s.invoiceRepository.FindInvoiceSettingsByBookId(bookIDUint)
+	if model.IsExisting(err) && !model.IsExistingNotFoundError(err) {
+		return err
+	}
+	settingsEntity.BookRealmEntityID = bookIDUint
+	mergeInvoiceSettings(&settingsEntity, settings)
+	return s.invoiceRepository.SaveInvoiceSettings(&settingsEntity)
+}
+
+func validateInvoiceSettings(settings model.InvoiceSettingsDTO) model.TokyError {
+	if !qrbill.IsValidIBAN(settings.IBAN) {
+		return createValidationError(fmt.Sprintf("IBAN %s is not valid", settings.IBAN))
+	}
+	if settings.Currency != "CHF" && settings.Currency != "EUR" {
+		return createValidationError("Currency must be CHF or EUR")
+	}
+	if strings.TrimSpace(settings.CreditorName) == "" || strings.TrimSpace(settings.City) == "" || len(strings.TrimSpace(settings.Country)) != 2 {
+		return createValidationError("Creditor needs a name, a city and a two letter country code")
+	}
+	return nil
+}
+
+func mergeInvoiceSettings(settingsEntity *model.InvoiceSettingsEntity, settings model.InvoiceSettingsDTO) {
+	settingsEntity.CreditorName = strings.TrimSpace(settings.CreditorName)
+	settingsEntity.Street = settings.Street
+	settingsEntity.BuildingNumber = settings.BuildingNumber
+	settingsEntity.ZipCode = settings.ZipCode
+	settingsEntity.City = strings.TrimSpace(settings.City)
+	settingsEntity.Country = strings.ToUpper(strings.TrimSpace(settings.Country))
+	settingsEntity.IBAN = qrbill.NormalizeReference(settings.IBAN)
+	settingsEntity.Currency = settings.Currency
+}
+
+func (s *invoiceServiceImpl) ReadInvoices(bookID string) ([]model.InvoiceDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	invoiceEntities, err := s.invoiceRepository.FindInvoicesByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	invoiceDTOs := make([]model.InvoiceDTO, 0, len(invoiceEntities))
+	for _, invoiceEntity := range invoiceEntities {
+		invoiceDTOs = append(invoiceDTOs, invoiceEntity.ToInvoiceDTO())
+	}
+	return invoiceDTOs, nil
+}
+
+func (s *invoiceServiceImpl) ReadInvoice(bookID, invoiceID string) (model.InvoiceDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.InvoiceDTO{}, err
+	}
+	invoice, err := s.readInvoice(bookIDUint, invoiceID)
+	if model.IsExisting(err) {
+		return model.InvoiceDTO{}, err
+	}
+	return invoice.ToInvoiceDTO(), nil
+}
+
+// CreateInvoice books the invoice total from the revenue account to the receivables account of the customer
+// and registers it as open item with a QR reference (QR-IBAN) or a creditor reference (regular IBAN)
+func (s *invoiceServiceImpl) CreateInvoice(bookID string, invoice model.InvoiceDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	settings, err := s.invoiceRepository.FindInvoiceSettingsByBookId(bookIDUint)
+	if model.IsExistingNotFoundError(err) {
+		return createValidationError("Invoice settings with IBAN and creditor address are required before creating invoices")
+	}
+	if model.IsExisting(err) {
+		return err
+	}
+	partner, err := s.readCustomer(bookIDUint, invoice.PartnerID)
+	if model.IsExisting(err) {
+		return err
+	}
+	revenueAccount, err := s.readRevenueAccount(bookIDUint, invoice.RevenueAccountID)
+	if model.IsExisting(err) {
+		return err
+	}
+	lines, total, err := createInvoiceLines(invoice.Lines)
+	if model.IsExisting(err) {
+		return err
+	}
+	date, dueDate, err := readInvoiceDates(invoice.Date, invoice.DueDate)
+	if model.IsExisting(err) {
+		return err
+	}
+	invoiceEntity := model.InvoiceEntity{
+		BookRealmEntityID:       bookIDUint,
+		BusinessPartnerEntityID: partner.ID,
+		BusinessPartnerEntity:   partner,
+		RevenueAccountID:        revenueAccount.ID,
+		Date:                    date,
+		DueDate:                 dueDate,
+		Currency:                settings.Currency,
+		Message:                 strings.TrimSpace(invoice.Message),
+		Ammount:                 bookingutils.FormatFloatToAmmount(total),
+		Lines:                   lines,
+	}
+	booking := model.BookingEntity{
+		Date:                  date,
+		SollBookingAccountID:  partner.AccountTableEntityID,
+		HabenBookingAccountID: revenueAccount.ID,
+		Ammount:               invoiceEntity.Ammount,
+	}
+	return s.invoiceRepository.PersistInvoice(&invoiceEntity, &booking, func(invoiceNumber uint) model.TokyError {
+		return numberInvoice(settings, &invoiceEntity, &booking, invoiceNumber)
+	}, bookingEvent(types.DomainEventBookingCreated, bookIDUint, &booking))
+}
+
+// numberInvoice completes the invoice and its booking with the number, which the repository assigns
+// within the transaction persisting the invoice
+func numberInvoice(settings model.InvoiceSettingsEntity, invoice *model.InvoiceEntity, booking *model.BookingEntity, invoiceNumber uint) model.TokyError {
+	referenceType, reference, convErr := createInvoiceReference(settings.IBAN, invoice.BookRealmEntityID, invoiceNumber)
+	if convErr != nil {
+		return model.CreateTechnicalError("Could not create payment reference", convErr)
+	}
+	invoice.InvoiceNumber = invoiceNumber
+	invoice.ReferenceType = referenceType
+	invoice.Reference = reference
+	if invoice.Message == "" {
+		invoice.Message = fmt.Sprintf("Rechnung %d", invoiceNumber)
+	}
+	if validationErr := createBill(settings, *invoice).Validate(); validationErr != nil {
+		return model.CreateBusinessValidationError("Invoice can not be paid with a QR-bill", validationErr)
+	}
+	booking.Description = fmt.Sprintf("%s %s", invoice.Message, invoice.BusinessPartnerEntity.Name)
+	return nil
+}
+
+// RenderInvoicePDF writes the invoice including the QR-bill payment part as PDF
+func (s *invoiceServiceImpl) RenderInvoicePDF(bookID, invoiceID string, w io.Writer) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	invoice, err := s.readInvoice(bookIDUint, invoiceID)
+	if model.IsExisting(err) {
+		return err
+	}
+	settings, err := s.invoiceRepository.FindInvoiceSettingsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return err
+	}
+	lines := make([]qrbill.InvoiceLine, 0, len(invoice.Lines))
+	for _, line := range invoice.Lines {
+		lines = append(lines, qrbill.InvoiceLine{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			Ammount:     line.Ammount,
+		})
+	}
+	renderErr := qrbill.RenderInvoice(qrbill.Invoice{
+		Title:   fmt.Sprintf("Rechnung %d", invoice.InvoiceNumber),
+		Date:    invoice.Date,
+		DueDate: invoice.DueDate,
+		Lines:   lines,
+		Ammount: invoice.Ammount,
+		Bill:    createBill(settings, invoice),
+	}, w)
+	if renderErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not render Invoice %s", invoiceID), renderErr)
+	}
+	return nil
+}
+
+// MatchPaymentByReference matches a payment booking with the open item of the invoice carrying the given reference.
+// It is the entry point for payments read from bank statements which contain a QR or creditor reference.
+func (s *invoiceServiceImpl) MatchPaymentByReference(bookID string, payment model.InvoicePaymentDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	reference := qrbill.NormalizeReference(payment.Reference)
+	if !qrbill.IsValidQRReference(reference) && !qrbill.IsValidCreditorReference(reference) {
+		return createValidationError(fmt.Sprintf("%s is neither a valid QR reference nor a valid creditor reference", payment.Reference))
+	}
+	invoice, err := s.invoiceRepository.FindInvoiceByReference(bookIDUint, reference)
+	if model.IsExisting(err) {
+		return err
+	}
+	return s.paymentMatcher.CreatePayment(bookID, bookingutils.UintToString(invoice.OpenItemEntityID), model.OpenItemPaymentDTO{
+		BookingID: payment.BookingID,
+		Ammount:   payment.Ammount,
+	})
+}
+
+// ImportPayments books the credited payments of a camt.053 statement or camt.054 notification carrying a reference
+// from the receivables account of the customer onto the bank account and matches them with the invoice of the reference.
+// Payments which do not fit an open invoice are reported as unmatched and left to be booked manually.
+func (s *invoiceServiceImpl) ImportPayments(bookID, bankAccountID string, statement io.Reader) (model.PaymentImportReportDTO, model.TokyError) {
+	report := model.PaymentImportReportDTO{Matched: []model.ImportedPaymentDTO{}, Unmatched: []model.ImportedPaymentDTO{}}
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return report, err
+	}
+	bankAccount, err := s.readBankAccount(bookIDUint, bankAccountID)
+	if model.IsExisting(err) {
+		return report, err
+	}
+	payments, readErr := camt.ReadPayments(statement)
+	if readErr != nil {
+		return report, model.CreateBusinessValidationError("Could not read camt statement", readErr)
+	}
+	for _, payment := range payments {
+		importedPayment := model.ImportedPaymentDTO{
+			Reference: payment.Reference,
+			Date:      payment.BookingDate,
+			Ammount:   payment.Ammount,
+			Currency:  payment.Currency,
+		}
+		err := s.importPayment(bookIDUint, bankAccount, payment, &importedPayment)
+		if model.IsExisting(err) && err.IsTechnicalError() {
+			return report, err
+		}
+		if model.IsExisting(err) {
+			importedPayment.Message = err.ErrorMessage()
+			report.Unmatched = append(report.Unmatched, importedPayment)
+			continue
+		}
+		report.Matched = append(report.Matched, importedPayment)
+	}
+	return report, nil
+}
+
+// importPayment only books a payment which does not exceed the open amount of the invoice of its reference
+func (s *invoiceServiceImpl) importPayment(bookID uint, bankAccount model.AccountTableEntity, payment camt.Payment, importedPayment *model.ImportedPaymentDTO) model.TokyError {
+	reference := qrbill.NormalizeReference(payment.Reference)
+	if !qrbill.IsValidQRReference(reference) && !qrbill.IsValidCreditorReference(reference) {
+		return createValidationError(fmt.Sprintf("%s is neither a valid QR reference nor a valid creditor reference", payment.Reference))
+	}
+	date, convErr := bookingutils.ParseDate(payment.BookingDate)
+	ammount, ammountErr := bookingutils.StrToFloat(payment.Ammount)
+	if convErr != nil || ammountErr != nil || ammount <= 0 {
+		return createValidationError("Payment needs a booking date and a positive amount")
+	}
+	invoice, err := s.invoiceRepository.FindInvoiceByReference(bookID, reference)
+	if model.IsExisting(err) {
+		return err
+	}
+	importedPayment.InvoiceID = bookingutils.UintToString(invoice.ID)
+	if payment.Currency != invoice.Currency {
+		return createValidationError(fmt.Sprintf("Payment in %s does not match the currency %s of the invoice", payment.Currency, invoice.Currency))
+	}
+	partner, err := s.invoiceRepository.FindBusinessPartnerByID(invoice.BusinessPartnerEntityID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if err := s.checkOpenAmmount(bookID, invoice, ammount); model.IsExisting(err) {
+		return err
+	}
+	receivablesAccount, err := s.invoiceRepository.FindAccountByID(partner.AccountTableEntityID)
+	if model.IsExisting(err) {
+		return err
+	}
+	booking := model.BookingEntity{
+		Date:                date.Format(bookingutils.DateLayout),
+		SollBookingAccount:  bankAccount,
+		HabenBookingAccount: receivablesAccount,
+		Ammount:             bookingutils.FormatFloatToAmmount(ammount),
+		Description:         fmt.Sprintf("Zahlung Rechnung %d %s", invoice.InvoiceNumber, partner.Name),
+	}
+	if err := s.invoiceRepository.PersistBooking(&booking, bookingEvent(types.DomainEventBookingCreated, bookID, &booking)); model.IsExisting(err) {
+		return err
+	}
+	importedPayment.BookingID = bookingutils.UintToString(booking.ID)
+	return s.MatchPaymentByReference(bookingutils.UintToString(bookID), model.InvoicePaymentDTO{
+		Reference: reference,
+		BookingID: importedPayment.BookingID,
+		Ammount:   booking.Ammount,
+	})
+}
+
+// checkOpenAmmount refuses payments of paid invoices, so importing a statement twice does not book its payments twice
+func (s *invoiceServiceImpl) checkOpenAmmount(bookID uint, invoice model.InvoiceEntity, ammount float64) model.TokyError {
+	openItems, err := s.paymentMatcher.ReadOpenItems(bookingutils.UintToString(bookID), bookingutils.UintToString(invoice.BusinessPartnerEntityID), true)
+	if model.IsExisting(err) {
+		return err
+	}
+	for _, openItem := range openItems {
+		if openItem.OpenItemID != bookingutils.UintToString(invoice.OpenItemEntityID) {
+			continue
+		}
+		openAmmount, convErr := bookingutils.StrToFloat(openItem.OpenAmmount)
+		if convErr != nil {
+			return model.CreateTechnicalError(fmt.Sprintf("Could not read open amount of invoice %d", invoice.InvoiceNumber), convErr)
+		}
+		if ammount > openAmmount+0.005 {
+			return createValidationError(fmt.Sprintf("Payment exceeds the open amount of %s", openItem.OpenAmmount))
+		}
+		return nil
+	}
+	return createValidationError(fmt.Sprintf("Invoice %d is already paid", invoice.InvoiceNumber))
+}
+
+func (s *invoiceServiceImpl) readBankAccount(bookID uint, accountID string) (model.AccountTableEntity, model.TokyError) {
+	accountIDUint, convErr := bookingutils.StringToUint(accountID)
+	if convErr != nil {
+		return model.AccountTableEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Account Id %s as AccountId", accountID), convErr)
+	}
+	account, err := s.invoiceRepository.FindAccountByID(accountIDUint)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	if account.BookRealmEntityID != bookID {
+		return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Account with Id %s found in Book", accountID), errors.New("account belongs to another book"))
+	}
+	if account.Category != types.AccountCategoryActive {
+		return model.AccountTableEntity{}, createValidationError("Bank account of imported payments must be an active account")
+	}
+	return account, nil
+}
+
+func (s *invoiceServiceImpl) readInvoice(bookID uint, invoiceID string) (model.InvoiceEntity, model.TokyError) {
+	invoiceIDUint, convErr := bookingutils.StringToUint(invoiceID)
+	if convErr != nil {
+		return model.InvoiceEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Invoice Id %s", invoiceID), convErr)
+	}
+	invoice, err := s.invoiceRepository.FindInvoiceByID(invoiceIDUint)
+	if model.IsExisting(err) {
+		return model.InvoiceEntity{}, err
+	}
+	if invoice.BookRealmEntityID != bookID {
+		return model.InvoiceEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Invoice with Id %s found in Book", invoiceID), errors.New("invoice belongs to another book"))
+	}
+	return invoice, nil
+}
+
+func (s *invoiceServiceImpl) readCustomer(bookID uint, partnerID string) (model.BusinessPartnerEntity, model.TokyError) {
+	partnerIDUint, convErr := bookingutils.StringToUint(partnerID)
+	if convErr != nil {
+		return model.BusinessPartnerEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Partner Id %s", partnerID), convErr)
+	}
+	partner, err := s.invoiceRepository.FindBusinessPartnerByID(partnerIDUint)
+	if model.IsExisting(err) {
+		return model.BusinessPartnerEntity{}, err
+	}
+	if partner.BookRealmEntityID != bookID {
+		return model.BusinessPartnerEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Business Partner with Id %s found in Book", partnerID), errors.New("partner belongs to another book"))
+	}
+	if partner.PartnerType != types.PartnerTypeCustomer {
+		return model.BusinessPartnerEntity{}, createValidationError("Invoices can only be created for customers")
+	}
+	return partner, nil
+}
+
+func (s *invoiceServiceImpl) readRevenueAccount(bookID uint, accountID string) (model.AccountTableEntity, model.TokyError) {
+	accountIDUint, convErr := bookingutils.StringToUint(accountID)
+	if convErr != nil {
+		return model.AccountTableEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Account Id %s as AccountId", accountID), convErr)
+	}
+	account, err := s.invoiceRepository.FindAccountByID(accountIDUint)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	if account.BookRealmEntityID != bookID {
+		return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Account with Id %s found in Book", accountID), errors.New("account belongs to another book"))
+	}
+	if account.Category != types.AccountCategoryGain {
+		return model.AccountTableEntity{}, createValidationError("Revenue account of an invoice must be a gain account")
+	}
+	return account, nil
+}
+
+func createInvoiceLines(lineDTOs []model.InvoiceLineDTO) ([]model.InvoiceLineEntity, float64, model.TokyError) {
+	if len(lineDTOs) == 0 {
+		return nil, 0, createValidationError("Invoice needs at least one line")
+	}
+	lines := make([]model.InvoiceLineEntity, 0, len(lineDTOs))
+	total := 0.0
+	for i, lineDTO := range lineDTOs {
+		if strings.TrimSpace(lineDTO.Description) == "" {
+			return nil, 0, createValidationError(fmt.Sprintf("Description of line %d must not be empty", i+1))
+		}
+		if len([]rune(strings.TrimSpace(lineDTO.Description))) > maxInvoiceLineDescription {
+			return nil, 0, createValidationError(fmt.Sprintf("Description of line %d must not be longer than %d characters", i+1, maxInvoiceLineDescription))
+		}
+		quantity, quantityErr := bookingutils.StrToFloat(strings.TrimSpace(lineDTO.Quantity))
+		unitPrice, priceErr := bookingutils.StrToFloat(strings.TrimSpace(lineDTO.UnitPrice))
+		if quantityErr != nil || priceErr != nil || quantity <= 0 || unitPrice < 0 {
+			return nil, 0, createValidationError(fmt.Sprintf("Quantity and UnitPrice of line %d must be positive numbers", i+1))
+		}
+		ammount := quantity * unitPrice
+		total += ammount
+		lines = append(lines, model.InvoiceLineEntity{
+			Position:    i + 1,
+			Description: strings.TrimSpace(lineDTO.Description),
+			Quantity:    strings.TrimSpace(lineDTO.Quantity),
+			UnitPrice:   bookingutils.FormatFloatToAmmount(unitPrice),
+			Ammount:     bookingutils.FormatFloatToAmmount(ammount),
+		})
+	}
+	if bookingutils.AlmostZero(total) {
+		return nil, 0, createValidationError("Total of an invoice must be greater than zero")
+	}
+	return lines, total, nil
+}
+
+// readInvoiceDates defaults the invoice date to today and the due date to the end of the payment term
+func readInvoiceDates(date, dueDate string) (string, string, model.TokyError) {
+	invoiceDate := time.Now().UTC()
+	if strings.TrimSpace(date) != "" {
+		parsedDate, convErr := bookingutils.ParseDate(date)
+		if convErr != nil {
+			return "", "", model.CreateBusinessValidationError(fmt.Sprintf("Could not read invoice date %s", date), convErr)
+		}
+		invoiceDate = parsedDate
+	}
+	invoiceDueDate := invoiceDate.AddDate(0, 0, defaultPaymentTermDays)
+	if strings.TrimSpace(dueDate) != "" {
+		parsedDueDate, convErr := bookingutils.ParseDate(dueDate)
+		if convErr != nil {
+			return "", "", model.CreateBusinessValidationError(fmt.Sprintf("Could not read due date %s", dueDate), convErr)
+		}
+		if parsedDueDate.Before(invoiceDate) {
+			return "", "", createValidationError("Due date must not be before the invoice date")
+		}
+		invoiceDueDate = parsedDueDate
+	}
+	return invoiceDate.Format(bookingutils.DateLayout), invoiceDueDate.Format(bookingutils.DateLayout), nil
+}
+
+// createInvoiceReference derives the payment reference from book and invoice number
+func createInvoiceReference(iban string, bookID, invoiceNumber uint) (string, string, error) {
+	if qrbill.IsQRIBAN(iban) {
+		reference, err := qrbill.QRReference(fmt.Sprintf("%08d%010d", bookID, invoiceNumber))
+		return qrbill.ReferenceTypeQR, reference, err
+	}
+	reference, err := qrbill.CreditorReference(fmt.Sprintf("%d%08d", bookID, invoiceNumber))
+	return qrbill.ReferenceTypeCreditor, reference, err
+}
+
+func createBill(settings model.InvoiceSettingsEntity, invoice model.InvoiceEntity) qrbill.Bill {
+	partner := invoice.BusinessPartnerEntity
+	debtorCountry := partner.Country
+	if debtorCountry == "" {
+		debtorCountry = settings.Country
+	}
+	return qrbill.Bill{
+		IBAN: settings.IBAN,
+		Creditor: qrbill.Address{
+			Name:           settings.CreditorName,
+			Street:         settings.Street,
+			BuildingNumber: settings.BuildingNumber,
+			ZipCode:        settings.ZipCode,
+			City:           settings.City,
+			Country:        settings.Country,
+		},
+		Debtor: qrbill.Address{
+			Name:    partner.Name,
+			Street:  partner.Street,
+			ZipCode: partner.ZipCode,
+			City:    partner.City,
+			Country: debtorCountry,
+		},
+		Ammount:       invoice.Ammount,
+		Currency:      invoice.Currency,
+		ReferenceType: invoice.ReferenceType,
+		Reference:     invoice.Reference,
+		Message:       invoice.Message,
+	}
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/camt"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/qrbill"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createInvoiceFixture(iban string) *mockInvoiceRepository {
+	repository := CreateMockInvoiceRepository()
+	repository.settings = []model.InvoiceSettingsEntity{
+		{BookRealmEntityID: 1, CreditorName: "Turnverein Muster", Street: "Hauptstrasse", BuildingNumber: "1",
+			ZipCode: "8000", City: "Zürich", Country: "CH", IBAN: iban, Currency: "CHF"},
+	}
+	repository.accounts = []model.AccountTableEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, AccountName: "Debitoren", Type: types.AccountTypeInventory,
+			Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+		{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, AccountName: "Mitgliederbeiträge", Type: types.AccountTypeIncome,
+			Category: types.AccountCategoryGain},
+	}
+	repository.partners = []model.BusinessPartnerEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, Name: "Huber", City: "Bern", PartnerType: types.PartnerTypeCustomer, AccountTableEntityID: 1},
+		{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, Name: "Lieferant", PartnerType: types.PartnerTypeSupplier, AccountTableEntityID: 1},
+	}
+	return repository
+}
+
+func Test_invoiceServiceImpl_CreateInvoice(t *testing.T) {
+	lines := []model.InvoiceLineDTO{
+		{Description: "Mitgliederbeitrag", Quantity: "2", UnitPrice: "50"},
+		{Description: "Lizenz", Quantity: "1", UnitPrice: "20.5"},
+	}
+	tests := []struct {
+		name        string
+		iban        string
+		invoice     model.InvoiceDTO
+		wantErr     bool
+		wantRefType string
+	}{
+		{"qr iban creates qr reference", "CH4431999123000889012",
+			model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2", Date: "2024-03-01", Lines: lines}, false, qrbill.ReferenceTypeQR},
+		{"regular iban creates creditor reference", "CH9300762011623852957",
+			model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2", Date: "2024-03-01", Lines: lines}, false, qrbill.ReferenceTypeCreditor},
+		{"supplier can not be invoiced", "CH4431999123000889012",
+			model.InvoiceDTO{PartnerID: "2", RevenueAccountID: "2", Lines: lines}, true, ""},
+		{"revenue account must be a gain account", "CH4431999123000889012",
+			model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "1", Lines: lines}, true, ""},
+		{"invoice without lines", "CH4431999123000889012",
+			model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2"}, true, ""},
+		{"description exceeding the page", "CH4431999123000889012",
+			model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2", Lines: []model.InvoiceLineDTO{{Description: strings.Repeat("Lizenz ", 80), Quantity: "1", UnitPrice: "20"}}}, true, ""},
+		{"due date before invoice date", "CH4431999123000889012",
+			model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2", Date: "2024-03-01", DueDate: "2024-02-01", Lines: lines}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createInvoiceFixture(tt.iban)
+			s := CreateInvoiceService(repository, &mockPaymentMatcher{})
+			err := s.CreateInvoice("1", tt.invoice)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateInvoice() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			invoice := repository.invoices[0]
+			if invoice.Ammount != "120.50" || invoice.DueDate != "2024-03-31" || invoice.ReferenceType != tt.wantRefType {
+				t.Errorf("CreateInvoice() persisted %+v", invoice)
+			}
+			booking := repository.bookings[0]
+			if booking.SollBookingAccountID != 1 || booking.HabenBookingAccountID != 2 || booking.Ammount != "120.50" {
+				t.Errorf("CreateInvoice() booked %+v", booking)
+			}
+			if len(repository.events) != 1 || repository.events[0].Type != types.DomainEventBookingCreated {
+				t.Errorf("CreateInvoice() events = %v, want booking.created", repository.events)
+			}
+			var pdf bytes.Buffer
+			if err := s.RenderInvoicePDF("1", "1", &pdf); err != nil {
+				t.Errorf("RenderInvoicePDF() error = %v", err)
+			}
+		})
+	}
+}
+
+func Test_invoiceServiceImpl_MatchPaymentByReference(t *testing.T) {
+	repository := createInvoiceFixture("CH4431999123000889012")
+	matcher := &mockPaymentMatcher{}
+	s := CreateInvoiceService(repository, matcher)
+	if err := s.CreateInvoice("1", model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2",
+		Lines: []model.InvoiceLineDTO{{Description: "Mitgliederbeitrag", Quantity: "1", UnitPrice: "50"}}}); err != nil {
+		t.Errorf("CreateInvoice() error = %v", err)
+		return
+	}
+	reference := repository.invoices[0].Reference
+
+	if err := s.MatchPaymentByReference("1", model.InvoicePaymentDTO{Reference: "21000000000313947143000901", BookingID: "5"}); err == nil {
+		t.Errorf("MatchPaymentByReference() invalid reference must fail")
+	}
+	if err := s.MatchPaymentByReference("2", model.InvoicePaymentDTO{Reference: reference, BookingID: "5"}); err == nil {
+		t.Errorf("MatchPaymentByReference() reference of another book must not match")
+	}
+	if err := s.MatchPaymentByReference("1", model.InvoicePaymentDTO{Reference: reference[:2] + " " + reference[2:], BookingID: "5"}); err != nil {
+		t.Errorf("MatchPaymentByReference() error = %v", err)
+		return
+	}
+	if len(matcher.openItemIDs) != 1 || matcher.openItemIDs[0] != "1" || matcher.payments[0].BookingID != "5" {
+		t.Errorf("MatchPaymentByReference() should create a payment on the open item, got %v %v", matcher.openItemIDs, matcher.payments)
+	}
+}
+
+// createCamtNotification creates a camt.054 notification with a booked credit entry per payment
+func createCamtNotification(payments ...camt.Payment) string {
+	var entries strings.Builder
+	for _, payment := range payments {
+		fmt.Fprintf(&entries, `<Ntry><Amt Ccy="%s">%s</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts><BookgDt><Dt>%s</Dt></BookgDt>
+<NtryDtls><TxDtls><RmtInf><Strd><CdtrRefInf><Ref>%s</Ref></CdtrRefInf></Strd></RmtInf></TxDtls></NtryDtls></Ntry>`,
+			payment.Currency, payment.Ammount, payment.BookingDate, payment.Reference)
+	}
+	return `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.054.001.08"><BkToCstmrDbtCdtNtfctn><Ntfctn>` +
+		entries.String() + `</Ntfctn></BkToCstmrDbtCdtNtfctn></Document>`
+}
+
+func Test_invoiceServiceImpl_ImportPayments(t *testing.T) {
+	repository := createInvoiceFixture("CH4431999123000889012")
+	repository.accounts = append(repository.accounts, model.AccountTableEntity{Model: gorm.Model{ID: 3}, BookRealmEntityID: 1,
+		AccountName: "Bank", Type: types.AccountTypeInventory, Category: types.AccountCategoryActive})
+	matcher := &mockPaymentMatcher{openItems: []model.OpenItemDTO{{OpenItemID: "1", OpenAmmount: "50.00"}}}
+	s := CreateInvoiceService(repository, matcher)
+	if err := s.CreateInvoice("1", model.InvoiceDTO{PartnerID: "1", RevenueAccountID: "2",
+		Lines: []model.InvoiceLineDTO{{Description: "Mitgliederbeitrag", Quantity: "1", UnitPrice: "50"}}}); err != nil {
+		t.Fatalf("CreateInvoice() error = %v", err)
+	}
+	reference := repository.invoices[0].Reference
+	notification := createCamtNotification(
+		camt.Payment{Reference: reference, Ammount: "50.00", Currency: "CHF", BookingDate: "2024-03-05"},
+		camt.Payment{Reference: reference, Ammount: "80.00", Currency: "CHF", BookingDate: "2024-03-05"},
+		camt.Payment{Reference: reference, Ammount: "50.00", Currency: "EUR", BookingDate: "2024-03-05"},
+		camt.Payment{Reference: "RF18539007547034", Ammount: "50.00", Currency: "CHF", BookingDate: "2024-03-05"},
+	)
+
+	if _, err := s.ImportPayments("1", "2", strings.NewReader(notification)); !model.IsExistingValidationError(err) {
+		t.Errorf("ImportPayments() onto a revenue account error = %v, want validation error", err)
+	}
+	if _, err := s.ImportPayments("1", "3", strings.NewReader("<Document/>")); !model.IsExistingValidationError(err) {
+		t.Errorf("ImportPayments() of a document without statement error = %v, want validation error", err)
+	}
+	report, err := s.ImportPayments("1", "3", strings.NewReader(notification))
+	if model.IsExisting(err) {
+		t.Fatalf("ImportPayments() error = %v", err)
+	}
+	if len(report.Matched) != 1 || report.Matched[0].BookingID != "2" || report.Matched[0].InvoiceID != "1" {
+		t.Errorf("ImportPayments() matched = %+v, want the payment of 50.00 booked as booking 2", report.Matched)
+	}
+	if len(report.Unmatched) != 3 || report.Unmatched[0].BookingID != "" || report.Unmatched[0].Message == "" {
+		t.Errorf("ImportPayments() unmatched = %+v, want the exceeding, foreign currency and unknown payments without booking", report.Unmatched)
+	}
+	payment := repository.bookings[len(repository.bookings)-1]
+	if len(repository.bookings) != 2 || payment.SollBookingAccount.ID != 3 || payment.HabenBookingAccount.ID != 1 || payment.Ammount != "50.00" || payment.Date != "2024-03-05" {
+		t.Errorf("ImportPayments() booked %+v, want 50.00 from the receivables onto the bank account", repository.bookings)
+	}
+	if len(matcher.payments) != 1 || matcher.openItemIDs[0] != "1" || matcher.payments[0].BookingID != "2" {
+		t.Errorf("ImportPayments() matched %v %v, want booking 2 on open item 1", matcher.openItemIDs, matcher.payments)
+	}
+
+	matcher.openItems = nil
+	report, _ = s.ImportPayments("1", "3", strings.NewReader(createCamtNotification(
+		camt.Payment{Reference: reference, Ammount: "50.00", Currency: "CHF", BookingDate: "2024-03-05"})))
+	if len(report.Matched) != 0 || len(repository.bookings) != 2 {
+		t.Errorf("ImportPayments() of a paid invoice = %+v, want it to be left unbooked", report)
+	}
+}
@@ -2,17 +2,24 @@ package service
 
 import (
 	"errors"
+	"time"
 
 	mockutils "github.com/toky03/toky-finance-accounting-service/mock_utils"
 	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type mockAccountingRepository struct {
 	/// bookings per account
 	bookings []model.BookingEntity
 	/// accounts per book
-	accounts   []model.AccountTableEntity
-	bookRealms map[uint]model.BookRealmEntity
+	accounts         []model.AccountTableEntity
+	bookRealms       map[uint]model.BookRealmEntity
+	clearingAccounts []model.ClearingAccountEntity
+	/// open items, payments and depreciations per booking
+	subledgerReferences map[uint]int64
+	/// events written to the outbox
+	events []model.DomainEvent
 }
 
 func CreateMockAccountingRepository() *mockAccountingRepository {
@@ -27,6 +34,8 @@ func (mar *mockAccountingRepository) Clear() {
 	mar.accounts = []model.AccountTableEntity{}
 	mar.bookRealms = map[uint]model.BookRealmEntity{}
 	mar.bookings = []model.BookingEntity{}
+	mar.clearingAccounts = nil
+	mar.events = nil
 }
 
 func (mar *mockAccountingRepository) SetAccounts(accounts []model.AccountTableEntity) {
@@ -86,15 +95,17 @@ func (mar *mockAccountingRepository) FindRelatedSollBuchungen(
 }
 
 func (mar *mockAccountingRepository) CreateAccount(
-	entity model.AccountTableEntity,
+	entity *model.AccountTableEntity, events ...model.DomainEvent,
 ) model.TokyError {
-	mar.accounts = append(mar.accounts, entity)
+	mar.events = append(mar.events, events...)
+	mar.accounts = append(mar.accounts, *entity)
 	return nil
 }
 
 func (mar *mockAccountingRepository) UpdateAccount(
-	entity *model.AccountTableEntity,
+	entity *model.AccountTableEntity, events ...model.DomainEvent,
 ) model.TokyError {
+	mar.events = append(mar.events, events...)
 	mar.accounts = mockutils.UpdateEntity(
 		mar.accounts,
 		*entity,
@@ -107,8 +118,9 @@ func (mar *mockAccountingRepository) UpdateAccount(
 }
 
 func (mar *mockAccountingRepository) DeleteAccount(
-	entity *model.AccountTableEntity,
+	entity *model.AccountTableEntity, events ...model.DomainEvent,
 ) model.TokyError {
+	mar.events = append(mar.events, events...)
 	mar.accounts = mockutils.DeleteEntity(
 		mar.accounts,
 		func(e model.AccountTableEntity) string { return e.AccountName },
@@ -130,12 +142,14 @@ func (mar *mockAccountingRepository) FindAccountByID(
 	return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound("Not found account with id", errors.New("No Account present"))
 
 }
-func (mar *mockAccountingRepository) PersistBooking(entity model.BookingEntity) model.TokyError {
-	mar.bookings = append(mar.bookings, entity)
+func (mar *mockAccountingRepository) PersistBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
+	mar.bookings = append(mar.bookings, *entity)
 	return nil
 
 }
-func (mar *mockAccountingRepository) UpdateBooking(entity *model.BookingEntity) model.TokyError {
+func (mar *mockAccountingRepository) UpdateBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
 	mar.bookings = mockutils.UpdateEntity(
 		mar.bookings,
 		*entity,
@@ -146,7 +160,8 @@ func (mar *mockAccountingRepository) UpdateBooking(entity *model.BookingEntity)
 	return nil
 
 }
-func (mar *mockAccountingRepository) DeleteBooking(entity *model.BookingEntity) model.TokyError {
+func (mar *mockAccountingRepository) DeleteBooking(entity *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
 	mar.bookings = mockutils.DeleteEntity(
 		mar.bookings,
 		func(e model.BookingEntity) string { return e.Description },
@@ -180,3 +195,827 @@ func (mar *mockAccountingRepository) FindBookingByID(bookingID uint) (model.Book
 	return model.BookingEntity{}, model.CreateBusinessErrorNotFound("Not found booking with given id", errors.New(""))
 
 }
+
+func (mar *mockAccountingRepository) CountSubledgerReferences(bookingID uint) (int64, model.TokyError) {
+	return mar.subledgerReferences[bookingID], nil
+}
+
+func (mar *mockAccountingRepository) FindAccrualsByBookId(bookID uint) ([]model.BookingEntity, model.TokyError) {
+	accruals := []model.BookingEntity{}
+	for _, booking := range mar.bookings {
+		if booking.BookingType == types.BookingTypeAccrual {
+			accruals = append(accruals, booking)
+		}
+	}
+	return accruals, nil
+}
+
+func (mar *mockAccountingRepository) FindDueAccruals(date string) ([]model.BookingEntity, model.TokyError) {
+	accruals := []model.BookingEntity{}
+	for _, booking := range mar.bookings {
+		if booking.BookingType == types.BookingTypeAccrual && booking.ReversalBookingID == 0 && booking.ReversalDate <= date {
+			accruals = append(accruals, booking)
+		}
+	}
+	return accruals, nil
+}
+
+func (mar *mockAccountingRepository) PersistReversal(accrual model.BookingEntity, reversal *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
+	reversal.ID = uint(len(mar.bookings) + 1)
+	mar.bookings = append(mar.bookings, *reversal)
+	for i, booking := range mar.bookings {
+		if booking.ID == accrual.ID {
+			mar.bookings[i].ReversalBookingID = reversal.ID
+		}
+	}
+	return nil
+}
+
+func (mar *mockAccountingRepository) FindClearingAccountsByBookId(bookID uint) ([]model.ClearingAccountEntity, model.TokyError) {
+	clearingAccounts := []model.ClearingAccountEntity{}
+	for _, clearingAccount := range mar.clearingAccounts {
+		if clearingAccount.BookRealmEntityID == bookID {
+			clearingAccounts = append(clearingAccounts, clearingAccount)
+		}
+	}
+	return clearingAccounts, nil
+}
+
+func (mar *mockAccountingRepository) FindClearingAccount(bookID, counterpartBookID uint) (model.ClearingAccountEntity, model.TokyError) {
+	for _, clearingAccount := range mar.clearingAccounts {
+		if clearingAccount.BookRealmEntityID == bookID && clearingAccount.CounterpartBookID == counterpartBookID {
+			return clearingAccount, nil
+		}
+	}
+	return model.ClearingAccountEntity{}, model.CreateBusinessErrorNotFound("Not found clearing account", errors.New("No Clearing Account present"))
+}
+
+func (mar *mockAccountingRepository) SaveClearingAccount(clearingAccount *model.ClearingAccountEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
+	for i, existing := range mar.clearingAccounts {
+		if existing.BookRealmEntityID == clearingAccount.BookRealmEntityID && existing.CounterpartBookID == clearingAccount.CounterpartBookID {
+			mar.clearingAccounts[i] = *clearingAccount
+			return nil
+		}
+	}
+	mar.clearingAccounts = append(mar.clearingAccounts, *clearingAccount)
+	return nil
+}
+
+func (mar *mockAccountingRepository) PersistInterBookTransfer(source, target *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
+	source.ID = uint(len(mar.bookings) + 1)
+	target.ID = source.ID + 1
+	source.LinkedBookingID = target.ID
+	target.LinkedBookingID = source.ID
+	mar.bookings = append(mar.bookings, *source, *target)
+	return nil
+}
+
+func (mar *mockAccountingRepository) UpdateLinkedBookings(booking, linkedBooking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
+	for i, existing := range mar.bookings {
+		if existing.ID == booking.ID {
+			mar.bookings[i] = *booking
+		}
+		if existing.ID == linkedBooking.ID {
+			mar.bookings[i] = *linkedBooking
+		}
+	}
+	return nil
+}
+
+func (mar *mockAccountingRepository) DeleteLinkedBookings(booking, linkedBooking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	mar.events = append(mar.events, events...)
+	bookings := []model.BookingEntity{}
+	for _, existing := range mar.bookings {
+		if existing.ID != booking.ID && existing.ID != linkedBooking.ID {
+			bookings = append(bookings, existing)
+		}
+	}
+	mar.bookings = bookings
+	return nil
+}
+
+type mockSubledgerRepository struct {
+	partners  []model.BusinessPartnerEntity
+	openItems []model.OpenItemEntity
+	payments  []model.OpenItemPaymentEntity
+	accounts  []model.AccountTableEntity
+	bookings  []model.BookingEntity
+}
+
+func CreateMockSubledgerRepository() *mockSubledgerRepository {
+	return &mockSubledgerRepository{
+		partners:  []model.BusinessPartnerEntity{},
+		openItems: []model.OpenItemEntity{},
+		payments:  []model.OpenItemPaymentEntity{},
+		accounts:  []model.AccountTableEntity{},
+		bookings:  []model.BookingEntity{},
+	}
+}
+
+func (msr *mockSubledgerRepository) FindBusinessPartnersByBookId(bookID uint) ([]model.BusinessPartnerEntity, model.TokyError) {
+	partners := []model.BusinessPartnerEntity{}
+	for _, partner := range msr.partners {
+		if partner.BookRealmEntityID == bookID {
+			partners = append(partners, partner)
+		}
+	}
+	return partners, nil
+}
+
+func (msr *mockSubledgerRepository) FindBusinessPartnerByID(partnerID uint) (model.BusinessPartnerEntity, model.TokyError) {
+	for _, partner := range msr.partners {
+		if partner.ID == partnerID {
+			return partner, nil
+		}
+	}
+	return model.BusinessPartnerEntity{}, model.CreateBusinessErrorNotFound("Not found partner with id", errors.New(""))
+}
+
+func (msr *mockSubledgerRepository) PersistBusinessPartner(partner model.BusinessPartnerEntity) model.TokyError {
+	partner.ID = uint(len(msr.partners) + 1)
+	msr.partners = append(msr.partners, partner)
+	return nil
+}
+
+func (msr *mockSubledgerRepository) UpdateBusinessPartner(partner *model.BusinessPartnerEntity) model.TokyError {
+	msr.partners = mockutils.UpdateEntity(
+		msr.partners,
+		*partner,
+		func(e model.BusinessPartnerEntity) string { return e.Name },
+		partner.Name,
+	)
+	return nil
+}
+
+func (msr *mockSubledgerRepository) FindOpenItemsByBookId(bookID uint) ([]model.OpenItemEntity, model.TokyError) {
+	openItems := []model.OpenItemEntity{}
+	for _, openItem := range msr.openItems {
+		if openItem.BookRealmEntityID == bookID {
+			openItems = append(openItems, msr.withPayments(openItem))
+		}
+	}
+	return openItems, nil
+}
+
+func (msr *mockSubledgerRepository) FindOpenItemByID(openItemID uint) (model.OpenItemEntity, model.TokyError) {
+	for _, openItem := range msr.openItems {
+		if openItem.ID == openItemID {
+			return msr.withPayments(openItem), nil
+		}
+	}
+	return model.OpenItemEntity{}, model.CreateBusinessErrorNotFound("Not found open item with id", errors.New(""))
+}
+
+func (msr *mockSubledgerRepository) withPayments(openItem model.OpenItemEntity) model.OpenItemEntity {
+	openItem.Payments = []model.OpenItemPaymentEntity{}
+	for _, payment := range msr.payments {
+		if payment.OpenItemEntityID == openItem.ID {
+			openItem.Payments = append(openItem.Payments, payment)
+		}
+	}
+	openItem.BusinessPartnerEntity, _ = msr.FindBusinessPartnerByID(openItem.BusinessPartnerEntityID)
+	return openItem
+}
+
+func (msr *mockSubledgerRepository) PersistOpenItem(openItem model.OpenItemEntity) model.TokyError {
+	openItem.ID = uint(len(msr.openItems) + 1)
+	msr.openItems = append(msr.openItems, openItem)
+	return nil
+}
+
+func (msr *mockSubledgerRepository) PersistOpenItemPayment(payment model.OpenItemPaymentEntity) model.TokyError {
+	payment.ID = uint(len(msr.payments) + 1)
+	msr.payments = append(msr.payments, payment)
+	return nil
+}
+
+func (msr *mockSubledgerRepository) FindOpenItemPaymentsByBookingID(bookingID uint) ([]model.OpenItemPaymentEntity, model.TokyError) {
+	payments := []model.OpenItemPaymentEntity{}
+	for _, payment := range msr.payments {
+		if payment.BookingEntityID == bookingID {
+			payments = append(payments, payment)
+		}
+	}
+	return payments, nil
+}
+
+func (msr *mockSubledgerRepository) FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError) {
+	for _, account := range msr.accounts {
+		if account.ID == id {
+			return account, nil
+		}
+	}
+	return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound("Not found account with id", errors.New("No Account present"))
+}
+
+func (msr *mockSubledgerRepository) FindBookingByID(bookingID uint) (model.BookingEntity, model.TokyError) {
+	for _, booking := range msr.bookings {
+		if booking.ID == bookingID {
+			return booking, nil
+		}
+	}
+	return model.BookingEntity{}, model.CreateBusinessErrorNotFound("Not found booking with given id", errors.New(""))
+}
+
+type mockInvoiceRepository struct {
+	settings []model.InvoiceSettingsEntity
+	invoices []model.InvoiceEntity
+	bookings []model.BookingEntity
+	partners []model.BusinessPartnerEntity
+	accounts []model.AccountTableEntity
+	events   []model.DomainEvent
+}
+
+func CreateMockInvoiceRepository() *mockInvoiceRepository {
+	return &mockInvoiceRepository{
+		settings: []model.InvoiceSettingsEntity{},
+		invoices: []model.InvoiceEntity{},
+		bookings: []model.BookingEntity{},
+		partners: []model.BusinessPartnerEntity{},
+		accounts: []model.AccountTableEntity{},
+	}
+}
+
+func (mir *mockInvoiceRepository) FindInvoiceSettingsByBookId(bookID uint) (model.InvoiceSettingsEntity, model.TokyError) {
+	for _, settings := range mir.settings {
+		if settings.BookRealmEntityID == bookID {
+			return settings, nil
+		}
+	}
+	return model.InvoiceSettingsEntity{}, model.CreateBusinessErrorNotFound("Not found invoice settings", errors.New(""))
+}
+
+func (mir *mockInvoiceRepository) SaveInvoiceSettings(settings *model.InvoiceSettingsEntity) model.TokyError {
+	mir.settings = append(mir.settings, *settings)
+	return nil
+}
+
+func (mir *mockInvoiceRepository) FindInvoicesByBookId(bookID uint) ([]model.InvoiceEntity, model.TokyError) {
+	invoices := []model.InvoiceEntity{}
+	for _, invoice := range mir.invoices {
+		if invoice.BookRealmEntityID == bookID {
+			invoices = append(invoices, invoice)
+		}
+	}
+	return invoices, nil
+}
+
+func (mir *mockInvoiceRepository) FindInvoiceByID(invoiceID uint) (model.InvoiceEntity, model.TokyError) {
+	for _, invoice := range mir.invoices {
+		if invoice.ID == invoiceID {
+			return invoice, nil
+		}
+	}
+	return model.InvoiceEntity{}, model.CreateBusinessErrorNotFound("Not found invoice with id", errors.New(""))
+}
+
+func (mir *mockInvoiceRepository) FindInvoiceByReference(bookID uint, reference string) (model.InvoiceEntity, model.TokyError) {
+	for _, invoice := range mir.invoices {
+		if invoice.BookRealmEntityID == bookID && invoice.Reference == reference {
+			return invoice, nil
+		}
+	}
+	return model.InvoiceEntity{}, model.CreateBusinessErrorNotFound("Not found invoice with reference", errors.New(""))
+}
+
+func (mir *mockInvoiceRepository) PersistInvoice(invoice *model.InvoiceEntity, booking *model.BookingEntity, numberInvoice func(uint) model.TokyError, events ...model.DomainEvent) model.TokyError {
+	invoices, _ := mir.FindInvoicesByBookId(invoice.BookRealmEntityID)
+	if err := numberInvoice(uint(len(invoices) + 1)); model.IsExisting(err) {
+		return err
+	}
+	booking.ID = uint(len(mir.bookings) + 1)
+	mir.bookings = append(mir.bookings, *booking)
+	mir.events = append(mir.events, events...)
+	invoice.ID = uint(len(mir.invoices) + 1)
+	invoice.BookingEntityID = booking.ID
+	invoice.OpenItemEntityID = invoice.ID
+	mir.invoices = append(mir.invoices, *invoice)
+	return nil
+}
+
+func (mir *mockInvoiceRepository) PersistBooking(booking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	booking.ID = uint(len(mir.bookings) + 1)
+	mir.bookings = append(mir.bookings, *booking)
+	mir.events = append(mir.events, events...)
+	return nil
+}
+
+func (mir *mockInvoiceRepository) FindBusinessPartnerByID(partnerID uint) (model.BusinessPartnerEntity, model.TokyError) {
+	for _, partner := range mir.partners {
+		if partner.ID == partnerID {
+			return partner, nil
+		}
+	}
+	return model.BusinessPartnerEntity{}, model.CreateBusinessErrorNotFound("Not found partner with id", errors.New(""))
+}
+
+func (mir *mockInvoiceRepository) FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError) {
+	for _, account := range mir.accounts {
+		if account.ID == id {
+			return account, nil
+		}
+	}
+	return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound("Not found account with id", errors.New("No Account present"))
+}
+
+type mockPaymentMatcher struct {
+	openItems   []model.OpenItemDTO
+	openItemIDs []string
+	payments    []model.OpenItemPaymentDTO
+}
+
+func (mpm *mockPaymentMatcher) ReadOpenItems(bookID, partnerID string, onlyOpen bool) ([]model.OpenItemDTO, model.TokyError) {
+	return mpm.openItems, nil
+}
+
+func (mpm *mockPaymentMatcher) CreatePayment(bookID, openItemID string, payment model.OpenItemPaymentDTO) model.TokyError {
+	mpm.openItemIDs = append(mpm.openItemIDs, openItemID)
+	mpm.payments = append(mpm.payments, payment)
+	return nil
+}
+
+type mockAssetRepository struct {
+	assets   []model.FixedAssetEntity
+	accounts []model.AccountTableEntity
+	bookings []model.BookingEntity
+	events   []model.DomainEvent
+}
+
+func (mar *mockAssetRepository) FindFixedAssetsByBookId(bookID uint) ([]model.FixedAssetEntity, model.TokyError) {
+	assets := []model.FixedAssetEntity{}
+	for _, asset := range mar.assets {
+		if asset.BookRealmEntityID == bookID {
+			assets = append(assets, asset)
+		}
+	}
+	return assets, nil
+}
+
+func (mar *mockAssetRepository) PersistFixedAsset(asset model.FixedAssetEntity) model.TokyError {
+	asset.ID = uint(len(mar.assets) + 1)
+	mar.assets = append(mar.assets, asset)
+	return nil
+}
+
+func (mar *mockAssetRepository) PersistDepreciation(depreciation model.DepreciationEntity, booking *model.BookingEntity, events ...model.DomainEvent) model.TokyError {
+	booking.ID = uint(len(mar.bookings) + 1)
+	mar.bookings = append(mar.bookings, *booking)
+	mar.events = append(mar.events, events...)
+	depreciation.BookingEntityID = booking.ID
+	depreciation.BookingEntity = *booking
+	for i, asset := range mar.assets {
+		if asset.ID == depreciation.FixedAssetEntityID {
+			mar.assets[i].Depreciations = append(mar.assets[i].Depreciations, depreciation)
+		}
+	}
+	return nil
+}
+
+func (mar *mockAssetRepository) FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError) {
+	for _, account := range mar.accounts {
+		if account.ID == id {
+			return account, nil
+		}
+	}
+	return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound("Not found account with id", errors.New("No Account present"))
+}
+
+type mockUserRepository struct {
+	users           []model.ApplicationUserEntity
+	bookRealms      []model.BookRealmEntity
+	roleAssignments []model.BookRoleAssignmentEntity
+	purgedBefore    time.Time
+}
+
+func (mur *mockUserRepository) PersistApplicationUser(user model.ApplicationUserEntity) model.TokyError {
+	mur.users = append(mur.users, user)
+	return nil
+}
+
+func (mur *mockUserRepository) UpdateApplicationUser(user model.ApplicationUserEntity) model.TokyError {
+	for i, existing := range mur.users {
+		if existing.ID == user.ID {
+			mur.users[i] = user
+		}
+	}
+	return nil
+}
+
+func (mur *mockUserRepository) FindAllApplicationUsersBySearchTerm(limit int, searchTerm string) ([]model.ApplicationUserEntity, model.TokyError) {
+	return mur.users, nil
+}
+
+func (mur *mockUserRepository) FindAllApplicationUsers() ([]model.ApplicationUserEntity, model.TokyError) {
+	return mur.users, nil
+}
+
+func (mur *mockUserRepository) DeleteUserWithAssociations(userId string, bookEvent model.BookEvent) model.TokyError {
+	return nil
+}
+
+func (mur *mockUserRepository) PurgeOrphanedBookRealms(archivedBefore time.Time, bookEvent model.BookEvent) (int, model.TokyError) {
+	mur.purgedBefore = archivedBefore
+	return 0, nil
+}
+
+func (mur *mockUserRepository) FindAllApplicationUsersByUserName(userName string) (model.ApplicationUserEntity, model.TokyError) {
+	for _, user := range mur.users {
+		if user.UserName == userName {
+			return user, nil
+		}
+	}
+	return model.ApplicationUserEntity{}, model.CreateBusinessErrorNotFound("Not found user", errors.New("No User present"))
+}
+
+func (mur *mockUserRepository) SyncApplicationUsers(users []model.ApplicationUserEntity, revision int64, bookEvent model.BookEvent) (model.UserSyncReportDTO, model.TokyError) {
+	mur.users = users
+	return model.UserSyncReportDTO{Revision: revision, Added: len(users)}, nil
+}
+
+func (mur *mockUserRepository) FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError) {
+	for _, user := range mur.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return model.ApplicationUserEntity{}, model.CreateBusinessErrorNotFound("Not found user", errors.New("No User present"))
+}
+
+func (mur *mockUserRepository) FindBookRealmByID(bookID uint) (model.BookRealmEntity, model.TokyError) {
+	for _, bookRealm := range mur.bookRealms {
+		if bookRealm.ID == bookID {
+			return bookRealm, nil
+		}
+	}
+	return model.BookRealmEntity{}, model.CreateBusinessErrorNotFound("Not found book", errors.New("No Book present"))
+}
+
+func (mur *mockUserRepository) FindRoleAssignment(bookID uint, userID string) (model.BookRoleAssignmentEntity, model.TokyError) {
+	for _, roleAssignment := range mur.roleAssignments {
+		if roleAssignment.BookRealmEntityID == bookID && roleAssignment.ApplicationUserEntityID == userID {
+			return roleAssignment, nil
+		}
+	}
+	return model.BookRoleAssignmentEntity{}, model.CreateBusinessErrorNotFound("Not found role", errors.New("No Role present"))
+}
+
+type mockSharingRepository struct {
+	bookRealms      []model.BookRealmEntity
+	users           []model.ApplicationUserEntity
+	roleAssignments []model.BookRoleAssignmentEntity
+	invitations     []model.BookInvitationEntity
+}
+
+func (msr *mockSharingRepository) FindBookRealmByID(bookID uint) (model.BookRealmEntity, model.TokyError) {
+	for _, bookRealm := range msr.bookRealms {
+		if bookRealm.ID == bookID {
+			return bookRealm, nil
+		}
+	}
+	return model.BookRealmEntity{}, model.CreateBusinessErrorNotFound("Not found book", errors.New("No Book present"))
+}
+
+func (msr *mockSharingRepository) FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError) {
+	for _, user := range msr.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return model.ApplicationUserEntity{}, model.CreateBusinessErrorNotFound("Not found user", errors.New("No User present"))
+}
+
+func (msr *mockSharingRepository) FindRoleAssignment(bookID uint, userID string) (model.BookRoleAssignmentEntity, model.TokyError) {
+	for _, roleAssignment := range msr.roleAssignments {
+		if roleAssignment.BookRealmEntityID == bookID && roleAssignment.ApplicationUserEntityID == userID {
+			return roleAssignment, nil
+		}
+	}
+	return model.BookRoleAssignmentEntity{}, model.CreateBusinessErrorNotFound("Not found role", errors.New("No Role present"))
+}
+
+func (msr *mockSharingRepository) DeleteRoleAssignment(bookID uint, userID string) model.TokyError {
+	remaining := []model.BookRoleAssignmentEntity{}
+	for _, roleAssignment := range msr.roleAssignments {
+		if roleAssignment.BookRealmEntityID != bookID || roleAssignment.ApplicationUserEntityID != userID {
+			remaining = append(remaining, roleAssignment)
+		}
+	}
+	msr.roleAssignments = remaining
+	return nil
+}
+
+func (msr *mockSharingRepository) assignRole(bookID uint, userID string, role types.BookRole) {
+	msr.DeleteRoleAssignment(bookID, userID)
+	msr.roleAssignments = append(msr.roleAssignments, model.BookRoleAssignmentEntity{
+		BookRealmEntityID: bookID, ApplicationUserEntityID: userID, Role: role})
+}
+
+func (msr *mockSharingRepository) PersistInvitation(invitation *model.BookInvitationEntity) model.TokyError {
+	invitation.ID = uint(len(msr.invitations) + 1)
+	msr.invitations = append(msr.invitations, *invitation)
+	return nil
+}
+
+func (msr *mockSharingRepository) FindPendingInvitationsByBookId(bookID uint) ([]model.BookInvitationEntity, model.TokyError) {
+	invitations := []model.BookInvitationEntity{}
+	for _, invitation := range msr.invitations {
+		if invitation.BookRealmEntityID == bookID && invitation.AcceptedByID == "" {
+			invitations = append(invitations, invitation)
+		}
+	}
+	return invitations, nil
+}
+
+func (msr *mockSharingRepository) FindPendingInvitationsByEMail(email string) ([]model.BookInvitationEntity, model.TokyError) {
+	invitations := []model.BookInvitationEntity{}
+	for _, invitation := range msr.invitations {
+		if invitation.EMail == email && invitation.AcceptedByID == "" {
+			invitations = append(invitations, invitation)
+		}
+	}
+	return invitations, nil
+}
+
+func (msr *mockSharingRepository) FindInvitationByToken(token string) (model.BookInvitationEntity, model.TokyError) {
+	for _, invitation := range msr.invitations {
+		if invitation.Token == token {
+			return invitation, nil
+		}
+	}
+	return model.BookInvitationEntity{}, model.CreateBusinessErrorNotFound("Not found invitation", errors.New("No Invitation present"))
+}
+
+func (msr *mockSharingRepository) DeleteInvitation(bookID, invitationID uint) model.TokyError {
+	return nil
+}
+
+func (msr *mockSharingRepository) AcceptInvitation(invitation model.BookInvitationEntity, userID string) model.TokyError {
+	for i := range msr.invitations {
+		if msr.invitations[i].ID == invitation.ID {
+			msr.invitations[i].AcceptedByID = userID
+		}
+	}
+	msr.assignRole(invitation.BookRealmEntityID, userID, invitation.Role)
+	return nil
+}
+
+func (msr *mockSharingRepository) UpdatePendingOwner(bookID uint, pendingOwnerID string) model.TokyError {
+	for i := range msr.bookRealms {
+		if msr.bookRealms[i].ID == bookID {
+			msr.bookRealms[i].PendingOwnerID = pendingOwnerID
+		}
+	}
+	return nil
+}
+
+func (msr *mockSharingRepository) TransferOwnership(bookID uint, previousOwnerID, newOwnerID string) model.TokyError {
+	for i := range msr.bookRealms {
+		if msr.bookRealms[i].ID == bookID {
+			msr.bookRealms[i].OwnerID = newOwnerID
+			msr.bookRealms[i].PendingOwnerID = ""
+		}
+	}
+	msr.DeleteRoleAssignment(bookID, newOwnerID)
+	msr.assignRole(bookID, previousOwnerID, types.BookRoleAdmin)
+	return nil
+}
+
+type mockApiTokenRepository struct {
+	tokens []model.ApiTokenEntity
+}
+
+func (mar *mockApiTokenRepository) PersistApiToken(token *model.ApiTokenEntity) model.TokyError {
+	token.ID = uint(len(mar.tokens) + 1)
+	mar.tokens = append(mar.tokens, *token)
+	return nil
+}
+
+func (mar *mockApiTokenRepository) FindApiTokensByUser(userID string) ([]model.ApiTokenEntity, model.TokyError) {
+	tokens := []model.ApiTokenEntity{}
+	for _, token := range mar.tokens {
+		if token.ApplicationUserEntityID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func (mar *mockApiTokenRepository) FindApiTokenByHash(tokenHash string) (model.ApiTokenEntity, model.TokyError) {
+	for _, token := range mar.tokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return model.ApiTokenEntity{}, model.CreateBusinessErrorNotFound("Not found token", errors.New("No Token present"))
+}
+
+func (mar *mockApiTokenRepository) DeleteApiToken(userID string, tokenID uint) model.TokyError {
+	remaining := []model.ApiTokenEntity{}
+	for _, token := range mar.tokens {
+		if token.ID != tokenID || token.ApplicationUserEntityID != userID {
+			remaining = append(remaining, token)
+		}
+	}
+	if len(remaining) == len(mar.tokens) {
+		return model.CreateBusinessErrorNotFound("Not found token", errors.New("No Token present"))
+	}
+	mar.tokens = remaining
+	return nil
+}
+
+type mockPermissionChecker struct {
+	permissions map[string]bool
+}
+
+func (mpc mockPermissionChecker) HasPermission(userId, bookID string, permission types.Permission) (bool, model.TokyError) {
+	return mpc.permissions[userId+bookID+string(permission)], nil
+}
+
+type mockBookingRepository struct {
+	bookRealms     []model.BookRealmEntity
+	users          []model.ApplicationUserEntity
+	accounts       []model.AccountTableEntity
+	bookings       []model.BookingEntity
+	purgedBookIDs  []uint
+	clonedAccounts []model.AccountTableEntity
+	clonedBookings []model.BookingEntity
+	clonedSettings *model.InvoiceSettingsEntity
+	events         []model.DomainEvent
+}
+
+func (mbr *mockBookingRepository) FindAllBookRealmsCorrespondingToUser(userId string, claimedBookIds []uint, includeArchived bool) ([]model.BookRealmEntity, model.TokyError) {
+	bookRealms := make([]model.BookRealmEntity, 0, len(mbr.bookRealms))
+	for _, bookRealm := range mbr.bookRealms {
+		claimed := false
+		for _, bookID := range claimedBookIds {
+			claimed = claimed || bookRealm.ID == bookID
+		}
+		if (bookRealm.OwnerID == userId || claimed) && (includeArchived || bookRealm.ArchivedAt == nil) {
+			bookRealms = append(bookRealms, bookRealm)
+		}
+	}
+	return bookRealms, nil
+}
+
+func (mbr *mockBookingRepository) FindAllBookRealms(includeArchived bool) ([]model.BookRealmEntity, model.TokyError) {
+	bookRealms := make([]model.BookRealmEntity, 0, len(mbr.bookRealms))
+	for _, bookRealm := range mbr.bookRealms {
+		if includeArchived || bookRealm.ArchivedAt == nil {
+			bookRealms = append(bookRealms, bookRealm)
+		}
+	}
+	return bookRealms, nil
+}
+
+func (mbr *mockBookingRepository) FindApplicationUsersByID(userIDs []string) ([]model.ApplicationUserEntity, model.TokyError) {
+	users := make([]model.ApplicationUserEntity, 0, len(userIDs))
+	for _, user := range mbr.users {
+		for _, userID := range userIDs {
+			if user.ID == userID {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (mbr *mockBookingRepository) FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError) {
+	for _, user := range mbr.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return model.ApplicationUserEntity{}, model.CreateBusinessErrorNotFound("Not found user", errors.New("No User present"))
+}
+
+func (mbr *mockBookingRepository) FindBookRealmByID(bookID uint) (model.BookRealmEntity, model.TokyError) {
+	for _, bookRealm := range mbr.bookRealms {
+		if bookRealm.ID == bookID {
+			return bookRealm, nil
+		}
+	}
+	return model.BookRealmEntity{}, model.CreateBusinessErrorNotFound("Not found book", errors.New("No Book present"))
+}
+
+func (mbr *mockBookingRepository) PersistBookRealm(bookRealm *model.BookRealmEntity, events ...model.DomainEvent) model.TokyError {
+	mbr.events = append(mbr.events, events...)
+	bookRealm.ID = uint(len(mbr.bookRealms) + 1)
+	mbr.bookRealms = append(mbr.bookRealms, *bookRealm)
+	return nil
+}
+
+func (mbr *mockBookingRepository) DeleteBookRealmByID(bookID uint, events ...model.DomainEvent) model.TokyError {
+	mbr.events = append(mbr.events, events...)
+	mbr.purgedBookIDs = append(mbr.purgedBookIDs, bookID)
+	return nil
+}
+
+func (mbr *mockBookingRepository) UpdateBookRealmArchivedAt(bookID uint, archivedAt *time.Time, events ...model.DomainEvent) model.TokyError {
+	mbr.events = append(mbr.events, events...)
+	for i, bookRealm := range mbr.bookRealms {
+		if bookRealm.ID == bookID {
+			mbr.bookRealms[i].ArchivedAt = archivedAt
+		}
+	}
+	return nil
+}
+
+func (mbr *mockBookingRepository) FindAccountsByBookId(bookID uint) ([]model.AccountTableEntity, model.TokyError) {
+	return mbr.accounts, nil
+}
+
+func (mbr *mockBookingRepository) FindBookingsByBookId(bookID uint) ([]model.BookingEntity, model.TokyError) {
+	return mbr.bookings, nil
+}
+
+func (mbr *mockBookingRepository) FindInvoiceSettingsByBookId(bookID uint) (model.InvoiceSettingsEntity, model.TokyError) {
+	return model.InvoiceSettingsEntity{BookRealmEntityID: bookID, CreditorName: "Verein", Currency: "CHF"}, nil
+}
+
+func (mbr *mockBookingRepository) PersistImportedBookRealm(bookRealm *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity, settings *model.InvoiceSettingsEntity, invitations []model.BookInvitationEntity, events ...model.DomainEvent) model.TokyError {
+	mbr.events = append(mbr.events, events...)
+	bookRealm.ID = uint(len(mbr.bookRealms) + 1)
+	mbr.bookRealms = append(mbr.bookRealms, *bookRealm)
+	mbr.clonedAccounts = accounts
+	mbr.clonedBookings = bookings
+	mbr.clonedSettings = settings
+	return nil
+}
+
+func (mbr *mockBookingRepository) UpdateBookRealm(bookRealm *model.BookRealmEntity, events ...model.DomainEvent) model.TokyError {
+	mbr.events = append(mbr.events, events...)
+	for i, existing := range mbr.bookRealms {
+		if existing.ID == bookRealm.ID {
+			mbr.bookRealms[i] = *bookRealm
+		}
+	}
+	return nil
+}
+
+type mockBookArchiveRepository struct {
+	bookRealms          []model.BookRealmEntity
+	accounts            []model.AccountTableEntity
+	bookings            []model.BookingEntity
+	users               []model.ApplicationUserEntity
+	importedBook        model.BookRealmEntity
+	importedAccounts    []model.AccountTableEntity
+	importedBookings    []model.BookingEntity
+	importedSettings    *model.InvoiceSettingsEntity
+	importedInvitations []model.BookInvitationEntity
+	importedEvents      []model.DomainEvent
+}
+
+func (mbar *mockBookArchiveRepository) FindBookRealmByID(bookID uint) (model.BookRealmEntity, model.TokyError) {
+	for _, bookRealm := range mbar.bookRealms {
+		if bookRealm.ID == bookID {
+			return bookRealm, nil
+		}
+	}
+	return model.BookRealmEntity{}, model.CreateBusinessErrorNotFound("Not found book", errors.New("No Book present"))
+}
+
+func (mbar *mockBookArchiveRepository) FindAccountsByBookId(bookID uint) ([]model.AccountTableEntity, model.TokyError) {
+	return mbar.accounts, nil
+}
+
+func (mbar *mockBookArchiveRepository) FindBookingsByBookId(bookID uint) ([]model.BookingEntity, model.TokyError) {
+	return mbar.bookings, nil
+}
+
+func (mbar *mockBookArchiveRepository) FindInvoiceSettingsByBookId(bookID uint) (model.InvoiceSettingsEntity, model.TokyError) {
+	return model.InvoiceSettingsEntity{}, model.CreateBusinessErrorNotFound("Not found settings", errors.New("No Settings present"))
+}
+
+func (mbar *mockBookArchiveRepository) FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError) {
+	for _, user := range mbar.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return model.ApplicationUserEntity{}, model.CreateBusinessErrorNotFound("Not found user", errors.New("No User present"))
+}
+
+func (mbar *mockBookArchiveRepository) FindAllApplicationUsersByUserName(userName string) (model.ApplicationUserEntity, model.TokyError) {
+	for _, user := range mbar.users {
+		if user.UserName == userName {
+			return user, nil
+		}
+	}
+	return model.ApplicationUserEntity{}, model.CreateBusinessErrorNotFound("Not found user", errors.New("No User present"))
+}
+
+func (mbar *mockBookArchiveRepository) PersistImportedBookRealm(bookRealm *model.BookRealmEntity, accounts []model.AccountTableEntity, bookings []model.BookingEntity, settings *model.InvoiceSettingsEntity, invitations []model.BookInvitationEntity, events ...model.DomainEvent) model.TokyError {
+	bookRealm.ID = 99
+	mbar.importedBook = *bookRealm
+	mbar.importedInvitations = invitations
+	mbar.importedAccounts = accounts
+	mbar.importedBookings = bookings
+	mbar.importedSettings = settings
+	mbar.importedEvents = events
+	return nil
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+const (
+	outboxDispatchBatch = 100
+	// outboxSettleDelay holds events back until most transactions which obtained a smaller event id have committed
+	outboxSettleDelay = 5 * time.Second
+	// outboxGapExpiry is how long a skipped event id is watched, a transaction still open after it is assumed rolled back
+	outboxGapExpiry = time.Hour
+)
+
+type outboxRepository interface {
+	FindOutboxEventsAfter(eventID uint, createdBefore time.Time, limit int) ([]model.OutboxEventEntity, model.TokyError)
+	FindOutboxEventsByIDs(eventIDs []uint) ([]model.OutboxEventEntity, model.TokyError)
+	FindOutboxCursor(sink string) (model.OutboxCursorEntity, model.TokyError)
+	FindOutboxGaps(sink string) ([]model.OutboxGapEntity, model.TokyError)
+	SaveOutboxCursor(cursor *model.OutboxCursorEntity, openedGaps []model.OutboxGapEntity, closedGapIDs []uint) model.TokyError
+}
+
+// EventSink receives the domain events of the outbox in the order of their ids. Every sink keeps its own
+// position, an event is delivered at least once and consumers recognize a repeated event by its id.
+// An event whose transaction committed after the sink passed its id is delivered late, after events with higher ids.
+type EventSink interface {
+	Name() string
+	Deliver(event model.DomainEventDTO) model.TokyError
+}
+
+type outboxDispatcherImpl struct {
+	outboxRepository outboxRepository
+	sinks            []EventSink
+}
+
+func CreateOutboxDispatcher(repository outboxRepository, sinks ...EventSink) *outboxDispatcherImpl {
+	return &outboxDispatcherImpl{
+		outboxRepository: repository,
+		sinks:            sinks,
+	}
+}
+
+// DispatchEvents hands the events written until the given time to every sink and returns the number of deliveries.
+// A sink that fails to take an event stays at its position and receives the event again on the next dispatch.
+func (d *outboxDispatcherImpl) DispatchEvents(now time.Time) (int, model.TokyError) {
+	dispatched := 0
+	for _, sink := range d.sinks {
+		delivered, err := d.dispatchToSink(sink, now)
+		dispatched += delivered
+		if model.IsExisting(err) {
+			return dispatched, err
+		}
+	}
+	return dispatched, nil
+}
+
+// dispatchToSink first delivers the events which appeared in the gaps of the sink and then the events after its cursor.
+// The ids the cursor passes without an event become gaps, as their transactions may still commit.
+func (d *outboxDispatcherImpl) dispatchToSink(sink EventSink, now time.Time) (int, model.TokyError) {
+	cursor, err := d.outboxRepository.FindOutboxCursor(sink.Name())
+	if model.IsExisting(err) {
+		return 0, err
+	}
+	delivered, closedGapIDs, complete, err := d.deliverLateEvents(sink, now)
+	if model.IsExisting(err) {
+		return 0, err
+	}
+	var openedGaps []model.OutboxGapEntity
+	if complete {
+		events, err := d.outboxRepository.FindOutboxEventsAfter(cursor.LastEventID, now.Add(-outboxSettleDelay), outboxDispatchBatch)
+		if model.IsExisting(err) {
+			return 0, err
+		}
+		for _, event := range events {
+			if !deliverEvent(sink, event) {
+				break
+			}
+			openedGaps = append(openedGaps, skippedEventIDs(sink.Name(), cursor.LastEventID, event.ID)...)
+			cursor.LastEventID = event.ID
+			delivered++
+		}
+	}
+	if delivered == 0 && len(closedGapIDs) == 0 {
+		return 0, nil
+	}
+	return delivered, d.outboxRepository.SaveOutboxCursor(&cursor, openedGaps, closedGapIDs)
+}
+
+// deliverLateEvents delivers the events which appeared in the gaps of the sink and returns the gaps to close.
+// Expired gaps are closed without an event. complete is false if the sink refused an event.
+func (d *outboxDispatcherImpl) deliverLateEvents(sink EventSink, now time.Time) (delivered int, closedGapIDs []uint, complete bool, err model.TokyError) {
+	gaps, err := d.outboxRepository.FindOutboxGaps(sink.Name())
+	if model.IsExisting(err) {
+		return 0, nil, false, err
+	}
+	if len(gaps) == 0 {
+		return 0, nil, true, nil
+	}
+	eventIDs := make([]uint, 0, len(gaps))
+	for _, gap := range gaps {
+		eventIDs = append(eventIDs, gap.EventID)
+	}
+	events, err := d.outboxRepository.FindOutboxEventsByIDs(eventIDs)
+	if model.IsExisting(err) {
+		return 0, nil, false, err
+	}
+	lateEvents := make(map[uint]model.OutboxEventEntity, len(events))
+	for _, event := range events {
+		lateEvents[event.ID] = event
+	}
+	expiredBefore := now.Add(-outboxGapExpiry)
+	for _, gap := range gaps {
+		event, found := lateEvents[gap.EventID]
+		if found {
+			if !deliverEvent(sink, event) {
+				return delivered, closedGapIDs, false, nil
+			}
+			delivered++
+		}
+		if found || gap.CreatedAt.Before(expiredBefore) {
+			closedGapIDs = append(closedGapIDs, gap.ID)
+		}
+	}
+	return delivered, closedGapIDs, true, nil
+}
+
+func deliverEvent(sink EventSink, event model.OutboxEventEntity) bool {
+	if deliverErr := sink.Deliver(event.ToDomainEventDTO()); model.IsExisting(deliverErr) {
+		log.Printf("Could not deliver event %d to %s: %s", event.ID, sink.Name(), deliverErr.ErrorMessage())
+		return false
+	}
+	return true
+}
+
+// skippedEventIDs returns the gaps between the cursor and the next event found. A new sink starts at the first
+// event found and does not watch the ids before it.
+func skippedEventIDs(sink string, lastEventID, nextEventID uint) []model.OutboxGapEntity {
+	if lastEventID == 0 {
+		return nil
+	}
+	gaps := make([]model.OutboxGapEntity, 0, nextEventID-lastEventID-1)
+	for eventID := lastEventID + 1; eventID < nextEventID; eventID++ {
+		gaps = append(gaps, model.OutboxGapEntity{Sink: sink, EventID: eventID})
+	}
+	return gaps
+}
+
+// RunEventDispatch dispatches the outbox immediately and then periodically in the given interval
+func (d *outboxDispatcherImpl) RunEventDispatch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		dispatched, err := d.DispatchEvents(time.Now().UTC())
+		if model.IsExisting(err) {
+			log.Printf("Could not dispatch events: %s", err.ErrorMessage())
+		} else if dispatched > 0 {
+			log.Printf("Dispatched %d events", dispatched)
+		}
+		<-ticker.C
+	}
+}
+
+// channelSinkImpl hands the events to consumers within the service. A full channel fails the delivery
+// so that a slow consumer delays its own events without blocking the other sinks.
+type channelSinkImpl struct {
+	name   string
+	events chan model.DomainEventDTO
+}
+
+func CreateChannelSink(name string, buffer int) *channelSinkImpl {
+	return &channelSinkImpl{
+		name:   name,
+		events: make(chan model.DomainEventDTO, buffer),
+	}
+}
+
+func (s *channelSinkImpl) Name() string {
+	return s.name
+}
+
+func (s *channelSinkImpl) Events() <-chan model.DomainEventDTO {
+	return s.events
+}
+
+func (s *channelSinkImpl) Deliver(event model.DomainEventDTO) model.TokyError {
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return model.CreateTechnicalError(fmt.Sprintf("Could not deliver Event %s", event.EventID), errors.New("channel is full"))
+	}
+}
+
+// fileStreamSinkImpl appends every event as json line to a file. The subject of a line follows the NATS convention
+// <prefix>.<bookId>.<event type>, so the stream can be filtered by book and replayed into a message broker.
+type fileStreamSinkImpl struct {
+	path          string
+	subjectPrefix string
+}
+
+func CreateFileStreamSink(path, subjectPrefix string) *fileStreamSinkImpl {
+	return &fileStreamSinkImpl{
+		path:          path,
+		subjectPrefix: strings.TrimSuffix(subjectPrefix, "."),
+	}
+}
+
+func (s *fileStreamSinkImpl) Name() string {
+	return "stream:" + s.path
+}
+
+func (s *fileStreamSinkImpl) Deliver(event model.DomainEventDTO) model.TokyError {
+	line, jsonErr := json.Marshal(model.EventStreamMessageDTO{
+		Subject: fmt.Sprintf("%s.%s.%s", s.subjectPrefix, event.BookID, event.Type),
+		Event:   event,
+	})
+	if jsonErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not serialize Event %s", event.EventID), jsonErr)
+	}
+	file, openErr := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if openErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not open Event Stream %s", s.path), openErr)
+	}
+	_, writeErr := file.Write(append(line, '\n'))
+	if closeErr := file.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not write Event %s to Stream %s", event.EventID, s.path), writeErr)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type mockOutboxRepository struct {
+	events  []model.OutboxEventEntity
+	cursors map[string]model.OutboxCursorEntity
+	gaps    []model.OutboxGapEntity
+}
+
+func (mor *mockOutboxRepository) FindOutboxEventsAfter(eventID uint, createdBefore time.Time, limit int) ([]model.OutboxEventEntity, model.TokyError) {
+	events := []model.OutboxEventEntity{}
+	for _, event := range mor.events {
+		if event.ID > eventID && event.CreatedAt.Before(createdBefore) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (mor *mockOutboxRepository) FindOutboxEventsByIDs(eventIDs []uint) ([]model.OutboxEventEntity, model.TokyError) {
+	events := []model.OutboxEventEntity{}
+	for _, event := range mor.events {
+		for _, eventID := range eventIDs {
+			if event.ID == eventID {
+				events = append(events, event)
+			}
+		}
+	}
+	return events, nil
+}
+
+func (mor *mockOutboxRepository) FindOutboxGaps(sink string) ([]model.OutboxGapEntity, model.TokyError) {
+	gaps := []model.OutboxGapEntity{}
+	for _, gap := range mor.gaps {
+		if gap.Sink == sink {
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps, nil
+}
+
+func (mor *mockOutboxRepository) FindOutboxCursor(sink string) (model.OutboxCursorEntity, model.TokyError) {
+	cursor := mor.cursors[sink]
+	cursor.Sink = sink
+	return cursor, nil
+}
+
+func (mor *mockOutboxRepository) SaveOutboxCursor(cursor *model.OutboxCursorEntity, openedGaps []model.OutboxGapEntity, closedGapIDs []uint) model.TokyError {
+	mor.cursors[cursor.Sink] = *cursor
+	gaps := []model.OutboxGapEntity{}
+	for _, gap := range mor.gaps {
+		if !slices.Contains(closedGapIDs, gap.ID) {
+			gaps = append(gaps, gap)
+		}
+	}
+	for _, gap := range openedGaps {
+		gap.ID = gap.EventID
+		gap.CreatedAt = time.Now().UTC()
+		gaps = append(gaps, gap)
+	}
+	mor.gaps = gaps
+	return nil
+}
+
+// failingSink takes the given number of events and refuses every further one
+type failingSink struct {
+	accepts   int
+	delivered []model.DomainEventDTO
+}
+
+func (fs *failingSink) Name() string {
+	return "failing"
+}
+
+func (fs *failingSink) Deliver(event model.DomainEventDTO) model.TokyError {
+	if len(fs.delivered) >= fs.accepts {
+		return model.CreateTechnicalError("sink unavailable", errors.New("unavailable"))
+	}
+	fs.delivered = append(fs.delivered, event)
+	return nil
+}
+
+func createOutboxRepository(createdAt time.Time, eventTypes ...types.DomainEventType) *mockOutboxRepository {
+	repository := &mockOutboxRepository{cursors: map[string]model.OutboxCursorEntity{}}
+	for i, eventType := range eventTypes {
+		repository.events = append(repository.events, model.OutboxEventEntity{
+			Model:             gorm.Model{ID: uint(i + 1), CreatedAt: createdAt},
+			BookRealmEntityID: 1,
+			EventType:         eventType,
+			Payload:           `{"bookingId":"1"}`,
+		})
+	}
+	return repository
+}
+
+func Test_outboxDispatcherImpl_DispatchEvents(t *testing.T) {
+	now := time.Now().UTC()
+	repository := createOutboxRepository(now.Add(-time.Minute), types.DomainEventBookingCreated, types.DomainEventBookingUpdated, types.DomainEventBookingDeleted)
+	repository.events = append(repository.events, model.OutboxEventEntity{Model: gorm.Model{ID: 4, CreatedAt: now}, BookRealmEntityID: 1, EventType: types.DomainEventAccountCreated})
+	channel := CreateChannelSink("channel", 10)
+	failing := &failingSink{accepts: 1}
+
+	dispatched, err := CreateOutboxDispatcher(repository, failing, channel).DispatchEvents(now)
+	if model.IsExisting(err) {
+		t.Fatalf("DispatchEvents() error = %v", err)
+	}
+	if dispatched != 4 {
+		t.Errorf("dispatched = %d, want 4", dispatched)
+	}
+	if cursor := repository.cursors["channel"]; cursor.LastEventID != 3 {
+		t.Errorf("channel cursor = %d, want 3 as the newest event has not settled", cursor.LastEventID)
+	}
+	if cursor := repository.cursors["failing"]; cursor.LastEventID != 1 {
+		t.Errorf("failing cursor = %d, want 1", cursor.LastEventID)
+	}
+	for _, wantType := range []types.DomainEventType{types.DomainEventBookingCreated, types.DomainEventBookingUpdated, types.DomainEventBookingDeleted} {
+		event := <-channel.Events()
+		if event.Type != wantType || event.BookID != "1" || string(event.Data) != `{"bookingId":"1"}` {
+			t.Errorf("event = %v, want %s of book 1", event, wantType)
+		}
+	}
+
+	// the failing sink receives the refused event again, the other sinks continue after their position
+	failing.accepts = 10
+	if _, err := CreateOutboxDispatcher(repository, failing, channel).DispatchEvents(now.Add(time.Minute)); model.IsExisting(err) {
+		t.Fatalf("DispatchEvents() error = %v", err)
+	}
+	if len(failing.delivered) != 4 || failing.delivered[1].EventID != "2" {
+		t.Errorf("failing sink received %v, want the events 1 to 4 exactly once", failing.delivered)
+	}
+	if event := <-channel.Events(); event.EventID != "4" || len(channel.Events()) != 0 {
+		t.Errorf("channel sink received %v, want only event 4", event)
+	}
+}
+
+func Test_outboxDispatcherImpl_DispatchLateEvents(t *testing.T) {
+	now := time.Now().UTC()
+	repository := createOutboxRepository(now.Add(-time.Minute), types.DomainEventBookingCreated, types.DomainEventBookingUpdated,
+		types.DomainEventBookingDeleted, types.DomainEventAccountCreated, types.DomainEventAccountDeleted)
+	// the transactions of the events 2 and 4 have not committed yet
+	lateEvents := []model.OutboxEventEntity{repository.events[1], repository.events[3]}
+	repository.events = []model.OutboxEventEntity{repository.events[0], repository.events[2], repository.events[4]}
+	channel := CreateChannelSink("channel", 10)
+	dispatcher := CreateOutboxDispatcher(repository, channel)
+
+	if dispatched, err := dispatcher.DispatchEvents(now); model.IsExisting(err) || dispatched != 3 {
+		t.Fatalf("DispatchEvents() = %d, %v, want the 3 committed events", dispatched, err)
+	}
+	if len(repository.gaps) != 2 || repository.gaps[0].EventID != 2 || repository.gaps[1].EventID != 4 {
+		t.Fatalf("gaps = %+v, want the skipped events 2 and 4", repository.gaps)
+	}
+
+	// event 2 commits late and is delivered, the gap of event 4 expires as its transaction was rolled back
+	repository.events = append(repository.events, lateEvents[0])
+	if dispatched, err := dispatcher.DispatchEvents(now.Add(time.Minute)); model.IsExisting(err) || dispatched != 1 {
+		t.Fatalf("DispatchEvents() = %d, %v, want the late event", dispatched, err)
+	}
+	if _, err := dispatcher.DispatchEvents(now.Add(2 * outboxGapExpiry)); model.IsExisting(err) || len(repository.gaps) != 0 {
+		t.Errorf("gaps = %+v, %v, want the gap of the rolled back event expired", repository.gaps, err)
+	}
+	for _, wantID := range []string{"1", "3", "5", "2"} {
+		if event := <-channel.Events(); event.EventID != wantID {
+			t.Errorf("event = %s, want %s", event.EventID, wantID)
+		}
+	}
+	if cursor := repository.cursors["channel"]; cursor.LastEventID != 5 || len(channel.Events()) != 0 {
+		t.Errorf("channel cursor = %d with %d more events, want 5 and no more events", cursor.LastEventID, len(channel.Events()))
+	}
+}
+
+func Test_channelSinkImpl_DeliverFull(t *testing.T) {
+	sink := CreateChannelSink("channel", 1)
+	if err := sink.Deliver(model.DomainEventDTO{EventID: "1"}); model.IsExisting(err) {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if err := sink.Deliver(model.DomainEventDTO{EventID: "2"}); !model.IsExisting(err) {
+		t.Errorf("expected an error when the channel is full")
+	}
+}
+
+func Test_fileStreamSinkImpl_Deliver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink := CreateFileStreamSink(path, "toky.book.")
+	for _, event := range []model.DomainEventDTO{
+		{EventID: "1", Type: types.DomainEventBookCreated, BookID: "3", Data: json.RawMessage(`{"bookId":"3"}`)},
+		{EventID: "2", Type: types.DomainEventBookingCreated, BookID: "3", Data: json.RawMessage(`{"bookingId":"9"}`)},
+	} {
+		if err := sink.Deliver(event); model.IsExisting(err) {
+			t.Fatalf("Deliver() error = %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open stream: %v", err)
+	}
+	defer file.Close()
+	messages := []model.EventStreamMessageDTO{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message model.EventStreamMessageDTO
+		if jsonErr := json.Unmarshal(scanner.Bytes(), &message); jsonErr != nil {
+			t.Fatalf("could not read line %s: %v", scanner.Text(), jsonErr)
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(messages))
+	}
+	if messages[0].Subject != "toky.book.3.book.created" || messages[1].Subject != "toky.book.3.booking.created" {
+		t.Errorf("unexpected subjects %s and %s", messages[0].Subject, messages[1].Subject)
+	}
+	if messages[1].Event.EventID != "2" || string(messages[1].Event.Data) != `{"bookingId":"9"}` {
+		t.Errorf("unexpected event %v", messages[1].Event)
+	}
+}
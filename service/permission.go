@@ -0,0 +1,29 @@
+package service
+
+import (
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+var rolePermissions = map[types.BookRole][]types.Permission{
+	types.BookRoleViewer:         {types.PermissionRead},
+	types.BookRoleBookkeeper:     {types.PermissionRead, types.PermissionBook},
+	types.BookRoleAccountManager: {types.PermissionRead, types.PermissionBook, types.PermissionManageAccounts},
+	types.BookRoleApprover:       {types.PermissionRead, types.PermissionApprove},
+	types.BookRoleAdmin: {types.PermissionRead, types.PermissionBook, types.PermissionManageAccounts,
+		types.PermissionApprove, types.PermissionAdminister},
+}
+
+func isKnownRole(role types.BookRole) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// roleGrants checks the permission matrix for the given role
+func roleGrants(role types.BookRole, permission types.Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
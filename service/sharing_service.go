@@ -0,0 +1,221 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+)
+
+const invitationValidity = 14 * 24 * time.Hour
+
+type sharingRepository interface {
+	FindBookRealmByID(bookID uint) (model.BookRealmEntity, model.TokyError)
+	FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError)
+	FindRoleAssignment(bookID uint, userID string) (model.BookRoleAssignmentEntity, model.TokyError)
+	DeleteRoleAssignment(bookID uint, userID string) model.TokyError
+	PersistInvitation(invitation *model.BookInvitationEntity) model.TokyError
+	FindPendingInvitationsByBookId(bookID uint) ([]model.BookInvitationEntity, model.TokyError)
+	FindPendingInvitationsByEMail(email string) ([]model.BookInvitationEntity, model.TokyError)
+	FindInvitationByToken(token string) (model.BookInvitationEntity, model.TokyError)
+	DeleteInvitation(bookID, invitationID uint) model.TokyError
+	AcceptInvitation(invitation model.BookInvitationEntity, userID string) model.TokyError
+	UpdatePendingOwner(bookID uint, pendingOwnerID string) model.TokyError
+	TransferOwnership(bookID uint, previousOwnerID, newOwnerID string) model.TokyError
+}
+
+type sharingServiceImpl struct {
+	sharingRepository sharingRepository
+}
+
+func CreateSharingService(repository sharingRepository) *sharingServiceImpl {
+	return &sharingServiceImpl{
+		sharingRepository: repository,
+	}
+}
+
+// CreateInvitation creates an invitation link for the book. If an email is given only the user
+// with this email can accept it, otherwise everybody who knows the link.
+func (s *sharingServiceImpl) CreateInvitation(bookID, userID string, invitation model.BookInvitationDTO) (model.BookInvitationDTO, model.TokyError) {
+	bookRealm, err := s.readBook(bookID)
+	if model.IsExisting(err) {
+		return model.BookInvitationDTO{}, err
+	}
+	if !isKnownRole(invitation.Role) {
+		return model.BookInvitationDTO{}, createValidationError(fmt.Sprintf("Unknown Role %s", invitation.Role))
+	}
+	email := strings.TrimSpace(invitation.EMail)
+	if email != "" && !strings.Contains(email, "@") {
+		return model.BookInvitationDTO{}, createValidationError(fmt.Sprintf("%s is not a valid email address", email))
+	}
+	token, tokenErr := createInvitationToken()
+	if tokenErr != nil {
+		return model.BookInvitationDTO{}, model.CreateTechnicalError("Could not create Invitation Token", tokenErr)
+	}
+	invitationEntity := model.BookInvitationEntity{
+		BookRealmEntityID: bookRealm.ID,
+		BookRealmEntity:   bookRealm,
+		Token:             token,
+		EMail:             email,
+		Role:              invitation.Role,
+		InvitedByID:       userID,
+		ExpiresAt:         time.Now().Add(invitationValidity),
+	}
+	err = s.sharingRepository.PersistInvitation(&invitationEntity)
+	if model.IsExisting(err) {
+		return model.BookInvitationDTO{}, err
+	}
+	return invitationEntity.ToBookInvitationDTO(), nil
+}
+
+func (s *sharingServiceImpl) ReadInvitations(bookID string) ([]model.BookInvitationDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	invitationEntities, err := s.sharingRepository.FindPendingInvitationsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	return toInvitationDTOs(invitationEntities), nil
+}
+
+// ReadInvitationsOfUser returns the pending invitations addressed to the email of the user
+func (s *sharingServiceImpl) ReadInvitationsOfUser(userID string) ([]model.BookInvitationDTO, model.TokyError) {
+	user, err := s.sharingRepository.FindApplicationUserByID(userID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	invitationEntities, err := s.sharingRepository.FindPendingInvitationsByEMail(user.EMail)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	return toInvitationDTOs(invitationEntities), nil
+}
+
+func (s *sharingServiceImpl) RevokeInvitation(bookID, invitationID string) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	invitationIDUint, convErr := bookingutils.StringToUint(invitationID)
+	if convErr != nil {
+		return model.CreateBusinessError(fmt.Sprintf("Could not read Invitation Id: %s", invitationID), convErr)
+	}
+	return s.sharingRepository.DeleteInvitation(bookIDUint, invitationIDUint)
+}
+
+func (s *sharingServiceImpl) AcceptInvitation(token, userID string) model.TokyError {
+	invitation, err := s.sharingRepository.FindInvitationByToken(token)
+	if model.IsExisting(err) {
+		return err
+	}
+	if invitation.EMail != "" {
+		user, err := s.sharingRepository.FindApplicationUserByID(userID)
+		if model.IsExisting(err) {
+			return err
+		}
+		if !strings.EqualFold(user.EMail, invitation.EMail) {
+			return model.CreateBusinessErrorNotFound("No Invitation found", errors.New("invitation is addressed to another user"))
+		}
+	}
+	if invitation.AcceptedByID != "" {
+		return model.CreateBusinessError("Einladung wurde bereits angenommen", errors.New("invitation is already accepted"))
+	}
+	if time.Now().After(invitation.ExpiresAt) {
+		return model.CreateBusinessError("Einladung ist abgelaufen", errors.New("invitation is expired"))
+	}
+	if invitation.BookRealmEntity.OwnerID == userID {
+		return model.CreateBusinessError("Besitzer des Buches kann keine Einladung annehmen", errors.New("user is owner of the book"))
+	}
+	return s.sharingRepository.AcceptInvitation(invitation, userID)
+}
+
+// LeaveBook removes the role of the user from the book. The owner has to transfer the ownership first.
+func (s *sharingServiceImpl) LeaveBook(bookID, userID string) model.TokyError {
+	bookRealm, err := s.readBook(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if bookRealm.OwnerID == userID {
+		return model.CreateBusinessError("Besitzer muss das Buch zuerst übertragen bevor er es verlassen kann", errors.New("owner can not leave the book"))
+	}
+	if _, err := s.sharingRepository.FindRoleAssignment(bookRealm.ID, userID); model.IsExisting(err) {
+		return err
+	}
+	return s.sharingRepository.DeleteRoleAssignment(bookRealm.ID, userID)
+}
+
+// RequestOwnershipTransfer offers the book to a new owner, who has to accept the transfer
+func (s *sharingServiceImpl) RequestOwnershipTransfer(bookID, userID string, transfer model.OwnershipTransferDTO) model.TokyError {
+	bookRealm, err := s.readOwnedBook(bookID, userID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if transfer.NewOwnerID == bookRealm.OwnerID {
+		return createValidationError("New Owner must be different from the current Owner")
+	}
+	if _, err := s.sharingRepository.FindApplicationUserByID(transfer.NewOwnerID); model.IsExisting(err) {
+		return err
+	}
+	return s.sharingRepository.UpdatePendingOwner(bookRealm.ID, transfer.NewOwnerID)
+}
+
+func (s *sharingServiceImpl) CancelOwnershipTransfer(bookID, userID string) model.TokyError {
+	bookRealm, err := s.readOwnedBook(bookID, userID)
+	if model.IsExisting(err) {
+		return err
+	}
+	return s.sharingRepository.UpdatePendingOwner(bookRealm.ID, "")
+}
+
+func (s *sharingServiceImpl) AcceptOwnershipTransfer(bookID, userID string) model.TokyError {
+	bookRealm, err := s.readBook(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if bookRealm.PendingOwnerID == "" || bookRealm.PendingOwnerID != userID {
+		return model.CreateBusinessErrorNotFound(fmt.Sprintf("No pending Ownership Transfer for Book %s", bookID), errors.New("no pending transfer"))
+	}
+	return s.sharingRepository.TransferOwnership(bookRealm.ID, bookRealm.OwnerID, userID)
+}
+
+func (s *sharingServiceImpl) readBook(bookID string) (model.BookRealmEntity, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.BookRealmEntity{}, err
+	}
+	return s.sharingRepository.FindBookRealmByID(bookIDUint)
+}
+
+func (s *sharingServiceImpl) readOwnedBook(bookID, userID string) (model.BookRealmEntity, model.TokyError) {
+	bookRealm, err := s.readBook(bookID)
+	if model.IsExisting(err) {
+		return model.BookRealmEntity{}, err
+	}
+	if bookRealm.OwnerID != userID {
+		return model.BookRealmEntity{}, model.CreateBusinessError("Nur der Besitzer kann das Buch übertragen", errors.New("user is not the owner"))
+	}
+	return bookRealm, nil
+}
+
+func createInvitationToken() (string, error) {
+	randomBytes := make([]byte, 24)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+func toInvitationDTOs(invitationEntities []model.BookInvitationEntity) []model.BookInvitationDTO {
+	invitations := make([]model.BookInvitationDTO, 0, len(invitationEntities))
+	for _, invitationEntity := range invitationEntities {
+		invitations = append(invitations, invitationEntity.ToBookInvitationDTO())
+	}
+	return invitations
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createSharingRepository() *mockSharingRepository {
+	return &mockSharingRepository{
+		bookRealms: []model.BookRealmEntity{{Model: gorm.Model{ID: 1}, BookName: "Verein", OwnerID: "owner"}},
+		users: []model.ApplicationUserEntity{
+			{ID: "owner", EMail: "owner@example.com"},
+			{ID: "anna", EMail: "anna@example.com"},
+			{ID: "ben", EMail: "ben@example.com"},
+		},
+		roleAssignments: []model.BookRoleAssignmentEntity{
+			{BookRealmEntityID: 1, ApplicationUserEntityID: "ben", Role: types.BookRoleViewer},
+		},
+	}
+}
+
+func Test_sharingServiceImpl_AcceptInvitation(t *testing.T) {
+	tests := []struct {
+		name       string
+		invitation model.BookInvitationEntity
+		userID     string
+		wantErr    bool
+		wantRole   types.BookRole
+	}{
+		{"link invitation", model.BookInvitationEntity{Role: types.BookRoleBookkeeper}, "anna", false, types.BookRoleBookkeeper},
+		{"email invitation", model.BookInvitationEntity{EMail: "Anna@Example.com", Role: types.BookRoleViewer}, "anna", false, types.BookRoleViewer},
+		{"email invitation of other user", model.BookInvitationEntity{EMail: "ben@example.com", Role: types.BookRoleViewer}, "anna", true, ""},
+		{"existing member gets new role", model.BookInvitationEntity{Role: types.BookRoleAdmin}, "ben", false, types.BookRoleAdmin},
+		{"already accepted", model.BookInvitationEntity{Role: types.BookRoleViewer, AcceptedByID: "ben"}, "anna", true, ""},
+		{"expired", model.BookInvitationEntity{Role: types.BookRoleViewer, ExpiresAt: time.Now().Add(-time.Hour)}, "anna", true, ""},
+		{"owner", model.BookInvitationEntity{Role: types.BookRoleViewer}, "owner", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createSharingRepository()
+			s := CreateSharingService(repository)
+			invitation := tt.invitation
+			invitation.BookRealmEntityID = 1
+			invitation.BookRealmEntity = repository.bookRealms[0]
+			invitation.Token = "token"
+			if invitation.ExpiresAt.IsZero() {
+				invitation.ExpiresAt = time.Now().Add(time.Hour)
+			}
+			repository.PersistInvitation(&invitation)
+
+			err := s.AcceptInvitation("token", tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AcceptInvitation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			roleAssignment, _ := repository.FindRoleAssignment(1, tt.userID)
+			if roleAssignment.Role != tt.wantRole {
+				t.Errorf("AcceptInvitation() role = %v, want %v", roleAssignment.Role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func Test_sharingServiceImpl_CreateInvitation(t *testing.T) {
+	repository := createSharingRepository()
+	s := CreateSharingService(repository)
+
+	if _, err := s.CreateInvitation("1", "owner", model.BookInvitationDTO{Role: "superuser"}); err == nil {
+		t.Errorf("CreateInvitation() with unknown role must fail")
+	}
+	invitation, err := s.CreateInvitation("1", "owner", model.BookInvitationDTO{EMail: " anna@example.com ", Role: types.BookRoleViewer})
+	if err != nil {
+		t.Errorf("CreateInvitation() error = %v", err)
+		return
+	}
+	if invitation.Token == "" || invitation.EMail != "anna@example.com" || invitation.BookName != "Verein" {
+		t.Errorf("CreateInvitation() = %+v", invitation)
+	}
+	pending, _ := s.ReadInvitationsOfUser("anna")
+	if len(pending) != 1 || pending[0].Token != invitation.Token {
+		t.Errorf("ReadInvitationsOfUser() = %+v", pending)
+	}
+}
+
+func Test_sharingServiceImpl_OwnershipTransfer(t *testing.T) {
+	repository := createSharingRepository()
+	s := CreateSharingService(repository)
+
+	if err := s.RequestOwnershipTransfer("1", "ben", model.OwnershipTransferDTO{NewOwnerID: "ben"}); err == nil {
+		t.Errorf("RequestOwnershipTransfer() by a non owner must fail")
+	}
+	if err := s.RequestOwnershipTransfer("1", "owner", model.OwnershipTransferDTO{NewOwnerID: "ben"}); err != nil {
+		t.Errorf("RequestOwnershipTransfer() error = %v", err)
+		return
+	}
+	if err := s.AcceptOwnershipTransfer("1", "anna"); !model.IsExistingNotFoundError(err) {
+		t.Errorf("AcceptOwnershipTransfer() by another user = %v, want not found", err)
+	}
+	if err := s.AcceptOwnershipTransfer("1", "ben"); err != nil {
+		t.Errorf("AcceptOwnershipTransfer() error = %v", err)
+		return
+	}
+	bookRealm := repository.bookRealms[0]
+	if bookRealm.OwnerID != "ben" || bookRealm.PendingOwnerID != "" {
+		t.Errorf("AcceptOwnershipTransfer() book = %+v", bookRealm)
+	}
+	previousOwner, err := repository.FindRoleAssignment(1, "owner")
+	if err != nil || previousOwner.Role != types.BookRoleAdmin {
+		t.Errorf("previous owner role = %v, %v, want admin", previousOwner.Role, err)
+	}
+	if _, err := repository.FindRoleAssignment(1, "ben"); err == nil {
+		t.Errorf("new owner must not keep a role assignment")
+	}
+}
+
+func Test_sharingServiceImpl_LeaveBook(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		wantErr bool
+	}{
+		{"member leaves", "ben", false},
+		{"owner can not leave", "owner", true},
+		{"non member", "anna", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createSharingRepository()
+			s := CreateSharingService(repository)
+			err := s.LeaveBook("1", tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LeaveBook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(repository.roleAssignments) != 0 {
+				t.Errorf("LeaveBook() left role assignments %+v", repository.roleAssignments)
+			}
+		})
+	}
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type subledgerRepository interface {
+	FindBusinessPartnersByBookId(uint) ([]model.BusinessPartnerEntity, model.TokyError)
+	FindBusinessPartnerByID(uint) (model.BusinessPartnerEntity, model.TokyError)
+	PersistBusinessPartner(model.BusinessPartnerEntity) model.TokyError
+	UpdateBusinessPartner(*model.BusinessPartnerEntity) model.TokyError
+	FindOpenItemsByBookId(uint) ([]model.OpenItemEntity, model.TokyError)
+	FindOpenItemByID(uint) (model.OpenItemEntity, model.TokyError)
+	PersistOpenItem(model.OpenItemEntity) model.TokyError
+	PersistOpenItemPayment(model.OpenItemPaymentEntity) model.TokyError
+	FindOpenItemPaymentsByBookingID(uint) ([]model.OpenItemPaymentEntity, model.TokyError)
+	FindAccountByID(id uint) (model.AccountTableEntity, model.TokyError)
+	FindBookingByID(uint) (model.BookingEntity, model.TokyError)
+}
+
+type subledgerServiceImpl struct {
+	subledgerRepository subledgerRepository
+}
+
+func CreateSubledgerService(repository subledgerRepository) *subledgerServiceImpl {
+	return &subledgerServiceImpl{
+		subledgerRepository: repository,
+	}
+}
+
+func (s *subledgerServiceImpl) ReadBusinessPartners(bookID string) ([]model.BusinessPartnerDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	partnerEntities, err := s.subledgerRepository.FindBusinessPartnersByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	partnerDTOs := make([]model.BusinessPartnerDTO, 0, len(partnerEntities))
+	for _, partnerEntity := range partnerEntities {
+		partnerDTOs = append(partnerDTOs, partnerEntity.ToBusinessPartnerDTO())
+	}
+	return partnerDTOs, nil
+}
+
+func (s *subledgerServiceImpl) CreateBusinessPartner(bookID string, partner model.BusinessPartnerDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	account, err := s.readPartnerAccount(bookIDUint, partner)
+	if model.IsExisting(err) {
+		return err
+	}
+	return s.subledgerRepository.PersistBusinessPartner(partner.ToBusinessPartnerEntity(bookIDUint, account.ID))
+}
+
+func (s *subledgerServiceImpl) UpdateBusinessPartner(bookID, partnerID string, partner model.BusinessPartnerDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	partnerEntity, err := s.readBusinessPartner(bookIDUint, partnerID)
+	if model.IsExisting(err) {
+		return err
+	}
+	account, err := s.readPartnerAccount(bookIDUint, partner)
+	if model.IsExisting(err) {
+		return err
+	}
+	updatedPartner := partner.ToBusinessPartnerEntity(bookIDUint, account.ID)
+	updatedPartner.Model = partnerEntity.Model
+	return s.subledgerRepository.UpdateBusinessPartner(&updatedPartner)
+}
+
+// readPartnerAccount validates the partner and reads its debtor or creditor account.
+// Customers need an active working capital account (receivables),
+// suppliers a passive borrowed capital account (payables).
+func (s *subledgerServiceImpl) readPartnerAccount(bookID uint, partner model.BusinessPartnerDTO) (model.AccountTableEntity, model.TokyError) {
+	if strings.TrimSpace(partner.Name) == "" {
+		return model.AccountTableEntity{}, createValidationError("Name of a business partner must not be empty")
+	}
+	if partner.PartnerType != types.PartnerTypeCustomer && partner.PartnerType != types.PartnerTypeSupplier {
+		return model.AccountTableEntity{}, createValidationError("PartnerType must be 'customer' or 'supplier'")
+	}
+	account, err := s.readAccountOfBook(bookID, partner.AccountID)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	if partner.PartnerType == types.PartnerTypeCustomer &&
+		(account.Category != types.AccountCategoryActive || account.SubCategory != types.AccountSubCategoryWorkingCapital) {
+		return model.AccountTableEntity{}, createValidationError("Account of a customer must be an active working capital account")
+	}
+	if partner.PartnerType == types.PartnerTypeSupplier &&
+		(account.Category != types.AccountCategoryPassive || account.SubCategory != types.AccountSubCategoryBorrowedCapital) {
+		return model.AccountTableEntity{}, createValidationError("Account of a supplier must be a passive borrowed capital account")
+	}
+	return account, nil
+}
+
+func (s *subledgerServiceImpl) readAccountOfBook(bookID uint, accountID string) (model.AccountTableEntity, model.TokyError) {
+	accountIDUint, convErr := bookingutils.StringToUint(accountID)
+	if convErr != nil {
+		return model.AccountTableEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Account Id %s as AccountId", accountID), convErr)
+	}
+	account, err := s.subledgerRepository.FindAccountByID(accountIDUint)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	if account.BookRealmEntityID != bookID {
+		return model.AccountTableEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Account with Id %s found in Book", accountID), errors.New("account belongs to another book"))
+	}
+	return account, nil
+}
+
+func (s *subledgerServiceImpl) readBusinessPartner(bookID uint, partnerID string) (model.BusinessPartnerEntity, model.TokyError) {
+	partnerIDUint, convErr := bookingutils.StringToUint(partnerID)
+	if convErr != nil {
+		return model.BusinessPartnerEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Partner Id %s", partnerID), convErr)
+	}
+	partner, err := s.subledgerRepository.FindBusinessPartnerByID(partnerIDUint)
+	if model.IsExisting(err) {
+		return model.BusinessPartnerEntity{}, err
+	}
+	if partner.BookRealmEntityID != bookID {
+		return model.BusinessPartnerEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Business Partner with Id %s found in Book", partnerID), errors.New("partner belongs to another book"))
+	}
+	return partner, nil
+}
+
+func (s *subledgerServiceImpl) readBookingOfBook(bookID uint, bookingID string) (model.BookingEntity, model.TokyError) {
+	bookingIDUint, convErr := bookingutils.StringToUint(bookingID)
+	if convErr != nil {
+		return model.BookingEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Booking Id %s", bookingID), convErr)
+	}
+	booking, err := s.subledgerRepository.FindBookingByID(bookingIDUint)
+	if model.IsExisting(err) {
+		return model.BookingEntity{}, err
+	}
+	account, err := s.subledgerRepository.FindAccountByID(booking.HabenBookingAccountID)
+	if model.IsExisting(err) {
+		return model.BookingEntity{}, err
+	}
+	if account.BookRealmEntityID != bookID {
+		return model.BookingEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Booking with Id %s found in Book", bookingID), errors.New("booking belongs to another book"))
+	}
+	return booking, nil
+}
+
+// ReadOpenItems returns the open items of a book, optionally restricted to a partner and to items which are not fully paid
+func (s *subledgerServiceImpl) ReadOpenItems(bookID, partnerID string, onlyOpen bool) ([]model.OpenItemDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	openItemEntities, err := s.subledgerRepository.FindOpenItemsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	openItemDTOs := make([]model.OpenItemDTO, 0, len(openItemEntities))
+	for _, openItemEntity := range openItemEntities {
+		if partnerID != "" && bookingutils.UintToString(openItemEntity.BusinessPartnerEntityID) != partnerID {
+			continue
+		}
+		openItemDTO, err := convertOpenItemEntityToDTO(openItemEntity)
+		if model.IsExisting(err) {
+			return nil, err
+		}
+		if onlyOpen && openItemDTO.Status == types.OpenItemStatusPaid {
+			continue
+		}
+		openItemDTOs = append(openItemDTOs, openItemDTO)
+	}
+	return openItemDTOs, nil
+}
+
+// CreateOpenItem registers a booking on the debtor or creditor account of a partner as open item.
+// For customers the booking must debit the receivables account, for suppliers it must credit the payables account.
+func (s *subledgerServiceImpl) CreateOpenItem(bookID string, openItem model.OpenItemDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	partner, err := s.readBusinessPartner(bookIDUint, openItem.PartnerID)
+	if model.IsExisting(err) {
+		return err
+	}
+	booking, err := s.readBookingOfBook(bookIDUint, openItem.BookingID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if invoiceAccountID(partner, booking) != partner.AccountTableEntityID {
+		return createValidationError("Booking of an open item must be booked on the account of the business partner")
+	}
+	dueDate, convErr := readDueDate(openItem.DueDate, booking.Date)
+	if convErr != nil {
+		return model.CreateBusinessValidationError(fmt.Sprintf("Could not read due date %s", openItem.DueDate), convErr)
+	}
+	return s.subledgerRepository.PersistOpenItem(model.OpenItemEntity{
+		BookRealmEntityID:       bookIDUint,
+		BusinessPartnerEntityID: partner.ID,
+		BookingEntityID:         booking.ID,
+		Reference:               strings.TrimSpace(openItem.Reference),
+		DueDate:                 dueDate,
+		Ammount:                 booking.Ammount,
+	})
+}
+
+// CreatePayment matches a payment booking against an open item. Partial payments are allowed
+// as long as neither the open amount of the item nor the unmatched amount of the booking is exceeded.
+// The repository checks both amounts again while it holds a lock on the open item and the booking.
+func (s *subledgerServiceImpl) CreatePayment(bookID, openItemID string, payment model.OpenItemPaymentDTO) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	openItem, err := s.readOpenItem(bookIDUint, openItemID)
+	if model.IsExisting(err) {
+		return err
+	}
+	booking, err := s.readBookingOfBook(bookIDUint, payment.BookingID)
+	if model.IsExisting(err) {
+		return err
+	}
+	if paymentAccountID(openItem.BusinessPartnerEntity, booking) != openItem.BusinessPartnerEntity.AccountTableEntityID {
+		return createValidationError("Payment must be booked against the account of the business partner")
+	}
+	openAmmount, convErr := calculateOpenAmmount(openItem)
+	if convErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not calculate open amount of open item %s", openItemID), convErr)
+	}
+	unmatchedAmmount, err := s.calculateUnmatchedAmmount(booking)
+	if model.IsExisting(err) {
+		return err
+	}
+	ammount := math.Min(openAmmount, unmatchedAmmount)
+	if strings.TrimSpace(payment.Ammount) != "" {
+		requestedAmmount, convErr := bookingutils.StrToFloat(strings.TrimSpace(payment.Ammount))
+		if convErr != nil || requestedAmmount <= 0 {
+			return createValidationError("payment Ammount must be a positive number")
+		}
+		ammount = requestedAmmount
+	}
+	if bookingutils.AlmostZero(ammount) {
+		return createValidationError("Open item is already paid or the booking is already fully matched")
+	}
+	if ammount > openAmmount+0.005 {
+		return createValidationError(fmt.Sprintf("Payment exceeds the open amount of %s", bookingutils.FormatFloatToAmmount(openAmmount)))
+	}
+	if ammount > unmatchedAmmount+0.005 {
+		return createValidationError(fmt.Sprintf("Payment exceeds the unmatched amount of the booking of %s", bookingutils.FormatFloatToAmmount(unmatchedAmmount)))
+	}
+	return s.subledgerRepository.PersistOpenItemPayment(model.OpenItemPaymentEntity{
+		OpenItemEntityID: openItem.ID,
+		BookingEntityID:  booking.ID,
+		Ammount:          bookingutils.FormatFloatToAmmount(ammount),
+	})
+}
+
+func (s *subledgerServiceImpl) readOpenItem(bookID uint, openItemID string) (model.OpenItemEntity, model.TokyError) {
+	openItemIDUint, convErr := bookingutils.StringToUint(openItemID)
+	if convErr != nil {
+		return model.OpenItemEntity{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not format Open Item Id %s", openItemID), convErr)
+	}
+	openItem, err := s.subledgerRepository.FindOpenItemByID(openItemIDUint)
+	if model.IsExisting(err) {
+		return model.OpenItemEntity{}, err
+	}
+	if openItem.BookRealmEntityID != bookID {
+		return model.OpenItemEntity{}, model.CreateBusinessErrorNotFound(fmt.Sprintf("No Open Item with Id %s found in Book", openItemID), errors.New("open item belongs to another book"))
+	}
+	return openItem, nil
+}
+
+func (s *subledgerServiceImpl) calculateUnmatchedAmmount(booking model.BookingEntity) (float64, model.TokyError) {
+	bookingAmmount, convErr := bookingutils.StrToFloat(booking.Ammount)
+	if convErr != nil {
+		return 0, model.CreateTechnicalError(fmt.Sprintf("Could not parse Ammount %s of booking %d", booking.Ammount, booking.ID), convErr)
+	}
+	payments, err := s.subledgerRepository.FindOpenItemPaymentsByBookingID(booking.ID)
+	if model.IsExisting(err) {
+		return 0, err
+	}
+	matchedAmmount, convErr := sumPayments(payments)
+	if convErr != nil {
+		return 0, model.CreateTechnicalError(fmt.Sprintf("Could not sum up payments of booking %d", booking.ID), convErr)
+	}
+	return bookingAmmount - matchedAmmount, nil
+}
+
+// ReadAgingReport groups the open amounts per partner by the number of days they are overdue at the report date
+func (s *subledgerServiceImpl) ReadAgingReport(bookID, reportDate string) (model.AgingReportDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.AgingReportDTO{}, err
+	}
+	date := time.Now().UTC()
+	if strings.TrimSpace(reportDate) != "" {
+		parsedDate, convErr := bookingutils.ParseDate(reportDate)
+		if convErr != nil {
+			return model.AgingReportDTO{}, model.CreateBusinessValidationError(fmt.Sprintf("Could not read report date %s", reportDate), convErr)
+		}
+		date = parsedDate
+	}
+	openItems, err := s.subledgerRepository.FindOpenItemsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return model.AgingReportDTO{}, err
+	}
+	report, convErr := calculateAgingReport(openItems, date)
+	if convErr != nil {
+		return model.AgingReportDTO{}, model.CreateTechnicalError("Could not calculate aging report", convErr)
+	}
+	return report, nil
+}
+
+type agingBuckets [6]float64
+
+const (
+	agingNotDue = iota
+	aging1To30
+	aging31To60
+	aging61To90
+	agingOver90
+	agingTotal
+)
+
+func calculateAgingReport(openItems []model.OpenItemEntity, reportDate time.Time) (model.AgingReportDTO, error) {
+	bucketsPerPartner := map[uint]*agingBuckets{}
+	partners := map[uint]model.BusinessPartnerEntity{}
+	total := agingBuckets{}
+	for _, openItem := range openItems {
+		openAmmount, err := calculateOpenAmmount(openItem)
+		if err != nil {
+			return model.AgingReportDTO{}, err
+		}
+		if bookingutils.AlmostZero(openAmmount) {
+			continue
+		}
+		dueDate, err := bookingutils.ParseDate(openItem.DueDate)
+		if err != nil {
+			return model.AgingReportDTO{}, err
+		}
+		bucket := agingBucket(int(reportDate.Sub(dueDate).Hours() / 24))
+		if _, ok := bucketsPerPartner[openItem.BusinessPartnerEntityID]; !ok {
+			bucketsPerPartner[openItem.BusinessPartnerEntityID] = &agingBuckets{}
+			partners[openItem.BusinessPartnerEntityID] = openItem.BusinessPartnerEntity
+		}
+		bucketsPerPartner[openItem.BusinessPartnerEntityID][bucket] += openAmmount
+		bucketsPerPartner[openItem.BusinessPartnerEntityID][agingTotal] += openAmmount
+		total[bucket] += openAmmount
+		total[agingTotal] += openAmmount
+	}
+	entries := make([]model.AgingReportEntryDTO, 0, len(bucketsPerPartner))
+	for partnerID, buckets := range bucketsPerPartner {
+		entry := buckets.toEntry()
+		entry.PartnerID = bookingutils.UintToString(partnerID)
+		entry.PartnerName = partners[partnerID].Name
+		entry.PartnerType = partners[partnerID].PartnerType
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].PartnerName < entries[j].PartnerName
+	})
+	return model.AgingReportDTO{
+		ReportDate: reportDate.Format(bookingutils.DateLayout),
+		Entries:    entries,
+		Total:      total.toEntry(),
+	}, nil
+}
+
+func agingBucket(daysOverdue int) int {
+	switch {
+	case daysOverdue <= 0:
+		return agingNotDue
+	case daysOverdue <= 30:
+		return aging1To30
+	case daysOverdue <= 60:
+		return aging31To60
+	case daysOverdue <= 90:
+		return aging61To90
+	default:
+		return agingOver90
+	}
+}
+
+func (buckets agingBuckets) toEntry() model.AgingReportEntryDTO {
+	return model.AgingReportEntryDTO{
+		NotDue:     bookingutils.FormatFloatToAmmount(buckets[agingNotDue]),
+		Days1To30:  bookingutils.FormatFloatToAmmount(buckets[aging1To30]),
+		Days31To60: bookingutils.FormatFloatToAmmount(buckets[aging31To60]),
+		Days61To90: bookingutils.FormatFloatToAmmount(buckets[aging61To90]),
+		Over90:     bookingutils.FormatFloatToAmmount(buckets[agingOver90]),
+		Total:      bookingutils.FormatFloatToAmmount(buckets[agingTotal]),
+	}
+}
+
+// invoiceAccountID returns the account of the booking on which an invoice of the partner is booked
+func invoiceAccountID(partner model.BusinessPartnerEntity, booking model.BookingEntity) uint {
+	if partner.PartnerType == types.PartnerTypeCustomer {
+		return booking.SollBookingAccountID
+	}
+	return booking.HabenBookingAccountID
+}
+
+// paymentAccountID returns the account of the booking on which a payment of the partner is booked
+func paymentAccountID(partner model.BusinessPartnerEntity, booking model.BookingEntity) uint {
+	if partner.PartnerType == types.PartnerTypeCustomer {
+		return booking.HabenBookingAccountID
+	}
+	return booking.SollBookingAccountID
+}
+
+func readDueDate(dueDate, bookingDate string) (string, error) {
+	date := dueDate
+	if strings.TrimSpace(date) == "" {
+		date = bookingDate
+	}
+	parsedDate, err := bookingutils.ParseDate(date)
+	if err != nil {
+		return "", err
+	}
+	return parsedDate.Format(bookingutils.DateLayout), nil
+}
+
+func sumPayments(payments []model.OpenItemPaymentEntity) (float64, error) {
+	sum := 0.0
+	for _, payment := range payments {
+		ammount, err := bookingutils.StrToFloat(payment.Ammount)
+		if err != nil {
+			return 0, err
+		}
+		sum += ammount
+	}
+	return sum, nil
+}
+
+func calculateOpenAmmount(openItem model.OpenItemEntity) (float64, error) {
+	ammount, err := bookingutils.StrToFloat(openItem.Ammount)
+	if err != nil {
+		return 0, err
+	}
+	paidAmmount, err := sumPayments(openItem.Payments)
+	if err != nil {
+		return 0, err
+	}
+	return ammount - paidAmmount, nil
+}
+
+func convertOpenItemEntityToDTO(openItem model.OpenItemEntity) (model.OpenItemDTO, model.TokyError) {
+	openAmmount, err := calculateOpenAmmount(openItem)
+	if err != nil {
+		return model.OpenItemDTO{}, model.CreateTechnicalError(fmt.Sprintf("Could not calculate open amount of open item %d", openItem.ID), err)
+	}
+	status := types.OpenItemStatusOpen
+	if bookingutils.AlmostZero(openAmmount) {
+		status = types.OpenItemStatusPaid
+	} else if len(openItem.Payments) > 0 {
+		status = types.OpenItemStatusPartiallyPaid
+	}
+	payments := make([]model.OpenItemPaymentDTO, 0, len(openItem.Payments))
+	for _, payment := range openItem.Payments {
+		payments = append(payments, payment.ToOpenItemPaymentDTO())
+	}
+	return model.OpenItemDTO{
+		OpenItemID:  bookingutils.UintToString(openItem.ID),
+		PartnerID:   bookingutils.UintToString(openItem.BusinessPartnerEntityID),
+		PartnerName: openItem.BusinessPartnerEntity.Name,
+		BookingID:   bookingutils.UintToString(openItem.BookingEntityID),
+		Reference:   openItem.Reference,
+		Date:        openItem.BookingEntity.Date,
+		DueDate:     openItem.DueDate,
+		Ammount:     openItem.Ammount,
+		OpenAmmount: bookingutils.FormatFloatToAmmount(openAmmount),
+		Status:      status,
+		Payments:    payments,
+	}, nil
+}
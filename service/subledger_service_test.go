@@ -0,0 +1,166 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createSubledgerFixture() *mockSubledgerRepository {
+	repository := CreateMockSubledgerRepository()
+	repository.accounts = []model.AccountTableEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, AccountName: "Debitoren", Type: types.AccountTypeInventory,
+			Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+		{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, AccountName: "Mitgliederbeiträge", Type: types.AccountTypeIncome,
+			Category: types.AccountCategoryGain},
+		{Model: gorm.Model{ID: 3}, BookRealmEntityID: 1, AccountName: "Bank", Type: types.AccountTypeInventory,
+			Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+		{Model: gorm.Model{ID: 4}, BookRealmEntityID: 2, AccountName: "Debitoren anderes Buch", Type: types.AccountTypeInventory,
+			Category: types.AccountCategoryActive, SubCategory: types.AccountSubCategoryWorkingCapital},
+	}
+	repository.bookings = []model.BookingEntity{
+		{Model: gorm.Model{ID: 10}, Date: "2024-01-15", SollBookingAccountID: 1, HabenBookingAccountID: 2, Ammount: "100"},
+		{Model: gorm.Model{ID: 11}, Date: "2024-02-01", SollBookingAccountID: 3, HabenBookingAccountID: 1, Ammount: "40"},
+		{Model: gorm.Model{ID: 12}, Date: "2024-02-20", SollBookingAccountID: 3, HabenBookingAccountID: 1, Ammount: "80"},
+	}
+	repository.partners = []model.BusinessPartnerEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, Name: "Muster", PartnerType: types.PartnerTypeCustomer, AccountTableEntityID: 1},
+	}
+	return repository
+}
+
+func Test_subledgerServiceImpl_CreateBusinessPartner(t *testing.T) {
+	tests := []struct {
+		name    string
+		partner model.BusinessPartnerDTO
+		wantErr bool
+	}{
+		{"customer with receivables account", model.BusinessPartnerDTO{Name: "Huber", PartnerType: types.PartnerTypeCustomer, AccountID: "1"}, false},
+		{"customer with income account", model.BusinessPartnerDTO{Name: "Huber", PartnerType: types.PartnerTypeCustomer, AccountID: "2"}, true},
+		{"supplier with receivables account", model.BusinessPartnerDTO{Name: "Huber", PartnerType: types.PartnerTypeSupplier, AccountID: "1"}, true},
+		{"account of another book", model.BusinessPartnerDTO{Name: "Huber", PartnerType: types.PartnerTypeCustomer, AccountID: "4"}, true},
+		{"missing name", model.BusinessPartnerDTO{PartnerType: types.PartnerTypeCustomer, AccountID: "1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := CreateSubledgerService(createSubledgerFixture())
+			err := s.CreateBusinessPartner("1", tt.partner)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateBusinessPartner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_subledgerServiceImpl_CreateOpenItem(t *testing.T) {
+	tests := []struct {
+		name      string
+		openItem  model.OpenItemDTO
+		wantErr   bool
+		wantDue   string
+		wantTotal string
+	}{
+		{"invoice booking", model.OpenItemDTO{PartnerID: "1", BookingID: "10", DueDate: "2024-02-14"}, false, "2024-02-14", "100"},
+		{"due date defaults to booking date", model.OpenItemDTO{PartnerID: "1", BookingID: "10"}, false, "2024-01-15", "100"},
+		{"payment booking is no invoice", model.OpenItemDTO{PartnerID: "1", BookingID: "11"}, true, "", ""},
+		{"unknown partner", model.OpenItemDTO{PartnerID: "9", BookingID: "10"}, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createSubledgerFixture()
+			s := CreateSubledgerService(repository)
+			err := s.CreateOpenItem("1", tt.openItem)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateOpenItem() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if repository.openItems[0].DueDate != tt.wantDue || repository.openItems[0].Ammount != tt.wantTotal {
+				t.Errorf("CreateOpenItem() persisted %+v", repository.openItems[0])
+			}
+		})
+	}
+}
+
+func Test_subledgerServiceImpl_CreatePayment(t *testing.T) {
+	repository := createSubledgerFixture()
+	repository.openItems = []model.OpenItemEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, BusinessPartnerEntityID: 1, BookingEntityID: 10, DueDate: "2024-02-14", Ammount: "100"},
+	}
+	s := CreateSubledgerService(repository)
+
+	if err := s.CreatePayment("1", "1", model.OpenItemPaymentDTO{BookingID: "10"}); err == nil {
+		t.Errorf("CreatePayment() invoice booking must not be accepted as payment")
+	}
+	if err := s.CreatePayment("1", "1", model.OpenItemPaymentDTO{BookingID: "11"}); err != nil {
+		t.Errorf("CreatePayment() partial payment error = %v", err)
+	}
+	openItems, _ := s.ReadOpenItems("1", "", false)
+	if openItems[0].Status != types.OpenItemStatusPartiallyPaid || openItems[0].OpenAmmount != "60.00" {
+		t.Errorf("ReadOpenItems() after partial payment got %+v", openItems[0])
+	}
+	if err := s.CreatePayment("1", "1", model.OpenItemPaymentDTO{BookingID: "12", Ammount: "70"}); err == nil {
+		t.Errorf("CreatePayment() payment exceeding the open amount must fail")
+	}
+	if err := s.CreatePayment("1", "1", model.OpenItemPaymentDTO{BookingID: "12"}); err != nil {
+		t.Errorf("CreatePayment() remaining payment error = %v", err)
+	}
+	if repository.payments[1].Ammount != "60.00" {
+		t.Errorf("CreatePayment() should only match the open amount, got %s", repository.payments[1].Ammount)
+	}
+	openItems, _ = s.ReadOpenItems("1", "", true)
+	if len(openItems) != 0 {
+		t.Errorf("ReadOpenItems() paid items should be filtered, got %+v", openItems)
+	}
+}
+
+func Test_calculateAgingReport(t *testing.T) {
+	partner := model.BusinessPartnerEntity{Model: gorm.Model{ID: 1}, Name: "Muster", PartnerType: types.PartnerTypeCustomer}
+	openItems := []model.OpenItemEntity{
+		{BusinessPartnerEntityID: 1, BusinessPartnerEntity: partner, DueDate: "2024-06-30", Ammount: "10"},
+		{BusinessPartnerEntityID: 1, BusinessPartnerEntity: partner, DueDate: "2024-06-01", Ammount: "20"},
+		{BusinessPartnerEntityID: 1, BusinessPartnerEntity: partner, DueDate: "2024-05-01", Ammount: "30"},
+		{BusinessPartnerEntityID: 1, BusinessPartnerEntity: partner, DueDate: "2024-04-10", Ammount: "40",
+			Payments: []model.OpenItemPaymentEntity{{Ammount: "15"}}},
+		{BusinessPartnerEntityID: 1, BusinessPartnerEntity: partner, DueDate: "2024-01-01", Ammount: "50"},
+		{BusinessPartnerEntityID: 1, BusinessPartnerEntity: partner, DueDate: "2024-01-01", Ammount: "60",
+			Payments: []model.OpenItemPaymentEntity{{Ammount: "60"}}},
+	}
+	got, err := calculateAgingReport(openItems, time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Errorf("calculateAgingReport() error = %v", err)
+		return
+	}
+	wantEntry := model.AgingReportEntryDTO{
+		PartnerID:   "1",
+		PartnerName: "Muster",
+		PartnerType: types.PartnerTypeCustomer,
+		NotDue:      "10.00",
+		Days1To30:   "20.00",
+		Days31To60:  "30.00",
+		Days61To90:  "25.00",
+		Over90:      "50.00",
+		Total:       "135.00",
+	}
+	want := model.AgingReportDTO{
+		ReportDate: "2024-06-30",
+		Entries:    []model.AgingReportEntryDTO{wantEntry},
+		Total: model.AgingReportEntryDTO{
+			NotDue:     "10.00",
+			Days1To30:  "20.00",
+			Days31To60: "30.00",
+			Days61To90: "25.00",
+			Over90:     "50.00",
+			Total:      "135.00",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("calculateAgingReport() got = \n%+v,\n want\n %+v", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+// CreateInterBookTransfer moves the amount from the source account of the book to the target account of the target book.
+// The book books the transfer against its clearing account for the target book and the target book against its clearing
+// account for the book. Both bookings are persisted in one transaction and linked to each other.
+func (s *accountingServiceImpl) CreateInterBookTransfer(
+	bookID string,
+	transfer model.InterBookTransferDTO,
+) (model.InterBookTransferResultDTO, model.TokyError) {
+	targetBookID := strings.TrimSpace(transfer.TargetBookID)
+	if targetBookID == bookID {
+		return model.InterBookTransferResultDTO{}, createValidationError("Source and target of a transfer must be different books")
+	}
+	if ammount, parseErr := bookingutils.StrToFloat(transfer.Ammount); parseErr != nil || ammount <= 0 {
+		return model.InterBookTransferResultDTO{}, createValidationError("Transfer Ammount must be a positive number")
+	}
+	sourceAccount, err := s.readAccountOfBook(bookID, transfer.SourceAccount)
+	if model.IsExisting(err) {
+		return model.InterBookTransferResultDTO{}, err
+	}
+	targetAccount, err := s.readAccountOfBook(targetBookID, transfer.TargetAccount)
+	if model.IsExisting(err) {
+		return model.InterBookTransferResultDTO{}, err
+	}
+	sourceClearingAccount, err := s.readClearingAccount(bookID, targetBookID)
+	if model.IsExisting(err) {
+		return model.InterBookTransferResultDTO{}, err
+	}
+	targetClearingAccount, err := s.readClearingAccount(targetBookID, bookID)
+	if model.IsExisting(err) {
+		return model.InterBookTransferResultDTO{}, err
+	}
+	date := model.BookingDTO{Date: transfer.Date}.ReadDateFormatted()
+	sourceBooking := model.BookingEntity{
+		Date:                  date,
+		SollBookingAccountID:  sourceClearingAccount.ID,
+		HabenBookingAccountID: sourceAccount.ID,
+		Ammount:               transfer.Ammount,
+		Description:           transfer.Description,
+		BookingType:           types.BookingTypeStandard,
+	}
+	targetBooking := model.BookingEntity{
+		Date:                  date,
+		SollBookingAccountID:  targetAccount.ID,
+		HabenBookingAccountID: targetClearingAccount.ID,
+		Ammount:               transfer.Ammount,
+		Description:           transfer.Description,
+		BookingType:           types.BookingTypeStandard,
+	}
+	err = s.AccountingRepository.PersistInterBookTransfer(&sourceBooking, &targetBooking,
+		bookingEvent(types.DomainEventBookingCreated, sourceAccount.BookRealmEntityID, &sourceBooking),
+		bookingEvent(types.DomainEventBookingCreated, targetAccount.BookRealmEntityID, &targetBooking),
+	)
+	if model.IsExisting(err) {
+		return model.InterBookTransferResultDTO{}, err
+	}
+	return model.InterBookTransferResultDTO{
+		SourceBooking: sourceBooking.ToBookingDTO(),
+		TargetBooking: targetBooking.ToBookingDTO(),
+	}, nil
+}
+
+// readClearingAccount reads the clearing account the book uses for transfers with the counterpart book
+func (s *accountingServiceImpl) readClearingAccount(bookID, counterpartBookID string) (model.AccountTableEntity, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	counterpartBookIDUint, err := readBookIDFromString(counterpartBookID)
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	clearingAccount, err := s.AccountingRepository.FindClearingAccount(bookIDUint, counterpartBookIDUint)
+	if model.IsExistingNotFoundError(err) {
+		return model.AccountTableEntity{}, model.CreateBusinessError(
+			fmt.Sprintf("Book %s has no Clearing Account for Book %s", bookID, counterpartBookID), err.Error())
+	}
+	if model.IsExisting(err) {
+		return model.AccountTableEntity{}, err
+	}
+	return s.readAccountOfBook(bookID, bookingutils.UintToString(clearingAccount.AccountTableEntityID))
+}
+
+func (s *accountingServiceImpl) ReadClearingAccounts(bookID string) ([]model.ClearingAccountDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	clearingAccounts, err := s.AccountingRepository.FindClearingAccountsByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	clearingAccountDTOs := make([]model.ClearingAccountDTO, 0, len(clearingAccounts))
+	for _, clearingAccount := range clearingAccounts {
+		clearingAccountDTOs = append(clearingAccountDTOs, model.ClearingAccountDTO{
+			CounterpartBookID: bookingutils.UintToString(clearingAccount.CounterpartBookID),
+			AccountID:         bookingutils.UintToString(clearingAccount.AccountTableEntityID),
+		})
+	}
+	return clearingAccountDTOs, nil
+}
+
+// SaveClearingAccount sets the account of the book against which transfers with the counterpart book are booked
+func (s *accountingServiceImpl) SaveClearingAccount(bookID, counterpartBookID string, clearingAccount model.ClearingAccountDTO) model.TokyError {
+	if counterpartBookID == bookID {
+		return createValidationError("A book can not have a Clearing Account for itself")
+	}
+	counterpartBookIDUint, err := readBookIDFromString(counterpartBookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	account, err := s.readAccountOfBook(bookID, clearingAccount.AccountID)
+	if model.IsExisting(err) {
+		return err
+	}
+	clearingAccountEntity := model.ClearingAccountEntity{
+		BookRealmEntityID:    account.BookRealmEntityID,
+		CounterpartBookID:    counterpartBookIDUint,
+		AccountTableEntityID: account.ID,
+	}
+	return s.AccountingRepository.SaveClearingAccount(&clearingAccountEntity, model.DomainEvent{
+		Type: types.DomainEventClearingAccountSaved,
+		Read: func() (uint, interface{}) {
+			return clearingAccountEntity.BookRealmEntityID, model.ClearingAccountDTO{
+				CounterpartBookID: counterpartBookID,
+				AccountID:         bookingutils.UintToString(clearingAccountEntity.AccountTableEntityID),
+			}
+		},
+	})
+}
+
+// ReadLinkedBookIdOfBooking returns the book of the other side of a transfer or an empty id if the booking is not linked
+func (s *accountingServiceImpl) ReadLinkedBookIdOfBooking(bookID, bookingID string) (string, model.TokyError) {
+	bookingEntity, err := s.readBookingOfBook(bookID, bookingID)
+	if model.IsExisting(err) {
+		return "", err
+	}
+	linkedBooking, linked, err := s.readLinkedBooking(bookingEntity)
+	if model.IsExisting(err) || !linked {
+		return "", err
+	}
+	linkedBookID, err := s.readBookIdOfBooking(linkedBooking)
+	if model.IsExisting(err) {
+		return "", err
+	}
+	return bookingutils.UintToString(linkedBookID), nil
+}
+
+// readBookIdOfBooking reads the book of a booking through its soll account
+func (s *accountingServiceImpl) readBookIdOfBooking(bookingEntity model.BookingEntity) (uint, model.TokyError) {
+	account, err := s.AccountingRepository.FindAccountByID(bookingEntity.SollBookingAccountID)
+	if model.IsExisting(err) {
+		return 0, err
+	}
+	return account.BookRealmEntityID, nil
+}
+
+// readLinkedBooking reads the other side of a transfer. A link to a booking that no longer exists,
+// for example because its book was purged, is treated as no link.
+func (s *accountingServiceImpl) readLinkedBooking(bookingEntity model.BookingEntity) (model.BookingEntity, bool, model.TokyError) {
+	if bookingEntity.LinkedBookingID == 0 {
+		return model.BookingEntity{}, false, nil
+	}
+	linkedBooking, err := s.AccountingRepository.FindBookingByID(bookingEntity.LinkedBookingID)
+	if model.IsExistingNotFoundError(err) {
+		return model.BookingEntity{}, false, nil
+	}
+	if model.IsExisting(err) {
+		return model.BookingEntity{}, false, err
+	}
+	return linkedBooking, true, nil
+}
+
+// propagateToLinkedBooking applies the date, ammount and description of the booking to the other side of the transfer
+func propagateToLinkedBooking(bookingEntity model.BookingEntity, linkedBooking *model.BookingEntity) model.TokyError {
+	if bookingEntity.BookingType != types.BookingTypeStandard {
+		return model.CreateBusinessError("Umbuchungen zwischen Büchern können nicht transitorisch gebucht werden", errors.New("transfer must be a standard booking"))
+	}
+	linkedBooking.Date = bookingEntity.Date
+	linkedBooking.Ammount = bookingEntity.Ammount
+	linkedBooking.Description = bookingEntity.Description
+	withdrawApproval(linkedBooking)
+	return nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func createTransferRepository() *mockAccountingRepository {
+	repository := CreateMockAccountingRepository()
+	repository.SetAccounts([]model.AccountTableEntity{
+		{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, AccountName: "Bank Sektion"},
+		{Model: gorm.Model{ID: 2}, BookRealmEntityID: 1, AccountName: "Verrechnung Dachverband"},
+		{Model: gorm.Model{ID: 11}, BookRealmEntityID: 2, AccountName: "Bank Dachverband"},
+		{Model: gorm.Model{ID: 12}, BookRealmEntityID: 2, AccountName: "Verrechnung Sektion"},
+	})
+	repository.clearingAccounts = []model.ClearingAccountEntity{
+		{BookRealmEntityID: 1, CounterpartBookID: 2, AccountTableEntityID: 2},
+		{BookRealmEntityID: 2, CounterpartBookID: 1, AccountTableEntityID: 12},
+	}
+	return repository
+}
+
+func createTransfer(t *testing.T, service *accountingServiceImpl) model.InterBookTransferResultDTO {
+	result, err := service.CreateInterBookTransfer("1", model.InterBookTransferDTO{
+		TargetBookID:  "2",
+		SourceAccount: "1",
+		TargetAccount: "11",
+		Ammount:       "50",
+		Date:          "2024-03-01",
+		Description:   "Beitrag",
+	})
+	if model.IsExisting(err) {
+		t.Fatalf("CreateInterBookTransfer() error = %v", err)
+	}
+	return result
+}
+
+func Test_accountingServiceImpl_CreateInterBookTransfer(t *testing.T) {
+	repository := createTransferRepository()
+	result := createTransfer(t, CreateAccountingService(repository))
+
+	if result.SourceBooking.SollAccount != "2" || result.SourceBooking.HabenAccount != "1" {
+		t.Errorf("source booking = %v, want soll clearing account 2 and haben account 1", result.SourceBooking)
+	}
+	if result.TargetBooking.SollAccount != "11" || result.TargetBooking.HabenAccount != "12" {
+		t.Errorf("target booking = %v, want soll account 11 and haben clearing account 12", result.TargetBooking)
+	}
+	if result.SourceBooking.LinkedBookingID != result.TargetBooking.BookingID || result.TargetBooking.LinkedBookingID != result.SourceBooking.BookingID {
+		t.Errorf("bookings are not linked: %v %v", result.SourceBooking, result.TargetBooking)
+	}
+	if len(repository.bookings) != 2 {
+		t.Errorf("expected 2 persisted bookings, got %d", len(repository.bookings))
+	}
+}
+
+func Test_accountingServiceImpl_CreateInterBookTransfer_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		transfer     model.InterBookTransferDTO
+		removeTarget bool
+	}{
+		{"same book", model.InterBookTransferDTO{TargetBookID: "1", SourceAccount: "1", TargetAccount: "2", Ammount: "50"}, false},
+		{"negative ammount", model.InterBookTransferDTO{TargetBookID: "2", SourceAccount: "1", TargetAccount: "11", Ammount: "-50"}, false},
+		{"target account of other book", model.InterBookTransferDTO{TargetBookID: "2", SourceAccount: "1", TargetAccount: "2", Ammount: "50"}, false},
+		{"missing clearing account", model.InterBookTransferDTO{TargetBookID: "2", SourceAccount: "1", TargetAccount: "11", Ammount: "50"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := createTransferRepository()
+			if tt.removeTarget {
+				repository.clearingAccounts = repository.clearingAccounts[:1]
+			}
+			_, err := CreateAccountingService(repository).CreateInterBookTransfer("1", tt.transfer)
+			if !model.IsExisting(err) {
+				t.Fatalf("CreateInterBookTransfer() expected error")
+			}
+			if len(repository.bookings) != 0 {
+				t.Errorf("expected no bookings, got %v", repository.bookings)
+			}
+		})
+	}
+}
+
+func Test_accountingServiceImpl_UpdateBooking_PropagatesToLinkedBooking(t *testing.T) {
+	repository := createTransferRepository()
+	service := CreateAccountingService(repository)
+	result := createTransfer(t, service)
+
+	err := service.UpdateBooking("2", result.TargetBooking.BookingID, model.BookingDTO{
+		SollAccount:  "11",
+		HabenAccount: "12",
+		Ammount:      "75",
+		Date:         "2024-03-05",
+		Description:  "Beitrag korrigiert",
+	})
+	if model.IsExisting(err) {
+		t.Fatalf("UpdateBooking() error = %v", err)
+	}
+	sourceBooking, _ := repository.FindBookingByID(1)
+	if sourceBooking.Ammount != "75" || sourceBooking.Date != "2024-03-05" || sourceBooking.Description != "Beitrag korrigiert" {
+		t.Errorf("linked booking was not updated: %v", sourceBooking)
+	}
+	if sourceBooking.SollBookingAccountID != 2 || sourceBooking.HabenBookingAccountID != 1 {
+		t.Errorf("accounts of the linked booking must not change: %v", sourceBooking)
+	}
+
+	err = service.UpdateBooking("1", result.SourceBooking.BookingID, model.BookingDTO{
+		SollAccount:  "2",
+		HabenAccount: "1",
+		Ammount:      "75",
+		Date:         "2024-03-05",
+		BookingType:  types.BookingTypeAccrual,
+	})
+	if !model.IsExisting(err) {
+		t.Errorf("UpdateBooking() expected error for accrual transfer")
+	}
+}
+
+func Test_accountingServiceImpl_DeleteBooking_DeletesLinkedBooking(t *testing.T) {
+	repository := createTransferRepository()
+	service := CreateAccountingService(repository)
+	result := createTransfer(t, service)
+
+	linkedBookID, err := service.ReadLinkedBookIdOfBooking("1", result.SourceBooking.BookingID)
+	if model.IsExisting(err) || linkedBookID != "2" {
+		t.Errorf("ReadLinkedBookIdOfBooking() = %v, %v, want 2", linkedBookID, err)
+	}
+	if err := service.DeleteBooking("1", result.SourceBooking.BookingID); model.IsExisting(err) {
+		t.Fatalf("DeleteBooking() error = %v", err)
+	}
+	if len(repository.bookings) != 0 {
+		t.Errorf("expected both bookings to be deleted, got %v", repository.bookings)
+	}
+}
+
+func Test_accountingServiceImpl_SaveClearingAccount(t *testing.T) {
+	repository := createTransferRepository()
+	repository.clearingAccounts = nil
+	service := CreateAccountingService(repository)
+
+	if err := service.SaveClearingAccount("1", "1", model.ClearingAccountDTO{AccountID: "2"}); !model.IsExisting(err) {
+		t.Errorf("SaveClearingAccount() expected error for the own book")
+	}
+	if err := service.SaveClearingAccount("1", "2", model.ClearingAccountDTO{AccountID: "12"}); !model.IsExistingNotFoundError(err) {
+		t.Errorf("SaveClearingAccount() expected not found for account of other book, got %v", err)
+	}
+	if err := service.SaveClearingAccount("1", "2", model.ClearingAccountDTO{AccountID: "1"}); model.IsExisting(err) {
+		t.Fatalf("SaveClearingAccount() error = %v", err)
+	}
+	if err := service.SaveClearingAccount("1", "2", model.ClearingAccountDTO{AccountID: "2"}); model.IsExisting(err) {
+		t.Fatalf("SaveClearingAccount() error = %v", err)
+	}
+	clearingAccounts, err := service.ReadClearingAccounts("1")
+	if model.IsExisting(err) {
+		t.Fatalf("ReadClearingAccounts() error = %v", err)
+	}
+	if len(clearingAccounts) != 1 || clearingAccounts[0] != (model.ClearingAccountDTO{CounterpartBookID: "2", AccountID: "2"}) {
+		t.Errorf("ReadClearingAccounts() = %v", clearingAccounts)
+	}
+}
@@ -3,13 +3,16 @@ package service
 import (
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/toky03/toky-finance-accounting-service/adapter"
 	"github.com/toky03/toky-finance-accounting-service/bookingutils"
 	"github.com/toky03/toky-finance-accounting-service/model"
 	"github.com/toky03/toky-finance-accounting-service/repository"
+	"github.com/toky03/toky-finance-accounting-service/types"
 )
 
 type UserRepository interface {
@@ -17,9 +20,13 @@ type UserRepository interface {
 	UpdateApplicationUser(model.ApplicationUserEntity) model.TokyError
 	FindAllApplicationUsersBySearchTerm(limit int, searchTerm string) ([]model.ApplicationUserEntity, model.TokyError)
 	FindAllApplicationUsers() ([]model.ApplicationUserEntity, model.TokyError)
-	DeleteUserWithAssociations(userId string) model.TokyError
+	DeleteUserWithAssociations(userId string, bookEvent model.BookEvent) model.TokyError
 	FindAllApplicationUsersByUserName(userName string) (model.ApplicationUserEntity, model.TokyError)
+	FindApplicationUserByID(userID string) (model.ApplicationUserEntity, model.TokyError)
 	FindBookRealmByID(bookingID uint) (bookRealm model.BookRealmEntity, err model.TokyError)
+	FindRoleAssignment(bookID uint, userID string) (model.BookRoleAssignmentEntity, model.TokyError)
+	SyncApplicationUsers(users []model.ApplicationUserEntity, revision int64, bookEvent model.BookEvent) (model.UserSyncReportDTO, model.TokyError)
+	PurgeOrphanedBookRealms(archivedBefore time.Time, bookEvent model.BookEvent) (int, model.TokyError)
 }
 
 type userBatchAdapter interface {
@@ -27,17 +34,30 @@ type userBatchAdapter interface {
 }
 
 type applicationUserServiceImpl struct {
-	userRepository   UserRepository
-	userBatchAdapter userBatchAdapter
+	userRepository      UserRepository
+	userBatchAdapter    userBatchAdapter
+	deletionGracePeriod time.Duration
 }
 
+const defaultDeletionGraceDays = 30
+
 func CreateApplicationUserService() *applicationUserServiceImpl {
 	return &applicationUserServiceImpl{
-		userRepository:   repository.CreateRepository(),
-		userBatchAdapter: adapter.CreateUserBatchAdapter(),
+		userRepository:      repository.CreateRepository(),
+		userBatchAdapter:    adapter.CreateUserBatchAdapter(),
+		deletionGracePeriod: readDeletionGracePeriod(),
 	}
 }
 
+// readDeletionGracePeriod reads USER_DELETION_GRACE_DAYS, the days an orphaned book is kept before it is purged
+func readDeletionGracePeriod() time.Duration {
+	graceDays, err := strconv.Atoi(os.Getenv("USER_DELETION_GRACE_DAYS"))
+	if err != nil || graceDays < 0 {
+		graceDays = defaultDeletionGraceDays
+	}
+	return time.Duration(graceDays) * 24 * time.Hour
+}
+
 func (s *applicationUserServiceImpl) CreateUser(applicationUser model.ApplicationUserDTO) model.TokyError {
 	applicationUserEntity := model.ApplicationUserEntity{
 		ID:        applicationUser.UserID,
@@ -62,13 +82,33 @@ func (s *applicationUserServiceImpl) UpdateUser(applicationUser model.Applicatio
 
 func (s *applicationUserServiceImpl) DeleteUser(userId string) model.TokyError {
 
-	err := s.userRepository.DeleteUserWithAssociations(userId)
+	err := s.userRepository.DeleteUserWithAssociations(userId, bookChangeEvent)
 	if model.IsExisting(err) {
 		return err
 	}
 	return model.BusinessError{}
 }
 
+// PurgeOrphanedBooks purges the books archived on the deletion of their owner once the grace period has passed
+func (s *applicationUserServiceImpl) PurgeOrphanedBooks(now time.Time) (int, model.TokyError) {
+	return s.userRepository.PurgeOrphanedBookRealms(now.Add(-s.deletionGracePeriod), bookChangeEvent)
+}
+
+// RunOrphanedBookPurge purges orphaned books immediately and then periodically in the given interval
+func (s *applicationUserServiceImpl) RunOrphanedBookPurge(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := s.PurgeOrphanedBooks(time.Now().UTC())
+		if model.IsExisting(err) {
+			log.Printf("Could not purge orphaned books: %s", err.ErrorMessage())
+		} else if purged > 0 {
+			log.Printf("Purged %d orphaned books", purged)
+		}
+		<-ticker.C
+	}
+}
+
 func (s *applicationUserServiceImpl) SearchUsers(limit, searchTerm string) ([]model.ApplicationUserDTO, model.TokyError) {
 	limitUint, err := strconv.Atoi(limit)
 	if err != nil {
@@ -115,6 +155,74 @@ func (s *applicationUserServiceImpl) FindUserByUsername(userName string) (model.
 	return mapApplicationUserEntityToDTO(applicationUserEntity), nil
 }
 
+// ProvisionUser creates the user of a token on its first request and keeps the name and email in sync afterwards.
+// An email missing in the token, e.g. because it is not verified, does not overwrite the stored one.
+func (s *applicationUserServiceImpl) ProvisionUser(applicationUser model.ApplicationUserDTO) (model.ApplicationUserDTO, model.TokyError) {
+	if applicationUser.UserID == "" || applicationUser.UserName == "" {
+		return model.ApplicationUserDTO{}, createValidationError("Token must contain sub and preferred_username")
+	}
+	existingUser, err := s.userRepository.FindApplicationUserByID(applicationUser.UserID)
+	if model.IsExistingNotFoundError(err) {
+		if applicationUser.EMail == "" {
+			return model.ApplicationUserDTO{}, createValidationError("Token must contain the verified email of a new user")
+		}
+		createErr := s.CreateUser(applicationUser)
+		if model.IsExisting(createErr) {
+			// a concurrent request of the same user might have created it in the meantime
+			existingUser, err = s.userRepository.FindApplicationUserByID(applicationUser.UserID)
+			if model.IsExisting(err) {
+				return model.ApplicationUserDTO{}, createErr
+			}
+			return mapApplicationUserEntityToDTO(existingUser), nil
+		}
+		log.Printf("Provisioned user %s", applicationUser.UserName)
+		return applicationUser, nil
+	}
+	if model.IsExisting(err) {
+		return model.ApplicationUserDTO{}, err
+	}
+	storedUser := mapApplicationUserEntityToDTO(existingUser)
+	if applicationUser.EMail == "" {
+		applicationUser.EMail = storedUser.EMail
+	}
+	if applicationUser == storedUser {
+		return storedUser, nil
+	}
+	if err := s.UpdateUser(applicationUser); model.IsExisting(err) {
+		return model.ApplicationUserDTO{}, err
+	}
+	return applicationUser, nil
+}
+
+// SyncUsers reconciles the stored users with the complete snapshot of the identity provider.
+// An empty snapshot is rejected, as it would delete every user.
+func (s *applicationUserServiceImpl) SyncUsers(applicationUsers []model.ApplicationUserDTO, revision int64) (model.UserSyncReportDTO, model.TokyError) {
+	if len(applicationUsers) == 0 {
+		return model.UserSyncReportDTO{}, createValidationError("User snapshot must not be empty")
+	}
+	userIDs := make(map[string]bool, len(applicationUsers))
+	eMails := make(map[string]bool, len(applicationUsers))
+	userEntities := make([]model.ApplicationUserEntity, 0, len(applicationUsers))
+	for _, applicationUser := range applicationUsers {
+		if applicationUser.UserID == "" || applicationUser.UserName == "" || applicationUser.EMail == "" {
+			return model.UserSyncReportDTO{}, createValidationError(fmt.Sprintf("User %s needs an id, username and email", applicationUser.UserName))
+		}
+		if userIDs[applicationUser.UserID] || eMails[applicationUser.EMail] {
+			return model.UserSyncReportDTO{}, createValidationError(fmt.Sprintf("User %s is not unique in the snapshot", applicationUser.UserName))
+		}
+		userIDs[applicationUser.UserID] = true
+		eMails[applicationUser.EMail] = true
+		userEntities = append(userEntities, model.ApplicationUserEntity{
+			ID:        applicationUser.UserID,
+			UserName:  applicationUser.UserName,
+			FirstName: applicationUser.FirstName,
+			LastName:  applicationUser.LastName,
+			EMail:     applicationUser.EMail,
+		})
+	}
+	return s.userRepository.SyncApplicationUsers(userEntities, revision, bookChangeEvent)
+}
+
 func (s *applicationUserServiceImpl) ReadAllUsers() ([]model.ApplicationUserDTO, model.TokyError) {
 	applicationUsersEntity, repoError := s.userRepository.FindAllApplicationUsers()
 	if repoError != nil {
@@ -127,20 +235,33 @@ func (s *applicationUserServiceImpl) ReadAllUsers() ([]model.ApplicationUserDTO,
 	return applicationUsersDto, nil
 }
 
-func (s *applicationUserServiceImpl) HasWriteAccessFromBook(userId, bookID string) (bool, model.TokyError) {
+// HasPermission checks the permission matrix for the role of the user in the given book.
+// The owner of a book always has all permissions.
+func (s *applicationUserServiceImpl) HasPermission(userId, bookID string, permission types.Permission) (bool, model.TokyError) {
 	bookRealm, err := s.readBook(bookID)
 	if model.IsExisting(err) {
 		return false, err
 	}
-	return bookRealm.OwnerID == userId || containsUser(bookRealm.WriteAccess, userId), nil
+	if bookRealm.OwnerID == userId {
+		return true, nil
+	}
+	roleAssignment, err := s.userRepository.FindRoleAssignment(bookRealm.ID, userId)
+	if model.IsExisting(err) {
+		if err.IsTechnicalError() {
+			return false, err
+		}
+		return false, nil
+	}
+	return roleGrants(roleAssignment.Role, permission), nil
 }
 
-func (s *applicationUserServiceImpl) IsOwnerOfBook(userId, bookId string) (bool, model.TokyError) {
-	bookRealm, err := s.readBook(bookId)
+// IsBookArchived reports whether the book is archived and therefore read-only
+func (s *applicationUserServiceImpl) IsBookArchived(bookID string) (bool, model.TokyError) {
+	bookRealm, err := s.readBook(bookID)
 	if model.IsExisting(err) {
 		return false, err
 	}
-	return bookRealm.OwnerID == userId, nil
+	return bookRealm.ArchivedAt != nil, nil
 }
 
 func (s *applicationUserServiceImpl) readBook(bookID string) (model.BookRealmEntity, model.TokyError) {
@@ -151,15 +272,6 @@ func (s *applicationUserServiceImpl) readBook(bookID string) (model.BookRealmEnt
 	return s.userRepository.FindBookRealmByID(bookIdUint)
 }
 
-func containsUser(writeApplicationUsers []*model.WriteApplicationUserWrapper, userId string) bool {
-	for _, writeApplicationUser := range writeApplicationUsers {
-		if writeApplicationUser.ApplicationUserEntityID == userId {
-			return true
-		}
-	}
-	return false
-}
-
 func mapApplicationUserEntityToDTO(entity model.ApplicationUserEntity) model.ApplicationUserDTO {
 	return model.ApplicationUserDTO{
 		UserID:    entity.ID,
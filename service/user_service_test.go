@@ -0,0 +1,161 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+func Test_applicationUserServiceImpl_HasPermission(t *testing.T) {
+	s := &applicationUserServiceImpl{
+		userRepository: &mockUserRepository{
+			bookRealms: []model.BookRealmEntity{{Model: gorm.Model{ID: 1}, OwnerID: "owner"}},
+			roleAssignments: []model.BookRoleAssignmentEntity{
+				{BookRealmEntityID: 1, ApplicationUserEntityID: "viewer", Role: types.BookRoleViewer},
+				{BookRealmEntityID: 1, ApplicationUserEntityID: "bookkeeper", Role: types.BookRoleBookkeeper},
+				{BookRealmEntityID: 1, ApplicationUserEntityID: "accountManager", Role: types.BookRoleAccountManager},
+				{BookRealmEntityID: 1, ApplicationUserEntityID: "approver", Role: types.BookRoleApprover},
+				{BookRealmEntityID: 1, ApplicationUserEntityID: "admin", Role: types.BookRoleAdmin},
+			},
+		},
+	}
+	tests := []struct {
+		name       string
+		userID     string
+		bookID     string
+		permission types.Permission
+		want       bool
+		wantErr    bool
+	}{
+		{"owner may administer", "owner", "1", types.PermissionAdminister, true, false},
+		{"viewer may read", "viewer", "1", types.PermissionRead, true, false},
+		{"viewer may not book", "viewer", "1", types.PermissionBook, false, false},
+		{"bookkeeper may book", "bookkeeper", "1", types.PermissionBook, true, false},
+		{"bookkeeper may not manage accounts", "bookkeeper", "1", types.PermissionManageAccounts, false, false},
+		{"account manager may manage accounts", "accountManager", "1", types.PermissionManageAccounts, true, false},
+		{"account manager may not administer", "accountManager", "1", types.PermissionAdminister, false, false},
+		{"account manager may not approve", "accountManager", "1", types.PermissionApprove, false, false},
+		{"approver may approve", "approver", "1", types.PermissionApprove, true, false},
+		{"approver may not book", "approver", "1", types.PermissionBook, false, false},
+		{"admin may approve", "admin", "1", types.PermissionApprove, true, false},
+		{"admin may administer", "admin", "1", types.PermissionAdminister, true, false},
+		{"user without role may not read", "stranger", "1", types.PermissionRead, false, false},
+		{"unknown book", "owner", "2", types.PermissionRead, false, true},
+		{"invalid book id", "owner", "abc", types.PermissionRead, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.HasPermission(tt.userID, tt.bookID, tt.permission)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HasPermission() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("HasPermission() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applicationUserServiceImpl_ProvisionUser(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      model.ApplicationUserDTO
+		wantErr   bool
+		wantEMail string
+		wantFirst string
+	}{
+		{"new user", model.ApplicationUserDTO{UserID: "new", UserName: "new", EMail: "new@example.com"}, false, "new@example.com", ""},
+		{"new user without email", model.ApplicationUserDTO{UserID: "new", UserName: "new"}, true, "", ""},
+		{"without sub", model.ApplicationUserDTO{UserName: "anna", EMail: "anna@example.com"}, true, "", ""},
+		{"unchanged user", model.ApplicationUserDTO{UserID: "anna", UserName: "anna", FirstName: "Anna", EMail: "anna@example.com"}, false, "anna@example.com", "Anna"},
+		{"renamed user", model.ApplicationUserDTO{UserID: "anna", UserName: "anna", FirstName: "Anne", EMail: "anne@example.com"}, false, "anne@example.com", "Anne"},
+		{"token without email keeps email", model.ApplicationUserDTO{UserID: "anna", UserName: "anna", FirstName: "Anna"}, false, "anna@example.com", "Anna"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &mockUserRepository{users: []model.ApplicationUserEntity{
+				{ID: "anna", UserName: "anna", FirstName: "Anna", EMail: "anna@example.com"},
+			}}
+			s := &applicationUserServiceImpl{userRepository: repository}
+			_, err := s.ProvisionUser(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ProvisionUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			stored, _ := repository.FindApplicationUserByID(tt.user.UserID)
+			if stored.EMail != tt.wantEMail || stored.FirstName != tt.wantFirst {
+				t.Errorf("ProvisionUser() stored = %v, want email %v and first name %v", stored, tt.wantEMail, tt.wantFirst)
+			}
+		})
+	}
+}
+
+func Test_applicationUserServiceImpl_SyncUsers(t *testing.T) {
+	anna := model.ApplicationUserDTO{UserID: "anna", UserName: "anna", EMail: "anna@example.com"}
+	tests := []struct {
+		name    string
+		users   []model.ApplicationUserDTO
+		wantErr bool
+	}{
+		{"valid snapshot", []model.ApplicationUserDTO{anna, {UserID: "ben", UserName: "ben", EMail: "ben@example.com"}}, false},
+		{"empty snapshot", []model.ApplicationUserDTO{}, true},
+		{"user without email", []model.ApplicationUserDTO{{UserID: "ben", UserName: "ben"}}, true},
+		{"duplicate id", []model.ApplicationUserDTO{anna, {UserID: "anna", UserName: "anne", EMail: "anne@example.com"}}, true},
+		{"duplicate email", []model.ApplicationUserDTO{anna, {UserID: "ben", UserName: "ben", EMail: "anna@example.com"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &mockUserRepository{}
+			s := &applicationUserServiceImpl{userRepository: repository}
+			_, err := s.SyncUsers(tt.users, 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SyncUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && len(repository.users) != len(tt.users) {
+				t.Errorf("SyncUsers() stored %d users, want %d", len(repository.users), len(tt.users))
+			}
+		})
+	}
+}
+
+func Test_applicationUserServiceImpl_PurgeOrphanedBooks(t *testing.T) {
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	repository := &mockUserRepository{}
+	s := &applicationUserServiceImpl{userRepository: repository, deletionGracePeriod: 30 * 24 * time.Hour}
+
+	if _, err := s.PurgeOrphanedBooks(now); err != nil {
+		t.Fatalf("PurgeOrphanedBooks() error = %v", err)
+	}
+	if want := now.AddDate(0, 0, -30); !repository.purgedBefore.Equal(want) {
+		t.Errorf("PurgeOrphanedBooks() purged before %v, want %v", repository.purgedBefore, want)
+	}
+}
+
+func Test_readDeletionGracePeriod(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"default", "", 30 * 24 * time.Hour},
+		{"configured", "7", 7 * 24 * time.Hour},
+		{"immediately", "0", 0},
+		{"invalid", "-1", 30 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("USER_DELETION_GRACE_DAYS", tt.value)
+			if got := readDeletionGracePeriod(); got != tt.want {
+				t.Errorf("readDeletionGracePeriod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/toky03/toky-finance-accounting-service/bookingutils"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+const (
+	WebhookEventHeader     = "X-Toky-Event"
+	WebhookDeliveryHeader  = "X-Toky-Delivery"
+	WebhookSignatureHeader = "X-Toky-Signature"
+
+	maxWebhookAttempts    = 8
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = 6 * time.Hour
+	webhookDeliveryBatch  = 50
+	webhookDeliveryLog    = 100
+	webhookRequestTimeout = 10 * time.Second
+)
+
+type webhookRepository interface {
+	FindWebhooksByBookId(bookID uint) ([]model.WebhookEntity, model.TokyError)
+	FindWebhookByID(webhookID uint) (model.WebhookEntity, model.TokyError)
+	PersistWebhook(webhook *model.WebhookEntity) model.TokyError
+	DeleteWebhook(bookID, webhookID uint) model.TokyError
+	PersistWebhookDeliveries(deliveries []model.WebhookDeliveryEntity) model.TokyError
+	FindDueWebhookDeliveries(now time.Time, limit int) ([]model.WebhookDeliveryEntity, model.TokyError)
+	UpdateWebhookDelivery(delivery *model.WebhookDeliveryEntity) model.TokyError
+	FindWebhookDeliveriesByBookId(bookID uint, limit int) ([]model.WebhookDeliveryEntity, model.TokyError)
+}
+
+type webhookServiceImpl struct {
+	webhookRepository   webhookRepository
+	client              *http.Client
+	allowPrivateTargets bool
+}
+
+func CreateWebhookService(repository webhookRepository) *webhookServiceImpl {
+	allowPrivateTargets := os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") == "true"
+	return &webhookServiceImpl{
+		webhookRepository:   repository,
+		client:              createWebhookClient(allowPrivateTargets),
+		allowPrivateTargets: allowPrivateTargets,
+	}
+}
+
+// createWebhookClient does not follow redirects and, unless private targets are allowed, refuses to connect to
+// loopback, private and link-local addresses. The address is checked when connecting, after the name was resolved,
+// so a host name resolving to another address than at the creation of the webhook is caught as well.
+func createWebhookClient(allowPrivateTargets bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookRequestTimeout}
+	if !allowPrivateTargets {
+		dialer.Control = func(network, address string, conn syscall.RawConn) error {
+			host, _, splitErr := net.SplitHostPort(address)
+			if splitErr != nil {
+				return splitErr
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateAddress(ip) {
+				return fmt.Errorf("webhook target %s is not a public address", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: webhookRequestTimeout,
+		// a proxy would resolve the target itself, the webhooks connect directly so that the dialer sees it
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookRequestTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+func (s *webhookServiceImpl) ReadWebhooks(bookID string) ([]model.WebhookDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	webhooks, err := s.webhookRepository.FindWebhooksByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	webhookDTOs := make([]model.WebhookDTO, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		webhookDTOs = append(webhookDTOs, webhook.ToWebhookDTO())
+	}
+	return webhookDTOs, nil
+}
+
+// CreateWebhook subscribes the url to the events of the book. The generated secret is returned only once
+// and signs every payload posted to the url.
+func (s *webhookServiceImpl) CreateWebhook(bookID string, webhook model.WebhookDTO) (model.WebhookDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return model.WebhookDTO{}, err
+	}
+	webhookUrl := strings.TrimSpace(webhook.Url)
+	parsedUrl, parseErr := url.Parse(webhookUrl)
+	if parseErr != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return model.WebhookDTO{}, createValidationError(fmt.Sprintf("Webhook Url %s must be an absolute http or https url", webhookUrl))
+	}
+	if !s.allowPrivateTargets && isPrivateHost(parsedUrl.Hostname()) {
+		return model.WebhookDTO{}, createValidationError(fmt.Sprintf("Webhook Url %s must not point to a private address", webhookUrl))
+	}
+	events := make([]string, 0, len(webhook.Events))
+	for _, event := range webhook.Events {
+		if !isWebhookEvent(event) {
+			return model.WebhookDTO{}, createValidationError(fmt.Sprintf("Unknown Webhook Event %s", event))
+		}
+		events = append(events, string(event))
+	}
+	secret, secretErr := createWebhookSecret()
+	if secretErr != nil {
+		return model.WebhookDTO{}, model.CreateTechnicalError("Could not create Webhook Secret", secretErr)
+	}
+	webhookEntity := model.WebhookEntity{
+		BookRealmEntityID: bookIDUint,
+		Url:               webhookUrl,
+		Secret:            secret,
+		Events:            strings.Join(events, ","),
+	}
+	if err := s.webhookRepository.PersistWebhook(&webhookEntity); model.IsExisting(err) {
+		return model.WebhookDTO{}, err
+	}
+	created := webhookEntity.ToWebhookDTO()
+	created.Secret = secret
+	return created, nil
+}
+
+func (s *webhookServiceImpl) DeleteWebhook(bookID, webhookID string) model.TokyError {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	webhookIDUint, convErr := bookingutils.StringToUint(webhookID)
+	if convErr != nil {
+		return model.CreateBusinessError(fmt.Sprintf("Could not read Webhook Id: %s", webhookID), convErr)
+	}
+	webhook, err := s.webhookRepository.FindWebhookByID(webhookIDUint)
+	if model.IsExisting(err) {
+		return err
+	}
+	if webhook.BookRealmEntityID != bookIDUint {
+		return model.CreateBusinessErrorNotFound(fmt.Sprintf("No Webhook with Id %s found", webhookID), errors.New("webhook belongs to another book"))
+	}
+	return s.webhookRepository.DeleteWebhook(bookIDUint, webhookIDUint)
+}
+
+// ReadWebhookDeliveries returns the delivery log of the webhooks of the book, newest first
+func (s *webhookServiceImpl) ReadWebhookDeliveries(bookID string) ([]model.WebhookDeliveryDTO, model.TokyError) {
+	bookIDUint, err := readBookIDFromString(bookID)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	deliveries, err := s.webhookRepository.FindWebhookDeliveriesByBookId(bookIDUint, webhookDeliveryLog)
+	if model.IsExisting(err) {
+		return nil, err
+	}
+	deliveryDTOs := make([]model.WebhookDeliveryDTO, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		deliveryDTOs = append(deliveryDTOs, delivery.ToWebhookDeliveryDTO())
+	}
+	return deliveryDTOs, nil
+}
+
+// Name identifies the webhooks as sink of the outbox dispatcher
+func (s *webhookServiceImpl) Name() string {
+	return "webhooks"
+}
+
+// Deliver queues a delivery of the domain event for every webhook of the book subscribed to it.
+// Events webhooks can not subscribe to are skipped.
+func (s *webhookServiceImpl) Deliver(event model.DomainEventDTO) model.TokyError {
+	webhookEvent := types.WebhookEvent(event.Type)
+	if !isWebhookEvent(webhookEvent) {
+		return nil
+	}
+	bookIDUint, err := readBookIDFromString(event.BookID)
+	if model.IsExisting(err) {
+		return err
+	}
+	webhooks, err := s.webhookRepository.FindWebhooksByBookId(bookIDUint)
+	if model.IsExisting(err) {
+		return err
+	}
+	subscribed := make([]model.WebhookEntity, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if isSubscribed(webhook, webhookEvent) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+	payload, jsonErr := json.Marshal(model.WebhookPayloadDTO{
+		EventID:    event.EventID,
+		Event:      webhookEvent,
+		BookID:     event.BookID,
+		OccurredAt: event.OccurredAt,
+		Data:       event.Data,
+	})
+	if jsonErr != nil {
+		return model.CreateTechnicalError(fmt.Sprintf("Could not serialize Event %s", event.EventID), jsonErr)
+	}
+	now := time.Now().UTC()
+	deliveries := make([]model.WebhookDeliveryEntity, 0, len(subscribed))
+	for _, webhook := range subscribed {
+		deliveries = append(deliveries, model.WebhookDeliveryEntity{
+			WebhookEntityID:   webhook.ID,
+			BookRealmEntityID: bookIDUint,
+			Event:             webhookEvent,
+			Payload:           string(payload),
+			Status:            types.WebhookDeliveryPending,
+			NextAttemptAt:     now,
+		})
+	}
+	return s.webhookRepository.PersistWebhookDeliveries(deliveries)
+}
+
+// DeliverDueWebhooks posts the pending deliveries that are due at the given time and returns the number delivered.
+// A failed attempt is retried with exponential backoff until the maximum number of attempts is reached.
+func (s *webhookServiceImpl) DeliverDueWebhooks(now time.Time) (int, model.TokyError) {
+	deliveries, err := s.webhookRepository.FindDueWebhookDeliveries(now, webhookDeliveryBatch)
+	if model.IsExisting(err) {
+		return 0, err
+	}
+	delivered := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		webhook, err := s.webhookRepository.FindWebhookByID(delivery.WebhookEntityID)
+		if model.IsExistingNotFoundError(err) {
+			delivery.Status = types.WebhookDeliveryFailed
+			delivery.LastError = "Webhook was deleted"
+		} else if model.IsExisting(err) {
+			return delivered, err
+		} else {
+			s.deliver(webhook, delivery, now)
+		}
+		if err := s.webhookRepository.UpdateWebhookDelivery(delivery); model.IsExisting(err) {
+			return delivered, err
+		}
+		if delivery.Status == types.WebhookDeliveryDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+func (s *webhookServiceImpl) deliver(webhook model.WebhookEntity, delivery *model.WebhookDeliveryEntity, now time.Time) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	request, requestErr := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewBufferString(delivery.Payload))
+	if requestErr != nil {
+		delivery.Status = types.WebhookDeliveryFailed
+		delivery.LastError = requestErr.Error()
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookEventHeader, string(delivery.Event))
+	request.Header.Set(WebhookDeliveryHeader, bookingutils.UintToString(delivery.ID))
+	request.Header.Set(WebhookSignatureHeader, signWebhookPayload(webhook.Secret, []byte(delivery.Payload)))
+	response, postErr := s.client.Do(request)
+	if postErr != nil {
+		delivery.LastError = postErr.Error()
+	} else {
+		response.Body.Close()
+		delivery.LastStatusCode = response.StatusCode
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			delivery.Status = types.WebhookDeliveryDelivered
+			return
+		}
+		delivery.LastError = response.Status
+	}
+	if delivery.Attempts >= maxWebhookAttempts {
+		delivery.Status = types.WebhookDeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+}
+
+// RunWebhookDeliveries delivers due webhooks immediately and then periodically in the given interval
+func (s *webhookServiceImpl) RunWebhookDeliveries(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		delivered, err := s.DeliverDueWebhooks(time.Now().UTC())
+		if model.IsExisting(err) {
+			log.Printf("Could not deliver webhooks: %s", err.ErrorMessage())
+		} else if delivered > 0 {
+			log.Printf("Delivered %d webhooks", delivered)
+		}
+		<-ticker.C
+	}
+}
+
+// webhookRetryDelay doubles the delay with every failed attempt up to the maximum delay
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		return webhookRetryMaxDelay
+	}
+	return delay
+}
+
+// signWebhookPayload returns the hex encoded HMAC-SHA256 of the payload which receivers compare to verify the sender
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// isPrivateHost rejects private addresses and localhost early, host names are checked again when connecting
+func isPrivateHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return isPrivateAddress(ip)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+func isWebhookEvent(event types.WebhookEvent) bool {
+	for _, known := range types.WebhookEvents {
+		if known == event {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubscribed reports whether the webhook receives the event, a webhook without events receives all of them
+func isSubscribed(webhook model.WebhookEntity, event types.WebhookEvent) bool {
+	events := webhook.ReadEvents()
+	if len(events) == 0 {
+		return true
+	}
+	for _, subscribed := range events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+func createWebhookSecret() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/toky03/toky-finance-accounting-service/model"
+	"github.com/toky03/toky-finance-accounting-service/types"
+)
+
+type mockWebhookRepository struct {
+	webhooks   []model.WebhookEntity
+	deliveries []model.WebhookDeliveryEntity
+}
+
+func (mwr *mockWebhookRepository) FindWebhooksByBookId(bookID uint) ([]model.WebhookEntity, model.TokyError) {
+	webhooks := []model.WebhookEntity{}
+	for _, webhook := range mwr.webhooks {
+		if webhook.BookRealmEntityID == bookID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (mwr *mockWebhookRepository) FindWebhookByID(webhookID uint) (model.WebhookEntity, model.TokyError) {
+	for _, webhook := range mwr.webhooks {
+		if webhook.ID == webhookID {
+			return webhook, nil
+		}
+	}
+	return model.WebhookEntity{}, model.CreateBusinessErrorNotFound("Not found webhook with id", errors.New("No Webhook present"))
+}
+
+func (mwr *mockWebhookRepository) PersistWebhook(webhook *model.WebhookEntity) model.TokyError {
+	webhook.ID = uint(len(mwr.webhooks) + 1)
+	mwr.webhooks = append(mwr.webhooks, *webhook)
+	return nil
+}
+
+func (mwr *mockWebhookRepository) DeleteWebhook(bookID, webhookID uint) model.TokyError {
+	webhooks := []model.WebhookEntity{}
+	for _, webhook := range mwr.webhooks {
+		if webhook.ID != webhookID || webhook.BookRealmEntityID != bookID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	mwr.webhooks = webhooks
+	return nil
+}
+
+func (mwr *mockWebhookRepository) PersistWebhookDeliveries(deliveries []model.WebhookDeliveryEntity) model.TokyError {
+	for _, delivery := range deliveries {
+		delivery.ID = uint(len(mwr.deliveries) + 1)
+		mwr.deliveries = append(mwr.deliveries, delivery)
+	}
+	return nil
+}
+
+func (mwr *mockWebhookRepository) FindDueWebhookDeliveries(now time.Time, limit int) ([]model.WebhookDeliveryEntity, model.TokyError) {
+	deliveries := []model.WebhookDeliveryEntity{}
+	for _, delivery := range mwr.deliveries {
+		if delivery.Status == types.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) && len(deliveries) < limit {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (mwr *mockWebhookRepository) UpdateWebhookDelivery(delivery *model.WebhookDeliveryEntity) model.TokyError {
+	for i := range mwr.deliveries {
+		if mwr.deliveries[i].ID == delivery.ID {
+			mwr.deliveries[i] = *delivery
+		}
+	}
+	return nil
+}
+
+func (mwr *mockWebhookRepository) FindWebhookDeliveriesByBookId(bookID uint, limit int) ([]model.WebhookDeliveryEntity, model.TokyError) {
+	deliveries := []model.WebhookDeliveryEntity{}
+	for i := len(mwr.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if mwr.deliveries[i].BookRealmEntityID == bookID {
+			deliveries = append(deliveries, mwr.deliveries[i])
+		}
+	}
+	return deliveries, nil
+}
+
+// createWebhookReceiver starts a local server answering with the given status and recording every request
+func createWebhookReceiver(t *testing.T, status int) (*httptest.Server, *[]*http.Request, *[][]byte) {
+	requests := []*http.Request{}
+	bodies := [][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests, &bodies
+}
+
+func createWebhook(t *testing.T, service *webhookServiceImpl, url string, events ...types.WebhookEvent) model.WebhookDTO {
+	webhook, err := service.CreateWebhook("1", model.WebhookDTO{Url: url, Events: events})
+	if model.IsExisting(err) {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	return webhook
+}
+
+func createDomainEvent(t *testing.T, eventID, bookID string, eventType types.DomainEventType, data interface{}) model.DomainEventDTO {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("could not serialize event data: %v", err)
+	}
+	return model.DomainEventDTO{EventID: eventID, Type: eventType, BookID: bookID, OccurredAt: time.Now().UTC().Format(time.RFC3339), Data: payload}
+}
+
+func Test_webhookServiceImpl_DeliversSignedPayload(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_TARGETS", "true")
+	server, requests, bodies := createWebhookReceiver(t, http.StatusOK)
+	repository := &mockWebhookRepository{}
+	service := CreateWebhookService(repository)
+	webhook := createWebhook(t, service, server.URL, types.WebhookEventBookingCreated)
+	if webhook.Secret == "" {
+		t.Fatalf("expected the secret to be returned on creation")
+	}
+
+	for _, event := range []model.DomainEventDTO{
+		createDomainEvent(t, "1", "1", types.DomainEventBookingCreated, model.BookingDTO{BookingID: "7", Ammount: "50"}),
+		createDomainEvent(t, "2", "1", types.DomainEventAccountCreated, model.AccountOptionDTO{AccountName: "Bank"}),
+		createDomainEvent(t, "3", "2", types.DomainEventBookingCreated, model.BookingDTO{BookingID: "8"}),
+		createDomainEvent(t, "4", "1", types.DomainEventYearClosed, model.BookClonedDTO{BookID: "1"}),
+	} {
+		if err := service.Deliver(event); model.IsExisting(err) {
+			t.Fatalf("Deliver() error = %v", err)
+		}
+	}
+
+	delivered, err := service.DeliverDueWebhooks(time.Now().UTC())
+	if model.IsExisting(err) {
+		t.Fatalf("DeliverDueWebhooks() error = %v", err)
+	}
+	if delivered != 1 || len(*requests) != 1 {
+		t.Fatalf("expected exactly the subscribed event to be delivered, got %d deliveries and %d requests", delivered, len(*requests))
+	}
+	request, body := (*requests)[0], (*bodies)[0]
+	if signature := request.Header.Get(WebhookSignatureHeader); signature != signWebhookPayload(webhook.Secret, body) {
+		t.Errorf("signature %s does not match the payload", signature)
+	}
+	if event := request.Header.Get(WebhookEventHeader); event != string(types.WebhookEventBookingCreated) {
+		t.Errorf("event header = %s, want %s", event, types.WebhookEventBookingCreated)
+	}
+	var payload struct {
+		model.WebhookPayloadDTO
+		Data model.BookingDTO `json:"data"`
+	}
+	if jsonErr := json.Unmarshal(body, &payload); jsonErr != nil {
+		t.Fatalf("could not read payload: %v", jsonErr)
+	}
+	if payload.BookID != "1" || payload.EventID != "1" || payload.Data.BookingID != "7" {
+		t.Errorf("unexpected payload %s", body)
+	}
+	if repository.deliveries[0].Status != types.WebhookDeliveryDelivered || repository.deliveries[0].LastStatusCode != http.StatusOK {
+		t.Errorf("delivery = %v, want delivered with status code 200", repository.deliveries[0])
+	}
+}
+
+func Test_webhookServiceImpl_RetriesWithBackoffUntilFailed(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_TARGETS", "true")
+	server, requests, _ := createWebhookReceiver(t, http.StatusInternalServerError)
+	repository := &mockWebhookRepository{}
+	service := CreateWebhookService(repository)
+	createWebhook(t, service, server.URL)
+	if err := service.Deliver(createDomainEvent(t, "1", "1", types.DomainEventBookingDeleted, model.BookingDTO{BookingID: "7"})); model.IsExisting(err) {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	now := time.Now().UTC()
+	if _, err := service.DeliverDueWebhooks(now); model.IsExisting(err) {
+		t.Fatalf("DeliverDueWebhooks() error = %v", err)
+	}
+	delivery := repository.deliveries[0]
+	if delivery.Status != types.WebhookDeliveryPending || delivery.Attempts != 1 || delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("delivery = %v, want pending after the first failed attempt", delivery)
+	}
+	if !delivery.NextAttemptAt.Equal(now.Add(webhookRetryBaseDelay)) {
+		t.Errorf("next attempt = %v, want %v", delivery.NextAttemptAt, now.Add(webhookRetryBaseDelay))
+	}
+
+	// the delivery is not due before its next attempt
+	if _, err := service.DeliverDueWebhooks(now.Add(time.Second)); model.IsExisting(err) {
+		t.Fatalf("DeliverDueWebhooks() error = %v", err)
+	}
+	if len(*requests) != 1 {
+		t.Fatalf("expected no retry before the backoff elapsed, got %d requests", len(*requests))
+	}
+
+	for attempt := 2; attempt <= maxWebhookAttempts; attempt++ {
+		now = repository.deliveries[0].NextAttemptAt
+		if _, err := service.DeliverDueWebhooks(now); model.IsExisting(err) {
+			t.Fatalf("DeliverDueWebhooks() error = %v", err)
+		}
+	}
+	delivery = repository.deliveries[0]
+	if delivery.Status != types.WebhookDeliveryFailed || delivery.Attempts != maxWebhookAttempts || len(*requests) != maxWebhookAttempts {
+		t.Errorf("delivery = %v with %d requests, want failed after %d attempts", delivery, len(*requests), maxWebhookAttempts)
+	}
+}
+
+func Test_webhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 5, want: 8 * time.Minute},
+		{attempts: 20, want: webhookRetryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func Test_webhookServiceImpl_CreateWebhook_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook model.WebhookDTO
+	}{
+		{name: "relative url", webhook: model.WebhookDTO{Url: "/hook"}},
+		{name: "unsupported scheme", webhook: model.WebhookDTO{Url: "ftp://example.com/hook"}},
+		{name: "unknown event", webhook: model.WebhookDTO{Url: "https://example.com/hook", Events: []types.WebhookEvent{"booking.archived"}}},
+		{name: "loopback address", webhook: model.WebhookDTO{Url: "http://127.0.0.1:8080/hook"}},
+		{name: "localhost", webhook: model.WebhookDTO{Url: "http://localhost/hook"}},
+		{name: "private address", webhook: model.WebhookDTO{Url: "http://10.0.0.7/hook"}},
+		{name: "link-local address", webhook: model.WebhookDTO{Url: "http://169.254.169.254/latest/meta-data"}},
+		{name: "ipv6 loopback address", webhook: model.WebhookDTO{Url: "http://[::1]/hook"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CreateWebhookService(&mockWebhookRepository{}).CreateWebhook("1", tt.webhook)
+			if !model.IsExisting(err) {
+				t.Errorf("expected a validation error")
+			}
+		})
+	}
+}
+
+func Test_webhookServiceImpl_RefusesPrivateTargetWhenConnecting(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_TARGETS", "")
+	server, requests, _ := createWebhookReceiver(t, http.StatusOK)
+	// a host name which resolved to a public address at the creation of the webhook can point anywhere later
+	repository := &mockWebhookRepository{webhooks: []model.WebhookEntity{{Model: gorm.Model{ID: 1}, BookRealmEntityID: 1, Url: server.URL}}}
+	service := CreateWebhookService(repository)
+	if err := service.Deliver(createDomainEvent(t, "1", "1", types.DomainEventBookingCreated, model.BookingDTO{BookingID: "7"})); model.IsExisting(err) {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	if _, err := service.DeliverDueWebhooks(time.Now().UTC()); model.IsExisting(err) {
+		t.Fatalf("DeliverDueWebhooks() error = %v", err)
+	}
+	if delivery := repository.deliveries[0]; len(*requests) != 0 || delivery.Status != types.WebhookDeliveryPending || delivery.LastError == "" {
+		t.Errorf("delivery = %v with %d requests, want the connection to the loopback address refused", delivery, len(*requests))
+	}
+}
+
+func Test_webhookServiceImpl_DoesNotFollowRedirects(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_TARGETS", "true")
+	target, targetRequests, _ := createWebhookReceiver(t, http.StatusOK)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+	repository := &mockWebhookRepository{}
+	service := CreateWebhookService(repository)
+	createWebhook(t, service, redirect.URL)
+	if err := service.Deliver(createDomainEvent(t, "1", "1", types.DomainEventBookingCreated, model.BookingDTO{BookingID: "7"})); model.IsExisting(err) {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	if _, err := service.DeliverDueWebhooks(time.Now().UTC()); model.IsExisting(err) {
+		t.Fatalf("DeliverDueWebhooks() error = %v", err)
+	}
+	if delivery := repository.deliveries[0]; len(*targetRequests) != 0 || delivery.LastStatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("delivery = %v with %d redirected requests, want the redirect answered as failure", delivery, len(*targetRequests))
+	}
+}
+
+func Test_webhookServiceImpl_DeleteWebhookOfOtherBook(t *testing.T) {
+	repository := &mockWebhookRepository{webhooks: []model.WebhookEntity{{Model: gorm.Model{ID: 1}, BookRealmEntityID: 2}}}
+	err := CreateWebhookService(repository).DeleteWebhook("1", "1")
+	if !model.IsExistingNotFoundError(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if len(repository.webhooks) != 1 {
+		t.Errorf("webhook of the other book must not be deleted")
+	}
+}
+
+func Test_accountingServiceImpl_WritesBookingEventsOfBothBooks(t *testing.T) {
+	repository := createTransferRepository()
+	createTransfer(t, CreateAccountingService(repository))
+
+	if len(repository.events) != 2 {
+		t.Fatalf("expected a booking.created event for both books, got %d events", len(repository.events))
+	}
+	for i, wantBookID := range []uint{1, 2} {
+		event := repository.events[i]
+		bookID, data := event.Read()
+		booking, ok := data.(model.BookingDTO)
+		if event.Type != types.DomainEventBookingCreated || bookID != wantBookID || !ok || booking.BookingID == "" {
+			t.Errorf("event %d = %s of book %d with %v, want booking.created of book %d", i, event.Type, bookID, data, wantBookID)
+		}
+	}
+}
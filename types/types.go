@@ -31,3 +31,115 @@ const (
 	SaldierungColumnSoll  SaldierungColumnType = "soll"
 	SaldierungColumnHaben SaldierungColumnType = "haben"
 )
+
+type PartnerType string
+
+const (
+	PartnerTypeCustomer PartnerType = "customer"
+	PartnerTypeSupplier PartnerType = "supplier"
+)
+
+type OpenItemStatus string
+
+const (
+	OpenItemStatusOpen          OpenItemStatus = "open"
+	OpenItemStatusPartiallyPaid OpenItemStatus = "partiallyPaid"
+	OpenItemStatusPaid          OpenItemStatus = "paid"
+)
+
+type DepreciationMethod string
+
+const (
+	DepreciationMethodLinear           DepreciationMethod = "linear"
+	DepreciationMethodDecliningBalance DepreciationMethod = "decliningBalance"
+)
+
+type BookingType string
+
+const (
+	BookingTypeStandard BookingType = "standard"
+	BookingTypeAccrual  BookingType = "accrual"
+	BookingTypeReversal BookingType = "reversal"
+)
+
+type BookRole string
+
+const (
+	BookRoleViewer         BookRole = "viewer"
+	BookRoleBookkeeper     BookRole = "bookkeeper"
+	BookRoleAccountManager BookRole = "accountManager"
+	BookRoleApprover       BookRole = "approver"
+	BookRoleAdmin          BookRole = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionRead           Permission = "read"
+	PermissionBook           Permission = "book"
+	PermissionManageAccounts Permission = "manageAccounts"
+	PermissionApprove        Permission = "approve"
+	PermissionAdminister     Permission = "administer"
+)
+
+type TokenScope string
+
+const (
+	TokenScopeRead  TokenScope = "read"
+	TokenScopeWrite TokenScope = "write"
+)
+
+// DomainEventType names a change written to the outbox. The events a webhook can subscribe to use the same names.
+type DomainEventType string
+
+const (
+	DomainEventBookCreated          DomainEventType = "book.created"
+	DomainEventBookUpdated          DomainEventType = "book.updated"
+	DomainEventBookArchived         DomainEventType = "book.archived"
+	DomainEventBookRestored         DomainEventType = "book.restored"
+	DomainEventBookPurged           DomainEventType = "book.purged"
+	DomainEventBookCloned           DomainEventType = "book.cloned"
+	DomainEventYearClosed           DomainEventType = "year.closed"
+	DomainEventAccountCreated       DomainEventType = "account.created"
+	DomainEventAccountUpdated       DomainEventType = "account.updated"
+	DomainEventAccountDeleted       DomainEventType = "account.deleted"
+	DomainEventBookingCreated       DomainEventType = "booking.created"
+	DomainEventBookingUpdated       DomainEventType = "booking.updated"
+	DomainEventBookingDeleted       DomainEventType = "booking.deleted"
+	DomainEventClearingAccountSaved DomainEventType = "clearingAccount.saved"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookEventBookingCreated WebhookEvent = "booking.created"
+	WebhookEventBookingUpdated WebhookEvent = "booking.updated"
+	WebhookEventBookingDeleted WebhookEvent = "booking.deleted"
+	WebhookEventAccountCreated WebhookEvent = "account.created"
+	WebhookEventAccountUpdated WebhookEvent = "account.updated"
+	WebhookEventAccountDeleted WebhookEvent = "account.deleted"
+	WebhookEventYearClosed     WebhookEvent = "year.closed"
+)
+
+// WebhookEvents lists all events a webhook can subscribe to
+var WebhookEvents = []WebhookEvent{
+	WebhookEventBookingCreated, WebhookEventBookingUpdated, WebhookEventBookingDeleted,
+	WebhookEventAccountCreated, WebhookEventAccountUpdated, WebhookEventAccountDeleted,
+	WebhookEventYearClosed,
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+type CloneMode string
+
+const (
+	CloneModeChartOfAccounts CloneMode = "chartOfAccounts"
+	CloneModeClosingBalances CloneMode = "closingBalances"
+	CloneModeFull            CloneMode = "full"
+)